@@ -0,0 +1,262 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/stillmatic/pummel"
+	"github.com/stillmatic/pummel/pkg/inspect"
+)
+
+type InspectCmd struct {
+	PMMLPath string `arg:"" help:"Path to PMML file" type:"existingfile"`
+	JSON     bool   `help:"Print the summary as JSON."`
+}
+
+func (c *InspectCmd) Run() error {
+	m, err := pummel.LoadFile(c.PMMLPath)
+	if err != nil {
+		return err
+	}
+	s := inspect.Inspect(m)
+	if c.JSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(s)
+	}
+	return printSummary(os.Stdout, s)
+}
+
+func printSummary(w io.Writer, s *inspect.Summary) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	row := func(cells ...interface{}) {
+		strs := make([]string, len(cells))
+		for i, c := range cells {
+			strs[i] = fmt.Sprint(c)
+		}
+		fmt.Fprintln(tw, strings.Join(strs, "\t"))
+	}
+	field := func(label, value string) {
+		if value != "" {
+			row(label+":", value)
+		}
+	}
+
+	field("PMML version", s.PMMLVersion)
+	field("Application", strings.TrimSpace(s.Application+" "+s.ApplicationVersion))
+	field("Description", s.Description)
+	field("Copyright", s.Copyright)
+	field("Model version", s.ModelVersion)
+	field("Timestamp", s.Timestamp)
+	field("Model", s.Model.Element)
+	field("Model name", s.ModelName)
+	field("Function", s.Model.FunctionName)
+
+	fields := func(title string, fs []*inspect.Field, output bool) {
+		if len(fs) == 0 {
+			return
+		}
+		row()
+		row(title)
+		if output {
+			row("  NAME", "FEATURE", "OPTYPE", "DATATYPE", "VALUE")
+		} else {
+			row("  NAME", "OPTYPE", "DATATYPE", "VALUES")
+		}
+		for _, f := range fs {
+			if output {
+				row("  "+f.Name, f.Feature, f.OpType, f.DataType, f.Value)
+			} else {
+				row("  "+f.Name, f.OpType, f.DataType, strings.Join(f.Values, ", "))
+			}
+		}
+	}
+	fields("Inputs", s.Inputs, false)
+	fields("Targets", s.Targets, false)
+	fields("Outputs", s.Outputs, true)
+
+	st := s.Model
+	if st.Segments > 0 {
+		row()
+		field("Segmentation", st.MultipleModelMethod)
+		row("Segments:", st.Segments)
+		elements := make([]string, 0, len(st.SegmentElements))
+		for element := range st.SegmentElements {
+			elements = append(elements, element)
+		}
+		sort.Strings(elements)
+		for _, element := range elements {
+			row("  "+element+":", st.SegmentElements[element])
+		}
+	}
+	if st.Trees > 0 {
+		row()
+		row("Trees:", st.Trees)
+		row("Nodes:", st.Nodes)
+		row("Leaves:", st.Leaves)
+		row("Max depth:", st.Depth)
+		if len(st.SplitFeatures) > 0 {
+			row()
+			row("Split features")
+			row("  NAME", "SPLITS")
+			for _, sf := range st.SplitFeatures {
+				row("  "+sf.Name, sf.Splits)
+			}
+		}
+	}
+	for _, rt := range st.RegressionTables {
+		row()
+		title := "Regression table"
+		if rt.Segment != "" {
+			title += " (segment " + rt.Segment + ")"
+		}
+		if rt.TargetCategory != "" {
+			title += " for " + rt.TargetCategory
+		}
+		row(title)
+		row("  TERM", "COEFFICIENT")
+		row("  (intercept)", rt.Intercept)
+		for _, c := range rt.Coefficients {
+			term := c.Name
+			switch c.Type {
+			case "categorical":
+				term = c.Name + "=" + c.Value
+			case "term":
+				term = strings.Join(c.Fields, "*")
+			case "numeric":
+				if c.Exponent != 1 {
+					term = fmt.Sprintf("%s^%v", c.Name, c.Exponent)
+				}
+			}
+			row("  "+term, c.Coefficient)
+		}
+	}
+	if st.ModelType != "" {
+		row()
+		row("Model type:", st.ModelType)
+		if st.Link != "" {
+			row("Link:", st.Link)
+		}
+		row("Coefficients")
+		row("  PARAMETER", "LABEL", "CATEGORY", "BETA")
+		for _, c := range st.PCells {
+			parameter := c.Parameter
+			if c.Segment != "" {
+				parameter = c.Segment + "/" + parameter
+			}
+			row("  "+parameter, c.Label, c.TargetCategory, c.Beta)
+		}
+	}
+	if len(st.Characteristics) > 0 {
+		row()
+		if st.ReasonCodeAlgorithm != "" {
+			row("Reason codes:", st.ReasonCodeAlgorithm)
+		}
+		row("Characteristics")
+		row("  NAME", "ATTRIBUTES", "BASELINE")
+		for _, c := range st.Characteristics {
+			name := c.Name
+			if c.Segment != "" {
+				name = c.Segment + "/" + name
+			}
+			var baseline interface{} = ""
+			if c.BaselineScore != nil {
+				baseline = *c.BaselineScore
+			}
+			row("  "+name, c.Attributes, baseline)
+		}
+	}
+	if len(st.Rules) > 0 {
+		row()
+		row("Rule selection:", st.RuleSelectionMethod)
+		row("Rules")
+		row("  ID", "SCORE", "CONFIDENCE", "WEIGHT")
+		for _, r := range st.Rules {
+			id := r.ID
+			if r.Segment != "" {
+				id = r.Segment + "/" + id
+			}
+			row("  "+id, r.Score, r.Confidence, r.Weight)
+		}
+	}
+	if st.AnomalyAlgorithm != "" {
+		row()
+		algorithm := st.AnomalyAlgorithm
+		if st.SampleDataSize > 0 {
+			algorithm = fmt.Sprintf("%s, with samples of %d records", algorithm, st.SampleDataSize)
+		}
+		row("Anomaly detection:", algorithm)
+	}
+	if st.Forecast != "" {
+		row()
+		forecast := st.Forecast
+		if st.History > 0 {
+			forecast = fmt.Sprintf("%s, from %d values", forecast, st.History)
+		}
+		row("Forecasting:", forecast)
+	}
+	if st.AssociationRules > 0 {
+		row()
+		row("Association rules:", fmt.Sprintf("%d between %d itemsets of %d items", st.AssociationRules, st.Itemsets, st.Items))
+	}
+	if len(st.Clusters) > 0 {
+		row()
+		row("Comparison measure:", st.ComparisonMeasure)
+		row("Clusters")
+		row("  ID", "NAME", "SIZE")
+		for _, c := range st.Clusters {
+			id := c.ID
+			if c.Segment != "" {
+				id = c.Segment + "/" + id
+			}
+			row("  "+id, c.Name, c.Size)
+		}
+	}
+	if st.TrainingInstances > 0 && st.Neighbors > 0 {
+		row()
+		row("Comparison measure:", st.ComparisonMeasure)
+		row("Neighbors:", fmt.Sprintf("%d of %d training instances", st.Neighbors, st.TrainingInstances))
+	} else if st.TrainingInstances > 0 {
+		row()
+		row("Kernel:", st.Kernel)
+		row("Training instances:", st.TrainingInstances)
+	}
+	if st.DiscreteNodes+st.ContinuousNodes > 0 {
+		row()
+		row("Network nodes:", fmt.Sprintf("%d discrete, %d continuous", st.DiscreteNodes, st.ContinuousNodes))
+	}
+	if st.SupportVectorMachines > 0 {
+		row()
+		row("Kernel:", st.Kernel)
+		row("Machines:", st.SupportVectorMachines)
+		row("Support vectors:", st.SupportVectors)
+	}
+	if st.BayesInputs > 0 {
+		row()
+		row("Bayes inputs:", fmt.Sprintf("%d (%d continuous)", st.BayesInputs, st.ContinuousBayesInputs))
+		row("Categories:", st.BayesCategories)
+	}
+	if len(st.NeuralLayers) > 0 {
+		row()
+		row("Neural inputs:", st.NeuralInputs)
+		row("Neural layers")
+		if st.Segments > 0 {
+			row("  SEGMENT", "NEURONS", "ACTIVATION", "NORMALIZATION")
+		} else {
+			row("  NEURONS", "ACTIVATION", "NORMALIZATION")
+		}
+		for _, nl := range st.NeuralLayers {
+			if st.Segments > 0 {
+				row("  "+nl.Segment, nl.Neurons, nl.ActivationFunction, nl.NormalizationMethod)
+			} else {
+				row("  "+fmt.Sprint(nl.Neurons), nl.ActivationFunction, nl.NormalizationMethod)
+			}
+		}
+	}
+	return tw.Flush()
+}
@@ -1,9 +1,19 @@
 package main
 
-// type Predict struct {
-// 	PMMLPath string `help:"Path to PMML file"`
-// }
+import "github.com/alecthomas/kong"
 
-func main() {
+var cli struct {
+	Score    ScoreCmd    `cmd:"" help:"Score a CSV or JSON Lines file with a PMML model."`
+	Validate ValidateCmd `cmd:"" help:"Report the parts of PMML documents which pummel cannot evaluate."`
+	Inspect  InspectCmd  `cmd:"" help:"Summarize the schema and structure of a PMML model."`
+	Verify   VerifyCmd   `cmd:"" help:"Check a model's results against the records of its ModelVerification."`
+}
 
+func main() {
+	ctx := kong.Parse(&cli,
+		kong.Name("pummel-cli"),
+		kong.Description("Evaluate PMML models."),
+		kong.UsageOnError(),
+	)
+	ctx.FatalIfErrorf(ctx.Run())
 }
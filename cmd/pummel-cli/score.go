@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/stillmatic/pummel"
+	"github.com/stillmatic/pummel/pkg/batch"
+)
+
+type ScoreCmd struct {
+	PMMLPath     string `arg:"" help:"Path to PMML file" type:"existingfile"`
+	Input        string `arg:"" optional:"" help:"Path to the input file, reads stdin when omitted or -." default:"-"`
+	Format       string `help:"Input format, detected from the file extension when auto." enum:"auto,csv,jsonl" default:"auto"`
+	OutputFormat string `help:"Output format, defaults to the input format." enum:"auto,csv,jsonl" default:"auto"`
+	Workers      int    `help:"Number of rows scored concurrently, defaults to the number of CPUs." default:"0"`
+}
+
+func (c *ScoreCmd) Run() error {
+	m, err := pummel.LoadFile(c.PMMLPath)
+	if err != nil {
+		return err
+	}
+	in := io.Reader(os.Stdin)
+	if c.Input != "-" {
+		f, err := os.Open(c.Input)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	format := c.Format
+	if format == "auto" {
+		format = detectFormat(c.Input)
+	}
+	outputFormat := c.OutputFormat
+	if outputFormat == "auto" {
+		outputFormat = format
+	}
+
+	var r batch.Reader
+	var inputColumns []string
+	switch format {
+	case "csv":
+		cr, err := batch.NewCSVReader(in)
+		if err != nil {
+			return err
+		}
+		r = cr
+		inputColumns = cr.Columns()
+	case "jsonl":
+		r = batch.NewJSONLReader(in)
+	}
+	var w batch.Writer
+	switch outputFormat {
+	case "csv":
+		w = batch.NewCSVWriter(os.Stdout, inputColumns, batch.OutputColumns(m))
+	case "jsonl":
+		w = batch.NewJSONLWriter(os.Stdout, batch.OutputColumns(m))
+	}
+
+	scorer := &batch.Scorer{Model: m, Coerce: m.DataDictionary.Coerce, Workers: c.Workers}
+	stats, err := scorer.Score(r, w)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "scored %d rows, %d failed\n", stats.Rows, stats.Failed)
+	return nil
+}
+
+// detectFormat guesses the input format from the file extension, defaulting to CSV.
+func detectFormat(path string) string {
+	switch filepath.Ext(path) {
+	case ".jsonl", ".ndjson", ".json":
+		return "jsonl"
+	}
+	return "csv"
+}
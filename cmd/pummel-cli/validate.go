@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/stillmatic/pummel/pkg/validate"
+)
+
+type ValidateCmd struct {
+	PMMLPaths []string `arg:"" name:"pmml-path" help:"Paths to PMML files" type:"existingfile"`
+}
+
+// Run prints each issue as path:line: message, and fails if any document has issues.
+func (c *ValidateCmd) Run() error {
+	var count int
+	for _, path := range c.PMMLPaths {
+		issues, err := validate.ValidateFile(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		for _, issue := range issues {
+			fmt.Printf("%s:%d: %s\n", path, issue.Line, issue.Message)
+		}
+		count += len(issues)
+	}
+	if count > 0 {
+		return fmt.Errorf("found %d issues", count)
+	}
+	return nil
+}
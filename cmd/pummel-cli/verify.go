@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/stillmatic/pummel"
+)
+
+type VerifyCmd struct {
+	PMMLPath string `arg:"" help:"Path to PMML file" type:"existingfile"`
+}
+
+// Run prints each mismatch between pummel's results and the expected values of the model's ModelVerification.
+func (c *VerifyCmd) Run() error {
+	m, err := pummel.LoadFile(c.PMMLPath)
+	if err != nil {
+		return err
+	}
+	report, err := m.Verify()
+	if err != nil {
+		return err
+	}
+	for _, mm := range report.Mismatches {
+		fmt.Println(mm)
+	}
+	if !report.OK() {
+		return fmt.Errorf("%d mismatches in %d rows", len(report.Mismatches), report.Rows)
+	}
+	fmt.Printf("verified %d rows\n", report.Rows)
+	return nil
+}
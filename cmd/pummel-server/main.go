@@ -1,7 +1,50 @@
 package main
 
-import "fmt"
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/alecthomas/kong"
+	"github.com/stillmatic/pummel/pkg/registry"
+	"github.com/stillmatic/pummel/pkg/server"
+)
+
+var cli struct {
+	Addr        string `help:"Address to listen on." default:":8080"`
+	ContextPath string `help:"Path prefix of the REST API, Openscoring serves it under /openscoring." default:"/openscoring"`
+	Workers     int    `help:"Number of rows evaluated concurrently per batch or CSV request, defaults to the number of CPUs." default:"0"`
+
+	ModelDir     string        `help:"Directory of .pmml files to deploy, each under its file name without the extension. Changed files are reloaded." type:"existingdir"`
+	PollInterval time.Duration `help:"How often to check the model directory for changes." default:"5s"`
+}
 
 func main() {
-	fmt.Println("running server")
+	kong.Parse(&cli,
+		kong.Name("pummel-server"),
+		kong.Description("Serve PMML models over the Openscoring REST API."),
+	)
+	reg := registry.New()
+	if cli.ModelDir != "" {
+		if err := reg.Scan(cli.ModelDir); err != nil {
+			log.Printf("failed to load models: %v", err)
+		}
+		go reg.Watch(context.Background(), cli.ModelDir, cli.PollInterval, func(err error) {
+			log.Printf("failed to reload models: %v", err)
+		})
+	}
+	srv := server.New(reg)
+	srv.Workers = cli.Workers
+
+	prefix := "/" + strings.Trim(cli.ContextPath, "/")
+	mux := http.NewServeMux()
+	if prefix == "/" {
+		mux.Handle("/", srv)
+	} else {
+		mux.Handle(prefix+"/", http.StripPrefix(prefix, srv))
+	}
+	log.Printf("running server on %s%s", cli.Addr, strings.TrimSuffix(prefix, "/"))
+	log.Fatal(http.ListenAndServe(cli.Addr, mux))
 }
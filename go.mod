@@ -8,8 +8,10 @@ require golang.org/x/exp v0.0.0-20220613132600-b0d781184e0d
 
 require github.com/mattn/go-shellwords v1.0.12
 
+require github.com/alecthomas/kong v0.6.1
+
 require (
-	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pkg/errors v0.9.1 // direct
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
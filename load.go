@@ -0,0 +1,203 @@
+package pummel
+
+import (
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/stillmatic/pummel/pkg/fields"
+	"github.com/stillmatic/pummel/pkg/miningschema"
+	"github.com/stillmatic/pummel/pkg/model"
+	"github.com/stillmatic/pummel/pkg/transformations"
+)
+
+var errNoModelElement = errors.New("no model element found in PMML document")
+
+// Model is a PMML document together with the single model element it holds.
+// It implements model.ModelElement, so it can be evaluated without knowing which kind of model was loaded.
+type Model struct {
+	Version                  string
+	Header                   *model.Header
+	DataDictionary           *model.DataDictionary
+	TransformationDictionary *transformations.TransformationDictionary
+	// Element is the local name of the model element, e.g. TreeModel.
+	Element       string
+	ModelName     string
+	FunctionName  string
+	AlgorithmName string
+	ModelElement  model.ModelElement
+
+	doc *transformations.Document
+}
+
+// LoadFile reads and parses the PMML document at path. The files its TableLocators refer to must be
+// within the directory of the document.
+func LoadFile(path string) (*Model, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadFrom(f, filepath.Dir(path))
+}
+
+// Load parses a PMML document and detects which model element it contains.
+// A bare model element without the enclosing PMML element is accepted as well.
+// Documents holding a model element pummel cannot evaluate return a *model.UnsupportedModelError.
+// Its TableLocators are rejected with table.ErrNoDirectory, since it has no directory to read them from.
+func Load(r io.Reader) (*Model, error) {
+	return LoadFrom(r, "")
+}
+
+// LoadFrom is Load for a document whose TableLocators refer to files within dir. Paths which are absolute
+// or lead out of dir are rejected.
+func LoadFrom(r io.Reader, dir string) (*Model, error) {
+	d := xml.NewDecoder(r)
+	doc := transformations.NewDocument(dir)
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			return nil, errors.New("no PMML element found")
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, ok := t.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		m := Model{doc: doc}
+		switch {
+		case start.Name.Local == "PMML":
+			if err := d.DecodeElement(&m, &start); err != nil {
+				return nil, err
+			}
+		case model.IsModelElement(start.Name.Local):
+			me, err := model.DecodeModelElement(d, start, doc)
+			if err != nil {
+				return nil, err
+			}
+			m.setModelElement(start, me)
+		default:
+			return nil, errors.Errorf("expected PMML element, found %s", start.Name.Local)
+		}
+		return &m, nil
+	}
+}
+
+func (m *Model) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	if m.doc == nil {
+		m.doc = transformations.NewDocument("")
+	}
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "version" {
+			m.Version = attr.Value
+		}
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "Header":
+				var h model.Header
+				if err := d.DecodeElement(&h, &tt); err != nil {
+					return errors.Wrap(err, "failed to decode Header")
+				}
+				m.Header = &h
+			case "DataDictionary":
+				var dd model.DataDictionary
+				if err := d.DecodeElement(&dd, &tt); err != nil {
+					return errors.Wrap(err, "failed to decode DataDictionary")
+				}
+				m.DataDictionary = &dd
+				for _, df := range dd.DataFields {
+					m.doc.DeclareField(df.Name, df.DataType)
+				}
+			case "TransformationDictionary":
+				// the functions it defines are called from the model element which follows it
+				td := m.doc.NewTransformationDictionary()
+				if err := d.DecodeElement(td, &tt); err != nil {
+					return errors.Wrap(err, "failed to decode TransformationDictionary")
+				}
+				m.TransformationDictionary = td
+			default:
+				// only the first scorable model element is used
+				if !model.IsModelElement(tt.Name.Local) || m.ModelElement != nil || !isScorable(tt) {
+					if err := d.Skip(); err != nil {
+						return err
+					}
+					continue
+				}
+				me, err := model.DecodeModelElement(d, tt, m.doc)
+				if err != nil {
+					return err
+				}
+				m.setModelElement(tt, me)
+			}
+		case xml.EndElement:
+			if m.ModelElement == nil {
+				return errNoModelElement
+			}
+			return nil
+		}
+	}
+}
+
+func (m *Model) setModelElement(start xml.StartElement, me model.ModelElement) {
+	m.Element = start.Name.Local
+	m.ModelElement = me
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "modelName":
+			m.ModelName = attr.Value
+		case "functionName":
+			m.FunctionName = attr.Value
+		case "algorithmName":
+			m.AlgorithmName = attr.Value
+		}
+	}
+}
+
+func isScorable(start xml.StartElement) bool {
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "isScorable" {
+			return attr.Value != "false"
+		}
+	}
+	return true
+}
+
+// Evaluate computes the global derived fields and evaluates the model element, which computes the output
+// fields whose values are transformed from its results. The derived fields are added to a copy of values,
+// which is left as it is.
+func (m *Model) Evaluate(values map[string]interface{}) (map[string]interface{}, error) {
+	inputs := values
+	values = make(map[string]interface{}, len(inputs))
+	for k, v := range inputs {
+		values[k] = v
+	}
+	if m.TransformationDictionary != nil {
+		if err := m.TransformationDictionary.Transform(values); err != nil {
+			return nil, err
+		}
+	}
+	return m.ModelElement.Evaluate(values)
+}
+
+func (m *Model) GetOutputField() string {
+	return m.ModelElement.GetOutputField()
+}
+
+func (m *Model) GetMiningSchema() *miningschema.MiningSchema {
+	return m.ModelElement.GetMiningSchema()
+}
+
+func (m *Model) GetOutput() *fields.Outputs {
+	return m.ModelElement.GetOutput()
+}
@@ -0,0 +1,270 @@
+package pummel_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stillmatic/pummel"
+	"github.com/stillmatic/pummel/pkg/model"
+	"github.com/stillmatic/pummel/pkg/regression"
+	"github.com/stillmatic/pummel/pkg/table"
+	"github.com/stillmatic/pummel/pkg/tree"
+	"github.com/stretchr/testify/assert"
+)
+
+var loadFixtureCases = []struct {
+	path         string
+	element      string
+	functionName string
+	dataFields   int
+}{
+	{"testdata/lr.pmml", "RegressionModel", "classification", 5},
+	{"testdata/rf.pmml", "MiningModel", "classification", 12},
+	{"testdata/gbm.pmml", "MiningModel", "classification", 2},
+	{"testdata/LogisticRegressionAudit.pmml", "RegressionModel", "classification", 9},
+}
+
+func TestLoadFile(t *testing.T) {
+	for _, tc := range loadFixtureCases {
+		t.Run(tc.path, func(t *testing.T) {
+			m, err := pummel.LoadFile(tc.path)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.element, m.Element)
+			assert.Equal(t, tc.functionName, m.FunctionName)
+			assert.Equal(t, tc.dataFields, len(m.DataDictionary.DataFields))
+			assert.NotNil(t, m.Header)
+		})
+	}
+}
+
+func TestLoadBareModelElement(t *testing.T) {
+	m, err := pummel.LoadFile("testdata/tree.pmml")
+	assert.NoError(t, err)
+	assert.Equal(t, "TreeModel", m.Element)
+	assert.Nil(t, m.DataDictionary)
+	assert.Equal(t, 4, len(m.GetMiningSchema().MiningFields))
+}
+
+func TestLoadEvaluate(t *testing.T) {
+	m, err := pummel.LoadFile("testdata/lr.pmml")
+	assert.NoError(t, err)
+	assert.Equal(t, "4.3", m.Version)
+	assert.Equal(t, "JPMML-SparkML", m.Header.Application.Name)
+	assert.IsType(t, &regression.RegressionModel{}, m.ModelElement)
+	res, err := m.Evaluate(map[string]interface{}{"x0": 0.1, "x1": 0.1, "x2": 100, "x3": 0.1})
+	assert.NoError(t, err)
+	assert.Equal(t, "CATEGORY_2", res[m.GetOutputField()])
+	// looked up in the MapValues of the output field
+	assert.Equal(t, 2.0, res["prediction"])
+	// by the model element itself, so that it is also when it is evaluated directly or as a segment
+	res, err = m.ModelElement.Evaluate(map[string]interface{}{"x0": 0.1, "x1": 0.1, "x2": 100, "x3": 0.1})
+	assert.NoError(t, err)
+	assert.Equal(t, 2.0, res["prediction"])
+}
+
+var transformationDictionaryXML = `<PMML xmlns="http://www.dmg.org/PMML-4_4" version="4.4">
+<Header/>
+<DataDictionary>
+  <DataField name="x" optype="continuous" dataType="double"/>
+  <DataField name="y" optype="continuous" dataType="double"/>
+</DataDictionary>
+<TransformationDictionary>
+  <DerivedField name="double(x)" optype="continuous" dataType="double">
+    <Apply function="*">
+      <FieldRef field="x"/>
+      <Constant dataType="double">2</Constant>
+    </Apply>
+  </DerivedField>
+</TransformationDictionary>
+<TreeModel functionName="regression">
+  <MiningSchema>
+    <MiningField name="x"/>
+    <MiningField name="y" usageType="target"/>
+  </MiningSchema>
+  <Node score="0">
+    <True/>
+    <Node score="1">
+      <SimplePredicate field="double(x)" operator="greaterThan" value="10"/>
+    </Node>
+    <Node score="-1">
+      <True/>
+    </Node>
+  </Node>
+</TreeModel>
+</PMML>`
+
+func TestLoadTransformationDictionary(t *testing.T) {
+	m, err := pummel.Load(strings.NewReader(transformationDictionaryXML))
+	assert.NoError(t, err)
+	assert.IsType(t, &tree.TreeModel{}, m.ModelElement)
+	assert.Equal(t, 1, len(m.TransformationDictionary.DerivedFields))
+	res, err := m.Evaluate(map[string]interface{}{"x": 6.0})
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, res["y"])
+	// the derived fields are not added to the inputs, so that reusing them does not reuse the fields
+	inputs := map[string]interface{}{"x": 4.0}
+	res, err = m.Evaluate(inputs)
+	assert.NoError(t, err)
+	assert.Equal(t, -1.0, res["y"])
+	assert.Equal(t, map[string]interface{}{"x": 4.0}, inputs)
+	inputs["x"] = 6.0
+	res, err = m.Evaluate(inputs)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, res["y"])
+}
+
+// sklearn2pmml pipelines define their preprocessing in the TransformationDictionary
+var defineFunctionXML = `<PMML xmlns="http://www.dmg.org/PMML-4_4" version="4.4">
+<Header/>
+<DataDictionary>
+  <DataField name="x" optype="continuous" dataType="double"/>
+  <DataField name="y" optype="continuous" dataType="double"/>
+</DataDictionary>
+<TransformationDictionary>
+  <DefineFunction name="standardize" optype="continuous" dataType="double">
+    <ParameterField name="value" optype="continuous" dataType="double"/>
+    <ParameterField name="mean" optype="continuous" dataType="double"/>
+    <ParameterField name="std" optype="continuous" dataType="double"/>
+    <Apply function="/">
+      <Apply function="-">
+        <FieldRef field="value"/>
+        <FieldRef field="mean"/>
+      </Apply>
+      <FieldRef field="std"/>
+    </Apply>
+  </DefineFunction>
+  <DerivedField name="z(x)" optype="continuous" dataType="double">
+    <Apply function="standardize">
+      <FieldRef field="x"/>
+      <Constant dataType="double">10</Constant>
+      <Constant dataType="double">4</Constant>
+    </Apply>
+  </DerivedField>
+</TransformationDictionary>
+<RegressionModel functionName="regression">
+  <MiningSchema>
+    <MiningField name="x"/>
+    <MiningField name="y" usageType="target"/>
+  </MiningSchema>
+  <LocalTransformations>
+    <DerivedField name="z(x)^2" optype="continuous" dataType="double">
+      <Apply function="pow">
+        <Apply function="standardize">
+          <FieldRef field="x"/>
+          <Constant dataType="double">10</Constant>
+          <Constant dataType="double">4</Constant>
+        </Apply>
+        <Constant dataType="double">2</Constant>
+      </Apply>
+    </DerivedField>
+  </LocalTransformations>
+  <RegressionTable intercept="1">
+    <NumericPredictor name="z(x)" coefficient="2"/>
+    <NumericPredictor name="z(x)^2" coefficient="0.5"/>
+  </RegressionTable>
+</RegressionModel>
+</PMML>`
+
+func TestLoadDefineFunction(t *testing.T) {
+	m, err := pummel.Load(strings.NewReader(defineFunctionXML))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(m.TransformationDictionary.DefineFunctions))
+	res, err := m.Evaluate(map[string]interface{}{"x": 16.0})
+	assert.NoError(t, err)
+	// z = 1.5, so 1 + 2 * 1.5 + 0.5 * 1.5²
+	assert.InDelta(t, 5.125, res["y"], 1e-12)
+
+	// the model elements of a MiningModel call them as well
+	mining := strings.NewReplacer(
+		`<RegressionModel functionName="regression">`, `<MiningModel functionName="regression">
+  <MiningSchema>
+    <MiningField name="x"/>
+    <MiningField name="y" usageType="target"/>
+  </MiningSchema>
+  <Segmentation multipleModelMethod="selectFirst">
+  <Segment id="1">
+  <True/>
+  <RegressionModel functionName="regression">`,
+		`</RegressionModel>`, `</RegressionModel></Segment></Segmentation></MiningModel>`,
+	).Replace(defineFunctionXML)
+	m, err = pummel.Load(strings.NewReader(mining))
+	assert.NoError(t, err)
+	res, err = m.Evaluate(map[string]interface{}{"x": 16.0})
+	assert.NoError(t, err)
+	assert.InDelta(t, 5.125, res["y"], 1e-12)
+
+	// functions are only defined within their document
+	_, err = pummel.Load(strings.NewReader(strings.Replace(transformationDictionaryXML, `function="*"`, `function="standardize"`, 1)))
+	assert.EqualError(t, err, "failed to decode TransformationDictionary: invalid DerivedField double(x): unknown function: standardize")
+}
+
+var tableLocatorXML = `<PMML version="4.4">
+<DataDictionary>
+  <DataField name="state" optype="categorical" dataType="string"/>
+  <DataField name="y" optype="continuous" dataType="double"/>
+</DataDictionary>
+<TransformationDictionary>
+  <DerivedField name="rate" optype="continuous" dataType="double">
+    <MapValues outputColumn="rate" defaultValue="0">
+      <FieldColumnPair field="state" column="state"/>
+      <TableLocator>
+        <Extension name="path" value="rates.csv"/>
+      </TableLocator>
+    </MapValues>
+  </DerivedField>
+</TransformationDictionary>
+<RegressionModel functionName="regression">
+  <MiningSchema>
+    <MiningField name="state"/>
+    <MiningField name="y" usageType="target"/>
+  </MiningSchema>
+  <RegressionTable intercept="1">
+    <NumericPredictor name="rate" coefficient="10"/>
+  </RegressionTable>
+</RegressionModel>
+</PMML>`
+
+func TestLoadTableLocator(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "model.pmml")
+	assert.NoError(t, os.WriteFile(path, []byte(tableLocatorXML), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "rates.csv"), []byte("state,rate\nCA,0.5\nNY,0.25\n"), 0o644))
+	m, err := pummel.LoadFile(path)
+	assert.NoError(t, err)
+	for state, expected := range map[string]float64{"CA": 6, "NY": 3.5, "TX": 1} {
+		res, err := m.Evaluate(map[string]interface{}{"state": state})
+		assert.NoError(t, err)
+		assert.InDelta(t, expected, res["y"], 1e-12, state)
+	}
+
+	// a document without a directory cannot read tables
+	_, err = pummel.Load(strings.NewReader(tableLocatorXML))
+	assert.ErrorIs(t, err, table.ErrNoDirectory)
+	_, err = pummel.LoadFrom(strings.NewReader(tableLocatorXML), dir)
+	assert.NoError(t, err)
+
+	// and a document cannot read files outside of its directory
+	outside := strings.Replace(tableLocatorXML, `value="rates.csv"`, `value="../rates.csv"`, 1)
+	_, err = pummel.LoadFrom(strings.NewReader(outside), filepath.Join(dir, "models"))
+	assert.ErrorContains(t, err, "path ../rates.csv of TableLocator is outside of the document directory")
+}
+
+func TestLoadUnsupportedModel(t *testing.T) {
+	doc := `<PMML version="4.4"><Header/><DataDictionary/>
+	<BaselineModel functionName="regression"><MiningSchema/></BaselineModel>
+	</PMML>`
+	_, err := pummel.Load(strings.NewReader(doc))
+	var unsupported *model.UnsupportedModelError
+	assert.True(t, errors.As(err, &unsupported))
+	assert.Equal(t, "BaselineModel", unsupported.Element)
+}
+
+func TestLoadNoModel(t *testing.T) {
+	_, err := pummel.Load(strings.NewReader(`<PMML version="4.4"><Header/><DataDictionary/></PMML>`))
+	assert.Error(t, err)
+	_, err = pummel.Load(strings.NewReader(`<NotPMML/>`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,179 @@
+// Package array implements PMML's array elements: Array, whose values are separated by whitespace,
+// and the sparse REAL-SparseArray and INT-SparseArray, which list the non-default entries of a vector.
+package array
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-shellwords"
+	"github.com/pkg/errors"
+)
+
+// Array holds the values of an Array element. String values may be quoted, so that they can contain spaces.
+type Array struct {
+	XMLName xml.Name `xml:"Array"`
+	N       int      `xml:"n,attr"`
+	// Type is int, real or string.
+	Type   string   `xml:"type,attr"`
+	Values []string `xml:"-"`
+}
+
+func (a *Array) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*a = Array{XMLName: start.Name}
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "n":
+			n, err := strconv.Atoi(attr.Value)
+			if err != nil {
+				return errors.Wrap(err, "invalid n of Array")
+			}
+			a.N = n
+		case "type":
+			a.Type = attr.Value
+		}
+	}
+	var content string
+	if err := d.DecodeElement(&content, &start); err != nil {
+		return err
+	}
+	values, err := shellwords.Parse(content)
+	if err != nil {
+		return err
+	}
+	if a.N != 0 && len(values) != a.N {
+		return fmt.Errorf("Array has %d values, expected %d", len(values), a.N)
+	}
+	a.Values = values
+	return nil
+}
+
+// Floats parses the values of a numeric array.
+func (a *Array) Floats() ([]float64, error) {
+	res := make([]float64, len(a.Values))
+	for i, v := range a.Values {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid value %d of Array", i+1)
+		}
+		res[i] = f
+	}
+	return res, nil
+}
+
+// SparseArray holds a REAL-SparseArray or an INT-SparseArray. Indices are 1-based, and entries which are
+// not listed have the default value.
+type SparseArray struct {
+	XMLName      xml.Name
+	N            int
+	DefaultValue float64
+	Indices      []int
+	Entries      []float64
+}
+
+func (sa *SparseArray) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*sa = SparseArray{XMLName: start.Name}
+	for _, attr := range start.Attr {
+		var err error
+		switch attr.Name.Local {
+		case "n":
+			sa.N, err = strconv.Atoi(attr.Value)
+		case "defaultValue":
+			sa.DefaultValue, err = strconv.ParseFloat(attr.Value, 64)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s of %s", attr.Name.Local, start.Name.Local)
+		}
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			var content string
+			if err := d.DecodeElement(&content, &tt); err != nil {
+				return err
+			}
+			for _, s := range strings.Fields(content) {
+				switch tt.Name.Local {
+				case "Indices":
+					i, err := strconv.Atoi(s)
+					if err != nil {
+						return errors.Wrapf(err, "invalid index of %s", start.Name.Local)
+					}
+					sa.Indices = append(sa.Indices, i)
+				case "REAL-Entries", "INT-Entries":
+					f, err := strconv.ParseFloat(s, 64)
+					if err != nil {
+						return errors.Wrapf(err, "invalid entry of %s", start.Name.Local)
+					}
+					sa.Entries = append(sa.Entries, f)
+				default:
+					return fmt.Errorf("unexpected element in %s: %s", start.Name.Local, tt.Name.Local)
+				}
+			}
+		case xml.EndElement:
+			if len(sa.Indices) != len(sa.Entries) {
+				return fmt.Errorf("%s has %d indices but %d entries", start.Name.Local, len(sa.Indices), len(sa.Entries))
+			}
+			for _, i := range sa.Indices {
+				if i < 1 || (sa.N != 0 && i > sa.N) {
+					return fmt.Errorf("index %d of %s is out of range", i, start.Name.Local)
+				}
+			}
+			return nil
+		}
+	}
+}
+
+// Dense returns the array as a vector of n entries. n is used if the array does not set its own length.
+func (sa *SparseArray) Dense(n int) []float64 {
+	if sa.N != 0 {
+		n = sa.N
+	}
+	res := make([]float64, n)
+	if sa.DefaultValue != 0 {
+		for i := range res {
+			res[i] = sa.DefaultValue
+		}
+	}
+	for j, i := range sa.Indices {
+		if i <= n {
+			res[i-1] = sa.Entries[j]
+		}
+	}
+	return res
+}
+
+// IsNumeric reports whether name is an element holding a numeric vector, which DecodeFloats can decode.
+func IsNumeric(name string) bool {
+	switch name {
+	case "Array", "REAL-SparseArray", "INT-SparseArray":
+		return true
+	}
+	return false
+}
+
+// DecodeFloats decodes an Array, REAL-SparseArray or INT-SparseArray into a vector.
+// n is the length of sparse arrays which do not set their own.
+func DecodeFloats(d *xml.Decoder, start xml.StartElement, n int) ([]float64, error) {
+	switch start.Name.Local {
+	case "Array":
+		var a Array
+		if err := d.DecodeElement(&a, &start); err != nil {
+			return nil, err
+		}
+		return a.Floats()
+	case "REAL-SparseArray", "INT-SparseArray":
+		var sa SparseArray
+		if err := d.DecodeElement(&sa, &start); err != nil {
+			return nil, err
+		}
+		return sa.Dense(n), nil
+	}
+	return nil, fmt.Errorf("unexpected array element: %s", start.Name.Local)
+}
@@ -0,0 +1,73 @@
+package array_test
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/stillmatic/pummel/pkg/array"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArray(t *testing.T) {
+	var a array.Array
+	err := xml.Unmarshal([]byte(`<Array n="3" type="string">"New York" Paris   London</Array>`), &a)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"New York", "Paris", "London"}, a.Values)
+
+	err = xml.Unmarshal([]byte(`<Array type="real">1.5 -2 3e2</Array>`), &a)
+	assert.NoError(t, err)
+	floats, err := a.Floats()
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{1.5, -2, 300}, floats)
+
+	err = xml.Unmarshal([]byte(`<Array n="2" type="real">1 2 3</Array>`), &a)
+	assert.EqualError(t, err, "Array has 3 values, expected 2")
+}
+
+func TestSparseArray(t *testing.T) {
+	var sa array.SparseArray
+	err := xml.Unmarshal([]byte(`<REAL-SparseArray n="5" defaultValue="0.5">
+	<Indices>2 5</Indices>
+	<REAL-Entries>1.25 -3</REAL-Entries>
+</REAL-SparseArray>`), &sa)
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{0.5, 1.25, 0.5, 0.5, -3}, sa.Dense(0))
+
+	err = xml.Unmarshal([]byte(`<INT-SparseArray><Indices>3</Indices><INT-Entries>7</INT-Entries></INT-SparseArray>`), &sa)
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{0, 0, 7, 0}, sa.Dense(4))
+
+	err = xml.Unmarshal([]byte(`<REAL-SparseArray n="2"><Indices>3</Indices><REAL-Entries>1</REAL-Entries></REAL-SparseArray>`), &sa)
+	assert.EqualError(t, err, "index 3 of REAL-SparseArray is out of range")
+}
+
+func TestMatrix(t *testing.T) {
+	var m array.Matrix
+	err := xml.Unmarshal([]byte(`<Matrix><Array type="real">1 2</Array><Array type="real">3 4</Array></Matrix>`), &m)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]float64{{1, 2}, {3, 4}}, m.Rows)
+
+	err = xml.Unmarshal([]byte(`<Matrix kind="symmetric">
+	<Array type="real">1</Array>
+	<Array type="real">2 3</Array>
+</Matrix>`), &m)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]float64{{1, 2}, {2, 3}}, m.Rows)
+
+	err = xml.Unmarshal([]byte(`<Matrix kind="diagonal"><Array type="real">5 6</Array></Matrix>`), &m)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]float64{{5, 0}, {0, 6}}, m.Rows)
+
+	err = xml.Unmarshal([]byte(`<Matrix nbRows="2" nbCols="3" diagDefault="1" offDiagDefault="0.5">
+	<MatCell row="2" col="3">7</MatCell>
+</Matrix>`), &m)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]float64{{1, 0.5, 0.5}, {0.5, 1, 7}}, m.Rows)
+
+	err = xml.Unmarshal([]byte(`<Matrix><Array type="real">1 2</Array><Array type="real">3</Array></Matrix>`), &m)
+	assert.EqualError(t, err, "row 2 of Matrix has 1 values, expected 2")
+	err = xml.Unmarshal([]byte(`<Matrix kind="symmetric"><Array type="real">1 2</Array></Matrix>`), &m)
+	assert.EqualError(t, err, "row 1 of symmetric Matrix has 2 values, expected 1")
+	err = xml.Unmarshal([]byte(`<Matrix kind="upper"/>`), &m)
+	assert.EqualError(t, err, "unknown kind of Matrix: upper")
+}
@@ -0,0 +1,162 @@
+package array
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// Matrix holds the values of a Matrix element, row by row. A matrix of kind any lists each row in an Array,
+// a symmetric one lists the rows of its lower triangle, and a diagonal one lists its diagonal in a single
+// Array. Matrices may instead list their non-default cells with MatCell elements, whose indices are 1-based.
+type Matrix struct {
+	XMLName xml.Name
+	Kind    string
+	Rows    [][]float64
+}
+
+func (m *Matrix) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*m = Matrix{XMLName: start.Name, Kind: "any"}
+	var nbRows, nbCols int
+	var diagDefault, offDiagDefault *float64
+	for _, attr := range start.Attr {
+		var err error
+		switch attr.Name.Local {
+		case "kind":
+			m.Kind = attr.Value
+		case "nbRows":
+			nbRows, err = strconv.Atoi(attr.Value)
+		case "nbCols":
+			nbCols, err = strconv.Atoi(attr.Value)
+		case "diagDefault", "offDiagDefault":
+			var f float64
+			f, err = strconv.ParseFloat(attr.Value, 64)
+			if attr.Name.Local == "diagDefault" {
+				diagDefault = &f
+			} else {
+				offDiagDefault = &f
+			}
+		}
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s of Matrix", attr.Name.Local)
+		}
+	}
+	switch m.Kind {
+	case "any", "symmetric", "diagonal":
+	default:
+		return fmt.Errorf("unknown kind of Matrix: %s", m.Kind)
+	}
+	var arrays [][]float64
+	type cell struct {
+		row, col int
+		value    float64
+	}
+	var cells []cell
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "Array":
+				values, err := DecodeFloats(d, tt, 0)
+				if err != nil {
+					return errors.Wrapf(err, "invalid row %d of Matrix", len(arrays)+1)
+				}
+				arrays = append(arrays, values)
+			case "MatCell":
+				var mc struct {
+					Row   int    `xml:"row,attr"`
+					Col   int    `xml:"col,attr"`
+					Value string `xml:",chardata"`
+				}
+				if err := d.DecodeElement(&mc, &tt); err != nil {
+					return err
+				}
+				f, err := strconv.ParseFloat(mc.Value, 64)
+				if err != nil {
+					return errors.Wrapf(err, "invalid MatCell %d,%d of Matrix", mc.Row, mc.Col)
+				}
+				if mc.Row < 1 || mc.Col < 1 {
+					return fmt.Errorf("MatCell %d,%d of Matrix is out of range", mc.Row, mc.Col)
+				}
+				cells = append(cells, cell{mc.Row, mc.Col, f})
+			default:
+				return fmt.Errorf("unexpected element in Matrix: %s", tt.Name.Local)
+			}
+		case xml.EndElement:
+			if len(cells) > 0 {
+				for _, c := range cells {
+					if c.row > nbRows {
+						nbRows = c.row
+					}
+					if c.col > nbCols {
+						nbCols = c.col
+					}
+				}
+				m.Rows = make([][]float64, nbRows)
+				for i := range m.Rows {
+					m.Rows[i] = make([]float64, nbCols)
+					for j := range m.Rows[i] {
+						if i == j && diagDefault != nil {
+							m.Rows[i][j] = *diagDefault
+						} else if i != j && offDiagDefault != nil {
+							m.Rows[i][j] = *offDiagDefault
+						}
+					}
+				}
+				for _, c := range cells {
+					m.Rows[c.row-1][c.col-1] = c.value
+					if m.Kind == "symmetric" {
+						m.Rows[c.col-1][c.row-1] = c.value
+					}
+				}
+				return nil
+			}
+			return m.fill(arrays)
+		}
+	}
+}
+
+// fill expands the arrays of a matrix into its rows.
+func (m *Matrix) fill(arrays [][]float64) error {
+	switch m.Kind {
+	case "diagonal":
+		if len(arrays) != 1 {
+			return fmt.Errorf("diagonal Matrix has %d arrays, expected 1", len(arrays))
+		}
+		n := len(arrays[0])
+		m.Rows = make([][]float64, n)
+		for i := range m.Rows {
+			m.Rows[i] = make([]float64, n)
+			m.Rows[i][i] = arrays[0][i]
+		}
+	case "symmetric":
+		n := len(arrays)
+		m.Rows = make([][]float64, n)
+		for i := range m.Rows {
+			m.Rows[i] = make([]float64, n)
+		}
+		for i, row := range arrays {
+			if len(row) != i+1 {
+				return fmt.Errorf("row %d of symmetric Matrix has %d values, expected %d", i+1, len(row), i+1)
+			}
+			for j, v := range row {
+				m.Rows[i][j] = v
+				m.Rows[j][i] = v
+			}
+		}
+	default:
+		for i, row := range arrays {
+			if len(row) != len(arrays[0]) {
+				return fmt.Errorf("row %d of Matrix has %d values, expected %d", i+1, len(row), len(arrays[0]))
+			}
+		}
+		m.Rows = arrays
+	}
+	return nil
+}
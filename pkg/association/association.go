@@ -0,0 +1,460 @@
+// Package association implements the AssociationModel element, which recommends the consequents of the
+// association rules whose antecedents are in a transaction.
+package association
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/stillmatic/pummel/pkg/fields"
+	"github.com/stillmatic/pummel/pkg/miningschema"
+	"github.com/stillmatic/pummel/pkg/transformations"
+	"github.com/stillmatic/pummel/pkg/verification"
+)
+
+// AssociationModel holds rules between itemsets. Its transaction is the value of its active field, a
+// fields.Collection of items. A string is parsed with fields.ParseCollection, so that transactions can be
+// read from CSV files.
+type AssociationModel struct {
+	XMLName              xml.Name                              `xml:"AssociationModel"`
+	ModelName            string                                `xml:"modelName,attr"`
+	FunctionName         string                                `xml:"functionName,attr"`
+	AlgorithmName        string                                `xml:"algorithmName,attr"`
+	IsScorable           bool                                  `xml:"isScorable,attr"`
+	NumberOfTransactions int                                   `xml:"numberOfTransactions,attr"`
+	MinimumSupport       float64                               `xml:"minimumSupport,attr"`
+	MinimumConfidence    float64                               `xml:"minimumConfidence,attr"`
+	MiningSchema         *miningschema.MiningSchema            `xml:"MiningSchema"`
+	Output               *fields.Outputs                       `xml:"Output"`
+	LocalTransformations *transformations.LocalTransformations `xml:"LocalTransformations"`
+	Items                []*Item                               `xml:"Item"`
+	Itemsets             []*Itemset                            `xml:"Itemset"`
+	AssociationRules     []*AssociationRule                    `xml:"AssociationRule"`
+	ModelVerification    *verification.ModelVerification       `xml:"ModelVerification"`
+
+	// itemField is the active field holding the transaction.
+	itemField string
+	doc       *transformations.Document
+}
+
+// NewAssociationModel returns an empty AssociationModel to be decoded into, whose expressions belong to doc.
+func NewAssociationModel(doc *transformations.Document) *AssociationModel {
+	return &AssociationModel{doc: doc}
+}
+
+type Item struct {
+	XMLName     xml.Name `xml:"Item"`
+	ID          string   `xml:"id,attr"`
+	Value       string   `xml:"value,attr"`
+	MappedValue string   `xml:"mappedValue,attr"`
+	Weight      float64  `xml:"weight,attr"`
+}
+
+type Itemset struct {
+	XMLName       xml.Name  `xml:"Itemset"`
+	ID            string    `xml:"id,attr"`
+	Support       float64   `xml:"support,attr"`
+	NumberOfItems int       `xml:"numberOfItems,attr"`
+	ItemRefs      []ItemRef `xml:"ItemRef"`
+
+	// values are those of the referenced items.
+	values []string
+}
+
+type ItemRef struct {
+	XMLName xml.Name `xml:"ItemRef"`
+	ItemRef string   `xml:"itemRef,attr"`
+}
+
+// AssociationRule states that the consequent itemset is likely to be in the transactions holding the
+// antecedent itemset. Lift, Leverage and Affinity are nil if the model leaves them out. ID defaults to
+// the 1-based position of the rule.
+type AssociationRule struct {
+	XMLName    xml.Name `xml:"AssociationRule"`
+	ID         string   `xml:"id,attr"`
+	Antecedent string   `xml:"antecedent,attr"`
+	Consequent string   `xml:"consequent,attr"`
+	Support    float64  `xml:"support,attr"`
+	Confidence float64  `xml:"confidence,attr"`
+	Lift       *float64 `xml:"lift,attr"`
+	Leverage   *float64 `xml:"leverage,attr"`
+	Affinity   *float64 `xml:"affinity,attr"`
+
+	antecedent, consequent *Itemset
+}
+
+var Algorithms = struct {
+	Recommendation          string
+	ExclusiveRecommendation string
+	RuleAssociation         string
+}{
+	Recommendation:          "recommendation",
+	ExclusiveRecommendation: "exclusiveRecommendation",
+	RuleAssociation:         "ruleAssociation",
+}
+
+var RankBases = struct {
+	Confidence string
+	Support    string
+	Lift       string
+	Leverage   string
+	Affinity   string
+}{
+	Confidence: "confidence",
+	Support:    "support",
+	Lift:       "lift",
+	Leverage:   "leverage",
+	Affinity:   "affinity",
+}
+
+func (m *AssociationModel) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	m.XMLName = start.Name
+	for _, attr := range start.Attr {
+		var err error
+		switch attr.Name.Local {
+		case "modelName":
+			m.ModelName = attr.Value
+		case "functionName":
+			m.FunctionName = attr.Value
+		case "algorithmName":
+			m.AlgorithmName = attr.Value
+		case "isScorable":
+			m.IsScorable = attr.Value == "true"
+		case "numberOfTransactions":
+			m.NumberOfTransactions, err = strconv.Atoi(attr.Value)
+		case "minimumSupport":
+			m.MinimumSupport, err = strconv.ParseFloat(attr.Value, 64)
+		case "minimumConfidence":
+			m.MinimumConfidence, err = strconv.ParseFloat(attr.Value, 64)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s of AssociationModel", attr.Name.Local)
+		}
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "MiningSchema":
+				var ms miningschema.MiningSchema
+				if err := d.DecodeElement(&ms, &tt); err != nil {
+					return err
+				}
+				m.MiningSchema = &ms
+			case "Output":
+				out := fields.NewOutputs(m.doc)
+				if err := d.DecodeElement(out, &tt); err != nil {
+					return err
+				}
+				m.Output = out
+			case "LocalTransformations":
+				lt := m.doc.NewLocalTransformations()
+				if err := d.DecodeElement(lt, &tt); err != nil {
+					return err
+				}
+				m.LocalTransformations = lt
+			case "Item":
+				var item Item
+				if err := d.DecodeElement(&item, &tt); err != nil {
+					return err
+				}
+				m.Items = append(m.Items, &item)
+			case "Itemset":
+				var is Itemset
+				if err := d.DecodeElement(&is, &tt); err != nil {
+					return err
+				}
+				m.Itemsets = append(m.Itemsets, &is)
+			case "AssociationRule":
+				var r AssociationRule
+				if err := d.DecodeElement(&r, &tt); err != nil {
+					return err
+				}
+				m.AssociationRules = append(m.AssociationRules, &r)
+			case "ModelVerification":
+				var mv verification.ModelVerification
+				if err := d.DecodeElement(&mv, &tt); err != nil {
+					return err
+				}
+				m.ModelVerification = &mv
+			case "Extension", "ModelStats", "ModelExplanation":
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unknown element: %s", tt.Name.Local)
+			}
+		case xml.EndElement:
+			return m.resolve()
+		}
+	}
+}
+
+// resolve finds the item field, and the items and itemsets referred to by the itemsets and rules.
+func (m *AssociationModel) resolve() error {
+	if m.MiningSchema == nil {
+		return errors.New("AssociationModel has no MiningSchema")
+	}
+	for _, mf := range m.MiningSchema.MiningFields {
+		if mf.UsageType == "" || mf.UsageType == "active" {
+			if m.itemField != "" {
+				return fmt.Errorf("AssociationModel has several active fields: %s and %s", m.itemField, mf.Name)
+			}
+			m.itemField = mf.Name
+		}
+	}
+	if m.itemField == "" {
+		return errors.New("AssociationModel has no active field")
+	}
+
+	items := make(map[string]*Item, len(m.Items))
+	for _, item := range m.Items {
+		items[item.ID] = item
+	}
+	itemsets := make(map[string]*Itemset, len(m.Itemsets))
+	for _, is := range m.Itemsets {
+		for _, ref := range is.ItemRefs {
+			item, ok := items[ref.ItemRef]
+			if !ok {
+				return fmt.Errorf("Itemset %s refers to unknown Item %s", is.ID, ref.ItemRef)
+			}
+			is.values = append(is.values, item.Value)
+		}
+		itemsets[is.ID] = is
+	}
+	for i, r := range m.AssociationRules {
+		if r.ID == "" {
+			r.ID = strconv.Itoa(i + 1)
+		}
+		var ok bool
+		if r.antecedent, ok = itemsets[r.Antecedent]; !ok {
+			return fmt.Errorf("AssociationRule %s refers to unknown antecedent Itemset %s", r.ID, r.Antecedent)
+		}
+		if r.consequent, ok = itemsets[r.Consequent]; !ok {
+			return fmt.Errorf("AssociationRule %s refers to unknown consequent Itemset %s", r.ID, r.Consequent)
+		}
+	}
+	if m.Output != nil {
+		for _, of := range m.Output.OutputFields {
+			if of.Feature != "ruleValue" {
+				continue
+			}
+			switch of.Algorithm {
+			case "", Algorithms.Recommendation, Algorithms.ExclusiveRecommendation, Algorithms.RuleAssociation:
+			default:
+				return fmt.Errorf("unknown algorithm of OutputField %s: %s", of.Name, of.Algorithm)
+			}
+			switch of.RankBasis {
+			case "", RankBases.Confidence, RankBases.Support, RankBases.Lift, RankBases.Leverage, RankBases.Affinity:
+			default:
+				return fmt.Errorf("unknown rank basis of OutputField %s: %s", of.Name, of.RankBasis)
+			}
+			switch of.RankOrder {
+			case "", "descending", "ascending":
+			default:
+				return fmt.Errorf("unknown rank order of OutputField %s: %s", of.Name, of.RankOrder)
+			}
+		}
+	}
+	return nil
+}
+
+// transaction returns the set of items of a value of the item field.
+func transaction(v interface{}) map[string]bool {
+	var c fields.Collection
+	switch v := v.(type) {
+	case nil:
+	case fields.Collection:
+		c = v
+	case []interface{}:
+		c = v
+	case string:
+		c = fields.ParseCollection(v)
+	default:
+		c = fields.Collection{v}
+	}
+	items := make(map[string]bool, len(c))
+	for _, item := range c.Strings() {
+		items[item] = true
+	}
+	return items
+}
+
+func (is *Itemset) in(items map[string]bool) bool {
+	for _, v := range is.values {
+		if !items[v] {
+			return false
+		}
+	}
+	return true
+}
+
+// selectRules returns the rules selected by an algorithm: recommendation selects the rules whose antecedent
+// is in the transaction, exclusiveRecommendation those of them whose consequent is not, and ruleAssociation
+// those whose antecedent and consequent both are.
+func (m *AssociationModel) selectRules(items map[string]bool, algorithm string) []*AssociationRule {
+	var rules []*AssociationRule
+	for _, r := range m.AssociationRules {
+		if !r.antecedent.in(items) {
+			continue
+		}
+		consequent := r.consequent.in(items)
+		switch algorithm {
+		case Algorithms.ExclusiveRecommendation:
+			if consequent {
+				continue
+			}
+		case Algorithms.RuleAssociation:
+			if !consequent {
+				continue
+			}
+		}
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+func (r *AssociationRule) measure(basis string) *float64 {
+	switch basis {
+	case RankBases.Support:
+		return &r.Support
+	case RankBases.Lift:
+		return r.Lift
+	case RankBases.Leverage:
+		return r.Leverage
+	case RankBases.Affinity:
+		return r.Affinity
+	}
+	return &r.Confidence
+}
+
+// rank sorts the rules by the measure of their rank basis, leaving out those without it. Ties keep the
+// order of the model.
+func rank(rules []*AssociationRule, basis string, ascending bool) []*AssociationRule {
+	ranked := make([]*AssociationRule, 0, len(rules))
+	for _, r := range rules {
+		if r.measure(basis) != nil {
+			ranked = append(ranked, r)
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := *ranked[i].measure(basis), *ranked[j].measure(basis)
+		if ascending {
+			return a < b
+		}
+		return a > b
+	})
+	return ranked
+}
+
+func (m *AssociationModel) Evaluate(values map[string]interface{}) (map[string]interface{}, error) {
+	out, err := m.evaluate(values)
+	return m.Output.Complete(values, out, err)
+}
+
+func (m *AssociationModel) evaluate(values map[string]interface{}) (map[string]interface{}, error) {
+	if m.LocalTransformations != nil {
+		for _, tr := range m.LocalTransformations.DerivedFields {
+			val, err := tr.Transform(values)
+			if err != nil {
+				return nil, err
+			}
+			values[tr.RequiredField()] = val
+		}
+	}
+	out := make(map[string]interface{})
+	if m.Output == nil {
+		return out, nil
+	}
+	items := transaction(values[m.itemField])
+	selected := make(map[string][]*AssociationRule)
+	for _, of := range m.Output.OutputFields {
+		if of.Feature != "ruleValue" {
+			continue
+		}
+		algorithm := of.Algorithm
+		if algorithm == "" {
+			algorithm = Algorithms.ExclusiveRecommendation
+		}
+		rules, ok := selected[algorithm]
+		if !ok {
+			rules = m.selectRules(items, algorithm)
+			selected[algorithm] = rules
+		}
+		ranked := rank(rules, of.RankBasis, of.RankOrder == "ascending")
+		n := of.Rank
+		if n == 0 {
+			n = 1
+		}
+		if of.IsMultiValued {
+			c := fields.Collection{}
+			for i := 0; i < n && i < len(ranked); i++ {
+				c = append(c, ranked[i].value(of.RuleFeature))
+			}
+			out[of.Name] = c
+			continue
+		}
+		out[of.Name] = nil
+		if n <= len(ranked) {
+			out[of.Name] = ranked[n-1].value(of.RuleFeature)
+		}
+	}
+	return out, nil
+}
+
+// value returns a feature of the rule. Its antecedent and consequent are collections of item values, and
+// the rule itself reads "{antecedent}->{consequent}".
+func (r *AssociationRule) value(feature string) interface{} {
+	switch feature {
+	case "", "consequent":
+		return r.consequent.collection()
+	case "antecedent":
+		return r.antecedent.collection()
+	case "rule":
+		return "{" + strings.Join(r.antecedent.values, ",") + "}->{" + strings.Join(r.consequent.values, ",") + "}"
+	case "ruleId":
+		return r.ID
+	case "confidence":
+		return r.Confidence
+	case "support":
+		return r.Support
+	case "lift", "leverage", "affinity":
+		if v := r.measure(feature); v != nil {
+			return *v
+		}
+	}
+	return nil
+}
+
+func (is *Itemset) collection() fields.Collection {
+	c := make(fields.Collection, len(is.values))
+	for i, v := range is.values {
+		c[i] = v
+	}
+	return c
+}
+
+// GetOutputField returns the target of the model, which association models usually leave out.
+func (m *AssociationModel) GetOutputField() string {
+	return m.MiningSchema.GetOutputField()
+}
+
+func (m *AssociationModel) GetMiningSchema() *miningschema.MiningSchema {
+	return m.MiningSchema
+}
+
+func (m *AssociationModel) GetOutput() *fields.Outputs {
+	return m.Output
+}
+
+func (m *AssociationModel) GetModelVerification() *verification.ModelVerification {
+	return m.ModelVerification
+}
@@ -0,0 +1,102 @@
+package association_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/stillmatic/pummel/pkg/association"
+	"github.com/stillmatic/pummel/pkg/fields"
+	"github.com/stretchr/testify/assert"
+)
+
+var associationXML = `<AssociationModel functionName="associationRules" numberOfTransactions="10" minimumSupport="0.2" minimumConfidence="0.5">
+	<MiningSchema>
+		<MiningField name="transaction" usageType="group"/>
+		<MiningField name="item"/>
+	</MiningSchema>
+	<Output>
+		<OutputField name="exclusive" feature="ruleValue"/>
+		<OutputField name="exclusiveRule" feature="ruleValue" ruleFeature="rule"/>
+		<OutputField name="top" feature="ruleValue" algorithm="recommendation" ruleFeature="ruleId"/>
+		<OutputField name="second" feature="ruleValue" algorithm="recommendation" ruleFeature="ruleId" rank="2"/>
+		<OutputField name="consequents" feature="ruleValue" algorithm="recommendation" rank="2" isMultiValued="1"/>
+		<OutputField name="lowestLift" feature="ruleValue" algorithm="recommendation" ruleFeature="ruleId" rankBasis="lift" rankOrder="ascending"/>
+		<OutputField name="leverage" feature="ruleValue" algorithm="recommendation" ruleFeature="leverage" rankBasis="leverage"/>
+		<OutputField name="associated" feature="ruleValue" algorithm="ruleAssociation" ruleFeature="antecedent" rankBasis="support"/>
+		<OutputField name="confidence" feature="ruleValue" algorithm="ruleAssociation" ruleFeature="confidence"/>
+	</Output>
+	<Item id="1" value="bread"/>
+	<Item id="2" value="milk"/>
+	<Item id="3" value="butter"/>
+	<Item id="4" value="beer"/>
+	<Item id="5" value="diapers"/>
+	<Itemset id="1"><ItemRef itemRef="1"/></Itemset>
+	<Itemset id="2"><ItemRef itemRef="2"/></Itemset>
+	<Itemset id="3"><ItemRef itemRef="3"/></Itemset>
+	<Itemset id="4"><ItemRef itemRef="4"/></Itemset>
+	<Itemset id="5"><ItemRef itemRef="5"/></Itemset>
+	<Itemset id="6"><ItemRef itemRef="1"/><ItemRef itemRef="2"/></Itemset>
+	<AssociationRule id="R1" antecedent="1" consequent="2" support="0.4" confidence="0.8" lift="1.2" leverage="0.05" affinity="0.5"/>
+	<AssociationRule id="R2" antecedent="6" consequent="3" support="0.2" confidence="0.6" lift="2"/>
+	<AssociationRule id="R3" antecedent="5" consequent="4" support="0.3" confidence="0.7" lift="1.5" leverage="0.1"/>
+	<AssociationRule antecedent="2" consequent="1" support="0.5" confidence="0.9" lift="1.2"/>
+</AssociationModel>`
+
+func TestAssociation(t *testing.T) {
+	var m association.AssociationModel
+	err := xml.Unmarshal([]byte(associationXML), &m)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, m.NumberOfTransactions)
+	assert.Equal(t, 4, len(m.AssociationRules))
+	assert.Equal(t, "4", m.AssociationRules[3].ID)
+
+	out, err := m.Evaluate(map[string]interface{}{"item": fields.Collection{"bread", "milk"}})
+	assert.NoError(t, err)
+	// only the consequent of R2 is not in the transaction
+	assert.Equal(t, fields.Collection{"butter"}, out["exclusive"])
+	assert.Equal(t, "{bread,milk}->{butter}", out["exclusiveRule"])
+	assert.Equal(t, "4", out["top"])
+	assert.Equal(t, "R1", out["second"])
+	assert.Equal(t, fields.Collection{fields.Collection{"bread"}, fields.Collection{"milk"}}, out["consequents"])
+	assert.Equal(t, "[[bread], [milk]]", fields.Collection{fields.Collection{"bread"}, fields.Collection{"milk"}}.String())
+	// R1 and 4 tie, and R1 comes first
+	assert.Equal(t, "R1", out["lowestLift"])
+	// R2 and 4 have no leverage, so only R1 is ranked
+	assert.Equal(t, 0.05, out["leverage"])
+	assert.Equal(t, fields.Collection{"milk"}, out["associated"])
+	assert.Equal(t, 0.9, out["confidence"])
+
+	// transactions can be read from text
+	out, err = m.Evaluate(map[string]interface{}{"item": "[diapers, bread]"})
+	assert.NoError(t, err)
+	assert.Equal(t, fields.Collection{"milk"}, out["exclusive"])
+	assert.Equal(t, "R1", out["top"])
+	assert.Equal(t, "R3", out["second"])
+	assert.Nil(t, out["associated"])
+
+	// a missing transaction is empty, so no rule is selected
+	out, err = m.Evaluate(map[string]interface{}{})
+	assert.NoError(t, err)
+	assert.Nil(t, out["exclusive"])
+	assert.Equal(t, fields.Collection{}, out["consequents"])
+}
+
+func TestAssociationErrors(t *testing.T) {
+	tcs := []struct {
+		old, new string
+		expected string
+	}{
+		{`<ItemRef itemRef="5"/>`, `<ItemRef itemRef="7"/>`, "Itemset 5 refers to unknown Item 7"},
+		{`antecedent="5" consequent="4"`, `antecedent="5" consequent="9"`, "AssociationRule R3 refers to unknown consequent Itemset 9"},
+		{`usageType="group"`, ``, "AssociationModel has several active fields: transaction and item"},
+		{`rankBasis="lift"`, `rankBasis="interest"`, "unknown rank basis of OutputField lowestLift: interest"},
+		{`algorithm="ruleAssociation" ruleFeature="confidence"`, `algorithm="sequence"`, "unknown algorithm of OutputField confidence: sequence"},
+		{`<Item id="1" value="bread"/>`, `<Items/>`, "unknown element: Items"},
+	}
+	for _, tc := range tcs {
+		var m association.AssociationModel
+		err := xml.Unmarshal([]byte(strings.Replace(associationXML, tc.old, tc.new, 1)), &m)
+		assert.EqualError(t, err, tc.expected)
+	}
+}
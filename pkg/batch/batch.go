@@ -0,0 +1,207 @@
+// Package batch scores streams of records with a PMML model.
+// Records are evaluated concurrently by a pool of workers, but are written in the order they were read.
+package batch
+
+import (
+	"io"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/stillmatic/pummel/pkg/model"
+)
+
+// Row is a single input record.
+// Values hold the raw values as read, before they are coerced to the model's data types.
+// Err is set when the record could not be read, e.g. a malformed JSON line.
+type Row struct {
+	Values map[string]interface{}
+	Err    error
+}
+
+// Reader reads input rows. It returns io.EOF once the input is exhausted.
+// Any other error aborts the batch.
+type Reader interface {
+	Read() (Row, error)
+}
+
+// Writer writes the inputs and outputs of each row. err is the error for that row, if any.
+type Writer interface {
+	Write(inputs map[string]interface{}, outputs map[string]interface{}, err error) error
+	Flush() error
+}
+
+// Stats summarizes a batch.
+type Stats struct {
+	Rows   int
+	Failed int
+}
+
+// Scorer evaluates every row read from a Reader and writes the results to a Writer.
+type Scorer struct {
+	Model model.ModelElement
+	// Coerce converts a raw input value for the named field, see model.DataDictionary.Coerce.
+	// Values are passed through unchanged when Coerce is nil.
+	Coerce func(field string, value interface{}) (interface{}, error)
+	// Workers is the number of rows evaluated concurrently. It defaults to GOMAXPROCS.
+	Workers int
+}
+
+type job struct {
+	index int
+	row   Row
+}
+
+type result struct {
+	index   int
+	inputs  map[string]interface{}
+	outputs map[string]interface{}
+	err     error
+}
+
+// Score reads rows until the Reader is exhausted. Errors evaluating a row are passed to the Writer
+// and do not stop the batch; errors from the Reader or Writer do.
+func (s *Scorer) Score(r Reader, w Writer) (Stats, error) {
+	workers := s.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	jobs := make(chan job, workers)
+	results := make(chan result, workers)
+	done := make(chan struct{})
+	// rows read but not yet written; a slow row stops the reader once the rows after it fill the slots,
+	// rather than letting their results pile up
+	slots := make(chan struct{}, 2*workers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				outputs, err := s.evaluate(j.row)
+				results <- result{index: j.index, inputs: j.row.Values, outputs: outputs, err: err}
+			}
+		}()
+	}
+
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(jobs)
+		for i := 0; ; i++ {
+			row, err := r.Read()
+			if err == io.EOF {
+				readErr <- nil
+				return
+			}
+			if err != nil {
+				readErr <- err
+				return
+			}
+			select {
+			case slots <- struct{}{}:
+			case <-done:
+				readErr <- nil
+				return
+			}
+			select {
+			case jobs <- job{index: i, row: row}:
+			case <-done:
+				readErr <- nil
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// results arrive out of order, hold them until every earlier row has been written
+	var stats Stats
+	var writeErr error
+	pending := make(map[int]result)
+	next := 0
+	for res := range results {
+		pending[res.index] = res
+		for {
+			res, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			stats.Rows++
+			if res.err != nil {
+				stats.Failed++
+			}
+			if writeErr == nil {
+				if writeErr = w.Write(res.inputs, res.outputs, res.err); writeErr != nil {
+					close(done)
+				}
+			}
+			// the row is written, so the reader may read another one
+			<-slots
+		}
+	}
+	if err := <-readErr; err != nil {
+		return stats, errors.Wrap(err, "failed to read input")
+	}
+	if writeErr != nil {
+		return stats, errors.Wrap(writeErr, "failed to write output")
+	}
+	return stats, w.Flush()
+}
+
+// evaluate coerces a copy of the row's values, so that the inputs can still be written as they were read.
+func (s *Scorer) evaluate(row Row) (map[string]interface{}, error) {
+	if row.Err != nil {
+		return nil, row.Err
+	}
+	values := make(map[string]interface{}, len(row.Values))
+	for k, v := range row.Values {
+		if s.Coerce != nil {
+			coerced, err := s.Coerce(k, v)
+			if err != nil {
+				return nil, err
+			}
+			v = coerced
+		}
+		if v != nil {
+			values[k] = v
+		}
+	}
+	return s.Model.Evaluate(values)
+}
+
+// OutputColumns returns the names of the model's results: the target field followed by each output field.
+// It returns nil if the model declares neither.
+func OutputColumns(me model.ModelElement) []string {
+	var cols []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			cols = append(cols, name)
+		}
+	}
+	if ms := me.GetMiningSchema(); ms != nil {
+		add(ms.GetOutputField())
+	}
+	if out := me.GetOutput(); out != nil {
+		for _, of := range out.OutputFields {
+			add(of.Name)
+		}
+	}
+	return cols
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
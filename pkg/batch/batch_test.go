@@ -0,0 +1,238 @@
+package batch_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stillmatic/pummel/pkg/batch"
+	"github.com/stillmatic/pummel/pkg/model"
+	"github.com/stillmatic/pummel/pkg/regression"
+	"github.com/stretchr/testify/assert"
+)
+
+var linearRegressionXML = []byte(`<PMML xmlns="https://www.dmg.org/PMML-4_1" version="4.1">
+<Header copyright="DMG.org"/>
+<DataDictionary numberOfFields="4">
+  <DataField name="age" optype="continuous" dataType="double"/>
+  <DataField name="salary" optype="continuous" dataType="double"/>
+  <DataField name="car_location" optype="categorical" dataType="string">
+	<Value value="carpark"/>
+	<Value value="street"/>
+  </DataField>
+  <DataField name="number_of_claims" optype="continuous" dataType="integer"/>
+</DataDictionary>
+<RegressionModel modelName="Sample for linear regression" functionName="regression" algorithmName="linearRegression" targetFieldName="number_of_claims">
+  <MiningSchema>
+	<MiningField name="age"/>
+	<MiningField name="salary"/>
+	<MiningField name="car_location"/>
+	<MiningField name="number_of_claims" usageType="predicted"/>
+  </MiningSchema>
+  <RegressionTable intercept="132.37">
+	<NumericPredictor name="age" exponent="1" coefficient="7.1"/>
+	<NumericPredictor name="salary" exponent="1" coefficient="0.01"/>
+	<CategoricalPredictor name="car_location" value="carpark" coefficient="41.1"/>
+	<CategoricalPredictor name="car_location" value="street" coefficient="325.03"/>
+  </RegressionTable>
+</RegressionModel>
+</PMML>`)
+
+func loadScorer(t testing.TB, workers int) *batch.Scorer {
+	var rm model.PMMLRegressionModel
+	err := xml.Unmarshal(linearRegressionXML, &rm)
+	assert.NoError(t, err)
+	return &batch.Scorer{Model: rm.RegressionModel, Coerce: rm.DataDictionary.Coerce, Workers: workers}
+}
+
+func TestScoreCSV(t *testing.T) {
+	s := loadScorer(t, 4)
+	input := "age,salary,car_location\n30,1000,carpark\nthirty,1000,street\n40,,street\n30,1000\n"
+	r, err := batch.NewCSVReader(strings.NewReader(input))
+	assert.NoError(t, err)
+	var out bytes.Buffer
+	w := batch.NewCSVWriter(&out, r.Columns(), batch.OutputColumns(s.Model))
+	stats, err := s.Score(r, w)
+	assert.NoError(t, err)
+	assert.Equal(t, batch.Stats{Rows: 4, Failed: 2}, stats)
+
+	records, err := csv.NewReader(&out).ReadAll()
+	assert.NoError(t, err)
+	assert.Equal(t, 5, len(records))
+	assert.Equal(t, []string{"age", "salary", "car_location", "number_of_claims", "error"}, records[0])
+	assert.Equal(t, []string{"30", "1000", "carpark", "396.47", ""}, records[1])
+	assert.Equal(t, "", records[2][3])
+	assert.Contains(t, records[2][4], "invalid double value for field age")
+	// missing salary contributes nothing
+	assert.Equal(t, "741.4", records[3][3])
+	assert.Contains(t, records[4][4], "expected 3 fields, found 2")
+}
+
+func TestScoreJSONL(t *testing.T) {
+	s := loadScorer(t, 2)
+	input := `{"age": 30, "salary": 1000, "car_location": "carpark"}
+
+{"age": 30, "salary": "1000", "car_location": "street"}
+{"age": 30,
+`
+	var out bytes.Buffer
+	stats, err := s.Score(batch.NewJSONLReader(strings.NewReader(input)), batch.NewJSONLWriter(&out, batch.OutputColumns(s.Model)))
+	assert.NoError(t, err)
+	assert.Equal(t, batch.Stats{Rows: 3, Failed: 1}, stats)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, 3, len(lines))
+	var first map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+	assert.Equal(t, "carpark", first["car_location"])
+	assert.InDelta(t, 396.47, first["number_of_claims"], 1e-9)
+	var second map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &second))
+	assert.InDelta(t, 680.4, second["number_of_claims"], 1e-9)
+	var third map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[2]), &third))
+	assert.Contains(t, third[batch.ErrorColumn], "line 4")
+}
+
+func TestScorePreservesOrder(t *testing.T) {
+	s := loadScorer(t, 8)
+	var input strings.Builder
+	input.WriteString("age,salary,car_location\n")
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&input, "%d,0,\n", i)
+	}
+	r, err := batch.NewCSVReader(strings.NewReader(input.String()))
+	assert.NoError(t, err)
+	var out bytes.Buffer
+	stats, err := s.Score(r, batch.NewCSVWriter(&out, r.Columns(), batch.OutputColumns(s.Model)))
+	assert.NoError(t, err)
+	assert.Equal(t, 1000, stats.Rows)
+	records, err := csv.NewReader(&out).ReadAll()
+	assert.NoError(t, err)
+	for i, rec := range records[1:] {
+		assert.Equal(t, fmt.Sprint(i), rec[0])
+	}
+}
+
+// slowModel blocks on its first row until it is released.
+type slowModel struct {
+	*regression.RegressionModel
+	release chan struct{}
+}
+
+func (m slowModel) Evaluate(values map[string]interface{}) (map[string]interface{}, error) {
+	if values["age"] == 0.0 {
+		<-m.release
+	}
+	return m.RegressionModel.Evaluate(values)
+}
+
+// pendingRows counts the rows read but not yet written, and releases the slow first row once as many rows
+// are pending as the scorer may hold.
+type pendingRows struct {
+	batch.Reader
+	batch.Writer
+	limit   int
+	release chan struct{}
+	read    int64
+	written int64
+	max     int64
+}
+
+func (p *pendingRows) Read() (batch.Row, error) {
+	pending := p.read - atomic.LoadInt64(&p.written)
+	if pending > p.max {
+		p.max = pending
+	}
+	if p.read == int64(p.limit) {
+		close(p.release)
+	}
+	p.read++
+	return p.Reader.Read()
+}
+
+func (p *pendingRows) Write(inputs map[string]interface{}, outputs map[string]interface{}, err error) error {
+	atomic.AddInt64(&p.written, 1)
+	return p.Writer.Write(inputs, outputs, err)
+}
+
+func TestScoreBoundsPendingRows(t *testing.T) {
+	const workers = 2
+	s := loadScorer(t, workers)
+	release := make(chan struct{})
+	s.Model = slowModel{s.Model.(*regression.RegressionModel), release}
+	var input strings.Builder
+	input.WriteString("age,salary,car_location\n")
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&input, "%d,0,\n", i)
+	}
+	r, err := batch.NewCSVReader(strings.NewReader(input.String()))
+	assert.NoError(t, err)
+	var out bytes.Buffer
+	p := &pendingRows{Reader: r, Writer: batch.NewCSVWriter(&out, r.Columns(), batch.OutputColumns(s.Model)), limit: 2 * workers, release: release}
+	stats, err := s.Score(p, p)
+	assert.NoError(t, err)
+	assert.Equal(t, 1000, stats.Rows)
+	// the rows after the slow one are read up to two per worker ahead of it
+	assert.Equal(t, int64(2*workers), p.max)
+}
+
+func TestCSVWriterColumnsFromFirstResult(t *testing.T) {
+	var out bytes.Buffer
+	w := batch.NewCSVWriter(&out, []string{"x"}, nil)
+	assert.NoError(t, w.Write(map[string]interface{}{"x": "a"}, nil, errors.New("invalid x")))
+	assert.NoError(t, w.Write(map[string]interface{}{"x": "1"}, map[string]interface{}{"y": 0.5}, nil))
+	assert.NoError(t, w.Flush())
+	assert.Equal(t, "x,y,error\na,,invalid x\n1,0.5,\n", out.String())
+
+	// without any result, there are no output columns
+	out.Reset()
+	w = batch.NewCSVWriter(&out, []string{"x"}, nil)
+	assert.NoError(t, w.Write(map[string]interface{}{"x": "a"}, nil, errors.New("invalid x")))
+	assert.NoError(t, w.Flush())
+	assert.Equal(t, "x,error\na,invalid x\n", out.String())
+}
+
+func TestCSVWriterOutputColumnClash(t *testing.T) {
+	var out bytes.Buffer
+	w := batch.NewCSVWriter(&out, []string{"y"}, []string{"y"})
+	assert.NoError(t, w.Write(map[string]interface{}{"y": "1"}, map[string]interface{}{"y": 0.5}, nil))
+	assert.NoError(t, w.Flush())
+	assert.Equal(t, "y,output(y),error\n1,0.5,\n", out.String())
+}
+
+func TestOutputColumns(t *testing.T) {
+	rm := &regression.RegressionModel{}
+	err := xml.Unmarshal([]byte(`<RegressionModel functionName="classification">
+	<MiningSchema>
+		<MiningField name="x"/>
+		<MiningField name="y" usageType="target"/>
+	</MiningSchema>
+	<Output>
+		<OutputField name="p(yes)" feature="probability" value="yes"/>
+		<OutputField name="y" feature="predictedValue"/>
+	</Output>
+	</RegressionModel>`), rm)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"y", "p(yes)"}, batch.OutputColumns(rm))
+}
+
+//nolint
+func BenchmarkScoreCSV(b *testing.B) {
+	s := loadScorer(b, 4)
+	var input strings.Builder
+	input.WriteString("age,salary,car_location\n")
+	for i := 0; i < b.N; i++ {
+		fmt.Fprintf(&input, "%d,1000,carpark\n", i%100)
+	}
+	r, _ := batch.NewCSVReader(strings.NewReader(input.String()))
+	var out bytes.Buffer
+	b.ResetTimer()
+	s.Score(r, batch.NewCSVWriter(&out, r.Columns(), batch.OutputColumns(s.Model)))
+}
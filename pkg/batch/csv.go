@@ -0,0 +1,164 @@
+package batch
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// ErrorColumn holds the error message of rows which failed to evaluate.
+const ErrorColumn = "error"
+
+// CSVReader reads rows from CSV with a header line. Empty cells are treated as missing values.
+type CSVReader struct {
+	r      *csv.Reader
+	header []string
+}
+
+func NewCSVReader(r io.Reader) (*CSVReader, error) {
+	return NewCSVReaderComma(r, ',')
+}
+
+// NewCSVReaderComma reads CSV whose fields are separated by comma.
+func NewCSVReaderComma(r io.Reader, comma rune) (*CSVReader, error) {
+	cr := csv.NewReader(r)
+	cr.Comma = comma
+	// rows with the wrong number of cells are reported per row
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read CSV header")
+	}
+	return &CSVReader{r: cr, header: header}, nil
+}
+
+// Columns returns the header of the CSV input.
+func (r *CSVReader) Columns() []string {
+	return r.header
+}
+
+func (r *CSVReader) Read() (Row, error) {
+	record, err := r.r.Read()
+	if err != nil {
+		return Row{}, err
+	}
+	values := make(map[string]interface{}, len(record))
+	for i, cell := range record {
+		if i < len(r.header) && cell != "" {
+			values[r.header[i]] = cell
+		}
+	}
+	if len(record) != len(r.header) {
+		line, _ := r.r.FieldPos(0)
+		return Row{Values: values, Err: fmt.Errorf("line %d: expected %d fields, found %d", line, len(r.header), len(record))}, nil
+	}
+	return Row{Values: values}, nil
+}
+
+// CSVWriter writes the input columns, then the output columns, then an error column.
+// Output columns which share a name with an input column are written as output(name).
+// Input columns default to the sorted keys of the first row, and output columns to the sorted
+// keys of the outputs of the first row which has any. Rows before it are held until then, or until Flush.
+type CSVWriter struct {
+	InputColumns  []string
+	OutputColumns []string
+	w             *csv.Writer
+	wroteHeader   bool
+	held          []heldRow
+}
+
+// heldRow is a row written before the output columns are known.
+type heldRow struct {
+	inputs map[string]interface{}
+	err    error
+}
+
+func NewCSVWriter(w io.Writer, inputColumns, outputColumns []string) *CSVWriter {
+	return &CSVWriter{InputColumns: inputColumns, OutputColumns: outputColumns, w: csv.NewWriter(w)}
+}
+
+func (cw *CSVWriter) Write(inputs map[string]interface{}, outputs map[string]interface{}, rowErr error) error {
+	if !cw.wroteHeader {
+		if cw.InputColumns == nil {
+			cw.InputColumns = sortedKeys(inputs)
+		}
+		if cw.OutputColumns == nil {
+			// e.g. the row failed, and has no outputs to take the columns from
+			if len(outputs) == 0 {
+				cw.held = append(cw.held, heldRow{inputs: inputs, err: rowErr})
+				return nil
+			}
+			cw.OutputColumns = sortedKeys(outputs)
+		}
+		if err := cw.writeHeader(); err != nil {
+			return err
+		}
+	}
+	return cw.writeRecord(inputs, outputs, rowErr)
+}
+
+// writeHeader writes the header, followed by the rows held until the output columns were known.
+func (cw *CSVWriter) writeHeader() error {
+	header := make([]string, 0, len(cw.InputColumns)+len(cw.OutputColumns)+1)
+	header = append(header, cw.InputColumns...)
+	inputs := make(map[string]bool, len(cw.InputColumns))
+	for _, col := range cw.InputColumns {
+		inputs[col] = true
+	}
+	for _, col := range cw.OutputColumns {
+		// e.g. the target field, when the input holds its actual values
+		if inputs[col] {
+			col = "output(" + col + ")"
+		}
+		header = append(header, col)
+	}
+	header = append(header, ErrorColumn)
+	if err := cw.w.Write(header); err != nil {
+		return err
+	}
+	cw.wroteHeader = true
+	for _, row := range cw.held {
+		if err := cw.writeRecord(row.inputs, nil, row.err); err != nil {
+			return err
+		}
+	}
+	cw.held = nil
+	return nil
+}
+
+func (cw *CSVWriter) writeRecord(inputs map[string]interface{}, outputs map[string]interface{}, rowErr error) error {
+	record := make([]string, 0, len(cw.InputColumns)+len(cw.OutputColumns)+1)
+	for _, col := range cw.InputColumns {
+		record = append(record, formatCell(inputs[col]))
+	}
+	for _, col := range cw.OutputColumns {
+		record = append(record, formatCell(outputs[col]))
+	}
+	if rowErr != nil {
+		record = append(record, rowErr.Error())
+	} else {
+		record = append(record, "")
+	}
+	return cw.w.Write(record)
+}
+
+func (cw *CSVWriter) Flush() error {
+	if !cw.wroteHeader && cw.held != nil {
+		// no row had outputs
+		cw.OutputColumns = []string{}
+		if err := cw.writeHeader(); err != nil {
+			return err
+		}
+	}
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+func formatCell(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
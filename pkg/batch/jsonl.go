@@ -0,0 +1,80 @@
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// JSONLReader reads one JSON object per line. Blank lines are skipped.
+type JSONLReader struct {
+	s    *bufio.Scanner
+	line int
+}
+
+func NewJSONLReader(r io.Reader) *JSONLReader {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	return &JSONLReader{s: s}
+}
+
+func (r *JSONLReader) Read() (Row, error) {
+	for r.s.Scan() {
+		r.line++
+		line := bytes.TrimSpace(r.s.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var values map[string]interface{}
+		if err := json.Unmarshal(line, &values); err != nil {
+			return Row{Err: fmt.Errorf("line %d: %w", r.line, err)}, nil
+		}
+		return Row{Values: values}, nil
+	}
+	if err := r.s.Err(); err != nil {
+		return Row{}, err
+	}
+	return Row{}, io.EOF
+}
+
+// JSONLWriter writes one JSON object per row, holding the inputs and outputs.
+// If OutputColumns is set, only those outputs are written.
+// Rows which failed to evaluate have an error key holding the error message.
+type JSONLWriter struct {
+	OutputColumns []string
+	w             *bufio.Writer
+	enc           *json.Encoder
+}
+
+func NewJSONLWriter(w io.Writer, outputColumns []string) *JSONLWriter {
+	bw := bufio.NewWriter(w)
+	return &JSONLWriter{OutputColumns: outputColumns, w: bw, enc: json.NewEncoder(bw)}
+}
+
+func (jw *JSONLWriter) Write(inputs map[string]interface{}, outputs map[string]interface{}, rowErr error) error {
+	record := make(map[string]interface{}, len(inputs)+len(outputs)+1)
+	for k, v := range inputs {
+		record[k] = v
+	}
+	if jw.OutputColumns != nil {
+		for _, col := range jw.OutputColumns {
+			if v, ok := outputs[col]; ok {
+				record[col] = v
+			}
+		}
+	} else {
+		for k, v := range outputs {
+			record[k] = v
+		}
+	}
+	if rowErr != nil {
+		record[ErrorColumn] = rowErr.Error()
+	}
+	return jw.enc.Encode(record)
+}
+
+func (jw *JSONLWriter) Flush() error {
+	return jw.w.Flush()
+}
@@ -0,0 +1,1010 @@
+// Package bayesiannetwork implements the BayesianNetworkModel element, which predicts its target from the
+// joint distribution of a directed acyclic graph of discrete and continuous nodes.
+package bayesiannetwork
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/stillmatic/pummel/pkg/distributions"
+	"github.com/stillmatic/pummel/pkg/fields"
+	"github.com/stillmatic/pummel/pkg/miningschema"
+	"github.com/stillmatic/pummel/pkg/transformations"
+	"github.com/stillmatic/pummel/pkg/verification"
+)
+
+// BayesianNetworkModel infers its target exactly, by summing the joint probability over the values of
+// the discrete nodes which are missing from the record. Nodes which are missing and have no descendant in
+// the record, nor the target, do not affect the result and are left out. A continuous node cannot be
+// summed over, so it must be in the record if it is needed.
+//
+// The target of a classification is a discrete node, whose categories are given probabilities. The
+// prediction of a regression is the expected value of its continuous target, which must not have
+// children in the record.
+type BayesianNetworkModel struct {
+	XMLName              xml.Name                              `xml:"BayesianNetworkModel"`
+	ModelName            string                                `xml:"modelName,attr"`
+	FunctionName         string                                `xml:"functionName,attr"`
+	AlgorithmName        string                                `xml:"algorithmName,attr"`
+	ModelStructure       string                                `xml:"modelStructure,attr"`
+	InferenceMethod      string                                `xml:"inferenceMethod,attr"`
+	IsScorable           bool                                  `xml:"isScorable,attr"`
+	MiningSchema         *miningschema.MiningSchema            `xml:"MiningSchema"`
+	Output               *fields.Outputs                       `xml:"Output"`
+	LocalTransformations *transformations.LocalTransformations `xml:"LocalTransformations"`
+	DiscreteNodes        []*DiscreteNode                       `xml:"BayesianNetworkNodes>DiscreteNode"`
+	ContinuousNodes      []*ContinuousNode                     `xml:"BayesianNetworkNodes>ContinuousNode"`
+	ModelVerification    *verification.ModelVerification       `xml:"ModelVerification"`
+
+	nodes  map[string]node
+	target node
+	doc    *transformations.Document
+}
+
+// NewBayesianNetworkModel returns an empty BayesianNetworkModel to be decoded into, whose expressions belong to doc.
+func NewBayesianNetworkModel(doc *transformations.Document) *BayesianNetworkModel {
+	return &BayesianNetworkModel{doc: doc}
+}
+
+// node is implemented by DiscreteNode and ContinuousNode.
+type node interface {
+	name() string
+	// parents returns the names of the nodes the distribution of the node depends on.
+	parents() []string
+	// probability returns the probability, or the density, of the value of the node in values, given the
+	// values of its parents there.
+	probability(values map[string]interface{}) (float64, error)
+}
+
+// DiscreteNode holds the probability of each value of a node, either unconditionally in
+// ValueProbabilities, or for each combination of the values of its parents.
+type DiscreteNode struct {
+	XMLName                  xml.Name                          `xml:"DiscreteNode"`
+	Name                     string                            `xml:"name,attr"`
+	Count                    float64                           `xml:"count,attr"`
+	DerivedFields            []*derivedField                   `xml:"DerivedField"`
+	ConditionalProbabilities []*DiscreteConditionalProbability `xml:"DiscreteConditionalProbability"`
+	ValueProbabilities       []*ValueProbability               `xml:"ValueProbability"`
+
+	// values are the values of the node, in the order they first appear.
+	values []string
+}
+
+type DiscreteConditionalProbability struct {
+	XMLName            xml.Name            `xml:"DiscreteConditionalProbability"`
+	Count              float64             `xml:"count,attr"`
+	ParentValues       []*ParentValue      `xml:"ParentValue"`
+	ValueProbabilities []*ValueProbability `xml:"ValueProbability"`
+}
+
+type ValueProbability struct {
+	XMLName     xml.Name `xml:"ValueProbability"`
+	Value       string   `xml:"value,attr"`
+	Probability float64  `xml:"probability,attr"`
+}
+
+// ParentValue selects the rows of a conditional probability table by the value of a discrete parent.
+type ParentValue struct {
+	XMLName xml.Name `xml:"ParentValue"`
+	Parent  string   `xml:"parent,attr"`
+	Value   string   `xml:"value,attr"`
+}
+
+// ContinuousNode holds the distribution of a node, either unconditionally, or for each combination of the
+// values of its discrete parents. The parameters of the distributions may depend on continuous parents.
+type ContinuousNode struct {
+	XMLName                  xml.Name                            `xml:"ContinuousNode"`
+	Name                     string                              `xml:"name,attr"`
+	Count                    float64                             `xml:"count,attr"`
+	DerivedFields            []*derivedField                     `xml:"DerivedField"`
+	ConditionalProbabilities []*ContinuousConditionalProbability `xml:"ContinuousConditionalProbability"`
+	Distributions            []*ContinuousDistribution           `xml:"ContinuousDistribution"`
+
+	doc *transformations.Document
+}
+
+type ContinuousConditionalProbability struct {
+	XMLName       xml.Name                  `xml:"ContinuousConditionalProbability"`
+	Count         float64                   `xml:"count,attr"`
+	ParentValues  []*ParentValue            `xml:"ParentValue"`
+	Distributions []*ContinuousDistribution `xml:"ContinuousDistribution"`
+
+	doc *transformations.Document
+}
+
+// derivedField is decoded so that nodes with one can be rejected.
+type derivedField struct {
+	Name string `xml:"name,attr"`
+}
+
+// ContinuousDistribution is a normal, lognormal, uniform or triangular distribution whose parameters are
+// expressions. The Mean and Variance of a lognormal distribution are those of the logarithm of the value,
+// and the Mean of a triangular distribution is its mode.
+type ContinuousDistribution struct {
+	XMLName  xml.Name
+	Type     string
+	Mean     transformations.Expression
+	Variance transformations.Expression
+	Lower    transformations.Expression
+	Upper    transformations.Expression
+
+	doc *transformations.Document
+}
+
+var DistributionTypes = struct {
+	Normal     string
+	Lognormal  string
+	Uniform    string
+	Triangular string
+}{
+	Normal:     "NormalDistributionForBN",
+	Lognormal:  "LognormalDistributionForBN",
+	Uniform:    "UniformDistributionForBN",
+	Triangular: "TriangularDistributionForBN",
+}
+
+func (m *BayesianNetworkModel) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*m = BayesianNetworkModel{XMLName: start.Name, doc: m.doc}
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "modelName":
+			m.ModelName = attr.Value
+		case "functionName":
+			m.FunctionName = attr.Value
+		case "algorithmName":
+			m.AlgorithmName = attr.Value
+		case "modelStructure":
+			m.ModelStructure = attr.Value
+		case "inferenceMethod":
+			m.InferenceMethod = attr.Value
+		case "isScorable":
+			m.IsScorable = attr.Value == "true"
+		}
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "MiningSchema":
+				var ms miningschema.MiningSchema
+				if err := d.DecodeElement(&ms, &tt); err != nil {
+					return err
+				}
+				m.MiningSchema = &ms
+			case "Output":
+				out := fields.NewOutputs(m.doc)
+				if err := d.DecodeElement(out, &tt); err != nil {
+					return err
+				}
+				m.Output = out
+			case "LocalTransformations":
+				lt := m.doc.NewLocalTransformations()
+				if err := d.DecodeElement(lt, &tt); err != nil {
+					return err
+				}
+				m.LocalTransformations = lt
+			case "BayesianNetworkNodes":
+				if err := m.decodeNodes(d); err != nil {
+					return err
+				}
+			case "ModelVerification":
+				var mv verification.ModelVerification
+				if err := d.DecodeElement(&mv, &tt); err != nil {
+					return err
+				}
+				m.ModelVerification = &mv
+			case "Extension", "ModelStats", "ModelExplanation":
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unknown element: %s", tt.Name.Local)
+			}
+		case xml.EndElement:
+			return m.resolve()
+		}
+	}
+}
+
+// decodeNodes decodes the nodes of BayesianNetworkNodes.
+func (m *BayesianNetworkModel) decodeNodes(d *xml.Decoder) error {
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "DiscreteNode":
+				var n DiscreteNode
+				if err := d.DecodeElement(&n, &tt); err != nil {
+					return err
+				}
+				m.DiscreteNodes = append(m.DiscreteNodes, &n)
+			case "ContinuousNode":
+				n := ContinuousNode{doc: m.doc}
+				if err := d.DecodeElement(&n, &tt); err != nil {
+					return err
+				}
+				m.ContinuousNodes = append(m.ContinuousNodes, &n)
+			default:
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+func (cn *ContinuousNode) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*cn = ContinuousNode{XMLName: start.Name, doc: cn.doc}
+	for _, attr := range start.Attr {
+		var err error
+		switch attr.Name.Local {
+		case "name":
+			cn.Name = attr.Value
+		case "count":
+			cn.Count, err = strconv.ParseFloat(attr.Value, 64)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s of ContinuousNode %s", attr.Name.Local, cn.Name)
+		}
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "DerivedField":
+				var df derivedField
+				if err := d.DecodeElement(&df, &tt); err != nil {
+					return err
+				}
+				cn.DerivedFields = append(cn.DerivedFields, &df)
+			case "ContinuousConditionalProbability":
+				ccp := ContinuousConditionalProbability{doc: cn.doc}
+				if err := d.DecodeElement(&ccp, &tt); err != nil {
+					return err
+				}
+				cn.ConditionalProbabilities = append(cn.ConditionalProbabilities, &ccp)
+			case "ContinuousDistribution":
+				cd := ContinuousDistribution{doc: cn.doc}
+				if err := d.DecodeElement(&cd, &tt); err != nil {
+					return err
+				}
+				cn.Distributions = append(cn.Distributions, &cd)
+			default:
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+func (ccp *ContinuousConditionalProbability) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*ccp = ContinuousConditionalProbability{XMLName: start.Name, doc: ccp.doc}
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "count" {
+			var err error
+			if ccp.Count, err = strconv.ParseFloat(attr.Value, 64); err != nil {
+				return errors.Wrap(err, "invalid count of ContinuousConditionalProbability")
+			}
+		}
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "ParentValue":
+				var pv ParentValue
+				if err := d.DecodeElement(&pv, &tt); err != nil {
+					return err
+				}
+				ccp.ParentValues = append(ccp.ParentValues, &pv)
+			case "ContinuousDistribution":
+				cd := ContinuousDistribution{doc: ccp.doc}
+				if err := d.DecodeElement(&cd, &tt); err != nil {
+					return err
+				}
+				ccp.Distributions = append(ccp.Distributions, &cd)
+			default:
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+func (cd *ContinuousDistribution) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*cd = ContinuousDistribution{XMLName: start.Name, doc: cd.doc}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case DistributionTypes.Normal, DistributionTypes.Lognormal, DistributionTypes.Uniform, DistributionTypes.Triangular:
+				if cd.Type != "" {
+					return errors.New("ContinuousDistribution has several distributions")
+				}
+				cd.Type = tt.Name.Local
+				if err := cd.decodeParameters(d); err != nil {
+					return errors.Wrapf(err, "invalid %s", cd.Type)
+				}
+			case "Extension":
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unsupported distribution: %s", tt.Name.Local)
+			}
+		case xml.EndElement:
+			return cd.check()
+		}
+	}
+}
+
+// decodeParameters decodes the expression of each parameter of a distribution.
+func (cd *ContinuousDistribution) decodeParameters(d *xml.Decoder) error {
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			var param *transformations.Expression
+			switch tt.Name.Local {
+			case "Mean":
+				param = &cd.Mean
+			case "Variance":
+				param = &cd.Variance
+			case "Lower":
+				param = &cd.Lower
+			case "Upper":
+				param = &cd.Upper
+			case "Extension":
+				if err := d.Skip(); err != nil {
+					return err
+				}
+				continue
+			default:
+				return fmt.Errorf("unknown parameter: %s", tt.Name.Local)
+			}
+			if *param, err = decodeExpression(d, tt.Name.Local, cd.doc); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// decodeExpression decodes the single expression of a parameter element.
+func decodeExpression(d *xml.Decoder, name string, doc *transformations.Document) (transformations.Expression, error) {
+	var expr transformations.Expression
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			e := doc.NewExpression(tt.Name.Local)
+			if e == nil {
+				if tt.Name.Local == "Extension" {
+					if err := d.Skip(); err != nil {
+						return nil, err
+					}
+					continue
+				}
+				return nil, fmt.Errorf("unsupported expression in %s: %s", name, tt.Name.Local)
+			}
+			if err := d.DecodeElement(e, &tt); err != nil {
+				return nil, errors.Wrapf(err, "invalid %s", name)
+			}
+			expr = e
+		case xml.EndElement:
+			if expr == nil {
+				return nil, fmt.Errorf("%s has no expression", name)
+			}
+			return expr, nil
+		}
+	}
+}
+
+// check makes sure a distribution has the parameters of its type.
+func (cd *ContinuousDistribution) check() error {
+	if cd.Type == "" {
+		return errors.New("ContinuousDistribution has no distribution")
+	}
+	var missing string
+	switch cd.Type {
+	case DistributionTypes.Normal, DistributionTypes.Lognormal:
+		if cd.Mean == nil {
+			missing = "Mean"
+		} else if cd.Variance == nil {
+			missing = "Variance"
+		}
+	case DistributionTypes.Uniform:
+		if cd.Lower == nil {
+			missing = "Lower"
+		} else if cd.Upper == nil {
+			missing = "Upper"
+		}
+	case DistributionTypes.Triangular:
+		if cd.Mean == nil {
+			missing = "Mean"
+		} else if cd.Lower == nil {
+			missing = "Lower"
+		} else if cd.Upper == nil {
+			missing = "Upper"
+		}
+	}
+	if missing != "" {
+		return fmt.Errorf("%s has no %s", cd.Type, missing)
+	}
+	return nil
+}
+
+// resolve indexes the nodes, checks that their parents exist and finds the target.
+func (m *BayesianNetworkModel) resolve() error {
+	if m.MiningSchema == nil {
+		return errors.New("BayesianNetworkModel has no MiningSchema")
+	}
+	m.nodes = make(map[string]node)
+	var all []node
+	for _, n := range m.DiscreteNodes {
+		all = append(all, n)
+		if len(n.DerivedFields) > 0 {
+			return fmt.Errorf("DerivedField of node %s is not supported", n.Name)
+		}
+		if len(n.ConditionalProbabilities) == 0 && len(n.ValueProbabilities) == 0 {
+			return fmt.Errorf("DiscreteNode %s has no probabilities", n.Name)
+		}
+		seen := make(map[string]bool)
+		tables := [][]*ValueProbability{n.ValueProbabilities}
+		for _, cp := range n.ConditionalProbabilities {
+			tables = append(tables, cp.ValueProbabilities)
+		}
+		for _, vps := range tables {
+			for _, vp := range vps {
+				if !seen[vp.Value] {
+					seen[vp.Value] = true
+					n.values = append(n.values, vp.Value)
+				}
+			}
+		}
+	}
+	for _, n := range m.ContinuousNodes {
+		all = append(all, n)
+		if len(n.DerivedFields) > 0 {
+			return fmt.Errorf("DerivedField of node %s is not supported", n.Name)
+		}
+		if len(n.ConditionalProbabilities) == 0 && len(n.Distributions) == 0 {
+			return fmt.Errorf("ContinuousNode %s has no distribution", n.Name)
+		}
+		if len(n.Distributions) > 1 {
+			return fmt.Errorf("ContinuousNode %s has %d distributions, expected 1", n.Name, len(n.Distributions))
+		}
+		for _, cp := range n.ConditionalProbabilities {
+			if len(cp.Distributions) != 1 {
+				return fmt.Errorf("ContinuousConditionalProbability of node %s has %d distributions, expected 1", n.Name, len(cp.Distributions))
+			}
+		}
+	}
+	for _, n := range all {
+		if _, ok := m.nodes[n.name()]; ok {
+			return fmt.Errorf("BayesianNetworkModel has several nodes named %s", n.name())
+		}
+		m.nodes[n.name()] = n
+	}
+	for _, n := range all {
+		for _, p := range n.parents() {
+			if _, ok := m.nodes[p]; !ok {
+				return fmt.Errorf("node %s refers to unknown parent %s", n.name(), p)
+			}
+		}
+	}
+	if err := m.checkAcyclic(); err != nil {
+		return err
+	}
+
+	target := m.GetOutputField()
+	var ok bool
+	if m.target, ok = m.nodes[target]; !ok {
+		return fmt.Errorf("target %q is not a node of the network", target)
+	}
+	switch m.FunctionName {
+	case "classification":
+		if _, ok := m.target.(*DiscreteNode); !ok {
+			return fmt.Errorf("target %s of a classification must be a DiscreteNode", target)
+		}
+	case "regression":
+		if _, ok := m.target.(*ContinuousNode); !ok {
+			return fmt.Errorf("target %s of a regression must be a ContinuousNode", target)
+		}
+	default:
+		return fmt.Errorf("unknown model type: %s", m.FunctionName)
+	}
+	return nil
+}
+
+// checkAcyclic makes sure no node is its own ancestor.
+func (m *BayesianNetworkModel) checkAcyclic() error {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int)
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("node %s is its own ancestor", name)
+		case done:
+			return nil
+		}
+		state[name] = visiting
+		for _, p := range m.nodes[name].parents() {
+			if err := visit(p); err != nil {
+				return err
+			}
+		}
+		state[name] = done
+		return nil
+	}
+	for _, names := range [][]string{m.discreteNames(), m.continuousNames()} {
+		for _, name := range names {
+			if err := visit(name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (m *BayesianNetworkModel) discreteNames() []string {
+	names := make([]string, len(m.DiscreteNodes))
+	for i, n := range m.DiscreteNodes {
+		names[i] = n.Name
+	}
+	return names
+}
+
+func (m *BayesianNetworkModel) continuousNames() []string {
+	names := make([]string, len(m.ContinuousNodes))
+	for i, n := range m.ContinuousNodes {
+		names[i] = n.Name
+	}
+	return names
+}
+
+func (n *DiscreteNode) name() string {
+	return n.Name
+}
+
+func (n *DiscreteNode) parents() []string {
+	return parentNames(n.conditionalParents(), nil)
+}
+
+func (n *DiscreteNode) conditionalParents() [][]*ParentValue {
+	rows := make([][]*ParentValue, len(n.ConditionalProbabilities))
+	for i, cp := range n.ConditionalProbabilities {
+		rows[i] = cp.ParentValues
+	}
+	return rows
+}
+
+func (n *DiscreteNode) probability(values map[string]interface{}) (float64, error) {
+	vps := n.ValueProbabilities
+	if len(n.ConditionalProbabilities) > 0 {
+		vps = nil
+		for _, cp := range n.ConditionalProbabilities {
+			if matches(cp.ParentValues, values) {
+				vps = cp.ValueProbabilities
+				break
+			}
+		}
+		if vps == nil {
+			return 0, fmt.Errorf("node %s has no probabilities for the values of its parents", n.Name)
+		}
+	}
+	for _, vp := range vps {
+		if transformations.EqualsValue(values[n.Name], vp.Value) {
+			return vp.Probability, nil
+		}
+	}
+	return 0, nil
+}
+
+func (n *ContinuousNode) name() string {
+	return n.Name
+}
+
+func (n *ContinuousNode) parents() []string {
+	rows := make([][]*ParentValue, len(n.ConditionalProbabilities))
+	dists := n.Distributions
+	for i, cp := range n.ConditionalProbabilities {
+		rows[i] = cp.ParentValues
+		dists = append(dists, cp.Distributions...)
+	}
+	var exprs []transformations.Expression
+	for _, cd := range dists {
+		exprs = append(exprs, cd.Mean, cd.Variance, cd.Lower, cd.Upper)
+	}
+	return parentNames(rows, exprs)
+}
+
+// distribution returns the distribution of the node given the values of its parents.
+func (n *ContinuousNode) distribution(values map[string]interface{}) (distributions.Distribution, error) {
+	var cd *ContinuousDistribution
+	if len(n.ConditionalProbabilities) == 0 {
+		cd = n.Distributions[0]
+	}
+	for _, cp := range n.ConditionalProbabilities {
+		if matches(cp.ParentValues, values) {
+			cd = cp.Distributions[0]
+			break
+		}
+	}
+	if cd == nil {
+		return nil, fmt.Errorf("node %s has no distribution for the values of its parents", n.Name)
+	}
+	dist, err := cd.evaluate(values)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to compute the distribution of node %s", n.Name)
+	}
+	return dist, nil
+}
+
+func (n *ContinuousNode) probability(values map[string]interface{}) (float64, error) {
+	dist, err := n.distribution(values)
+	if err != nil {
+		return 0, err
+	}
+	x, err := transformations.InterfaceToFloat64(values[n.Name])
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid value of node %s", n.Name)
+	}
+	return dist.Probability(x), nil
+}
+
+// evaluate computes the parameters of a distribution.
+func (cd *ContinuousDistribution) evaluate(values map[string]interface{}) (distributions.Distribution, error) {
+	params := make(map[string]float64, 3)
+	for name, expr := range map[string]transformations.Expression{
+		"Mean": cd.Mean, "Variance": cd.Variance, "Lower": cd.Lower, "Upper": cd.Upper,
+	} {
+		if expr == nil {
+			continue
+		}
+		val, err := expr.Transform(values)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to compute %s", name)
+		}
+		if params[name], err = transformations.InterfaceToFloat64(val); err != nil {
+			return nil, errors.Wrapf(err, "invalid %s", name)
+		}
+	}
+	switch cd.Type {
+	case DistributionTypes.Normal, DistributionTypes.Lognormal:
+		if params["Variance"] <= 0 {
+			return nil, fmt.Errorf("variance must be positive, got %v", params["Variance"])
+		}
+		if cd.Type == DistributionTypes.Lognormal {
+			return &distributions.LognormalDistribution{Mean: params["Mean"], Variance: params["Variance"]}, nil
+		}
+		return &distributions.GaussianDistribution{Mean: params["Mean"], Variance: params["Variance"]}, nil
+	}
+	if params["Upper"] <= params["Lower"] {
+		return nil, fmt.Errorf("upper bound %v must be greater than lower bound %v", params["Upper"], params["Lower"])
+	}
+	if cd.Type == DistributionTypes.Uniform {
+		return &distributions.UniformDistribution{Lower: params["Lower"], Upper: params["Upper"]}, nil
+	}
+	if params["Mean"] < params["Lower"] || params["Mean"] > params["Upper"] {
+		return nil, fmt.Errorf("mode %v must be between the bounds", params["Mean"])
+	}
+	return &distributions.TriangularDistribution{Lower: params["Lower"], Mode: params["Mean"], Upper: params["Upper"]}, nil
+}
+
+// expectation returns the expected value of a distribution.
+func expectation(dist distributions.Distribution) float64 {
+	switch d := dist.(type) {
+	case *distributions.GaussianDistribution:
+		return d.Mean
+	case *distributions.LognormalDistribution:
+		return math.Exp(d.Mean + d.Variance/2)
+	case *distributions.UniformDistribution:
+		return (d.Lower + d.Upper) / 2
+	case *distributions.TriangularDistribution:
+		return (d.Lower + d.Mode + d.Upper) / 3
+	}
+	return math.NaN()
+}
+
+// matches reports whether the values of the parents of a row of a conditional probability table are those
+// in values.
+func matches(pvs []*ParentValue, values map[string]interface{}) bool {
+	for _, pv := range pvs {
+		if !transformations.EqualsValue(values[pv.Parent], pv.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// parentNames lists, once each, the parents of the rows of a table and the fields the expressions refer to.
+func parentNames(rows [][]*ParentValue, exprs []transformations.Expression) []string {
+	var names []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, pvs := range rows {
+		for _, pv := range pvs {
+			add(pv.Parent)
+		}
+	}
+	var walk func(expr transformations.Expression)
+	walk = func(expr transformations.Expression) {
+		switch e := expr.(type) {
+		case *transformations.FieldRef:
+			add(e.Field)
+		case *transformations.Apply:
+			for _, child := range e.Children {
+				walk(*child)
+			}
+		}
+	}
+	for _, expr := range exprs {
+		walk(expr)
+	}
+	return names
+}
+
+// relevant returns the nodes the result depends on: the target, the nodes in the record, and their ancestors.
+func (m *BayesianNetworkModel) relevant(observed map[string]bool) []node {
+	needed := make(map[string]bool)
+	var visit func(name string)
+	visit = func(name string) {
+		if needed[name] {
+			return
+		}
+		needed[name] = true
+		for _, p := range m.nodes[name].parents() {
+			visit(p)
+		}
+	}
+	visit(m.target.name())
+	var nodes []node
+	for _, names := range [][]string{m.discreteNames(), m.continuousNames()} {
+		for _, name := range names {
+			if observed[name] {
+				visit(name)
+			}
+		}
+	}
+	for _, names := range [][]string{m.discreteNames(), m.continuousNames()} {
+		for _, name := range names {
+			if needed[name] {
+				nodes = append(nodes, m.nodes[name])
+			}
+		}
+	}
+	return nodes
+}
+
+// enumerate calls f with every combination of the values of the hidden discrete nodes, set in values.
+func enumerate(hidden []*DiscreteNode, values map[string]interface{}, f func() error) error {
+	if len(hidden) == 0 {
+		return f()
+	}
+	n := hidden[0]
+	for _, v := range n.values {
+		values[n.Name] = v
+		if err := enumerate(hidden[1:], values, f); err != nil {
+			return err
+		}
+	}
+	delete(values, n.Name)
+	return nil
+}
+
+// joint returns the product of the probabilities of nodes given values.
+func joint(nodes []node, values map[string]interface{}) (float64, error) {
+	p := 1.0
+	for _, n := range nodes {
+		q, err := n.probability(values)
+		if err != nil {
+			return 0, err
+		}
+		if p *= q; p == 0 {
+			break
+		}
+	}
+	return p, nil
+}
+
+func (m *BayesianNetworkModel) Evaluate(values map[string]interface{}) (map[string]interface{}, error) {
+	out, err := m.evaluate(values)
+	return m.Output.Complete(values, out, err)
+}
+
+func (m *BayesianNetworkModel) evaluate(values map[string]interface{}) (map[string]interface{}, error) {
+	if m.LocalTransformations != nil {
+		for _, tr := range m.LocalTransformations.DerivedFields {
+			val, err := tr.Transform(values)
+			if err != nil {
+				return nil, err
+			}
+			values[tr.RequiredField()] = val
+		}
+	}
+	target := m.target.name()
+	evidence := make(map[string]interface{}, len(m.nodes))
+	observed := make(map[string]bool, len(m.nodes))
+	for name := range m.nodes {
+		if v := values[name]; v != nil && name != target {
+			evidence[name] = v
+			observed[name] = true
+		}
+	}
+	nodes := m.relevant(observed)
+	var hidden []*DiscreteNode
+	var others []node
+	for _, n := range nodes {
+		if n.name() == target {
+			continue
+		}
+		others = append(others, n)
+		if observed[n.name()] {
+			continue
+		}
+		dn, ok := n.(*DiscreteNode)
+		if !ok {
+			return nil, fmt.Errorf("continuous node %s is missing", n.name())
+		}
+		hidden = append(hidden, dn)
+	}
+	if m.FunctionName == "regression" {
+		return m.expectedValue(others, hidden, evidence)
+	}
+	return m.posterior(nodes, hidden, evidence)
+}
+
+// posterior returns the probability of each category of the target given the evidence.
+func (m *BayesianNetworkModel) posterior(nodes []node, hidden []*DiscreteNode, evidence map[string]interface{}) (map[string]interface{}, error) {
+	target := m.target.(*DiscreteNode)
+	probabilities := make([]float64, len(target.values))
+	var sum float64
+	for i, v := range target.values {
+		evidence[target.Name] = v
+		err := enumerate(hidden, evidence, func() error {
+			p, err := joint(nodes, evidence)
+			probabilities[i] += p
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		sum += probabilities[i]
+	}
+	if sum == 0 {
+		return nil, errors.New("the record has zero probability in the BayesianNetworkModel")
+	}
+
+	top := 0
+	out := make(map[string]interface{}, len(probabilities)+1)
+	for i, v := range target.values {
+		if probabilities[i] > probabilities[top] {
+			top = i
+		}
+		name := v
+		if m.Output != nil {
+			if of, err := m.Output.GetFeature(v); err == nil {
+				name = of.Name
+			}
+		}
+		out[name] = probabilities[i] / sum
+	}
+	predicted := target.values[top]
+	out[target.Name] = predicted
+	if m.Output != nil {
+		for _, of := range m.Output.OutputFields {
+			if of.Feature == "predictedValue" {
+				out[of.Name] = predicted
+			}
+		}
+	}
+	return out, nil
+}
+
+// expectedValue returns the expected value of the continuous target, averaging its expectation given each
+// combination of the hidden nodes by the probability of the combination.
+func (m *BayesianNetworkModel) expectedValue(others []node, hidden []*DiscreteNode, evidence map[string]interface{}) (map[string]interface{}, error) {
+	target := m.target.(*ContinuousNode)
+	for _, n := range others {
+		for _, p := range n.parents() {
+			if p == target.Name {
+				return nil, fmt.Errorf("target %s has a child in the record, which is not supported", target.Name)
+			}
+		}
+	}
+	var sum, weighted float64
+	err := enumerate(hidden, evidence, func() error {
+		p, err := joint(others, evidence)
+		if err != nil || p == 0 {
+			return err
+		}
+		dist, err := target.distribution(evidence)
+		if err != nil {
+			return err
+		}
+		sum += p
+		weighted += p * expectation(dist)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if sum == 0 {
+		return nil, errors.New("the record has zero probability in the BayesianNetworkModel")
+	}
+
+	predicted := weighted / sum
+	out := map[string]interface{}{target.Name: predicted}
+	if m.Output != nil {
+		for _, of := range m.Output.OutputFields {
+			if of.Feature == "predictedValue" {
+				out[of.Name] = predicted
+			}
+		}
+	}
+	return out, nil
+}
+
+func (m *BayesianNetworkModel) GetOutputField() string {
+	return m.MiningSchema.GetOutputField()
+}
+
+func (m *BayesianNetworkModel) GetMiningSchema() *miningschema.MiningSchema {
+	return m.MiningSchema
+}
+
+func (m *BayesianNetworkModel) GetOutput() *fields.Outputs {
+	return m.Output
+}
+
+func (m *BayesianNetworkModel) GetModelVerification() *verification.ModelVerification {
+	return m.ModelVerification
+}
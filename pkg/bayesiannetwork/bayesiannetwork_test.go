@@ -0,0 +1,228 @@
+package bayesiannetwork_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/stillmatic/pummel/pkg/bayesiannetwork"
+	"github.com/stretchr/testify/assert"
+)
+
+var sprinklerXML = `<BayesianNetworkModel functionName="classification" modelStructure="directed" inferenceMethod="Exact">
+	<MiningSchema>
+		<MiningField name="rain" usageType="target"/>
+		<MiningField name="sprinkler"/>
+		<MiningField name="wet"/>
+		<MiningField name="temperature"/>
+		<MiningField name="humidity"/>
+	</MiningSchema>
+	<Output>
+		<OutputField name="prediction" feature="predictedValue"/>
+		<OutputField name="P(rain)" feature="probability" value="yes"/>
+	</Output>
+	<BayesianNetworkNodes>
+		<DiscreteNode name="rain">
+			<ValueProbability value="yes" probability="0.2"/>
+			<ValueProbability value="no" probability="0.8"/>
+		</DiscreteNode>
+		<DiscreteNode name="sprinkler">
+			<DiscreteConditionalProbability>
+				<ParentValue parent="rain" value="yes"/>
+				<ValueProbability value="on" probability="0.01"/>
+				<ValueProbability value="off" probability="0.99"/>
+			</DiscreteConditionalProbability>
+			<DiscreteConditionalProbability>
+				<ParentValue parent="rain" value="no"/>
+				<ValueProbability value="on" probability="0.4"/>
+				<ValueProbability value="off" probability="0.6"/>
+			</DiscreteConditionalProbability>
+		</DiscreteNode>
+		<DiscreteNode name="wet">
+			<DiscreteConditionalProbability>
+				<ParentValue parent="rain" value="yes"/>
+				<ParentValue parent="sprinkler" value="on"/>
+				<ValueProbability value="yes" probability="0.99"/>
+				<ValueProbability value="no" probability="0.01"/>
+			</DiscreteConditionalProbability>
+			<DiscreteConditionalProbability>
+				<ParentValue parent="rain" value="yes"/>
+				<ParentValue parent="sprinkler" value="off"/>
+				<ValueProbability value="yes" probability="0.8"/>
+				<ValueProbability value="no" probability="0.2"/>
+			</DiscreteConditionalProbability>
+			<DiscreteConditionalProbability>
+				<ParentValue parent="rain" value="no"/>
+				<ParentValue parent="sprinkler" value="on"/>
+				<ValueProbability value="yes" probability="0.9"/>
+				<ValueProbability value="no" probability="0.1"/>
+			</DiscreteConditionalProbability>
+			<DiscreteConditionalProbability>
+				<ParentValue parent="rain" value="no"/>
+				<ParentValue parent="sprinkler" value="off"/>
+				<ValueProbability value="yes" probability="0.05"/>
+				<ValueProbability value="no" probability="0.95"/>
+			</DiscreteConditionalProbability>
+		</DiscreteNode>
+		<ContinuousNode name="temperature">
+			<ContinuousConditionalProbability>
+				<ParentValue parent="rain" value="yes"/>
+				<ContinuousDistribution>
+					<NormalDistributionForBN>
+						<Mean><Constant dataType="double">15</Constant></Mean>
+						<Variance><Constant dataType="double">4</Constant></Variance>
+					</NormalDistributionForBN>
+				</ContinuousDistribution>
+			</ContinuousConditionalProbability>
+			<ContinuousConditionalProbability>
+				<ParentValue parent="rain" value="no"/>
+				<ContinuousDistribution>
+					<NormalDistributionForBN>
+						<Mean><Constant dataType="double">22</Constant></Mean>
+						<Variance><Constant dataType="double">9</Constant></Variance>
+					</NormalDistributionForBN>
+				</ContinuousDistribution>
+			</ContinuousConditionalProbability>
+		</ContinuousNode>
+		<ContinuousNode name="humidity">
+			<ContinuousDistribution>
+				<NormalDistributionForBN>
+					<Mean>
+						<Apply function="+">
+							<Apply function="*">
+								<Constant dataType="double">0.5</Constant>
+								<FieldRef field="temperature"/>
+							</Apply>
+							<Constant dataType="double">40</Constant>
+						</Apply>
+					</Mean>
+					<Variance><Constant dataType="double">25</Constant></Variance>
+				</NormalDistributionForBN>
+			</ContinuousDistribution>
+		</ContinuousNode>
+	</BayesianNetworkNodes>
+</BayesianNetworkModel>`
+
+func TestBayesianNetworkClassification(t *testing.T) {
+	var m bayesiannetwork.BayesianNetworkModel
+	err := xml.Unmarshal([]byte(sprinklerXML), &m)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(m.DiscreteNodes))
+	assert.Equal(t, 2, len(m.ContinuousNodes))
+
+	tcs := []struct {
+		values   map[string]interface{}
+		expected float64
+	}{
+		// without evidence, the prior of the target
+		{map[string]interface{}{}, 0.2},
+		// the sprinkler is summed over, and the missing humidity is left out
+		{map[string]interface{}{"wet": "yes", "temperature": 18.0}, 0.3784568693290333},
+		// the humidity depends on the rain only through the temperature
+		{map[string]interface{}{"wet": "yes", "temperature": 18.0, "humidity": 50.0}, 0.3784568693290333},
+		{map[string]interface{}{"sprinkler": "on"}, 0.006211180124223601},
+		{map[string]interface{}{"temperature": 18.0, "sprinkler": "off", "wet": "no"}, 0.0932730880714721},
+	}
+	for _, tc := range tcs {
+		out, err := m.Evaluate(tc.values)
+		assert.NoError(t, err)
+		assert.InDelta(t, tc.expected, out["P(rain)"], 1e-12)
+		assert.InDelta(t, 1-tc.expected, out["no"], 1e-12)
+		assert.Equal(t, "no", out["prediction"])
+		assert.Equal(t, "no", out["rain"])
+	}
+
+	// the temperature cannot be summed over when the humidity is known
+	_, err = m.Evaluate(map[string]interface{}{"humidity": 50.0})
+	assert.EqualError(t, err, "continuous node temperature is missing")
+	// values without a probability make the record impossible
+	_, err = m.Evaluate(map[string]interface{}{"sprinkler": "broken"})
+	assert.EqualError(t, err, "the record has zero probability in the BayesianNetworkModel")
+}
+
+func TestBayesianNetworkRegression(t *testing.T) {
+	regression := strings.Replace(sprinklerXML, `functionName="classification"`, `functionName="regression"`, 1)
+	regression = strings.Replace(regression, `<MiningField name="rain" usageType="target"/>`, `<MiningField name="rain"/>`, 1)
+	regression = strings.Replace(regression, `<MiningField name="temperature"/>`, `<MiningField name="temperature" usageType="target"/>`, 1)
+	var m bayesiannetwork.BayesianNetworkModel
+	err := xml.Unmarshal([]byte(regression), &m)
+	assert.NoError(t, err)
+
+	out, err := m.Evaluate(map[string]interface{}{"wet": "yes"})
+	assert.NoError(t, err)
+	// the means given rain and no rain, weighted by their probabilities given wet grass
+	assert.InDelta(t, 19.62339641813794, out["temperature"], 1e-12)
+	assert.InDelta(t, 19.62339641813794, out["prediction"], 1e-12)
+	out, err = m.Evaluate(map[string]interface{}{"wet": "yes", "sprinkler": "on"})
+	assert.NoError(t, err)
+	assert.InDelta(t, 21.952203600248296, out["temperature"], 1e-12)
+
+	_, err = m.Evaluate(map[string]interface{}{"humidity": 50.0})
+	assert.EqualError(t, err, "target temperature has a child in the record, which is not supported")
+}
+
+func TestContinuousDistributions(t *testing.T) {
+	tcs := []struct {
+		distribution string
+		expected     float64
+	}{
+		{`<LognormalDistributionForBN>
+			<Mean><Constant dataType="double">0</Constant></Mean>
+			<Variance><Constant dataType="double">1</Constant></Variance>
+		</LognormalDistributionForBN>`, 1.6487212707001282},
+		{`<UniformDistributionForBN>
+			<Lower><Constant dataType="double">10</Constant></Lower>
+			<Upper><Constant dataType="double">20</Constant></Upper>
+		</UniformDistributionForBN>`, 15},
+		{`<TriangularDistributionForBN>
+			<Mean><Constant dataType="double">12</Constant></Mean>
+			<Lower><Constant dataType="double">10</Constant></Lower>
+			<Upper><Constant dataType="double">20</Constant></Upper>
+		</TriangularDistributionForBN>`, 14},
+	}
+	for _, tc := range tcs {
+		model := `<BayesianNetworkModel functionName="regression">
+	<MiningSchema>
+		<MiningField name="y" usageType="target"/>
+	</MiningSchema>
+	<BayesianNetworkNodes>
+		<ContinuousNode name="y">
+			<ContinuousDistribution>` + tc.distribution + `</ContinuousDistribution>
+		</ContinuousNode>
+	</BayesianNetworkNodes>
+</BayesianNetworkModel>`
+		var m bayesiannetwork.BayesianNetworkModel
+		err := xml.Unmarshal([]byte(model), &m)
+		assert.NoError(t, err)
+		out, err := m.Evaluate(map[string]interface{}{})
+		assert.NoError(t, err)
+		assert.InDelta(t, tc.expected, out["y"], 1e-12)
+	}
+}
+
+func TestBayesianNetworkErrors(t *testing.T) {
+	tcs := []struct {
+		old, new string
+		expected string
+	}{
+		{`<ParentValue parent="rain" value="yes"/>
+				<ValueProbability value="on"`, `<ParentValue parent="snow" value="yes"/>
+				<ValueProbability value="on"`, "node sprinkler refers to unknown parent snow"},
+		{`<DiscreteNode name="rain">
+			<ValueProbability value="yes" probability="0.2"/>`, `<DiscreteNode name="rain">
+			<DiscreteConditionalProbability><ParentValue parent="wet" value="yes"/></DiscreteConditionalProbability>
+			<ValueProbability value="yes" probability="0.2"/>`, "node rain is its own ancestor"},
+		{`<MiningField name="rain" usageType="target"/>`, `<MiningField name="rain"/><MiningField name="fog" usageType="target"/>`, `target "fog" is not a node of the network`},
+		{`functionName="classification"`, `functionName="clustering"`, "unknown model type: clustering"},
+		{`<Mean><Constant dataType="double">15</Constant></Mean>`, ``, "NormalDistributionForBN has no Mean"},
+		{`<NormalDistributionForBN>`, `<PoissonDistributionForBN>`, "unsupported distribution: PoissonDistributionForBN"},
+		{`<DiscreteNode name="wet">`, `<DiscreteNode name="wet"><DerivedField name="x"/>`, "DerivedField of node wet is not supported"},
+		{`<DiscreteNode name="rain">`, `<DiscreteNode name="humidity">`, "BayesianNetworkModel has several nodes named humidity"},
+		{`<BayesianNetworkNodes>`, `<Targets/><BayesianNetworkNodes>`, "unknown element: Targets"},
+	}
+	for _, tc := range tcs {
+		var m bayesiannetwork.BayesianNetworkModel
+		err := xml.Unmarshal([]byte(strings.Replace(sprinklerXML, tc.old, tc.new, 1)), &m)
+		assert.EqualError(t, err, tc.expected, tc.new)
+	}
+}
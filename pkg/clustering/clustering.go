@@ -0,0 +1,467 @@
+// Package clustering implements the ClusteringModel element, which assigns a record to the cluster whose
+// center is the closest to it by the model's ComparisonMeasure.
+package clustering
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/stillmatic/pummel/pkg/array"
+	"github.com/stillmatic/pummel/pkg/comparisonmeasure"
+	"github.com/stillmatic/pummel/pkg/fields"
+	"github.com/stillmatic/pummel/pkg/miningschema"
+	"github.com/stillmatic/pummel/pkg/transformations"
+	"github.com/stillmatic/pummel/pkg/verification"
+)
+
+type ClusteringModel struct {
+	XMLName       xml.Name `xml:"ClusteringModel"`
+	ModelName     string   `xml:"modelName,attr"`
+	FunctionName  string   `xml:"functionName,attr"`
+	AlgorithmName string   `xml:"algorithmName,attr"`
+	// ModelClass is centerBased or distributionBased.
+	ModelClass           string                                `xml:"modelClass,attr"`
+	NumberOfClusters     int                                   `xml:"numberOfClusters,attr"`
+	IsScorable           bool                                  `xml:"isScorable,attr"`
+	MiningSchema         *miningschema.MiningSchema            `xml:"MiningSchema"`
+	Output               *fields.Outputs                       `xml:"Output"`
+	LocalTransformations *transformations.LocalTransformations `xml:"LocalTransformations"`
+	ComparisonMeasure    *comparisonmeasure.ComparisonMeasure  `xml:"ComparisonMeasure"`
+	ClusteringFields     []*ClusteringField                    `xml:"ClusteringField"`
+	// MissingValueWeights has a weight for each center field, which scales up the measure of records with
+	// missing values.
+	MissingValueWeights []float64
+	Clusters            []*Cluster                      `xml:"Cluster"`
+	ModelVerification   *verification.ModelVerification `xml:"ModelVerification"`
+
+	// centerFields are the ClusteringFields which are part of the cluster centers, and measures their
+	// comparisons.
+	centerFields []*ClusteringField
+	measures     []comparisonmeasure.Field
+	doc          *transformations.Document
+}
+
+// NewClusteringModel returns an empty ClusteringModel to be decoded into, whose expressions belong to doc.
+func NewClusteringModel(doc *transformations.Document) *ClusteringModel {
+	return &ClusteringModel{doc: doc}
+}
+
+var ModelClasses = struct {
+	CenterBased       string
+	DistributionBased string
+}{
+	CenterBased:       "centerBased",
+	DistributionBased: "distributionBased",
+}
+
+// ClusteringField is a field compared with the cluster centers. Fields which are not center fields
+// are only used to describe the clusters.
+type ClusteringField struct {
+	XMLName         xml.Name `xml:"ClusteringField"`
+	Field           string   `xml:"field,attr"`
+	IsCenterField   bool     `xml:"isCenterField,attr"`
+	FieldWeight     float64  `xml:"fieldWeight,attr"`
+	SimilarityScale *float64 `xml:"similarityScale,attr"`
+	// CompareFunction overrides the compare function of the ComparisonMeasure, if set.
+	CompareFunction string `xml:"compareFunction,attr"`
+}
+
+// Cluster is a cluster of the model. Its ID defaults to its 1-based position in the model.
+type Cluster struct {
+	XMLName xml.Name `xml:"Cluster"`
+	ID      string   `xml:"id,attr"`
+	Name    string   `xml:"name,attr"`
+	Size    int      `xml:"size,attr"`
+	// Center holds the value of each center field, as a number or a string.
+	Center []interface{}
+	// Means holds the mean of each numeric field of the cluster's Partition, which is its center
+	// if it has no Array.
+	Means map[string]float64
+}
+
+func (m *ClusteringModel) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	m.XMLName = start.Name
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "modelName":
+			m.ModelName = attr.Value
+		case "functionName":
+			m.FunctionName = attr.Value
+		case "algorithmName":
+			m.AlgorithmName = attr.Value
+		case "modelClass":
+			m.ModelClass = attr.Value
+		case "numberOfClusters":
+			n, err := strconv.Atoi(attr.Value)
+			if err != nil {
+				return errors.Wrap(err, "invalid numberOfClusters of ClusteringModel")
+			}
+			m.NumberOfClusters = n
+		case "isScorable":
+			m.IsScorable = attr.Value == "true"
+		}
+	}
+	switch m.ModelClass {
+	case ModelClasses.CenterBased, ModelClasses.DistributionBased:
+	default:
+		return fmt.Errorf("unknown model class: %q", m.ModelClass)
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "MiningSchema":
+				var ms miningschema.MiningSchema
+				if err := d.DecodeElement(&ms, &tt); err != nil {
+					return err
+				}
+				m.MiningSchema = &ms
+			case "Output":
+				out := fields.NewOutputs(m.doc)
+				if err := d.DecodeElement(out, &tt); err != nil {
+					return err
+				}
+				m.Output = out
+			case "LocalTransformations":
+				lt := m.doc.NewLocalTransformations()
+				if err := d.DecodeElement(lt, &tt); err != nil {
+					return err
+				}
+				m.LocalTransformations = lt
+			case "ComparisonMeasure":
+				var cm comparisonmeasure.ComparisonMeasure
+				if err := d.DecodeElement(&cm, &tt); err != nil {
+					return err
+				}
+				m.ComparisonMeasure = &cm
+			case "ClusteringField":
+				var cf ClusteringField
+				if err := d.DecodeElement(&cf, &tt); err != nil {
+					return err
+				}
+				m.ClusteringFields = append(m.ClusteringFields, &cf)
+			case "MissingValueWeights":
+				m.MissingValueWeights, err = decodeMissingValueWeights(d)
+				if err != nil {
+					return err
+				}
+			case "Cluster":
+				var c Cluster
+				if err := d.DecodeElement(&c, &tt); err != nil {
+					return err
+				}
+				m.Clusters = append(m.Clusters, &c)
+			case "ModelVerification":
+				var mv verification.ModelVerification
+				if err := d.DecodeElement(&mv, &tt); err != nil {
+					return err
+				}
+				m.ModelVerification = &mv
+			case "Extension", "ModelStats", "ModelExplanation":
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unknown element: %s", tt.Name.Local)
+			}
+		case xml.EndElement:
+			return m.prepare()
+		}
+	}
+}
+
+func (cf *ClusteringField) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*cf = ClusteringField{XMLName: start.Name, IsCenterField: true, FieldWeight: 1}
+	for _, attr := range start.Attr {
+		var err error
+		switch attr.Name.Local {
+		case "field":
+			cf.Field = attr.Value
+		case "isCenterField":
+			cf.IsCenterField = attr.Value != "false"
+		case "fieldWeight":
+			cf.FieldWeight, err = strconv.ParseFloat(attr.Value, 64)
+		case "similarityScale":
+			var scale float64
+			scale, err = strconv.ParseFloat(attr.Value, 64)
+			cf.SimilarityScale = &scale
+		case "compareFunction":
+			cf.CompareFunction = attr.Value
+			err = comparisonmeasure.CheckCompareFunction(attr.Value)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s of ClusteringField %s", attr.Name.Local, cf.Field)
+		}
+	}
+	// Comparisons is only used by the table compare function
+	return d.Skip()
+}
+
+func decodeMissingValueWeights(d *xml.Decoder) ([]float64, error) {
+	var res []float64
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			if !array.IsNumeric(tt.Name.Local) {
+				if err := d.Skip(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			res, err = array.DecodeFloats(d, tt, 0)
+			if err != nil {
+				return nil, errors.Wrap(err, "invalid MissingValueWeights")
+			}
+		case xml.EndElement:
+			return res, nil
+		}
+	}
+}
+
+func (c *Cluster) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	c.XMLName = start.Name
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "id":
+			c.ID = attr.Value
+		case "name":
+			c.Name = attr.Value
+		case "size":
+			size, err := strconv.Atoi(attr.Value)
+			if err != nil {
+				return errors.Wrap(err, "invalid size of Cluster")
+			}
+			c.Size = size
+		}
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "Array":
+				var a array.Array
+				if err := d.DecodeElement(&a, &tt); err != nil {
+					return err
+				}
+				c.Center = make([]interface{}, len(a.Values))
+				for i, v := range a.Values {
+					c.Center[i] = v
+					if a.Type != "string" {
+						if f, err := strconv.ParseFloat(v, 64); err == nil {
+							c.Center[i] = f
+						}
+					}
+				}
+			case "Partition":
+				c.Means, err = decodePartitionMeans(d, tt)
+				if err != nil {
+					return err
+				}
+			case "Extension", "KohonenMap", "Covariances":
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unexpected element in Cluster: %s", tt.Name.Local)
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// decodePartitionMeans returns the mean of each field of a Partition with NumericInfo.
+func decodePartitionMeans(d *xml.Decoder, start xml.StartElement) (map[string]float64, error) {
+	var p struct {
+		FieldStats []struct {
+			Field       string `xml:"field,attr"`
+			NumericInfo *struct {
+				Mean *float64 `xml:"mean,attr"`
+			} `xml:"NumericInfo"`
+		} `xml:"PartitionFieldStats"`
+	}
+	if err := d.DecodeElement(&p, &start); err != nil {
+		return nil, err
+	}
+	means := make(map[string]float64)
+	for _, fs := range p.FieldStats {
+		if fs.NumericInfo != nil && fs.NumericInfo.Mean != nil {
+			means[fs.Field] = *fs.NumericInfo.Mean
+		}
+	}
+	return means, nil
+}
+
+// prepare checks the clusters against the center fields. The clusters of a distribution-based model
+// without an Array are centered on the means of their Partition.
+func (m *ClusteringModel) prepare() error {
+	if m.ComparisonMeasure == nil {
+		return errors.New("ClusteringModel has no ComparisonMeasure")
+	}
+	if len(m.Clusters) == 0 {
+		return errors.New("ClusteringModel has no Cluster")
+	}
+	for _, cf := range m.ClusteringFields {
+		if !cf.IsCenterField {
+			continue
+		}
+		m.centerFields = append(m.centerFields, cf)
+		m.measures = append(m.measures, comparisonmeasure.Field{
+			CompareFunction: cf.CompareFunction,
+			Weight:          cf.FieldWeight,
+			SimilarityScale: cf.SimilarityScale,
+		})
+	}
+	n := len(m.centerFields)
+	if n == 0 {
+		return errors.New("ClusteringModel has no center fields")
+	}
+	if m.MissingValueWeights != nil && len(m.MissingValueWeights) != n {
+		return fmt.Errorf("MissingValueWeights has %d weights for %d center fields", len(m.MissingValueWeights), n)
+	}
+	for i, c := range m.Clusters {
+		if c.ID == "" {
+			c.ID = strconv.Itoa(i + 1)
+		}
+		if c.Center == nil && m.ModelClass == ModelClasses.DistributionBased && c.Means != nil {
+			for _, cf := range m.centerFields {
+				mean, ok := c.Means[cf.Field]
+				if !ok {
+					return fmt.Errorf("Partition of cluster %s has no mean of field %s", c.ID, cf.Field)
+				}
+				c.Center = append(c.Center, mean)
+			}
+		}
+		if len(c.Center) != n {
+			return fmt.Errorf("cluster %s has %d center values for %d center fields", c.ID, len(c.Center), n)
+		}
+	}
+	return nil
+}
+
+// Evaluate assigns the record to a cluster. The result is missing if every center field is.
+func (m *ClusteringModel) Evaluate(values map[string]interface{}) (map[string]interface{}, error) {
+	out, err := m.evaluate(values)
+	return m.Output.Complete(values, out, err)
+}
+
+func (m *ClusteringModel) evaluate(values map[string]interface{}) (map[string]interface{}, error) {
+	if m.LocalTransformations != nil {
+		for _, tr := range m.LocalTransformations.DerivedFields {
+			val, err := tr.Transform(values)
+			if err != nil {
+				return nil, err
+			}
+			values[tr.RequiredField()] = val
+		}
+	}
+	x := make([]interface{}, len(m.centerFields))
+	for i, cf := range m.centerFields {
+		x[i] = values[cf.Field]
+	}
+	affinities := make([]float64, len(m.Clusters))
+	for i, c := range m.Clusters {
+		affinity, ok, err := m.ComparisonMeasure.Compare(m.measures, x, c.Center, m.MissingValueWeights)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot compare with cluster %s", c.ID)
+		}
+		if !ok {
+			return nil, nil
+		}
+		affinities[i] = affinity
+	}
+	// ranked holds the positions of the clusters, from the closest to the farthest
+	ranked := make([]int, len(m.Clusters))
+	for i := range ranked {
+		ranked[i] = i
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return m.ComparisonMeasure.Better(affinities[ranked[i]], affinities[ranked[j]])
+	})
+	winner := m.Clusters[ranked[0]]
+
+	out := make(map[string]interface{})
+	if target := m.GetOutputField(); target != "" {
+		out[target] = winner.ID
+	}
+	if m.Output == nil {
+		return out, nil
+	}
+	for _, of := range m.Output.OutputFields {
+		rank := of.Rank
+		if rank == 0 {
+			rank = 1
+		}
+		// nth is the position of the rank-th closest cluster, or -1 if there are fewer clusters
+		nth := -1
+		if rank <= len(ranked) {
+			nth = ranked[rank-1]
+		}
+		switch of.Feature {
+		case "predictedValue":
+			out[of.Name] = winner.ID
+		case "predictedDisplayValue":
+			if winner.Name != "" {
+				out[of.Name] = winner.Name
+			} else {
+				out[of.Name] = winner.ID
+			}
+		case "entityId", "clusterId":
+			switch {
+			case nth < 0:
+				out[of.Name] = nil
+			case of.Feature == "entityId":
+				out[of.Name] = m.Clusters[nth].ID
+			default:
+				out[of.Name] = nth + 1
+			}
+		case "clusterAffinity":
+			out[of.Name] = affinities[ranked[0]]
+		case "affinity":
+			out[of.Name] = nil
+			if of.Value == "" {
+				if nth >= 0 {
+					out[of.Name] = affinities[nth]
+				}
+				continue
+			}
+			for i, c := range m.Clusters {
+				if c.ID == of.Value {
+					out[of.Name] = affinities[i]
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+func (m *ClusteringModel) GetOutputField() string {
+	return m.MiningSchema.GetOutputField()
+}
+
+func (m *ClusteringModel) GetMiningSchema() *miningschema.MiningSchema {
+	return m.MiningSchema
+}
+
+func (m *ClusteringModel) GetOutput() *fields.Outputs {
+	return m.Output
+}
+
+func (m *ClusteringModel) GetModelVerification() *verification.ModelVerification {
+	return m.ModelVerification
+}
@@ -0,0 +1,175 @@
+package clustering_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/stillmatic/pummel/pkg/clustering"
+	"github.com/stretchr/testify/assert"
+)
+
+var kmeansXML = `<ClusteringModel functionName="clustering" modelClass="centerBased" numberOfClusters="3">
+	<MiningSchema>
+		<MiningField name="x"/>
+		<MiningField name="y"/>
+		<MiningField name="label"/>
+	</MiningSchema>
+	<Output>
+		<OutputField name="cluster" feature="predictedValue"/>
+		<OutputField name="display" feature="predictedDisplayValue"/>
+		<OutputField name="second" feature="entityId" rank="2"/>
+		<OutputField name="second_index" feature="clusterId" rank="2"/>
+		<OutputField name="fourth" feature="entityId" rank="4"/>
+		<OutputField name="distance" feature="clusterAffinity"/>
+		<OutputField name="distance_3" feature="affinity" value="3"/>
+		<OutputField name="distance_last" feature="affinity" rank="3"/>
+	</Output>
+	<ComparisonMeasure kind="distance">
+		<squaredEuclidean/>
+	</ComparisonMeasure>
+	<ClusteringField field="x"/>
+	<ClusteringField field="label" isCenterField="false"/>
+	<ClusteringField field="y"/>
+	<Cluster id="low" name="Low">
+		<Array n="2" type="real">0 0</Array>
+	</Cluster>
+	<Cluster>
+		<Array n="2" type="real">5 5</Array>
+	</Cluster>
+	<Cluster name="far">
+		<Array n="2" type="real">10 0</Array>
+	</Cluster>
+</ClusteringModel>`
+
+func TestClusteringModel(t *testing.T) {
+	var m clustering.ClusteringModel
+	err := xml.Unmarshal([]byte(kmeansXML), &m)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(m.Clusters))
+	assert.Equal(t, "2", m.Clusters[1].ID)
+
+	out, err := m.Evaluate(map[string]interface{}{"x": 1, "y": 1.0})
+	assert.NoError(t, err)
+	assert.Equal(t, "low", out["cluster"])
+	assert.Equal(t, "Low", out["display"])
+	assert.Equal(t, "2", out["second"])
+	assert.Equal(t, 2, out["second_index"])
+	assert.Nil(t, out["fourth"])
+	assert.Equal(t, 2.0, out["distance"])
+	assert.Equal(t, 82.0, out["distance_3"])
+	assert.Equal(t, 82.0, out["distance_last"])
+
+	out, err = m.Evaluate(map[string]interface{}{"x": "9", "y": 1})
+	assert.NoError(t, err)
+	assert.Equal(t, "3", out["cluster"])
+	assert.Equal(t, "far", out["display"])
+	assert.Equal(t, "2", out["second"])
+
+	// the missing x doubles the distance along y
+	out, err = m.Evaluate(map[string]interface{}{"y": 5})
+	assert.NoError(t, err)
+	assert.Equal(t, "2", out["cluster"])
+	assert.Equal(t, 50.0, out["distance_3"])
+
+	out, err = m.Evaluate(map[string]interface{}{"label": "a"})
+	assert.NoError(t, err)
+	assert.Nil(t, out)
+}
+
+func TestClusteringModelSimilarity(t *testing.T) {
+	xmlData := `<ClusteringModel functionName="clustering" modelClass="distributionBased">
+		<MiningSchema>
+			<MiningField name="income"/>
+			<MiningField name="region"/>
+			<MiningField name="segment" usageType="target"/>
+		</MiningSchema>
+		<ComparisonMeasure kind="similarity" compareFunction="gaussSim">
+			<cityBlock/>
+		</ComparisonMeasure>
+		<ClusteringField field="income" similarityScale="10"/>
+		<ClusteringField field="region" compareFunction="equal" fieldWeight="0.5"/>
+		<MissingValueWeights>
+			<Array type="real">1 0.5</Array>
+		</MissingValueWeights>
+		<Cluster id="a">
+			<Array type="string">20 "north east"</Array>
+		</Cluster>
+		<Cluster id="b">
+			<Array type="string">40 south</Array>
+		</Cluster>
+	</ClusteringModel>`
+	var m clustering.ClusteringModel
+	err := xml.Unmarshal([]byte(xmlData), &m)
+	assert.NoError(t, err)
+
+	tcs := []struct {
+		inputs   map[string]interface{}
+		expected string
+	}{
+		{map[string]interface{}{"income": 25.0, "region": "south"}, "a"},
+		{map[string]interface{}{"income": 35.0, "region": "north east"}, "b"},
+		{map[string]interface{}{"income": 31.0, "region": "north east"}, "a"},
+		{map[string]interface{}{"income": 31.0}, "b"},
+		{map[string]interface{}{"region": "south"}, "b"},
+	}
+	for _, tc := range tcs {
+		out, err := m.Evaluate(tc.inputs)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected, out["segment"], tc.inputs)
+	}
+}
+
+func TestClusteringModelPartition(t *testing.T) {
+	xmlData := `<ClusteringModel functionName="clustering" modelClass="distributionBased">
+		<MiningSchema>
+			<MiningField name="x"/>
+		</MiningSchema>
+		<Output>
+			<OutputField name="cluster" feature="predictedValue"/>
+		</Output>
+		<ComparisonMeasure kind="distance">
+			<euclidean/>
+		</ComparisonMeasure>
+		<ClusteringField field="x"/>
+		<Cluster id="small">
+			<Partition name="p1">
+				<PartitionFieldStats field="x">
+					<NumericInfo mean="1" standardDeviation="0.5"/>
+				</PartitionFieldStats>
+			</Partition>
+		</Cluster>
+		<Cluster id="large">
+			<Partition name="p2">
+				<PartitionFieldStats field="x">
+					<NumericInfo mean="10" standardDeviation="2"/>
+				</PartitionFieldStats>
+			</Partition>
+		</Cluster>
+	</ClusteringModel>`
+	var m clustering.ClusteringModel
+	err := xml.Unmarshal([]byte(xmlData), &m)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{10.0}, m.Clusters[1].Center)
+
+	out, err := m.Evaluate(map[string]interface{}{"x": 6})
+	assert.NoError(t, err)
+	assert.Equal(t, "large", out["cluster"])
+}
+
+func TestClusteringModelErrors(t *testing.T) {
+	tcs := []struct {
+		old, new string
+	}{
+		{`modelClass="centerBased"`, `modelClass="hierarchical"`},
+		{`<ComparisonMeasure kind="distance">`, `<ComparisonMeasure kind="closeness">`},
+		{`<ClusteringField field="x"/>`, `<ClusteringField field="x" compareFunction="table"/>`},
+		{`<Array n="2" type="real">5 5</Array>`, `<Array type="real">5</Array>`},
+		{`<ClusteringField field="y"/>`, `<ClusteringField field="y"/><MissingValueWeights><Array type="real">1</Array></MissingValueWeights>`},
+	}
+	for _, tc := range tcs {
+		var m clustering.ClusteringModel
+		err := xml.Unmarshal([]byte(strings.Replace(kmeansXML, tc.old, tc.new, 1)), &m)
+		assert.Error(t, err, tc.new)
+	}
+}
@@ -0,0 +1,353 @@
+// Package comparisonmeasure implements PMML's ComparisonMeasure, which measures how close a record is to
+// a reference point, such as the center of a cluster or a training instance, field by field.
+package comparisonmeasure
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/stillmatic/pummel/pkg/transformations"
+)
+
+var Kinds = struct {
+	Distance   string
+	Similarity string
+}{
+	Distance:   "distance",
+	Similarity: "similarity",
+}
+
+var CompareFunctions = struct {
+	AbsDiff  string
+	GaussSim string
+	Delta    string
+	Equal    string
+	Table    string
+}{
+	AbsDiff:  "absDiff",
+	GaussSim: "gaussSim",
+	Delta:    "delta",
+	Equal:    "equal",
+	Table:    "table",
+}
+
+var Measures = struct {
+	Euclidean        string
+	SquaredEuclidean string
+	Chebychev        string
+	CityBlock        string
+	Minkowski        string
+	SimpleMatching   string
+	Jaccard          string
+	Tanimoto         string
+	BinarySimilarity string
+}{
+	Euclidean:        "euclidean",
+	SquaredEuclidean: "squaredEuclidean",
+	Chebychev:        "chebychev",
+	CityBlock:        "cityBlock",
+	Minkowski:        "minkowski",
+	SimpleMatching:   "simpleMatching",
+	Jaccard:          "jaccard",
+	Tanimoto:         "tanimoto",
+	BinarySimilarity: "binarySimilarity",
+}
+
+// ComparisonMeasure combines the comparisons of each field into a single distance or similarity.
+// Distances are smaller for closer records, and similarities larger.
+type ComparisonMeasure struct {
+	XMLName xml.Name `xml:"ComparisonMeasure"`
+	// Kind is distance or similarity.
+	Kind string
+	// CompareFunction compares the values of a field, unless the Field sets its own.
+	CompareFunction string
+	Minimum         *float64
+	Maximum         *float64
+	// Measure is the name of the measure element, e.g. euclidean.
+	Measure string
+	// P is the p-parameter of minkowski.
+	P float64
+	// Parameters holds the c00-parameter to d11-parameter of binarySimilarity, keyed without the
+	// -parameter suffix.
+	Parameters map[string]float64
+}
+
+// Field is a field compared by the measure.
+type Field struct {
+	// CompareFunction overrides the compare function of the measure, if set.
+	CompareFunction string
+	Weight          float64
+	// SimilarityScale is the distance at which gaussSim is one half.
+	SimilarityScale *float64
+}
+
+func (cm *ComparisonMeasure) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*cm = ComparisonMeasure{XMLName: start.Name, CompareFunction: CompareFunctions.AbsDiff}
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "kind":
+			cm.Kind = attr.Value
+		case "compareFunction":
+			cm.CompareFunction = attr.Value
+		case "minimum", "maximum":
+			val, err := strconv.ParseFloat(attr.Value, 64)
+			if err != nil {
+				return errors.Wrapf(err, "invalid %s of ComparisonMeasure", attr.Name.Local)
+			}
+			if attr.Name.Local == "minimum" {
+				cm.Minimum = &val
+			} else {
+				cm.Maximum = &val
+			}
+		}
+	}
+	switch cm.Kind {
+	case Kinds.Distance, Kinds.Similarity:
+	default:
+		return fmt.Errorf("unknown kind of ComparisonMeasure: %q", cm.Kind)
+	}
+	if err := CheckCompareFunction(cm.CompareFunction); err != nil {
+		return err
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case Measures.Euclidean, Measures.SquaredEuclidean, Measures.Chebychev, Measures.CityBlock,
+				Measures.Minkowski, Measures.SimpleMatching, Measures.Jaccard, Measures.Tanimoto, Measures.BinarySimilarity:
+				if cm.Measure != "" {
+					return fmt.Errorf("ComparisonMeasure has more than one measure: %s and %s", cm.Measure, tt.Name.Local)
+				}
+				if err := cm.decodeMeasure(tt); err != nil {
+					return err
+				}
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			case "Extension":
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unexpected element in ComparisonMeasure: %s", tt.Name.Local)
+			}
+		case xml.EndElement:
+			if cm.Measure == "" {
+				return errors.New("ComparisonMeasure has no measure")
+			}
+			return nil
+		}
+	}
+}
+
+func (cm *ComparisonMeasure) decodeMeasure(start xml.StartElement) error {
+	cm.Measure = start.Name.Local
+	params := make(map[string]float64)
+	for _, attr := range start.Attr {
+		val, err := strconv.ParseFloat(attr.Value, 64)
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s of %s", attr.Name.Local, cm.Measure)
+		}
+		params[attr.Name.Local] = val
+	}
+	switch cm.Measure {
+	case Measures.Minkowski:
+		p, ok := params["p-parameter"]
+		if !ok {
+			return errors.New("minkowski requires the p-parameter attribute")
+		}
+		if p <= 0 {
+			return fmt.Errorf("p-parameter of minkowski must be positive, got %v", p)
+		}
+		cm.P = p
+	case Measures.BinarySimilarity:
+		cm.Parameters = make(map[string]float64)
+		for _, name := range []string{"c00", "c01", "c10", "c11", "d00", "d01", "d10", "d11"} {
+			p, ok := params[name+"-parameter"]
+			if !ok {
+				return fmt.Errorf("binarySimilarity requires the %s-parameter attribute", name)
+			}
+			cm.Parameters[name] = p
+		}
+	}
+	return nil
+}
+
+// CheckCompareFunction returns an error if name is not a compare function pummel can evaluate. table,
+// which looks up the comparison of two categories in a matrix, is not supported.
+func CheckCompareFunction(name string) error {
+	switch name {
+	case CompareFunctions.AbsDiff, CompareFunctions.GaussSim, CompareFunctions.Delta, CompareFunctions.Equal:
+		return nil
+	case CompareFunctions.Table:
+		return fmt.Errorf("unsupported compare function: %s", name)
+	}
+	return fmt.Errorf("unknown compare function: %s", name)
+}
+
+// IsBinary reports whether the measure counts matching and mismatching binary values instead of
+// comparing the values of each field.
+func (cm *ComparisonMeasure) IsBinary() bool {
+	switch cm.Measure {
+	case Measures.SimpleMatching, Measures.Jaccard, Measures.Tanimoto, Measures.BinarySimilarity:
+		return true
+	}
+	return false
+}
+
+// Better reports whether the measure a is closer than b.
+func (cm *ComparisonMeasure) Better(a, b float64) bool {
+	if cm.Kind == Kinds.Similarity {
+		return a > b
+	}
+	return a < b
+}
+
+// Compare measures x against the reference point y, both holding a value for each of fields. Values may be
+// numbers or strings, and nil values are missing. Fields missing from x or y are left out of the measure, which
+// is scaled up by the missing value weights q, one per field: by the sum of all weights over the sum of the
+// weights of the fields present in both. q may be nil, in which case every field weighs 1.
+// Compare returns false if no field is present in both.
+func (cm *ComparisonMeasure) Compare(fields []Field, x, y []interface{}, q []float64) (float64, bool, error) {
+	if cm.IsBinary() {
+		return cm.compareBinary(x, y)
+	}
+	var res, total, present float64
+	for i, f := range fields {
+		weight := 1.0
+		if q != nil {
+			weight = q[i]
+		}
+		total += weight
+		if x[i] == nil || y[i] == nil {
+			continue
+		}
+		present += weight
+		c, err := cm.compareValues(f, x[i], y[i])
+		if err != nil {
+			return 0, false, errors.Wrapf(err, "cannot compare field %d", i+1)
+		}
+		switch cm.Measure {
+		case Measures.Euclidean, Measures.SquaredEuclidean:
+			res += f.Weight * c * c
+		case Measures.Chebychev:
+			res = math.Max(res, f.Weight*c)
+		case Measures.CityBlock:
+			res += f.Weight * c
+		case Measures.Minkowski:
+			res += f.Weight * math.Pow(c, cm.P)
+		}
+	}
+	if present == 0 {
+		return 0, false, nil
+	}
+	res *= total / present
+	switch cm.Measure {
+	case Measures.Euclidean:
+		res = math.Sqrt(res)
+	case Measures.Minkowski:
+		res = math.Pow(res, 1/cm.P)
+	}
+	return res, true, nil
+}
+
+func (cm *ComparisonMeasure) compareValues(f Field, x, y interface{}) (float64, error) {
+	compareFunction := f.CompareFunction
+	if compareFunction == "" {
+		compareFunction = cm.CompareFunction
+	}
+	switch compareFunction {
+	case CompareFunctions.Delta:
+		if equal(x, y) {
+			return 0, nil
+		}
+		return 1, nil
+	case CompareFunctions.Equal:
+		if equal(x, y) {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	a, err := transformations.InterfaceToFloat64(x)
+	if err != nil {
+		return 0, err
+	}
+	b, err := transformations.InterfaceToFloat64(y)
+	if err != nil {
+		return 0, err
+	}
+	z := math.Abs(a - b)
+	if compareFunction == CompareFunctions.GaussSim {
+		if f.SimilarityScale == nil {
+			return 0, errors.New("gaussSim requires a similarityScale")
+		}
+		s := *f.SimilarityScale
+		return math.Exp(-math.Ln2 * z * z / (s * s)), nil
+	}
+	return z, nil
+}
+
+func equal(x, y interface{}) bool {
+	if s, ok := y.(string); ok {
+		return transformations.EqualsValue(x, s)
+	}
+	return transformations.EqualsValue(x, fmt.Sprint(y))
+}
+
+// compareBinary counts the fields where x and y are both 1, only one of them is, or neither is,
+// and combines the counts into a similarity. Missing values are left out.
+func (cm *ComparisonMeasure) compareBinary(x, y []interface{}) (float64, bool, error) {
+	var a00, a01, a10, a11 float64
+	var present bool
+	for i := range x {
+		if x[i] == nil || y[i] == nil {
+			continue
+		}
+		present = true
+		a, err := transformations.InterfaceToFloat64(x[i])
+		if err != nil {
+			return 0, false, errors.Wrapf(err, "cannot compare field %d", i+1)
+		}
+		b, err := transformations.InterfaceToFloat64(y[i])
+		if err != nil {
+			return 0, false, errors.Wrapf(err, "cannot compare field %d", i+1)
+		}
+		switch {
+		case a != 0 && b != 0:
+			a11++
+		case a != 0:
+			a10++
+		case b != 0:
+			a01++
+		default:
+			a00++
+		}
+	}
+	if !present {
+		return 0, false, nil
+	}
+	var num, den float64
+	switch cm.Measure {
+	case Measures.SimpleMatching:
+		num, den = a11+a00, a11+a10+a01+a00
+	case Measures.Jaccard:
+		num, den = a11, a11+a10+a01
+	case Measures.Tanimoto:
+		num, den = a11+a00, a11+2*(a10+a01)+a00
+	case Measures.BinarySimilarity:
+		p := cm.Parameters
+		num = p["c11"]*a11 + p["c10"]*a10 + p["c01"]*a01 + p["c00"]*a00
+		den = p["d11"]*a11 + p["d10"]*a10 + p["d01"]*a01 + p["d00"]*a00
+	}
+	if den == 0 {
+		return 0, false, nil
+	}
+	return num / den, true, nil
+}
@@ -0,0 +1,110 @@
+package comparisonmeasure_test
+
+import (
+	"encoding/xml"
+	"math"
+	"testing"
+
+	"github.com/stillmatic/pummel/pkg/comparisonmeasure"
+	"github.com/stretchr/testify/assert"
+)
+
+func decode(t *testing.T, s string) *comparisonmeasure.ComparisonMeasure {
+	t.Helper()
+	var cm comparisonmeasure.ComparisonMeasure
+	err := xml.Unmarshal([]byte(s), &cm)
+	assert.NoError(t, err)
+	return &cm
+}
+
+func TestDistances(t *testing.T) {
+	fields := []comparisonmeasure.Field{{Weight: 1}, {Weight: 1}}
+	y := []interface{}{4.0, 6.0}
+	tcs := []struct {
+		measure  string
+		x        []interface{}
+		q        []float64
+		expected float64
+	}{
+		{`<euclidean/>`, []interface{}{1.0, 2.0}, nil, 5},
+		{`<squaredEuclidean/>`, []interface{}{1.0, 2.0}, nil, 25},
+		{`<chebychev/>`, []interface{}{1.0, 2.0}, nil, 4},
+		{`<cityBlock/>`, []interface{}{1.0, "2"}, nil, 7},
+		{`<minkowski p-parameter="3"/>`, []interface{}{1.0, 2.0}, nil, math.Cbrt(91)},
+		// the missing field is made up for by scaling by 2/1, or by 4/3 with the missing value weights
+		{`<euclidean/>`, []interface{}{nil, 2.0}, nil, math.Sqrt(32)},
+		{`<squaredEuclidean/>`, []interface{}{nil, 2.0}, []float64{1, 3}, 16 * 4.0 / 3},
+	}
+	for _, tc := range tcs {
+		cm := decode(t, `<ComparisonMeasure kind="distance">`+tc.measure+`</ComparisonMeasure>`)
+		d, ok, err := cm.Compare(fields, tc.x, y, tc.q)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.InDelta(t, tc.expected, d, 1e-12, tc.measure)
+	}
+
+	cm := decode(t, `<ComparisonMeasure kind="distance"><squaredEuclidean/></ComparisonMeasure>`)
+	_, ok, err := cm.Compare(fields, []interface{}{nil, nil}, y, nil)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.True(t, cm.Better(1, 2))
+}
+
+func TestCompareFunctions(t *testing.T) {
+	scale := 3.0
+	fields := []comparisonmeasure.Field{
+		{Weight: 1, CompareFunction: "gaussSim", SimilarityScale: &scale},
+		{Weight: 2, CompareFunction: "delta"},
+		{Weight: 4, CompareFunction: "equal"},
+		{Weight: 1},
+	}
+	cm := decode(t, `<ComparisonMeasure kind="similarity" compareFunction="absDiff"><cityBlock/></ComparisonMeasure>`)
+	d, ok, err := cm.Compare(fields, []interface{}{1.0, "a", 2, 0.5}, []interface{}{4.0, "b", "2.0", -1.0}, nil)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.InDelta(t, 0.5+2+4+1.5, d, 1e-12)
+	assert.True(t, cm.Better(2, 1))
+
+	_, _, err = cm.Compare(fields[3:], []interface{}{"a"}, []interface{}{1.0}, nil)
+	assert.Error(t, err)
+}
+
+func TestBinarySimilarities(t *testing.T) {
+	x := []interface{}{1.0, 0.0, 1, nil, "1"}
+	y := []interface{}{1.0, 1.0, 0.0, 1.0, 1.0}
+	// a11 = 2, a10 = 1, a01 = 1, a00 = 0
+	tcs := []struct {
+		measure  string
+		expected float64
+	}{
+		{`<simpleMatching/>`, 0.5},
+		{`<jaccard/>`, 0.5},
+		{`<tanimoto/>`, 1.0 / 3},
+		{`<binarySimilarity c00-parameter="0" c01-parameter="0" c10-parameter="0" c11-parameter="1"
+			d00-parameter="0" d01-parameter="0.5" d10-parameter="0.5" d11-parameter="1"/>`, 2.0 / 3},
+	}
+	for _, tc := range tcs {
+		cm := decode(t, `<ComparisonMeasure kind="similarity">`+tc.measure+`</ComparisonMeasure>`)
+		assert.True(t, cm.IsBinary())
+		s, ok, err := cm.Compare(nil, x, y, nil)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.InDelta(t, tc.expected, s, 1e-12, tc.measure)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tcs := []string{
+		`<ComparisonMeasure><euclidean/></ComparisonMeasure>`,
+		`<ComparisonMeasure kind="distance"/>`,
+		`<ComparisonMeasure kind="distance"><euclidean/><cityBlock/></ComparisonMeasure>`,
+		`<ComparisonMeasure kind="distance"><minkowski/></ComparisonMeasure>`,
+		`<ComparisonMeasure kind="similarity"><binarySimilarity c00-parameter="1"/></ComparisonMeasure>`,
+		`<ComparisonMeasure kind="distance" compareFunction="table"><euclidean/></ComparisonMeasure>`,
+	}
+	for _, tc := range tcs {
+		var cm comparisonmeasure.ComparisonMeasure
+		err := xml.Unmarshal([]byte(tc), &cm)
+		assert.Error(t, err, tc)
+	}
+}
@@ -0,0 +1,146 @@
+// Package distributions implements PMML's continuous distribution elements, which describe the distribution
+// of a continuous field, e.g. given a target category.
+package distributions
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// Distribution is a probability distribution with a density.
+type Distribution interface {
+	// Probability returns the density of the distribution at x, or for discrete distributions,
+	// the probability of x.
+	Probability(x float64) float64
+}
+
+type GaussianDistribution struct {
+	Mean     float64
+	Variance float64
+}
+
+// PoissonDistribution is a discrete distribution of counts. Its probability is extended to non-integer
+// values through the gamma function.
+type PoissonDistribution struct {
+	Mean float64
+}
+
+type UniformDistribution struct {
+	Lower float64
+	Upper float64
+}
+
+// LognormalDistribution is the distribution of a value whose logarithm is normally distributed, with the
+// given Mean and Variance.
+type LognormalDistribution struct {
+	Mean     float64
+	Variance float64
+}
+
+// TriangularDistribution rises linearly from Lower to Mode, and falls linearly from Mode to Upper.
+type TriangularDistribution struct {
+	Lower float64
+	Mode  float64
+	Upper float64
+}
+
+func (g *GaussianDistribution) Probability(x float64) float64 {
+	d := x - g.Mean
+	return math.Exp(-d*d/(2*g.Variance)) / math.Sqrt(2*math.Pi*g.Variance)
+}
+
+func (p *PoissonDistribution) Probability(x float64) float64 {
+	if x < 0 {
+		return 0
+	}
+	lgamma, _ := math.Lgamma(x + 1)
+	return math.Exp(x*math.Log(p.Mean) - p.Mean - lgamma)
+}
+
+func (u *UniformDistribution) Probability(x float64) float64 {
+	if x < u.Lower || x > u.Upper {
+		return 0
+	}
+	return 1 / (u.Upper - u.Lower)
+}
+
+func (l *LognormalDistribution) Probability(x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	d := math.Log(x) - l.Mean
+	return math.Exp(-d*d/(2*l.Variance)) / (x * math.Sqrt(2*math.Pi*l.Variance))
+}
+
+func (t *TriangularDistribution) Probability(x float64) float64 {
+	switch {
+	case x < t.Lower || x > t.Upper:
+		return 0
+	case x < t.Mode:
+		return 2 * (x - t.Lower) / ((t.Upper - t.Lower) * (t.Mode - t.Lower))
+	case x > t.Mode:
+		return 2 * (t.Upper - x) / ((t.Upper - t.Lower) * (t.Upper - t.Mode))
+	}
+	return 2 / (t.Upper - t.Lower)
+}
+
+// IsDistribution reports whether name is one of the continuous distribution elements.
+func IsDistribution(name string) bool {
+	switch name {
+	case "GaussianDistribution", "PoissonDistribution", "UniformDistribution", "AnyDistribution":
+		return true
+	}
+	return false
+}
+
+// Decode decodes the distribution element starting at start. AnyDistribution, which only gives
+// the mean and variance of an unknown distribution, has no density and is rejected.
+func Decode(d *xml.Decoder, start xml.StartElement) (Distribution, error) {
+	params := make(map[string]float64)
+	for _, attr := range start.Attr {
+		val, err := strconv.ParseFloat(attr.Value, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s of %s", attr.Name.Local, start.Name.Local)
+		}
+		params[attr.Name.Local] = val
+	}
+	if err := d.Skip(); err != nil {
+		return nil, err
+	}
+	required := func(names ...string) error {
+		for _, name := range names {
+			if _, ok := params[name]; !ok {
+				return fmt.Errorf("%s requires the %s attribute", start.Name.Local, name)
+			}
+		}
+		return nil
+	}
+	switch start.Name.Local {
+	case "GaussianDistribution":
+		if err := required("mean", "variance"); err != nil {
+			return nil, err
+		}
+		if params["variance"] <= 0 {
+			return nil, fmt.Errorf("variance of GaussianDistribution must be positive")
+		}
+		return &GaussianDistribution{Mean: params["mean"], Variance: params["variance"]}, nil
+	case "PoissonDistribution":
+		if err := required("mean"); err != nil {
+			return nil, err
+		}
+		return &PoissonDistribution{Mean: params["mean"]}, nil
+	case "UniformDistribution":
+		if err := required("lower", "upper"); err != nil {
+			return nil, err
+		}
+		if params["upper"] <= params["lower"] {
+			return nil, fmt.Errorf("upper of UniformDistribution must be greater than lower")
+		}
+		return &UniformDistribution{Lower: params["lower"], Upper: params["upper"]}, nil
+	}
+	return nil, fmt.Errorf("unsupported distribution: %s", start.Name.Local)
+}
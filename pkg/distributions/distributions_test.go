@@ -0,0 +1,69 @@
+package distributions_test
+
+import (
+	"encoding/xml"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stillmatic/pummel/pkg/distributions"
+	"github.com/stretchr/testify/assert"
+)
+
+func decode(t *testing.T, s string) (distributions.Distribution, error) {
+	d := xml.NewDecoder(strings.NewReader(s))
+	tok, err := d.Token()
+	assert.NoError(t, err)
+	return distributions.Decode(d, tok.(xml.StartElement))
+}
+
+func TestDistributions(t *testing.T) {
+	tcs := []struct {
+		xml      string
+		x        float64
+		expected float64
+	}{
+		{`<GaussianDistribution mean="0" variance="1"/>`, 0, 1 / math.Sqrt(2*math.Pi)},
+		{`<GaussianDistribution mean="2" variance="4"/>`, 4, math.Exp(-0.5) / math.Sqrt(8*math.Pi)},
+		{`<PoissonDistribution mean="3"/>`, 0, math.Exp(-3)},
+		{`<PoissonDistribution mean="3"/>`, 2, 4.5 * math.Exp(-3)},
+		{`<PoissonDistribution mean="3"/>`, -1, 0},
+		{`<UniformDistribution lower="-1" upper="3"/>`, 0.5, 0.25},
+		{`<UniformDistribution lower="-1" upper="3"/>`, 3.5, 0},
+	}
+	for _, tc := range tcs {
+		dist, err := decode(t, tc.xml)
+		assert.NoError(t, err)
+		assert.InDelta(t, tc.expected, dist.Probability(tc.x), 1e-12, tc.xml)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tcs := []struct {
+		xml      string
+		expected string
+	}{
+		{`<GaussianDistribution mean="0"/>`, "GaussianDistribution requires the variance attribute"},
+		{`<GaussianDistribution mean="0" variance="0"/>`, "variance of GaussianDistribution must be positive"},
+		{`<PoissonDistribution mean="x"/>`, `invalid mean of PoissonDistribution: strconv.ParseFloat: parsing "x": invalid syntax`},
+		{`<UniformDistribution lower="1" upper="1"/>`, "upper of UniformDistribution must be greater than lower"},
+		{`<AnyDistribution mean="1" variance="1"/>`, "unsupported distribution: AnyDistribution"},
+	}
+	for _, tc := range tcs {
+		_, err := decode(t, tc.xml)
+		assert.EqualError(t, err, tc.expected)
+	}
+}
+
+func TestLognormalAndTriangular(t *testing.T) {
+	l := &distributions.LognormalDistribution{Mean: 0, Variance: 1}
+	assert.InDelta(t, 1/math.Sqrt(2*math.Pi), l.Probability(1), 1e-12)
+	assert.InDelta(t, math.Exp(-0.5)/(math.E*math.Sqrt(2*math.Pi)), l.Probability(math.E), 1e-12)
+	assert.Equal(t, 0.0, l.Probability(0))
+
+	tr := &distributions.TriangularDistribution{Lower: 0, Mode: 1, Upper: 4}
+	assert.InDelta(t, 0.25, tr.Probability(0.5), 1e-12)
+	assert.InDelta(t, 0.5, tr.Probability(1), 1e-12)
+	assert.InDelta(t, 1.0/3, tr.Probability(2), 1e-12)
+	assert.Equal(t, 0.0, tr.Probability(5))
+}
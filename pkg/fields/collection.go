@@ -0,0 +1,55 @@
+package fields
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Collection is the value of a field holding several values, such as the items of a transaction,
+// or the result of a multi-valued output field. Its text form lists the values between brackets,
+// separated by commas, e.g. "[bread, milk]".
+type Collection []interface{}
+
+// ParseCollection parses the text form of a collection, whose values are strings.
+// A string without brackets is a collection of a single value, and "[]" is empty.
+func ParseCollection(s string) Collection {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "[") || !strings.HasSuffix(s, "]") {
+		return Collection{s}
+	}
+	s = strings.TrimSpace(s[1 : len(s)-1])
+	if s == "" {
+		return Collection{}
+	}
+	parts := strings.Split(s, ",")
+	c := make(Collection, len(parts))
+	for i, p := range parts {
+		c[i] = strings.TrimSpace(p)
+	}
+	return c
+}
+
+// Strings returns the values of the collection as strings, so that they can be compared
+// with the values declared in a model.
+func (c Collection) Strings() []string {
+	res := make([]string, len(c))
+	for i, v := range c {
+		res[i] = formatValue(v)
+	}
+	return res
+}
+
+func (c Collection) String() string {
+	return "[" + strings.Join(c.Strings(), ", ") + "]"
+}
+
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
@@ -2,12 +2,21 @@ package fields
 
 import (
 	"encoding/xml"
+	"strconv"
 
 	"github.com/pkg/errors"
+	"github.com/stillmatic/pummel/pkg/transformations"
 )
 
 type Outputs struct {
 	OutputFields []*OutputField `xml:"OutputField"`
+
+	doc *transformations.Document
+}
+
+// NewOutputs returns empty Outputs to be decoded into, whose expressions belong to doc.
+func NewOutputs(doc *transformations.Document) *Outputs {
+	return &Outputs{doc: doc}
 }
 
 type OutputField struct {
@@ -18,6 +27,149 @@ type OutputField struct {
 	DataType    string   `xml:"dataType,attr"`
 	Feature     string   `xml:"feature,attr"`
 	Value       string   `xml:"value,attr"`
+	// Rank selects the n-th reason code, or another ranked feature; 0 means the first.
+	Rank int `xml:"rank,attr"`
+	// RuleFeature is the property of a rule returned by the ruleValue feature, which defaults to consequent.
+	RuleFeature string `xml:"ruleFeature,attr"`
+	// Algorithm, RankBasis and RankOrder select and rank the association rules of a ruleValue feature.
+	// They default to exclusiveRecommendation, confidence and descending.
+	Algorithm string `xml:"algorithm,attr"`
+	RankBasis string `xml:"rankBasis,attr"`
+	RankOrder string `xml:"rankOrder,attr"`
+	// IsMultiValued makes a ranked feature return a Collection of the values up to Rank.
+	IsMultiValued bool `xml:"isMultiValued,attr"`
+	// Expression computes a transformedValue from the inputs and the output fields before it.
+	// It is nil if the field has none.
+	Expression transformations.Expression
+
+	doc *transformations.Document
+}
+
+func (o *Outputs) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			if tt.Name.Local != "OutputField" {
+				if err := d.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			of := &OutputField{doc: o.doc}
+			if err := d.DecodeElement(of, &tt); err != nil {
+				return err
+			}
+			o.OutputFields = append(o.OutputFields, of)
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+func (of *OutputField) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*of = OutputField{XMLName: start.Name, doc: of.doc}
+	for _, attr := range start.Attr {
+		var err error
+		switch attr.Name.Local {
+		case "name":
+			of.Name = attr.Value
+		case "displayName":
+			of.DisplayName = attr.Value
+		case "optype":
+			of.OpType = attr.Value
+		case "dataType":
+			of.DataType = attr.Value
+		case "feature":
+			of.Feature = attr.Value
+		case "value":
+			of.Value = attr.Value
+		case "rank":
+			of.Rank, err = strconv.Atoi(attr.Value)
+		case "ruleFeature":
+			of.RuleFeature = attr.Value
+		case "algorithm":
+			of.Algorithm = attr.Value
+		case "rankBasis":
+			of.RankBasis = attr.Value
+		case "rankOrder":
+			of.RankOrder = attr.Value
+		case "isMultiValued":
+			of.IsMultiValued, err = strconv.ParseBool(attr.Value)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s of OutputField %s", attr.Name.Local, of.Name)
+		}
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			if tt.Name.Local == "Extension" || tt.Name.Local == "Decisions" {
+				if err := d.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			expr := of.doc.NewExpression(tt.Name.Local)
+			if expr == nil {
+				return errors.Errorf("unsupported expression of OutputField %s: %s", of.Name, tt.Name.Local)
+			}
+			if err := d.DecodeElement(expr, &tt); err != nil {
+				return errors.Wrapf(err, "invalid expression of OutputField %s", of.Name)
+			}
+			transformations.InheritDataType(expr, of.DataType)
+			of.Expression = expr
+		case xml.EndElement:
+			of.doc.DeclareField(of.Name, of.DataType)
+			return nil
+		}
+	}
+}
+
+// Transform computes the transformedValue output fields which have an expression, in document order, and adds
+// them to out. Their expressions may refer to the output fields in out as well as to the inputs in values.
+func (o *Outputs) Transform(values, out map[string]interface{}) error {
+	var merged map[string]interface{}
+	for _, of := range o.OutputFields {
+		if of.Feature != "transformedValue" || of.Expression == nil {
+			continue
+		}
+		if merged == nil {
+			merged = make(map[string]interface{}, len(values)+len(out))
+			for k, v := range values {
+				merged[k] = v
+			}
+			for k, v := range out {
+				merged[k] = v
+			}
+		}
+		val, err := of.Expression.Transform(merged)
+		if err != nil {
+			return errors.Wrapf(err, "failed to compute output field %s", of.Name)
+		}
+		out[of.Name] = val
+		merged[of.Name] = val
+	}
+	return nil
+}
+
+// Complete computes the transformedValue output fields of o into out, the result of evaluating a model element
+// on values, unless the evaluation failed or had no result. o may be nil, if the model element has no Output.
+func (o *Outputs) Complete(values, out map[string]interface{}, err error) (map[string]interface{}, error) {
+	if err != nil || out == nil || o == nil {
+		return out, err
+	}
+	if err := o.Transform(values, out); err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
 var (
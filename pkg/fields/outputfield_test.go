@@ -22,3 +22,41 @@ func TestParseOutputFields(t *testing.T) {
 	assert.Equal(t, 3, len(output.OutputFields))
 	assert.Equal(t, "Predicted_Survived", output.OutputFields[0].Name)
 }
+
+func TestParseCollection(t *testing.T) {
+	tcs := []struct {
+		input    string
+		expected fields.Collection
+	}{
+		{"[bread, milk]", fields.Collection{"bread", "milk"}},
+		{" [beer] ", fields.Collection{"beer"}},
+		{"[]", fields.Collection{}},
+		{"bread", fields.Collection{"bread"}},
+	}
+	for _, tc := range tcs {
+		c := fields.ParseCollection(tc.input)
+		assert.Equal(t, tc.expected, c, tc.input)
+	}
+	assert.Equal(t, "[bread, 1.5, 2]", fields.Collection{"bread", 1.5, 2}.String())
+}
+
+func TestParseOutputFieldExpression(t *testing.T) {
+	var output *fields.Outputs
+	err := xml.Unmarshal([]byte(`<Output>
+	<OutputField name="p" feature="probability" value="1"><Extension name="x"/></OutputField>
+	<OutputField name="logit" dataType="double" feature="transformedValue">
+		<Apply function="ln"><FieldRef field="p"/></Apply>
+	</OutputField>
+</Output>`), &output)
+	assert.NoError(t, err)
+	assert.Nil(t, output.OutputFields[0].Expression)
+	assert.NotNil(t, output.OutputFields[1].Expression)
+
+	// an expression which cannot be computed fails the decode rather than dropping the output
+	err = xml.Unmarshal([]byte(`<Output>
+	<OutputField name="lag" dataType="double" feature="transformedValue">
+		<Lag field="y" n="1"/>
+	</OutputField>
+</Output>`), &output)
+	assert.EqualError(t, err, "unsupported expression of OutputField lag: Lag")
+}
@@ -0,0 +1,478 @@
+// Package gaussianprocess implements the GaussianProcessModel element, a regression which predicts the
+// mean and the standard error of a Gaussian process conditioned on its training instances.
+package gaussianprocess
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/stillmatic/pummel/pkg/array"
+	"github.com/stillmatic/pummel/pkg/fields"
+	"github.com/stillmatic/pummel/pkg/miningschema"
+	"github.com/stillmatic/pummel/pkg/nearestneighbor"
+	"github.com/stillmatic/pummel/pkg/table"
+	"github.com/stillmatic/pummel/pkg/transformations"
+	"github.com/stillmatic/pummel/pkg/verification"
+)
+
+type GaussianProcessModel struct {
+	XMLName              xml.Name                              `xml:"GaussianProcessModel"`
+	ModelName            string                                `xml:"modelName,attr"`
+	FunctionName         string                                `xml:"functionName,attr"`
+	AlgorithmName        string                                `xml:"algorithmName,attr"`
+	Optimizer            string                                `xml:"optimizer,attr"`
+	IsScorable           bool                                  `xml:"isScorable,attr"`
+	MiningSchema         *miningschema.MiningSchema            `xml:"MiningSchema"`
+	Output               *fields.Outputs                       `xml:"Output"`
+	LocalTransformations *transformations.LocalTransformations `xml:"LocalTransformations"`
+	Kernel               *Kernel
+	TrainingInstances    *nearestneighbor.TrainingInstances `xml:"TrainingInstances"`
+	ModelVerification    *verification.ModelVerification    `xml:"ModelVerification"`
+
+	inputs []string
+	target string
+	// instances holds the inputs of each training instance, chol the Cholesky factor of their
+	// covariance matrix and alpha its inverse times their targets.
+	instances [][]float64
+	chol      [][]float64
+	alpha     []float64
+	doc       *transformations.Document
+}
+
+// NewGaussianProcessModel returns an empty GaussianProcessModel to be decoded into, whose expressions belong to doc.
+func NewGaussianProcessModel(doc *transformations.Document) *GaussianProcessModel {
+	return &GaussianProcessModel{doc: doc}
+}
+
+var Kernels = struct {
+	RadialBasis            string
+	ARDSquaredExponential  string
+	AbsoluteExponential    string
+	GeneralizedExponential string
+}{
+	RadialBasis:            "RadialBasisKernel",
+	ARDSquaredExponential:  "ARDSquaredExponentialKernel",
+	AbsoluteExponential:    "AbsoluteExponentialKernel",
+	GeneralizedExponential: "GeneralizedExponentialKernel",
+}
+
+// Kernel is the covariance function of the process. Lambda holds the length scale of each input, except for
+// a RadialBasisKernel, which has a single one for all of them. Each parameter defaults to 1.
+//
+//	RadialBasisKernel            gamma * exp(-Σ (x_i - z_i)² / (2 lambda²))
+//	ARDSquaredExponentialKernel  gamma * exp(-Σ (x_i - z_i)² / (2 lambda_i²))
+//	AbsoluteExponentialKernel    gamma * exp(-Σ |x_i - z_i| / (2 lambda_i²))
+//	GeneralizedExponentialKernel gamma * exp(-Σ |x_i - z_i|^degree / (2 lambda_i²))
+//
+// NoiseVariance is the variance of the noise on the targets, which is only added to the covariance of each
+// training instance with itself.
+type Kernel struct {
+	XMLName       xml.Name
+	Description   string
+	Gamma         float64
+	NoiseVariance float64
+	Lambda        []float64
+	Degree        float64
+}
+
+func (k *Kernel) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*k = Kernel{XMLName: start.Name, Gamma: 1, NoiseVariance: 1, Degree: 1}
+	for _, attr := range start.Attr {
+		var err error
+		switch attr.Name.Local {
+		case "description":
+			k.Description = attr.Value
+		case "gamma":
+			k.Gamma, err = strconv.ParseFloat(attr.Value, 64)
+		case "noiseVariance":
+			k.NoiseVariance, err = strconv.ParseFloat(attr.Value, 64)
+		case "degree":
+			k.Degree, err = strconv.ParseFloat(attr.Value, 64)
+		case "lambda":
+			var lambda float64
+			lambda, err = strconv.ParseFloat(attr.Value, 64)
+			k.Lambda = []float64{lambda}
+		}
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s of %s", attr.Name.Local, start.Name.Local)
+		}
+	}
+	if start.Name.Local == Kernels.RadialBasis && k.Lambda == nil {
+		k.Lambda = []float64{1}
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "Lambda":
+				if k.Lambda, err = decodeLambda(d, tt); err != nil {
+					return errors.Wrapf(err, "invalid Lambda of %s", start.Name.Local)
+				}
+			case "Extension":
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unknown element: %s", tt.Name.Local)
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// decodeLambda decodes the Array of a Lambda element.
+func decodeLambda(d *xml.Decoder, start xml.StartElement) ([]float64, error) {
+	var lambda []float64
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			if tt.Name.Local != "Array" {
+				return nil, fmt.Errorf("unknown element: %s", tt.Name.Local)
+			}
+			if lambda, err = array.DecodeFloats(d, tt, 0); err != nil {
+				return nil, err
+			}
+		case xml.EndElement:
+			if lambda == nil {
+				return nil, errors.New("Lambda has no Array")
+			}
+			return lambda, nil
+		}
+	}
+}
+
+// covariance is the value of the kernel for the inputs x and z, without the noise.
+func (k *Kernel) covariance(x, z []float64) float64 {
+	var sum float64
+	for i := range x {
+		lambda := k.Lambda[0]
+		if k.XMLName.Local != Kernels.RadialBasis {
+			lambda = k.Lambda[i]
+		}
+		var d float64
+		switch k.XMLName.Local {
+		case Kernels.RadialBasis, Kernels.ARDSquaredExponential:
+			d = (x[i] - z[i]) * (x[i] - z[i])
+		case Kernels.AbsoluteExponential:
+			d = math.Abs(x[i] - z[i])
+		case Kernels.GeneralizedExponential:
+			d = math.Pow(math.Abs(x[i]-z[i]), k.Degree)
+		}
+		sum += d / (2 * lambda * lambda)
+	}
+	return k.Gamma * math.Exp(-sum)
+}
+
+func (m *GaussianProcessModel) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*m = GaussianProcessModel{XMLName: start.Name, doc: m.doc}
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "modelName":
+			m.ModelName = attr.Value
+		case "functionName":
+			m.FunctionName = attr.Value
+		case "algorithmName":
+			m.AlgorithmName = attr.Value
+		case "optimizer":
+			m.Optimizer = attr.Value
+		case "isScorable":
+			m.IsScorable = attr.Value == "true"
+		}
+	}
+	if m.FunctionName != "regression" {
+		return fmt.Errorf("unknown model type: %s", m.FunctionName)
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "MiningSchema":
+				var ms miningschema.MiningSchema
+				if err := d.DecodeElement(&ms, &tt); err != nil {
+					return err
+				}
+				m.MiningSchema = &ms
+			case "Output":
+				out := fields.NewOutputs(m.doc)
+				if err := d.DecodeElement(out, &tt); err != nil {
+					return err
+				}
+				m.Output = out
+			case "LocalTransformations":
+				lt := m.doc.NewLocalTransformations()
+				if err := d.DecodeElement(lt, &tt); err != nil {
+					return err
+				}
+				m.LocalTransformations = lt
+			case Kernels.RadialBasis, Kernels.ARDSquaredExponential, Kernels.AbsoluteExponential, Kernels.GeneralizedExponential:
+				var k Kernel
+				if err := d.DecodeElement(&k, &tt); err != nil {
+					return err
+				}
+				m.Kernel = &k
+			case "TrainingInstances":
+				var ti nearestneighbor.TrainingInstances
+				if err := d.DecodeElement(&ti, &tt); err != nil {
+					return err
+				}
+				m.TrainingInstances = &ti
+			case "ModelVerification":
+				var mv verification.ModelVerification
+				if err := d.DecodeElement(&mv, &tt); err != nil {
+					return err
+				}
+				m.ModelVerification = &mv
+			case "Extension", "ModelStats", "ModelExplanation":
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unknown element: %s", tt.Name.Local)
+			}
+		case xml.EndElement:
+			return m.prepare()
+		}
+	}
+}
+
+// prepare reads the training instances and factors their covariance matrix, so that each record only
+// needs its covariance with the instances.
+func (m *GaussianProcessModel) prepare() error {
+	switch {
+	case m.MiningSchema == nil:
+		return errors.New("GaussianProcessModel has no MiningSchema")
+	case m.Kernel == nil:
+		return errors.New("GaussianProcessModel has no kernel")
+	case m.TrainingInstances == nil:
+		return errors.New("GaussianProcessModel has no TrainingInstances")
+	case m.TrainingInstances.TableLocator != nil:
+		return errors.New("TrainingInstances with a TableLocator are not supported")
+	case m.TrainingInstances.InlineTable == nil:
+		return errors.New("TrainingInstances has no InlineTable")
+	}
+	for _, mf := range m.MiningSchema.MiningFields {
+		switch mf.UsageType {
+		case "", "active":
+			m.inputs = append(m.inputs, mf.Name)
+		case "target", "predicted":
+			m.target = mf.Name
+		}
+	}
+	if m.target == "" {
+		return errors.New("GaussianProcessModel has no target")
+	}
+	if m.Kernel.XMLName.Local != Kernels.RadialBasis && len(m.Kernel.Lambda) != len(m.inputs) {
+		return fmt.Errorf("%s has %d values of lambda for %d inputs", m.Kernel.XMLName.Local, len(m.Kernel.Lambda), len(m.inputs))
+	}
+
+	columns := make(map[string]string)
+	for _, f := range m.TrainingInstances.InstanceFields {
+		column := f.Column
+		if column == "" {
+			column = f.Field
+		}
+		columns[f.Field] = table.ColumnName(column)
+	}
+	if _, ok := columns[m.target]; !ok {
+		return fmt.Errorf("no InstanceField for target %s", m.target)
+	}
+	var targets []float64
+	for i, row := range m.TrainingInstances.InlineTable.Rows {
+		values := make(map[string]interface{}, len(columns))
+		for field, column := range columns {
+			values[field] = cellValue(row[column])
+		}
+		if !m.TrainingInstances.IsTransformed && m.LocalTransformations != nil {
+			for _, tr := range m.LocalTransformations.DerivedFields {
+				val, err := tr.Transform(values)
+				if err != nil {
+					return errors.Wrapf(err, "cannot transform instance %d", i+1)
+				}
+				values[tr.RequiredField()] = val
+			}
+		}
+		x := make([]float64, len(m.inputs))
+		for j, input := range m.inputs {
+			f, ok := values[input].(float64)
+			if !ok {
+				return fmt.Errorf("invalid value %v of %s of instance %d", values[input], input, i+1)
+			}
+			x[j] = f
+		}
+		y, ok := values[m.target].(float64)
+		if !ok {
+			return fmt.Errorf("invalid value %v of target %s of instance %d", values[m.target], m.target, i+1)
+		}
+		m.instances = append(m.instances, x)
+		targets = append(targets, y)
+	}
+	if len(m.instances) == 0 {
+		return errors.New("TrainingInstances has no instances")
+	}
+
+	n := len(m.instances)
+	cov := make([][]float64, n)
+	for i := range cov {
+		cov[i] = make([]float64, n)
+		for j := 0; j <= i; j++ {
+			cov[i][j] = m.Kernel.covariance(m.instances[i], m.instances[j])
+		}
+		cov[i][i] += m.Kernel.NoiseVariance
+	}
+	var ok bool
+	if m.chol, ok = cholesky(cov); !ok {
+		return errors.New("the covariance matrix of the TrainingInstances is not positive definite")
+	}
+	m.alpha = solveTransposed(m.chol, solve(m.chol, targets))
+	return nil
+}
+
+// cellValue converts a cell of the instance table into a number if it is one, or nil if it is empty.
+func cellValue(cell string) interface{} {
+	if cell == "" {
+		return nil
+	}
+	if f, err := strconv.ParseFloat(cell, 64); err == nil {
+		return f
+	}
+	return cell
+}
+
+// cholesky returns the lower triangular L such that L·Lᵀ is the symmetric matrix a, of which only the lower
+// triangle is read, and false if a is not positive definite.
+func cholesky(a [][]float64) ([][]float64, bool) {
+	n := len(a)
+	l := make([][]float64, n)
+	for i := range l {
+		l[i] = make([]float64, i+1)
+		for j := 0; j <= i; j++ {
+			sum := a[i][j]
+			for k := 0; k < j; k++ {
+				sum -= l[i][k] * l[j][k]
+			}
+			if i == j {
+				if sum <= 0 {
+					return nil, false
+				}
+				l[i][i] = math.Sqrt(sum)
+			} else {
+				l[i][j] = sum / l[j][j]
+			}
+		}
+	}
+	return l, true
+}
+
+// solve returns x such that L·x = b for a lower triangular L.
+func solve(l [][]float64, b []float64) []float64 {
+	x := make([]float64, len(b))
+	for i := range b {
+		sum := b[i]
+		for k := 0; k < i; k++ {
+			sum -= l[i][k] * x[k]
+		}
+		x[i] = sum / l[i][i]
+	}
+	return x
+}
+
+// solveTransposed returns x such that Lᵀ·x = b for a lower triangular L.
+func solveTransposed(l [][]float64, b []float64) []float64 {
+	x := make([]float64, len(b))
+	for i := len(b) - 1; i >= 0; i-- {
+		sum := b[i]
+		for k := i + 1; k < len(b); k++ {
+			sum -= l[k][i] * x[k]
+		}
+		x[i] = sum / l[i][i]
+	}
+	return x
+}
+
+// Evaluate predicts the mean of the process at the inputs of the record. Its standardError may be returned by
+// an output field, and is that of the mean, without the noise of the targets. The record is not scored if
+// any input is missing.
+func (m *GaussianProcessModel) Evaluate(values map[string]interface{}) (map[string]interface{}, error) {
+	out, err := m.evaluate(values)
+	return m.Output.Complete(values, out, err)
+}
+
+func (m *GaussianProcessModel) evaluate(values map[string]interface{}) (map[string]interface{}, error) {
+	if m.LocalTransformations != nil {
+		for _, tr := range m.LocalTransformations.DerivedFields {
+			val, err := tr.Transform(values)
+			if err != nil {
+				return nil, err
+			}
+			values[tr.RequiredField()] = val
+		}
+	}
+	x := make([]float64, len(m.inputs))
+	for i, input := range m.inputs {
+		if values[input] == nil {
+			return nil, nil
+		}
+		f, err := transformations.InterfaceToFloat64(values[input])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid value of %s", input)
+		}
+		x[i] = f
+	}
+	k := make([]float64, len(m.instances))
+	var mean float64
+	for i, inst := range m.instances {
+		k[i] = m.Kernel.covariance(x, inst)
+		mean += k[i] * m.alpha[i]
+	}
+
+	out := make(map[string]interface{})
+	out[m.target] = mean
+	if m.Output == nil {
+		return out, nil
+	}
+	for _, of := range m.Output.OutputFields {
+		switch of.Feature {
+		case "predictedValue":
+			out[of.Name] = mean
+		case "standardError":
+			variance := m.Kernel.covariance(x, x)
+			for _, v := range solve(m.chol, k) {
+				variance -= v * v
+			}
+			out[of.Name] = math.Sqrt(math.Max(variance, 0))
+		}
+	}
+	return out, nil
+}
+
+func (m *GaussianProcessModel) GetOutputField() string {
+	return m.MiningSchema.GetOutputField()
+}
+
+func (m *GaussianProcessModel) GetMiningSchema() *miningschema.MiningSchema {
+	return m.MiningSchema
+}
+
+func (m *GaussianProcessModel) GetOutput() *fields.Outputs {
+	return m.Output
+}
+
+func (m *GaussianProcessModel) GetModelVerification() *verification.ModelVerification {
+	return m.ModelVerification
+}
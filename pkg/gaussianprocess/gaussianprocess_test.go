@@ -0,0 +1,112 @@
+package gaussianprocess_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/stillmatic/pummel/pkg/gaussianprocess"
+	"github.com/stretchr/testify/assert"
+)
+
+var gpXML = `<GaussianProcessModel functionName="regression" optimizer="L-BFGS">
+	<MiningSchema>
+		<MiningField name="x1"/>
+		<MiningField name="x2"/>
+		<MiningField name="y" usageType="target"/>
+	</MiningSchema>
+	<Output>
+		<OutputField name="prediction" feature="predictedValue"/>
+		<OutputField name="se" feature="standardError"/>
+	</Output>
+	KERNEL
+	<TrainingInstances recordCount="5" fieldCount="3">
+		<InstanceFields>
+			<InstanceField field="x1" column="a"/>
+			<InstanceField field="x2" column="b"/>
+			<InstanceField field="y"/>
+		</InstanceFields>
+		<InlineTable>
+			<row><a>1</a><b>3</b><y>1.2</y></row>
+			<row><a>2</a><b>6</b><y>2.5</y></row>
+			<row><a>3</a><b>1</b><y>0.7</y></row>
+			<row><a>4</a><b>4</b><y>3.1</y></row>
+			<row><a>5</a><b>2</b><y>2.0</y></row>
+		</InlineTable>
+	</TrainingInstances>
+</GaussianProcessModel>`
+
+const ardKernel = `<ARDSquaredExponentialKernel gamma="2" noiseVariance="0.1">
+		<Lambda><Array n="2" type="real">1.5 2</Array></Lambda>
+	</ARDSquaredExponentialKernel>`
+
+func TestGaussianProcessKernels(t *testing.T) {
+	tcs := []struct {
+		kernel   string
+		expected [2][2]float64
+	}{
+		{`<RadialBasisKernel gamma="2" noiseVariance="0.1" lambda="1.2"/>`,
+			[2][2]float64{{1.543574258630343, 1.1602508358994403}, {2.97274330037949, 0.3083481244572559}}},
+		{ardKernel,
+			[2][2]float64{{1.9870849644994935, 0.7851781299678415}, {2.995018212536554, 0.3056683129004986}}},
+		{`<AbsoluteExponentialKernel gamma="2" noiseVariance="0.1">
+		<Lambda><Array n="2" type="real">1.5 2</Array></Lambda>
+	</AbsoluteExponentialKernel>`,
+			[2][2]float64{{1.7186963353576374, 0.7616363894634515}, {2.9657691028520605, 0.30142726567967265}}},
+		{`<GeneralizedExponentialKernel gamma="2" noiseVariance="0.1" degree="1.5">
+		<Lambda><Array n="2" type="real">1.5 2</Array></Lambda>
+	</GeneralizedExponentialKernel>`,
+			[2][2]float64{{1.8750079594411582, 0.7654163576898974}, {2.9888913374725963, 0.3035136856537341}}},
+	}
+	records := []map[string]interface{}{{"x1": 2.5, "x2": 3.0}, {"x1": 4.0, "x2": "4"}}
+	for _, tc := range tcs {
+		var m gaussianprocess.GaussianProcessModel
+		err := xml.Unmarshal([]byte(strings.Replace(gpXML, "KERNEL", tc.kernel, 1)), &m)
+		assert.NoError(t, err, tc.kernel)
+		for i, values := range records {
+			out, err := m.Evaluate(values)
+			assert.NoError(t, err)
+			assert.InDelta(t, tc.expected[i][0], out["y"], 1e-9, tc.kernel)
+			assert.InDelta(t, tc.expected[i][0], out["prediction"], 1e-9, tc.kernel)
+			assert.InDelta(t, tc.expected[i][1], out["se"], 1e-9, tc.kernel)
+		}
+	}
+
+	var m gaussianprocess.GaussianProcessModel
+	err := xml.Unmarshal([]byte(strings.Replace(gpXML, "KERNEL", `<RadialBasisKernel/>`, 1)), &m)
+	assert.NoError(t, err)
+	assert.Equal(t, gaussianprocess.Kernel{
+		XMLName: xml.Name{Local: "RadialBasisKernel"}, Gamma: 1, NoiseVariance: 1, Lambda: []float64{1}, Degree: 1,
+	}, *m.Kernel)
+	out, err := m.Evaluate(map[string]interface{}{"x1": 2.5})
+	assert.NoError(t, err)
+	assert.Nil(t, out)
+}
+
+func TestGaussianProcessErrors(t *testing.T) {
+	tcs := []struct {
+		old, new string
+		expected string
+	}{
+		{`functionName="regression"`, `functionName="classification"`, "unknown model type: classification"},
+		{"KERNEL", "", "GaussianProcessModel has no kernel"},
+		{`<MiningField name="y" usageType="target"/>`, ``, "GaussianProcessModel has no target"},
+		{`<InstanceField field="y"/>`, ``, "no InstanceField for target y"},
+		{`<b>6</b>`, `<b>six</b>`, "invalid value six of x2 of instance 2"},
+		{`<y>0.7</y>`, ``, "invalid value <nil> of target y of instance 3"},
+		{`<InlineTable>`, `<TableLocator/><InlineTable>`, "TrainingInstances with a TableLocator are not supported"},
+		{`n="2" type="real">1.5 2`, `n="1" type="real">1.5`, "ARDSquaredExponentialKernel has 1 values of lambda for 2 inputs"},
+		{`noiseVariance="0.1"`, `noiseVariance="-3"`, "the covariance matrix of the TrainingInstances is not positive definite"},
+		{`<Lambda>`, `<Sigma/><Lambda>`, "unknown element: Sigma"},
+		{`gamma="2"`, `gamma="two"`, `invalid gamma of ARDSquaredExponentialKernel: strconv.ParseFloat: parsing "two": invalid syntax`},
+	}
+	for _, tc := range tcs {
+		model := strings.Replace(gpXML, "KERNEL", ardKernel, 1)
+		if tc.old == "KERNEL" {
+			model = gpXML
+		}
+		var m gaussianprocess.GaussianProcessModel
+		err := xml.Unmarshal([]byte(strings.Replace(model, tc.old, tc.new, 1)), &m)
+		assert.EqualError(t, err, tc.expected, tc.new)
+	}
+}
@@ -0,0 +1,100 @@
+package generalregression
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// BaseCumHazardTables holds the baseline cumulative hazard of a CoxRegression model, either as a single
+// table of cells or as one stratum per value of the baselineStrataVariable.
+type BaseCumHazardTables struct {
+	XMLName xml.Name `xml:"BaseCumHazardTables"`
+	// MaxTime is the last time covered by the baseline cells. Later times have no prediction.
+	MaxTime        *float64
+	BaselineCells  []*BaselineCell
+	BaselineStrata []*BaselineStratum
+}
+
+type BaselineStratum struct {
+	XMLName       xml.Name        `xml:"BaselineStratum"`
+	Value         string          `xml:"value,attr"`
+	Label         string          `xml:"label,attr"`
+	MaxTime       float64         `xml:"maxTime,attr"`
+	BaselineCells []*BaselineCell `xml:"BaselineCell"`
+}
+
+type BaselineCell struct {
+	XMLName   xml.Name `xml:"BaselineCell"`
+	Time      float64  `xml:"time,attr"`
+	CumHazard float64  `xml:"cumHazard,attr"`
+}
+
+func (bt *BaseCumHazardTables) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	bt.XMLName = start.Name
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "maxTime" {
+			maxTime, err := strconv.ParseFloat(attr.Value, 64)
+			if err != nil {
+				return errors.Wrap(err, "invalid maxTime of BaseCumHazardTables")
+			}
+			bt.MaxTime = &maxTime
+		}
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "BaselineStratum":
+				var bs BaselineStratum
+				if err := d.DecodeElement(&bs, &tt); err != nil {
+					return err
+				}
+				sortCells(bs.BaselineCells)
+				bt.BaselineStrata = append(bt.BaselineStrata, &bs)
+			case "BaselineCell":
+				var bc BaselineCell
+				if err := d.DecodeElement(&bc, &tt); err != nil {
+					return err
+				}
+				bt.BaselineCells = append(bt.BaselineCells, &bc)
+			case "Extension":
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unexpected element in BaseCumHazardTables: %s", tt.Name.Local)
+			}
+		case xml.EndElement:
+			if len(bt.BaselineStrata) == 0 && bt.MaxTime == nil {
+				return errors.New("BaseCumHazardTables without strata requires maxTime")
+			}
+			sortCells(bt.BaselineCells)
+			return nil
+		}
+	}
+}
+
+func sortCells(cells []*BaselineCell) {
+	sort.SliceStable(cells, func(i, j int) bool { return cells[i].Time < cells[j].Time })
+}
+
+// cumHazard returns the baseline cumulative hazard at time t, which is the hazard of the last cell at or
+// before t. It is 0 before the first cell, and missing after maxTime.
+func cumHazard(cells []*BaselineCell, maxTime, t float64) (float64, bool) {
+	if t > maxTime {
+		return 0, false
+	}
+	i := sort.Search(len(cells), func(i int) bool { return cells[i].Time > t })
+	if i == 0 {
+		return 0, true
+	}
+	return cells[i-1].CumHazard, true
+}
@@ -0,0 +1,653 @@
+// Package generalregression implements the GeneralRegressionModel element. Its ParameterList names the
+// parameters, the PPMatrix defines the value of each parameter from the factors and covariates, and the
+// ParamMatrix holds their coefficients, which may be specific to a target category.
+package generalregression
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/stillmatic/pummel/pkg/fields"
+	"github.com/stillmatic/pummel/pkg/miningschema"
+	"github.com/stillmatic/pummel/pkg/transformations"
+	"github.com/stillmatic/pummel/pkg/verification"
+)
+
+type GeneralRegressionModel struct {
+	XMLName                 xml.Name `xml:"GeneralRegressionModel"`
+	ModelName               string   `xml:"modelName,attr"`
+	FunctionName            string   `xml:"functionName,attr"`
+	AlgorithmName           string   `xml:"algorithmName,attr"`
+	TargetVariableName      string   `xml:"targetVariableName,attr"`
+	ModelType               string   `xml:"modelType,attr"`
+	TargetReferenceCategory string   `xml:"targetReferenceCategory,attr"`
+	CumulativeLink          string   `xml:"cumulativeLink,attr"`
+	LinkFunction            string   `xml:"linkFunction,attr"`
+	LinkParameter           *float64 `xml:"linkParameter,attr"`
+	Distribution            string   `xml:"distribution,attr"`
+	DistParameter           *float64 `xml:"distParameter,attr"`
+	// the offset is added to the linear predictor, and the number of trials multiplies the prediction
+	// of a generalized linear model. A variable takes precedence over the fixed value.
+	OffsetVariable         string   `xml:"offsetVariable,attr"`
+	OffsetValue            *float64 `xml:"offsetValue,attr"`
+	TrialsVariable         string   `xml:"trialsVariable,attr"`
+	TrialsValue            *float64 `xml:"trialsValue,attr"`
+	EndTimeVariable        string   `xml:"endTimeVariable,attr"`
+	StartTimeVariable      string   `xml:"startTimeVariable,attr"`
+	StatusVariable         string   `xml:"statusVariable,attr"`
+	SubjectIDVariable      string   `xml:"subjectIDVariable,attr"`
+	BaselineStrataVariable string   `xml:"baselineStrataVariable,attr"`
+	IsScorable             bool     `xml:"isScorable,attr"`
+
+	MiningSchema         *miningschema.MiningSchema            `xml:"MiningSchema"`
+	Output               *fields.Outputs                       `xml:"Output"`
+	LocalTransformations *transformations.LocalTransformations `xml:"LocalTransformations"`
+	Parameters           []*Parameter                          `xml:"ParameterList>Parameter"`
+	Factors              []*Predictor                          `xml:"FactorList>Predictor"`
+	Covariates           []*Predictor                          `xml:"CovariateList>Predictor"`
+	PPMatrix             []*PPCell                             `xml:"PPMatrix>PPCell"`
+	ParamMatrix          []*PCell                              `xml:"ParamMatrix>PCell"`
+	BaseCumHazardTables  *BaseCumHazardTables                  `xml:"BaseCumHazardTables"`
+	ModelVerification    *verification.ModelVerification       `xml:"ModelVerification"`
+
+	// categories are the target categories of a classification, in order.
+	categories []string
+	// cells holds the PPCells of each parameter.
+	cells map[string][]*PPCell
+	// factors is set for the predictors which are factors rather than covariates.
+	factors map[string]bool
+	link    func(float64) float64
+	doc     *transformations.Document
+}
+
+// NewGeneralRegressionModel returns an empty GeneralRegressionModel to be decoded into, whose expressions belong to doc.
+func NewGeneralRegressionModel(doc *transformations.Document) *GeneralRegressionModel {
+	return &GeneralRegressionModel{doc: doc}
+}
+
+var ModelTypes = struct {
+	Regression          string
+	GeneralLinear       string
+	GeneralizedLinear   string
+	MultinomialLogistic string
+	OrdinalMultinomial  string
+	CoxRegression       string
+}{
+	Regression:          "regression",
+	GeneralLinear:       "generalLinear",
+	GeneralizedLinear:   "generalizedLinear",
+	MultinomialLogistic: "multinomialLogistic",
+	OrdinalMultinomial:  "ordinalMultinomial",
+	CoxRegression:       "CoxRegression",
+}
+
+type Parameter struct {
+	XMLName xml.Name `xml:"Parameter"`
+	Name    string   `xml:"name,attr"`
+	Label   string   `xml:"label,attr"`
+	// ReferencePoint is subtracted from the parameter's value in a CoxRegression.
+	ReferencePoint float64 `xml:"referencePoint,attr"`
+}
+
+type Predictor struct {
+	XMLName            xml.Name  `xml:"Predictor"`
+	Name               string    `xml:"name,attr"`
+	ContrastMatrixType string    `xml:"contrastMatrixType,attr"`
+	Matrix             *struct{} `xml:"Matrix"`
+}
+
+// PPCell contributes to the value of a parameter: for a factor, 1 if the factor has the cell's value and
+// 0 otherwise, and for a covariate, its value raised to the power of the cell's value. A parameter's
+// value is the product of its cells, so a parameter without cells is an intercept.
+type PPCell struct {
+	XMLName        xml.Name `xml:"PPCell"`
+	Value          string   `xml:"value,attr"`
+	PredictorName  string   `xml:"predictorName,attr"`
+	ParameterName  string   `xml:"parameterName,attr"`
+	TargetCategory string   `xml:"targetCategory,attr"`
+	// exponent is the parsed value of a covariate's cell.
+	exponent float64
+}
+
+// PCell is the coefficient of a parameter. Without a target category it applies to every category.
+type PCell struct {
+	XMLName        xml.Name `xml:"PCell"`
+	TargetCategory string   `xml:"targetCategory,attr"`
+	ParameterName  string   `xml:"parameterName,attr"`
+	Beta           float64  `xml:"beta,attr"`
+	DF             *int     `xml:"df,attr"`
+}
+
+func (m *GeneralRegressionModel) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	m.XMLName = start.Name
+	for _, attr := range start.Attr {
+		var err error
+		parse := func() *float64 {
+			var f float64
+			f, err = strconv.ParseFloat(attr.Value, 64)
+			return &f
+		}
+		switch attr.Name.Local {
+		case "modelName":
+			m.ModelName = attr.Value
+		case "functionName":
+			m.FunctionName = attr.Value
+		case "algorithmName":
+			m.AlgorithmName = attr.Value
+		case "targetVariableName":
+			m.TargetVariableName = attr.Value
+		case "modelType":
+			m.ModelType = attr.Value
+		case "targetReferenceCategory":
+			m.TargetReferenceCategory = attr.Value
+		case "cumulativeLink":
+			m.CumulativeLink = attr.Value
+		case "linkFunction":
+			m.LinkFunction = attr.Value
+		case "linkParameter":
+			m.LinkParameter = parse()
+		case "distribution":
+			m.Distribution = attr.Value
+		case "distParameter":
+			m.DistParameter = parse()
+		case "offsetVariable":
+			m.OffsetVariable = attr.Value
+		case "offsetValue":
+			m.OffsetValue = parse()
+		case "trialsVariable":
+			m.TrialsVariable = attr.Value
+		case "trialsValue":
+			m.TrialsValue = parse()
+		case "endTimeVariable":
+			m.EndTimeVariable = attr.Value
+		case "startTimeVariable":
+			m.StartTimeVariable = attr.Value
+		case "statusVariable":
+			m.StatusVariable = attr.Value
+		case "subjectIDVariable":
+			m.SubjectIDVariable = attr.Value
+		case "baselineStrataVariable":
+			m.BaselineStrataVariable = attr.Value
+		case "isScorable":
+			m.IsScorable = attr.Value == "true"
+		}
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s of GeneralRegressionModel", attr.Name.Local)
+		}
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "MiningSchema":
+				var ms miningschema.MiningSchema
+				if err := d.DecodeElement(&ms, &tt); err != nil {
+					return err
+				}
+				m.MiningSchema = &ms
+			case "Output":
+				out := fields.NewOutputs(m.doc)
+				if err := d.DecodeElement(out, &tt); err != nil {
+					return err
+				}
+				m.Output = out
+			case "LocalTransformations":
+				lt := m.doc.NewLocalTransformations()
+				if err := d.DecodeElement(lt, &tt); err != nil {
+					return err
+				}
+				m.LocalTransformations = lt
+			case "ParameterList":
+				var pl struct {
+					Parameters []*Parameter `xml:"Parameter"`
+				}
+				if err := d.DecodeElement(&pl, &tt); err != nil {
+					return err
+				}
+				m.Parameters = pl.Parameters
+			case "FactorList", "CovariateList":
+				var pl struct {
+					Predictors []*Predictor `xml:"Predictor"`
+				}
+				if err := d.DecodeElement(&pl, &tt); err != nil {
+					return err
+				}
+				for _, p := range pl.Predictors {
+					if p.ContrastMatrixType != "" || p.Matrix != nil {
+						return fmt.Errorf("unsupported contrast matrix of predictor %s", p.Name)
+					}
+				}
+				if tt.Name.Local == "FactorList" {
+					m.Factors = pl.Predictors
+				} else {
+					m.Covariates = pl.Predictors
+				}
+			case "PPMatrix":
+				var pp struct {
+					Cells []*PPCell `xml:"PPCell"`
+				}
+				if err := d.DecodeElement(&pp, &tt); err != nil {
+					return err
+				}
+				m.PPMatrix = pp.Cells
+			case "ParamMatrix":
+				var pm struct {
+					Cells []*PCell `xml:"PCell"`
+				}
+				if err := d.DecodeElement(&pm, &tt); err != nil {
+					return err
+				}
+				m.ParamMatrix = pm.Cells
+			case "BaseCumHazardTables":
+				var bt BaseCumHazardTables
+				if err := d.DecodeElement(&bt, &tt); err != nil {
+					return err
+				}
+				m.BaseCumHazardTables = &bt
+			case "ModelVerification":
+				var mv verification.ModelVerification
+				if err := d.DecodeElement(&mv, &tt); err != nil {
+					return err
+				}
+				m.ModelVerification = &mv
+			case "Extension", "ModelStats", "ModelExplanation", "PCovMatrix", "EventValues":
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unknown element: %s", tt.Name.Local)
+			}
+		case xml.EndElement:
+			return m.prepare()
+		}
+	}
+}
+
+// prepare checks that the matrices refer to known parameters and predictors, indexes them, and works out
+// the target categories and the link function of the model type.
+func (m *GeneralRegressionModel) prepare() error {
+	parameters := make(map[string]bool, len(m.Parameters))
+	for _, p := range m.Parameters {
+		parameters[p.Name] = true
+	}
+	m.factors = make(map[string]bool)
+	covariates := make(map[string]bool)
+	for _, p := range m.Factors {
+		m.factors[p.Name] = true
+	}
+	for _, p := range m.Covariates {
+		covariates[p.Name] = true
+	}
+	m.cells = make(map[string][]*PPCell)
+	for _, c := range m.PPMatrix {
+		if !parameters[c.ParameterName] {
+			return fmt.Errorf("PPCell refers to unknown parameter %s", c.ParameterName)
+		}
+		switch {
+		case m.factors[c.PredictorName]:
+		case covariates[c.PredictorName]:
+			exponent, err := strconv.ParseFloat(c.Value, 64)
+			if err != nil {
+				return errors.Wrapf(err, "invalid exponent of covariate %s", c.PredictorName)
+			}
+			c.exponent = exponent
+		default:
+			return fmt.Errorf("PPCell refers to unknown predictor %s", c.PredictorName)
+		}
+		m.cells[c.ParameterName] = append(m.cells[c.ParameterName], c)
+	}
+	seen := make(map[string]bool)
+	for _, c := range m.ParamMatrix {
+		if !parameters[c.ParameterName] {
+			return fmt.Errorf("PCell refers to unknown parameter %s", c.ParameterName)
+		}
+		if c.TargetCategory != "" && !seen[c.TargetCategory] {
+			seen[c.TargetCategory] = true
+			m.categories = append(m.categories, c.TargetCategory)
+		}
+	}
+
+	switch m.ModelType {
+	case ModelTypes.Regression, ModelTypes.GeneralLinear, ModelTypes.GeneralizedLinear:
+		link := m.LinkFunction
+		if link == "" || m.ModelType == ModelTypes.Regression {
+			link = LinkFunctions.Identity
+		}
+		var param float64
+		switch link {
+		case LinkFunctions.Power, LinkFunctions.OddsPower:
+			if m.LinkParameter == nil {
+				return fmt.Errorf("link function %s requires linkParameter", link)
+			}
+			param = *m.LinkParameter
+		case LinkFunctions.NegBin:
+			if m.DistParameter == nil {
+				return fmt.Errorf("link function %s requires distParameter", link)
+			}
+			param = *m.DistParameter
+		}
+		var err error
+		if m.link, err = inverseLink(link, param); err != nil {
+			return err
+		}
+		if m.FunctionName == "classification" {
+			// a binary model predicts the probability of the category its coefficients belong to
+			if len(m.categories) != 1 || m.TargetReferenceCategory == "" {
+				return fmt.Errorf("binary %s classification requires coefficients for a single target category and a targetReferenceCategory", m.ModelType)
+			}
+			m.categories = append(m.categories, m.TargetReferenceCategory)
+		}
+	case ModelTypes.MultinomialLogistic:
+		if m.TargetReferenceCategory != "" && !seen[m.TargetReferenceCategory] {
+			m.categories = append(m.categories, m.TargetReferenceCategory)
+		}
+		if len(m.categories) < 2 {
+			return errors.New("multinomialLogistic requires at least two target categories")
+		}
+	case ModelTypes.OrdinalMultinomial:
+		var err error
+		if m.link, err = cumulativeLink(m.CumulativeLink); err != nil {
+			return err
+		}
+		last := m.lastOrdinalCategory(seen)
+		if last == "" {
+			return errors.New("ordinalMultinomial requires a targetReferenceCategory or probability output naming the last category")
+		}
+		m.categories = append(m.categories, last)
+	case ModelTypes.CoxRegression:
+		if m.EndTimeVariable == "" || m.BaseCumHazardTables == nil {
+			return errors.New("CoxRegression requires an endTimeVariable and BaseCumHazardTables")
+		}
+		if len(m.BaseCumHazardTables.BaselineStrata) > 0 && m.BaselineStrataVariable == "" {
+			return errors.New("CoxRegression with baseline strata requires a baselineStrataVariable")
+		}
+	default:
+		return fmt.Errorf("unknown model type: %s", m.ModelType)
+	}
+	return nil
+}
+
+// lastOrdinalCategory finds the highest category of an ordinal model, which has no intercept of its own.
+// As the categories of the target are not part of the model element, it is the target reference category,
+// or else the only probability output of a category without an intercept.
+func (m *GeneralRegressionModel) lastOrdinalCategory(seen map[string]bool) string {
+	if m.TargetReferenceCategory != "" {
+		return m.TargetReferenceCategory
+	}
+	if m.Output == nil {
+		return ""
+	}
+	var last string
+	for _, of := range m.Output.OutputFields {
+		if of.Feature != "probability" || of.Value == "" || seen[of.Value] {
+			continue
+		}
+		if last != "" && last != of.Value {
+			return ""
+		}
+		last = of.Value
+	}
+	return last
+}
+
+// parameterValue computes the value of a parameter for the given target category. ok is false if a
+// predictor of the parameter is missing.
+func (m *GeneralRegressionModel) parameterValue(name, category string, values map[string]interface{}) (float64, bool, error) {
+	x := 1.0
+	for _, c := range m.cells[name] {
+		if c.TargetCategory != "" && c.TargetCategory != category {
+			continue
+		}
+		value := values[c.PredictorName]
+		if value == nil {
+			return 0, false, nil
+		}
+		if m.factors[c.PredictorName] {
+			if !transformations.EqualsValue(value, c.Value) {
+				return 0, true, nil
+			}
+			continue
+		}
+		f, err := transformations.InterfaceToFloat64(value)
+		if err != nil {
+			return 0, false, errors.Wrapf(err, "invalid value of covariate %s", c.PredictorName)
+		}
+		x *= math.Pow(f, c.exponent)
+	}
+	return x, true, nil
+}
+
+// linearPredictor sums the coefficients of the category, and those without a category, times the values
+// of their parameters, less the parameters' reference points if center is set.
+func (m *GeneralRegressionModel) linearPredictor(category string, center bool, values map[string]interface{}) (float64, bool, error) {
+	var eta float64
+	for _, c := range m.ParamMatrix {
+		if c.TargetCategory != "" && c.TargetCategory != category {
+			continue
+		}
+		x, ok, err := m.parameterValue(c.ParameterName, category, values)
+		if err != nil || !ok {
+			return 0, ok, err
+		}
+		if center {
+			x -= m.referencePoint(c.ParameterName)
+		}
+		eta += c.Beta * x
+	}
+	return eta, true, nil
+}
+
+func (m *GeneralRegressionModel) referencePoint(name string) float64 {
+	for _, p := range m.Parameters {
+		if p.Name == name {
+			return p.ReferencePoint
+		}
+	}
+	return 0
+}
+
+// variable returns the value of the named variable, or the fixed value if the variable is not set.
+// ok is false if the variable is set but missing from values.
+func variable(values map[string]interface{}, name string, fixed *float64, def float64) (float64, bool, error) {
+	if name != "" {
+		value := values[name]
+		if value == nil {
+			return 0, false, nil
+		}
+		f, err := transformations.InterfaceToFloat64(value)
+		if err != nil {
+			return 0, false, errors.Wrapf(err, "invalid value of %s", name)
+		}
+		return f, true, nil
+	}
+	if fixed != nil {
+		return *fixed, true, nil
+	}
+	return def, true, nil
+}
+
+func (m *GeneralRegressionModel) Evaluate(values map[string]interface{}) (map[string]interface{}, error) {
+	out, err := m.evaluate(values)
+	return m.Output.Complete(values, out, err)
+}
+
+func (m *GeneralRegressionModel) evaluate(values map[string]interface{}) (map[string]interface{}, error) {
+	if m.LocalTransformations != nil {
+		for _, tr := range m.LocalTransformations.DerivedFields {
+			val, err := tr.Transform(values)
+			if err != nil {
+				return nil, err
+			}
+			values[tr.RequiredField()] = val
+		}
+	}
+	switch m.ModelType {
+	case ModelTypes.MultinomialLogistic:
+		return m.evaluateMultinomial(values)
+	case ModelTypes.OrdinalMultinomial:
+		return m.evaluateOrdinal(values)
+	case ModelTypes.CoxRegression:
+		return m.evaluateCox(values)
+	}
+
+	category := ""
+	if m.FunctionName == "classification" {
+		category = m.categories[0]
+	}
+	eta, ok, err := m.linearPredictor(category, false, values)
+	if err != nil || !ok {
+		return nil, err
+	}
+	offset, ok, err := variable(values, m.OffsetVariable, m.OffsetValue, 0)
+	if err != nil || !ok {
+		return nil, err
+	}
+	mu := m.link(eta + offset)
+	if m.FunctionName == "classification" {
+		return m.classification([]float64{mu, 1 - mu}), nil
+	}
+	trials, ok, err := variable(values, m.TrialsVariable, m.TrialsValue, 1)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return map[string]interface{}{m.GetOutputField(): mu * trials}, nil
+}
+
+// evaluateMultinomial applies softmax to the linear predictors of the categories. The reference category
+// has no coefficients, so its linear predictor is 0.
+func (m *GeneralRegressionModel) evaluateMultinomial(values map[string]interface{}) (map[string]interface{}, error) {
+	etas := make([]float64, len(m.categories))
+	top := math.Inf(-1)
+	for i, category := range m.categories {
+		eta, ok, err := m.linearPredictor(category, false, values)
+		if err != nil || !ok {
+			return nil, err
+		}
+		etas[i] = eta
+		top = math.Max(top, eta)
+	}
+	var sum float64
+	for i := range etas {
+		etas[i] = math.Exp(etas[i] - top)
+		sum += etas[i]
+	}
+	for i := range etas {
+		etas[i] /= sum
+	}
+	return m.classification(etas), nil
+}
+
+// evaluateOrdinal maps the linear predictor of each category but the last onto the cumulative probability
+// of that category or a lower one. The probability of a category is the difference between its cumulative
+// probability and that of the category below it.
+func (m *GeneralRegressionModel) evaluateOrdinal(values map[string]interface{}) (map[string]interface{}, error) {
+	probabilities := make([]float64, len(m.categories))
+	var previous float64
+	for i, category := range m.categories[:len(m.categories)-1] {
+		eta, ok, err := m.linearPredictor(category, false, values)
+		if err != nil || !ok {
+			return nil, err
+		}
+		cumulative := m.link(eta)
+		probabilities[i] = cumulative - previous
+		previous = cumulative
+	}
+	probabilities[len(probabilities)-1] = 1 - previous
+	return m.classification(probabilities), nil
+}
+
+// evaluateCox predicts the cumulative hazard at the end time: the baseline cumulative hazard of the
+// subject's stratum, times the exponential of the linear predictor centered on the reference points.
+func (m *GeneralRegressionModel) evaluateCox(values map[string]interface{}) (map[string]interface{}, error) {
+	t, ok, err := variable(values, m.EndTimeVariable, nil, 0)
+	if err != nil || !ok {
+		return nil, err
+	}
+	bt := m.BaseCumHazardTables
+	cells := bt.BaselineCells
+	var maxTime float64
+	if bt.MaxTime != nil {
+		maxTime = *bt.MaxTime
+	}
+	if len(bt.BaselineStrata) > 0 {
+		value := values[m.BaselineStrataVariable]
+		if value == nil {
+			return nil, nil
+		}
+		var stratum *BaselineStratum
+		for _, bs := range bt.BaselineStrata {
+			if transformations.EqualsValue(value, bs.Value) {
+				stratum = bs
+				break
+			}
+		}
+		if stratum == nil {
+			return nil, nil
+		}
+		cells, maxTime = stratum.BaselineCells, stratum.MaxTime
+	}
+	h0, ok := cumHazard(cells, maxTime, t)
+	if !ok {
+		return nil, nil
+	}
+	eta, ok, err := m.linearPredictor("", true, values)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return map[string]interface{}{m.GetOutputField(): h0 * math.Exp(eta)}, nil
+}
+
+// classification outputs the probability of each category, named by the category's probability output
+// field if there is one, and the most probable category, with ties going to the first.
+func (m *GeneralRegressionModel) classification(probabilities []float64) map[string]interface{} {
+	out := make(map[string]interface{}, len(probabilities)+1)
+	top := 0
+	for i, category := range m.categories {
+		if probabilities[i] > probabilities[top] {
+			top = i
+		}
+		// check if we have a output field for this value
+		name := category
+		if m.Output != nil {
+			if of, err := m.Output.GetFeature(category); err == nil {
+				name = of.Name
+			}
+		}
+		out[name] = probabilities[i]
+	}
+	out[m.GetOutputField()] = m.categories[top]
+	if m.Output != nil {
+		for _, of := range m.Output.OutputFields {
+			if of.Feature == "predictedValue" {
+				out[of.Name] = m.categories[top]
+			}
+		}
+	}
+	return out
+}
+
+func (m *GeneralRegressionModel) GetOutputField() string {
+	if target := m.MiningSchema.GetOutputField(); target != "" {
+		return target
+	}
+	return m.TargetVariableName
+}
+
+func (m *GeneralRegressionModel) GetMiningSchema() *miningschema.MiningSchema {
+	return m.MiningSchema
+}
+
+func (m *GeneralRegressionModel) GetOutput() *fields.Outputs {
+	return m.Output
+}
+
+func (m *GeneralRegressionModel) GetModelVerification() *verification.ModelVerification {
+	return m.ModelVerification
+}
@@ -0,0 +1,282 @@
+package generalregression_test
+
+import (
+	"encoding/xml"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stillmatic/pummel/pkg/generalregression"
+	"github.com/stretchr/testify/assert"
+)
+
+// eta = 0.5 + 0.2*x - 0.3*(color == red)
+var glmXML = `<GeneralRegressionModel targetVariableName="y" modelType="generalizedLinear" functionName="regression" linkFunction="identity">
+	<MiningSchema>
+		<MiningField name="x"/>
+		<MiningField name="color"/>
+		<MiningField name="n"/>
+		<MiningField name="y" usageType="target"/>
+	</MiningSchema>
+	<ParameterList>
+		<Parameter name="p0"/>
+		<Parameter name="p1"/>
+		<Parameter name="p2"/>
+	</ParameterList>
+	<FactorList>
+		<Predictor name="color"/>
+	</FactorList>
+	<CovariateList>
+		<Predictor name="x"/>
+	</CovariateList>
+	<PPMatrix>
+		<PPCell value="1" predictorName="x" parameterName="p1"/>
+		<PPCell value="red" predictorName="color" parameterName="p2"/>
+	</PPMatrix>
+	<ParamMatrix>
+		<PCell parameterName="p0" beta="0.5"/>
+		<PCell parameterName="p1" beta="0.2"/>
+		<PCell parameterName="p2" beta="-0.3"/>
+	</ParamMatrix>
+</GeneralRegressionModel>`
+
+func load(t *testing.T, s string) *generalregression.GeneralRegressionModel {
+	var m generalregression.GeneralRegressionModel
+	err := xml.Unmarshal([]byte(s), &m)
+	assert.NoError(t, err)
+	return &m
+}
+
+func TestGeneralizedLinearLinks(t *testing.T) {
+	// x = 1 and color = red, so eta = 0.4
+	eta := 0.4
+	tcs := []struct {
+		attrs    string
+		expected float64
+	}{
+		{`linkFunction="identity"`, eta},
+		{`linkFunction="log"`, math.Exp(eta)},
+		{`linkFunction="logc"`, 1 - math.Exp(eta)},
+		{`linkFunction="logit"`, 1 / (1 + math.Exp(-eta))},
+		{`linkFunction="probit"`, 0.6554217416103242},
+		{`linkFunction="cloglog"`, 1 - math.Exp(-math.Exp(eta))},
+		{`linkFunction="loglog"`, math.Exp(-math.Exp(-eta))},
+		{`linkFunction="power" linkParameter="0.5"`, eta * eta},
+		{`linkFunction="power" linkParameter="0"`, math.Exp(eta)},
+		{`linkFunction="oddspower" linkParameter="2"`, 1 / (1 + math.Pow(1+2*eta, -0.5))},
+		{`linkFunction="oddspower" linkParameter="0"`, 1 / (1 + math.Exp(-eta))},
+		{`linkFunction="negbin" distribution="negbin" distParameter="0.5"`, 1 / (0.5 * (math.Exp(-eta) - 1))},
+		// regression ignores the link function
+		{`linkFunction="log" modelType="regression"`, eta},
+	}
+	for _, tc := range tcs {
+		s := strings.Replace(glmXML, `linkFunction="identity"`, tc.attrs, 1)
+		if strings.Contains(tc.attrs, "modelType") {
+			s = strings.Replace(s, `modelType="generalizedLinear" `, "", 1)
+		}
+		m := load(t, s)
+		out, err := m.Evaluate(map[string]interface{}{"x": 1.0, "color": "red"})
+		assert.NoError(t, err)
+		assert.InDelta(t, tc.expected, out["y"], 1e-12, tc.attrs)
+	}
+}
+
+func TestGeneralizedLinearOffsetAndTrials(t *testing.T) {
+	m := load(t, strings.Replace(glmXML, `linkFunction="identity"`, `linkFunction="log" offsetVariable="offset" trialsValue="10"`, 1))
+	out, err := m.Evaluate(map[string]interface{}{"x": 2, "color": "blue", "offset": 0.1})
+	assert.NoError(t, err)
+	assert.InDelta(t, 10*math.Exp(1.0), out["y"], 1e-12)
+	// a missing offset or predictor is a missing result
+	out, err = m.Evaluate(map[string]interface{}{"x": 2, "color": "blue"})
+	assert.NoError(t, err)
+	assert.Nil(t, out)
+	out, err = m.Evaluate(map[string]interface{}{"x": 2, "offset": 0.1})
+	assert.NoError(t, err)
+	assert.Nil(t, out)
+
+	m = load(t, strings.Replace(glmXML, `linkFunction="identity"`, `linkFunction="logit" offsetValue="-0.4" trialsVariable="n"`, 1))
+	out, err = m.Evaluate(map[string]interface{}{"x": 0, "color": "red", "n": 4})
+	assert.NoError(t, err)
+	assert.InDelta(t, 4/(1+math.Exp(0.2)), out["y"], 1e-12)
+}
+
+func TestGeneralizedLinearClassification(t *testing.T) {
+	s := strings.Replace(glmXML, `functionName="regression" linkFunction="identity"`, `functionName="classification" linkFunction="logit" targetReferenceCategory="no"`, 1)
+	s = strings.Replace(s, `<PCell `, `<PCell targetCategory="yes" `, -1)
+	m := load(t, s)
+	out, err := m.Evaluate(map[string]interface{}{"x": 3, "color": "green"})
+	assert.NoError(t, err)
+	p := 1 / (1 + math.Exp(-1.1))
+	assert.Equal(t, "yes", out["y"])
+	assert.InDelta(t, p, out["yes"], 1e-12)
+	assert.InDelta(t, 1-p, out["no"], 1e-12)
+}
+
+var multinomialXML = `<GeneralRegressionModel modelType="multinomialLogistic" functionName="classification" targetReferenceCategory="c">
+	<MiningSchema>
+		<MiningField name="x"/>
+		<MiningField name="y" usageType="target"/>
+	</MiningSchema>
+	<Output>
+		<OutputField name="p(a)" feature="probability" value="a"/>
+		<OutputField name="predicted" feature="predictedValue"/>
+	</Output>
+	<ParameterList>
+		<Parameter name="p0"/>
+		<Parameter name="p1"/>
+	</ParameterList>
+	<CovariateList>
+		<Predictor name="x"/>
+	</CovariateList>
+	<PPMatrix>
+		<PPCell value="1" predictorName="x" parameterName="p1"/>
+	</PPMatrix>
+	<ParamMatrix>
+		<PCell targetCategory="a" parameterName="p0" beta="1"/>
+		<PCell targetCategory="a" parameterName="p1" beta="-1"/>
+		<PCell targetCategory="b" parameterName="p0" beta="-1"/>
+		<PCell targetCategory="b" parameterName="p1" beta="1"/>
+	</ParamMatrix>
+</GeneralRegressionModel>`
+
+func TestMultinomialLogistic(t *testing.T) {
+	m := load(t, multinomialXML)
+	tcs := []struct {
+		x        float64
+		expected string
+		etas     []float64
+	}{
+		{0, "a", []float64{1, -1, 0}},
+		{1, "a", []float64{0, 0, 0}},
+		{3, "b", []float64{-2, 2, 0}},
+	}
+	for _, tc := range tcs {
+		out, err := m.Evaluate(map[string]interface{}{"x": tc.x})
+		assert.NoError(t, err)
+		var sum float64
+		for _, eta := range tc.etas {
+			sum += math.Exp(eta)
+		}
+		assert.Equal(t, tc.expected, out["y"])
+		assert.Equal(t, tc.expected, out["predicted"])
+		assert.InDelta(t, math.Exp(tc.etas[0])/sum, out["p(a)"], 1e-12)
+		assert.InDelta(t, math.Exp(tc.etas[1])/sum, out["b"], 1e-12)
+		assert.InDelta(t, math.Exp(tc.etas[2])/sum, out["c"], 1e-12)
+	}
+}
+
+func TestOrdinalMultinomial(t *testing.T) {
+	s := strings.Replace(multinomialXML, `modelType="multinomialLogistic"`, `modelType="ordinalMultinomial" cumulativeLink="cloglog"`, 1)
+	s = strings.Replace(s, `<PCell targetCategory="a" parameterName="p1" beta="-1"/>`, ``, 1)
+	s = strings.Replace(s, `<PCell targetCategory="b" parameterName="p1" beta="1"/>`, `<PCell parameterName="p1" beta="0.5"/>`, 1)
+	m := load(t, s)
+	out, err := m.Evaluate(map[string]interface{}{"x": 2.0})
+	assert.NoError(t, err)
+	a := 1 - math.Exp(-math.Exp(2))
+	b := 1 - math.Exp(-math.Exp(0))
+	assert.InDelta(t, a, out["p(a)"], 1e-12)
+	assert.InDelta(t, b-a, out["b"], 1e-12)
+	assert.InDelta(t, 1-b, out["c"], 1e-12)
+	assert.Equal(t, "a", out["y"])
+}
+
+var coxXML = `<GeneralRegressionModel targetVariableName="hazard" modelType="CoxRegression" functionName="regression" endTimeVariable="time" statusVariable="status" baselineStrataVariable="group">
+	<MiningSchema>
+		<MiningField name="x"/>
+		<MiningField name="time"/>
+		<MiningField name="status"/>
+		<MiningField name="group"/>
+		<MiningField name="hazard" usageType="target"/>
+	</MiningSchema>
+	<ParameterList>
+		<Parameter name="p0" referencePoint="2"/>
+	</ParameterList>
+	<CovariateList>
+		<Predictor name="x"/>
+	</CovariateList>
+	<PPMatrix>
+		<PPCell value="1" predictorName="x" parameterName="p0"/>
+	</PPMatrix>
+	<ParamMatrix>
+		<PCell parameterName="p0" beta="0.5"/>
+	</ParamMatrix>
+	<BaseCumHazardTables>
+		<BaselineStratum value="1" maxTime="10">
+			<BaselineCell time="5" cumHazard="0.3"/>
+			<BaselineCell time="2" cumHazard="0.1"/>
+			<BaselineCell time="8" cumHazard="0.6"/>
+		</BaselineStratum>
+		<BaselineStratum value="2" maxTime="4">
+			<BaselineCell time="1" cumHazard="0.2"/>
+		</BaselineStratum>
+	</BaseCumHazardTables>
+</GeneralRegressionModel>`
+
+func TestCoxRegression(t *testing.T) {
+	m := load(t, coxXML)
+	tcs := []struct {
+		x, time  float64
+		group    interface{}
+		expected interface{}
+	}{
+		{2, 1, 1, 0.0},
+		{2, 2, 1, 0.1},
+		{4, 6.5, 1, 0.3 * math.E},
+		{0, 10, "1", 0.6 / math.E},
+		{2, 3, 2, 0.2},
+		// after the last time of the stratum, or in an unknown stratum
+		{2, 10.5, 1, nil},
+		{2, 3, 3, nil},
+	}
+	for _, tc := range tcs {
+		out, err := m.Evaluate(map[string]interface{}{"x": tc.x, "time": tc.time, "group": tc.group})
+		assert.NoError(t, err)
+		if tc.expected == nil {
+			assert.Nil(t, out)
+			continue
+		}
+		assert.InDelta(t, tc.expected, out["hazard"], 1e-12)
+	}
+
+	// without strata, the table's maxTime applies
+	s := strings.Replace(coxXML, `<BaselineStratum value="2" maxTime="4">
+			<BaselineCell time="1" cumHazard="0.2"/>
+		</BaselineStratum>`, "", 1)
+	s = strings.Replace(s, `<BaselineStratum value="1" maxTime="10">`, "", 1)
+	s = strings.Replace(s, `</BaselineStratum>`, "", 1)
+	s = strings.Replace(s, `<BaseCumHazardTables>`, `<BaseCumHazardTables maxTime="9">`, 1)
+	s = strings.Replace(s, ` baselineStrataVariable="group"`, "", 1)
+	m = load(t, s)
+	out, err := m.Evaluate(map[string]interface{}{"x": 2, "time": 9})
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.6, out["hazard"], 1e-12)
+	out, err = m.Evaluate(map[string]interface{}{"x": 2, "time": 9.5})
+	assert.NoError(t, err)
+	assert.Nil(t, out)
+}
+
+func TestGeneralRegressionErrors(t *testing.T) {
+	tcs := []struct {
+		old, new string
+		expected string
+	}{
+		{`modelType="generalizedLinear"`, `modelType="linear"`, "unknown model type: linear"},
+		{`linkFunction="identity"`, `linkFunction="power"`, "link function power requires linkParameter"},
+		{`linkFunction="identity"`, `linkFunction="inverse"`, "unknown link function: inverse"},
+		{`<PCell parameterName="p2" beta="-0.3"/>`, `<PCell parameterName="p3" beta="-0.3"/>`, "PCell refers to unknown parameter p3"},
+		{`predictorName="color"`, `predictorName="colour"`, "PPCell refers to unknown predictor colour"},
+		{`<PPCell value="1"`, `<PPCell value="one"`, `invalid exponent of covariate x: strconv.ParseFloat: parsing "one": invalid syntax`},
+		{`<Predictor name="color"/>`, `<Predictor name="color" contrastMatrixType="helmert"/>`, "unsupported contrast matrix of predictor color"},
+		{`functionName="regression"`, `functionName="classification"`,
+			"binary generalizedLinear classification requires coefficients for a single target category and a targetReferenceCategory"},
+	}
+	for _, tc := range tcs {
+		var m generalregression.GeneralRegressionModel
+		err := xml.Unmarshal([]byte(strings.Replace(glmXML, tc.old, tc.new, 1)), &m)
+		assert.EqualError(t, err, tc.expected)
+	}
+
+	var m generalregression.GeneralRegressionModel
+	err := xml.Unmarshal([]byte(strings.Replace(multinomialXML, `modelType="multinomialLogistic" functionName="classification" targetReferenceCategory="c"`, `modelType="ordinalMultinomial" cumulativeLink="logit"`, 1)), &m)
+	assert.EqualError(t, err, "ordinalMultinomial requires a targetReferenceCategory or probability output naming the last category")
+}
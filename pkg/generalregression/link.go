@@ -0,0 +1,106 @@
+package generalregression
+
+import (
+	"fmt"
+	"math"
+)
+
+var LinkFunctions = struct {
+	CLogLog   string
+	Identity  string
+	Log       string
+	LogC      string
+	Logit     string
+	LogLog    string
+	NegBin    string
+	OddsPower string
+	Power     string
+	Probit    string
+}{
+	CLogLog:   "cloglog",
+	Identity:  "identity",
+	Log:       "log",
+	LogC:      "logc",
+	Logit:     "logit",
+	LogLog:    "loglog",
+	NegBin:    "negbin",
+	OddsPower: "oddspower",
+	Power:     "power",
+	Probit:    "probit",
+}
+
+var CumulativeLinks = struct {
+	Logit   string
+	Probit  string
+	CLogLog string
+	LogLog  string
+	Cauchit string
+}{
+	Logit:   "logit",
+	Probit:  "probit",
+	CLogLog: "cloglog",
+	LogLog:  "loglog",
+	Cauchit: "cauchit",
+}
+
+func logistic(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
+
+// normalCDF is the cumulative distribution function of the standard normal distribution.
+func normalCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
+
+// inverseLink returns the inverse of a link function of a generalized linear model, which maps the linear
+// predictor onto the expected value of the target. power and oddspower take the linkParameter, and negbin
+// the distParameter.
+func inverseLink(link string, param float64) (func(float64) float64, error) {
+	switch link {
+	case LinkFunctions.CLogLog:
+		return func(eta float64) float64 { return 1 - math.Exp(-math.Exp(eta)) }, nil
+	case LinkFunctions.Identity:
+		return func(eta float64) float64 { return eta }, nil
+	case LinkFunctions.Log:
+		return math.Exp, nil
+	case LinkFunctions.LogC:
+		return func(eta float64) float64 { return 1 - math.Exp(eta) }, nil
+	case LinkFunctions.Logit:
+		return logistic, nil
+	case LinkFunctions.LogLog:
+		return func(eta float64) float64 { return math.Exp(-math.Exp(-eta)) }, nil
+	case LinkFunctions.NegBin:
+		return func(eta float64) float64 { return 1 / (param * (math.Exp(-eta) - 1)) }, nil
+	case LinkFunctions.OddsPower:
+		if param == 0 {
+			return logistic, nil
+		}
+		return func(eta float64) float64 { return 1 / (1 + math.Pow(1+param*eta, -1/param)) }, nil
+	case LinkFunctions.Power:
+		if param == 0 {
+			return math.Exp, nil
+		}
+		return func(eta float64) float64 { return math.Pow(eta, 1/param) }, nil
+	case LinkFunctions.Probit:
+		return normalCDF, nil
+	}
+	return nil, fmt.Errorf("unknown link function: %s", link)
+}
+
+// cumulativeLink returns the inverse of a cumulative link of an ordinal model, which maps the linear
+// predictor of a category onto the probability of that category or a lower one.
+func cumulativeLink(link string) (func(float64) float64, error) {
+	switch link {
+	case CumulativeLinks.Logit:
+		return logistic, nil
+	case CumulativeLinks.Probit:
+		return normalCDF, nil
+	case CumulativeLinks.CLogLog:
+		return func(eta float64) float64 { return 1 - math.Exp(-math.Exp(eta)) }, nil
+	case CumulativeLinks.LogLog:
+		return func(eta float64) float64 { return math.Exp(-math.Exp(-eta)) }, nil
+	case CumulativeLinks.Cauchit:
+		return func(eta float64) float64 { return 0.5 + math.Atan(eta)/math.Pi }, nil
+	}
+	return nil, fmt.Errorf("unknown cumulative link: %s", link)
+}
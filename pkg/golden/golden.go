@@ -0,0 +1,190 @@
+// Package golden checks pummel against golden files: the expected results of scoring a model on input records.
+//
+// A case is a directory holding a model, input records and the expected results:
+//
+//	model.pmml    the model
+//	input.csv     one record per row, with a header naming the input fields
+//	expected.csv  the expected result of each input row, with a header naming the result fields
+//	case.json     optional settings: {"precision": 1e-6, "zeroThreshold": 1e-16, "skip": "reason"}
+//
+// Only the columns of expected.csv are compared, so they may be a subset of the model's results.
+package golden
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/stillmatic/pummel"
+	"github.com/stillmatic/pummel/pkg/batch"
+	"github.com/stillmatic/pummel/pkg/verification"
+)
+
+const (
+	ModelFile    = "model.pmml"
+	InputFile    = "input.csv"
+	ExpectedFile = "expected.csv"
+	ConfigFile   = "case.json"
+)
+
+// Case is a single golden case.
+// Numeric results are compared as by verification.VerificationField, using Precision and ZeroThreshold.
+type Case struct {
+	Name          string  `json:"-"`
+	Dir           string  `json:"-"`
+	Precision     float64 `json:"precision"`
+	ZeroThreshold float64 `json:"zeroThreshold"`
+	// Skip is the reason the case is skipped, e.g. a known difference which is yet to be fixed.
+	Skip string `json:"skip"`
+}
+
+// Discover returns every case under root, sorted by name. A case is any directory containing a model.pmml;
+// its name is its path relative to root.
+func Discover(root string) ([]*Case, error) {
+	var cases []*Case
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || info.Name() != ModelFile {
+			return nil
+		}
+		dir := filepath.Dir(path)
+		name, err := filepath.Rel(root, dir)
+		if err != nil {
+			return err
+		}
+		c, err := newCase(filepath.ToSlash(name), dir)
+		if err != nil {
+			return err
+		}
+		cases = append(cases, c)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(cases, func(i, j int) bool { return cases[i].Name < cases[j].Name })
+	return cases, nil
+}
+
+func newCase(name, dir string) (*Case, error) {
+	c := &Case{
+		Name:          name,
+		Dir:           dir,
+		Precision:     verification.DefaultPrecision,
+		ZeroThreshold: verification.DefaultZeroThreshold,
+	}
+	b, err := os.ReadFile(filepath.Join(dir, ConfigFile))
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, errors.Wrapf(err, "invalid %s of case %s", ConfigFile, name)
+	}
+	return c, nil
+}
+
+// Run evaluates every input row and compares the results with the expected row.
+// Rows which fail to evaluate are reported as mismatches; an error is only returned if the case cannot be run.
+func (c *Case) Run() (*verification.Report, error) {
+	m, err := pummel.LoadFile(filepath.Join(c.Dir, ModelFile))
+	if err != nil {
+		return nil, err
+	}
+	results, err := c.evaluate(m)
+	if err != nil {
+		return nil, err
+	}
+	header, expected, err := readCSV(filepath.Join(c.Dir, ExpectedFile))
+	if err != nil {
+		return nil, err
+	}
+	if len(expected) != len(results) {
+		return nil, fmt.Errorf("%s has %d rows, but %s has %d", InputFile, len(results), ExpectedFile, len(expected))
+	}
+
+	report := &verification.Report{Rows: len(results)}
+	for i, res := range results {
+		if res.err != nil {
+			report.Mismatches = append(report.Mismatches, &verification.Mismatch{Row: i + 1, Err: res.err})
+			continue
+		}
+		for j, field := range header {
+			vf := &verification.VerificationField{Field: field, Precision: c.Precision, ZeroThreshold: c.ZeroThreshold}
+			want := expected[i][j]
+			if isMissing(want) {
+				want = ""
+			}
+			if got := res.outputs[field]; !vf.Match(want, got) {
+				report.Mismatches = append(report.Mismatches, &verification.Mismatch{Row: i + 1, Field: field, Expected: want, Actual: got})
+			}
+		}
+	}
+	return report, nil
+}
+
+type result struct {
+	outputs map[string]interface{}
+	err     error
+}
+
+// collector is a batch.Writer which keeps every result in memory.
+type collector struct {
+	results []result
+}
+
+func (c *collector) Write(_ map[string]interface{}, outputs map[string]interface{}, err error) error {
+	c.results = append(c.results, result{outputs: outputs, err: err})
+	return nil
+}
+
+func (c *collector) Flush() error {
+	return nil
+}
+
+func (c *Case) evaluate(m *pummel.Model) ([]result, error) {
+	f, err := os.Open(filepath.Join(c.Dir, InputFile))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := batch.NewCSVReader(f)
+	if err != nil {
+		return nil, err
+	}
+	w := &collector{}
+	scorer := &batch.Scorer{Model: m, Coerce: m.DataDictionary.Coerce}
+	if _, err := scorer.Score(r, w); err != nil {
+		return nil, err
+	}
+	return w.results, nil
+}
+
+func readCSV(path string) ([]string, [][]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to read %s", path)
+	}
+	if len(records) == 0 {
+		return nil, nil, fmt.Errorf("%s has no header", path)
+	}
+	return records[0], records[1:], nil
+}
+
+// isMissing reports whether an expected cell denotes a missing result, as written by JPMML-Evaluator or R.
+func isMissing(cell string) bool {
+	return cell == "" || cell == "N/A" || cell == "NA"
+}
@@ -0,0 +1,85 @@
+package golden_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stillmatic/pummel/pkg/golden"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestGolden runs the cases in testdata/golden, and in each directory listed in
+// PUMMEL_GOLDEN_DIR.
+func TestGolden(t *testing.T) {
+	roots := []string{"../../testdata/golden"}
+	if dirs := os.Getenv("PUMMEL_GOLDEN_DIR"); dirs != "" {
+		roots = append(roots, filepath.SplitList(dirs)...)
+	}
+	for _, root := range roots {
+		cases, err := golden.Discover(root)
+		assert.NoError(t, err)
+		for _, c := range cases {
+			c := c
+			t.Run(c.Name, func(t *testing.T) {
+				if c.Skip != "" {
+					t.Skip(c.Skip)
+				}
+				report, err := c.Run()
+				if !assert.NoError(t, err) {
+					return
+				}
+				for _, m := range report.Mismatches {
+					t.Error(m)
+				}
+			})
+		}
+	}
+}
+
+func writeCase(t *testing.T, dir string, files map[string]string) {
+	assert.NoError(t, os.MkdirAll(dir, 0o755))
+	for name, content := range files {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+}
+
+func TestRunReportsMismatches(t *testing.T) {
+	pmml, err := os.ReadFile("../../testdata/lr.pmml")
+	assert.NoError(t, err)
+	root := t.TempDir()
+	writeCase(t, filepath.Join(root, "nested", "lr"), map[string]string{
+		golden.ModelFile:    string(pmml),
+		golden.InputFile:    "x0,x1,x2,x3\n0.1,0.1,100,0.1\nabc,0,0,0\n0.1,0.1,0.1,0.1\n",
+		golden.ExpectedFile: "labels,probability(CATEGORY_2)\nCATEGORY_2,0.999\nCATEGORY_0,0\nCATEGORY_0,N/A\n",
+		golden.ConfigFile:   `{"precision": 1e-2}`,
+	})
+	writeCase(t, filepath.Join(root, "short"), map[string]string{
+		golden.ModelFile:    string(pmml),
+		golden.InputFile:    "x0,x1,x2,x3\n0.1,0.1,100,0.1\n",
+		golden.ExpectedFile: "labels\n",
+		golden.ConfigFile:   `{"skip": "not ready"}`,
+	})
+
+	cases, err := golden.Discover(root)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(cases))
+	assert.Equal(t, "nested/lr", cases[0].Name)
+	assert.Equal(t, 1e-2, cases[0].Precision)
+	assert.Equal(t, "not ready", cases[1].Skip)
+
+	report, err := cases[0].Run()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, report.Rows)
+	var messages []string
+	for _, m := range report.Mismatches {
+		messages = append(messages, m.String())
+	}
+	assert.Equal(t, 3, len(messages), messages)
+	assert.Contains(t, messages[0], "row 2: invalid double value for field x0")
+	assert.Equal(t, `row 3: labels: expected "CATEGORY_0", got CATEGORY_3`, messages[1])
+	assert.Contains(t, messages[2], `row 3: probability(CATEGORY_2): expected ""`)
+
+	_, err = cases[1].Run()
+	assert.Error(t, err)
+}
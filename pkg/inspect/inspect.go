@@ -0,0 +1,596 @@
+// Package inspect summarizes the structure and schema of a PMML model.
+package inspect
+
+import (
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/stillmatic/pummel"
+	"github.com/stillmatic/pummel/pkg/association"
+	"github.com/stillmatic/pummel/pkg/bayesiannetwork"
+	"github.com/stillmatic/pummel/pkg/clustering"
+	"github.com/stillmatic/pummel/pkg/fields"
+	"github.com/stillmatic/pummel/pkg/gaussianprocess"
+	"github.com/stillmatic/pummel/pkg/generalregression"
+	"github.com/stillmatic/pummel/pkg/miningschema"
+	"github.com/stillmatic/pummel/pkg/model"
+	"github.com/stillmatic/pummel/pkg/naivebayes"
+	"github.com/stillmatic/pummel/pkg/nearestneighbor"
+	"github.com/stillmatic/pummel/pkg/neuralnetwork"
+	"github.com/stillmatic/pummel/pkg/node"
+	"github.com/stillmatic/pummel/pkg/predicates"
+	"github.com/stillmatic/pummel/pkg/regression"
+	"github.com/stillmatic/pummel/pkg/ruleset"
+	"github.com/stillmatic/pummel/pkg/scorecard"
+	"github.com/stillmatic/pummel/pkg/svm"
+	"github.com/stillmatic/pummel/pkg/timeseries"
+	"github.com/stillmatic/pummel/pkg/tree"
+)
+
+type Summary struct {
+	PMMLVersion        string   `json:"pmmlVersion,omitempty"`
+	Description        string   `json:"description,omitempty"`
+	Copyright          string   `json:"copyright,omitempty"`
+	ModelVersion       string   `json:"modelVersion,omitempty"`
+	Application        string   `json:"application,omitempty"`
+	ApplicationVersion string   `json:"applicationVersion,omitempty"`
+	Timestamp          string   `json:"timestamp,omitempty"`
+	ModelName          string   `json:"modelName,omitempty"`
+	Inputs             []*Field `json:"inputs"`
+	Targets            []*Field `json:"targets"`
+	Outputs            []*Field `json:"outputs"`
+	Model              *Stats   `json:"model"`
+}
+
+// Field describes an input, target or output field.
+// Inputs and targets combine their MiningField with their DataField.
+type Field struct {
+	Name      string   `json:"name"`
+	UsageType string   `json:"usageType,omitempty"`
+	OpType    string   `json:"opType,omitempty"`
+	DataType  string   `json:"dataType,omitempty"`
+	Values    []string `json:"values,omitempty"`
+	// Feature and Value are only set for output fields.
+	Feature string `json:"feature,omitempty"`
+	Value   string `json:"value,omitempty"`
+}
+
+// Stats describes a model element. Which statistics are set depends on the element:
+// tree, association, support vector machine and naive Bayes statistics are summed over every model of an
+// ensemble, and regression tables, general regression coefficients, scorecard characteristics, rules, clusters
+// and neural layers are listed for the models holding them, including segments.
+type Stats struct {
+	Element      string `json:"element"`
+	FunctionName string `json:"functionName,omitempty"`
+
+	MultipleModelMethod string `json:"multipleModelMethod,omitempty"`
+	Segments            int    `json:"segments,omitempty"`
+	// SegmentElements counts the model elements of each kind in the segmentation, including nested ones.
+	SegmentElements map[string]int `json:"segmentElements,omitempty"`
+
+	Trees  int `json:"trees,omitempty"`
+	Nodes  int `json:"nodes,omitempty"`
+	Leaves int `json:"leaves,omitempty"`
+	// Depth is the number of splits on the longest path from a root to a leaf.
+	Depth         int             `json:"depth,omitempty"`
+	SplitFeatures []*SplitFeature `json:"splitFeatures,omitempty"`
+
+	RegressionTables []*RegressionTable `json:"regressionTables,omitempty"`
+
+	// ModelType and Link describe a general regression, whose Link is its link function or cumulative link.
+	ModelType string   `json:"modelType,omitempty"`
+	Link      string   `json:"link,omitempty"`
+	PCells    []*PCell `json:"pCells,omitempty"`
+
+	// ReasonCodeAlgorithm is only set for scorecards which use reason codes.
+	ReasonCodeAlgorithm string            `json:"reasonCodeAlgorithm,omitempty"`
+	Characteristics     []*Characteristic `json:"characteristics,omitempty"`
+
+	// ComparisonMeasure is the measure of a clustering or nearest neighbor model and its kind,
+	// e.g. "euclidean distance".
+	ComparisonMeasure string     `json:"comparisonMeasure,omitempty"`
+	Clusters          []*Cluster `json:"clusters,omitempty"`
+	Neighbors         int        `json:"neighbors,omitempty"`
+	TrainingInstances int        `json:"trainingInstances,omitempty"`
+
+	// RuleSelectionMethod is the criterion a rule set selects the rules which fire by.
+	RuleSelectionMethod string  `json:"ruleSelectionMethod,omitempty"`
+	Rules               []*Rule `json:"rules,omitempty"`
+
+	// AnomalyAlgorithm is the algorithm type of an anomaly detection model, whose inner model is described by
+	// the other statistics. SampleDataSize is only set for isolation forests.
+	AnomalyAlgorithm string `json:"anomalyAlgorithm,omitempty"`
+	SampleDataSize   int    `json:"sampleDataSize,omitempty"`
+
+	// Forecast describes the algorithm of a time series model, e.g. "ARIMA(1,1,1)(1,0,0)4 by conditionalLeastSquares",
+	// and History counts the values of the series it forecasts from.
+	Forecast string `json:"forecast,omitempty"`
+	History  int    `json:"history,omitempty"`
+
+	// DiscreteNodes and ContinuousNodes count the nodes of a Bayesian network.
+	DiscreteNodes   int `json:"discreteNodes,omitempty"`
+	ContinuousNodes int `json:"continuousNodes,omitempty"`
+
+	// Items, Itemsets and AssociationRules count the elements of an association model.
+	Items            int `json:"items,omitempty"`
+	Itemsets         int `json:"itemsets,omitempty"`
+	AssociationRules int `json:"associationRules,omitempty"`
+
+	// Kernel is the kernel of a support vector machine or the covariance function of a Gaussian process.
+	Kernel                string `json:"kernel,omitempty"`
+	SupportVectorMachines int    `json:"supportVectorMachines,omitempty"`
+	SupportVectors        int    `json:"supportVectors,omitempty"`
+
+	NeuralInputs int            `json:"neuralInputs,omitempty"`
+	NeuralLayers []*NeuralLayer `json:"neuralLayers,omitempty"`
+
+	// BayesInputs counts the inputs of a naive Bayes model, and ContinuousBayesInputs those with distributions.
+	BayesInputs           int `json:"bayesInputs,omitempty"`
+	ContinuousBayesInputs int `json:"continuousBayesInputs,omitempty"`
+	BayesCategories       int `json:"bayesCategories,omitempty"`
+
+	splits map[string]int
+}
+
+// SplitFeature counts the tree nodes whose predicate tests a field.
+type SplitFeature struct {
+	Name   string `json:"name"`
+	Splits int    `json:"splits"`
+}
+
+type RegressionTable struct {
+	// Segment is the id of the segment holding the regression, if it is part of an ensemble.
+	Segment        string         `json:"segment,omitempty"`
+	TargetCategory string         `json:"targetCategory,omitempty"`
+	Intercept      float64        `json:"intercept"`
+	Coefficients   []*Coefficient `json:"coefficients"`
+}
+
+// PCell is a coefficient of a general regression. Label describes the parameter, if the model labels it.
+type PCell struct {
+	Segment        string  `json:"segment,omitempty"`
+	Parameter      string  `json:"parameter"`
+	Label          string  `json:"label,omitempty"`
+	TargetCategory string  `json:"targetCategory,omitempty"`
+	Beta           float64 `json:"beta"`
+}
+
+// Characteristic describes a characteristic of a scorecard, with the baseline score its reason codes
+// are ranked against.
+type Characteristic struct {
+	Segment       string   `json:"segment,omitempty"`
+	Name          string   `json:"name"`
+	Attributes    int      `json:"attributes"`
+	BaselineScore *float64 `json:"baselineScore,omitempty"`
+}
+
+type Cluster struct {
+	Segment string `json:"segment,omitempty"`
+	ID      string `json:"id"`
+	Name    string `json:"name,omitempty"`
+	Size    int    `json:"size,omitempty"`
+}
+
+// Rule is a simple rule of a rule set, including those nested in compound rules.
+type Rule struct {
+	Segment    string  `json:"segment,omitempty"`
+	ID         string  `json:"id"`
+	Score      string  `json:"score"`
+	Confidence float64 `json:"confidence"`
+	Weight     float64 `json:"weight"`
+}
+
+// NeuralLayer describes a layer of a neural network, with the activation and normalization it inherits
+// from the network if it does not set its own.
+type NeuralLayer struct {
+	// Segment is the id of the segment holding the network, if it is part of an ensemble.
+	Segment             string `json:"segment,omitempty"`
+	Neurons             int    `json:"neurons"`
+	ActivationFunction  string `json:"activationFunction"`
+	NormalizationMethod string `json:"normalizationMethod,omitempty"`
+}
+
+// Coefficient is a term of a regression table.
+// Type is numeric, categorical or term; Value is the category of categorical predictors,
+// and Fields the fields multiplied by a term.
+type Coefficient struct {
+	Type        string   `json:"type"`
+	Name        string   `json:"name"`
+	Value       string   `json:"value,omitempty"`
+	Exponent    float64  `json:"exponent,omitempty"`
+	Fields      []string `json:"fields,omitempty"`
+	Coefficient float64  `json:"coefficient"`
+}
+
+// Inspect summarizes m.
+func Inspect(m *pummel.Model) *Summary {
+	s := &Summary{
+		PMMLVersion: m.Version,
+		ModelName:   m.ModelName,
+		Inputs:      make([]*Field, 0),
+		Targets:     make([]*Field, 0),
+		Outputs:     make([]*Field, 0),
+	}
+	if h := m.Header; h != nil {
+		s.Description = h.Description
+		s.Copyright = h.Copyright
+		s.ModelVersion = h.ModelVersion
+		s.Timestamp = h.Timestamp
+		if h.Application != nil {
+			s.Application = h.Application.Name
+			s.ApplicationVersion = h.Application.Version
+		}
+	}
+	if ms := m.GetMiningSchema(); ms != nil {
+		for _, mf := range ms.MiningFields {
+			f := newField(m.DataDictionary, mf)
+			switch mf.UsageType {
+			case "", "active":
+				s.Inputs = append(s.Inputs, f)
+			case "target", "predicted":
+				s.Targets = append(s.Targets, f)
+			}
+		}
+	}
+	if out := m.GetOutput(); out != nil {
+		s.Outputs = outputFields(out)
+	}
+	s.Model = newStats(m.Element, m.ModelElement)
+	return s
+}
+
+func newField(dd *model.DataDictionary, mf *miningschema.MiningField) *Field {
+	usageType := mf.UsageType
+	if usageType == "" {
+		usageType = "active"
+	}
+	f := &Field{Name: mf.Name, UsageType: usageType, OpType: mf.OpType}
+	df := dd.GetDataField(mf.Name)
+	if df == nil {
+		return f
+	}
+	f.DataType = df.DataType
+	if f.OpType == "" {
+		f.OpType = df.OpType
+	}
+	for _, v := range df.Values {
+		if v.Property == "" || v.Property == "valid" {
+			f.Values = append(f.Values, v.Value)
+		}
+	}
+	return f
+}
+
+func outputFields(out *fields.Outputs) []*Field {
+	res := make([]*Field, 0, len(out.OutputFields))
+	for _, of := range out.OutputFields {
+		res = append(res, &Field{
+			Name:     of.Name,
+			OpType:   of.OpType,
+			DataType: of.DataType,
+			Feature:  of.Feature,
+			Value:    of.Value,
+		})
+	}
+	return res
+}
+
+func newStats(element string, me model.ModelElement) *Stats {
+	s := &Stats{Element: element, splits: make(map[string]int)}
+	s.add(me, "")
+	s.SplitFeatures = make([]*SplitFeature, 0, len(s.splits))
+	for name, n := range s.splits {
+		s.SplitFeatures = append(s.SplitFeatures, &SplitFeature{Name: name, Splits: n})
+	}
+	sort.Slice(s.SplitFeatures, func(i, j int) bool {
+		a, b := s.SplitFeatures[i], s.SplitFeatures[j]
+		if a.Splits != b.Splits {
+			return a.Splits > b.Splits
+		}
+		return a.Name < b.Name
+	})
+	return s
+}
+
+// add accumulates the statistics of me, which is the model of the given segment, if any.
+func (s *Stats) add(me model.ModelElement, segment string) {
+	switch me := me.(type) {
+	case *tree.TreeModel:
+		if s.FunctionName == "" {
+			s.FunctionName = me.FunctionName
+		}
+		s.Trees++
+		if me.Node != nil {
+			if depth := s.addNode(me.Node, 0); depth > s.Depth {
+				s.Depth = depth
+			}
+		}
+	case *regression.RegressionModel:
+		if s.FunctionName == "" {
+			s.FunctionName = me.FunctionName
+		}
+		for _, rt := range me.RegressionTables {
+			s.RegressionTables = append(s.RegressionTables, newRegressionTable(rt, segment))
+		}
+	case *generalregression.GeneralRegressionModel:
+		if s.FunctionName == "" {
+			s.FunctionName = me.FunctionName
+		}
+		s.ModelType = me.ModelType
+		s.Link = me.LinkFunction
+		if me.ModelType == generalregression.ModelTypes.OrdinalMultinomial {
+			s.Link = me.CumulativeLink
+		}
+		labels := make(map[string]string, len(me.Parameters))
+		for _, p := range me.Parameters {
+			labels[p.Name] = p.Label
+		}
+		for _, c := range me.ParamMatrix {
+			s.PCells = append(s.PCells, &PCell{
+				Segment:        segment,
+				Parameter:      c.ParameterName,
+				Label:          labels[c.ParameterName],
+				TargetCategory: c.TargetCategory,
+				Beta:           c.Beta,
+			})
+		}
+	case *clustering.ClusteringModel:
+		if s.FunctionName == "" {
+			s.FunctionName = me.FunctionName
+		}
+		s.ComparisonMeasure = me.ComparisonMeasure.Measure + " " + me.ComparisonMeasure.Kind
+		for _, c := range me.Clusters {
+			s.Clusters = append(s.Clusters, &Cluster{Segment: segment, ID: c.ID, Name: c.Name, Size: c.Size})
+		}
+	case *nearestneighbor.NearestNeighborModel:
+		if s.FunctionName == "" {
+			s.FunctionName = me.FunctionName
+		}
+		s.ComparisonMeasure = me.ComparisonMeasure.Measure + " " + me.ComparisonMeasure.Kind
+		s.Neighbors = me.NumberOfNeighbors
+		s.TrainingInstances = len(me.TrainingInstances.InlineTable.Rows)
+	case *ruleset.RuleSetModel:
+		if s.FunctionName == "" {
+			s.FunctionName = me.FunctionName
+		}
+		s.RuleSelectionMethod = me.RuleSet.Criteria[0]
+		s.addRules(me.RuleSet.Rules, segment)
+	case *association.AssociationModel:
+		if s.FunctionName == "" {
+			s.FunctionName = me.FunctionName
+		}
+		s.Items += len(me.Items)
+		s.Itemsets += len(me.Itemsets)
+		s.AssociationRules += len(me.AssociationRules)
+	case *scorecard.Scorecard:
+		if s.FunctionName == "" {
+			s.FunctionName = me.FunctionName
+		}
+		if me.UseReasonCodes {
+			s.ReasonCodeAlgorithm = me.ReasonCodeAlgorithm
+		}
+		for _, c := range me.Characteristics {
+			s.Characteristics = append(s.Characteristics, &Characteristic{
+				Segment:       segment,
+				Name:          c.Name,
+				Attributes:    len(c.Attributes),
+				BaselineScore: c.BaselineScore,
+			})
+		}
+	case *neuralnetwork.NeuralNetwork:
+		if s.FunctionName == "" {
+			s.FunctionName = me.FunctionName
+		}
+		s.NeuralInputs += len(me.NeuralInputs)
+		for _, nl := range me.NeuralLayers {
+			layer := &NeuralLayer{Segment: segment, Neurons: len(nl.Neurons), ActivationFunction: nl.ActivationFunction}
+			if nl.NormalizationMethod != neuralnetwork.NormalizationMethods.None {
+				layer.NormalizationMethod = nl.NormalizationMethod
+			}
+			s.NeuralLayers = append(s.NeuralLayers, layer)
+		}
+	case *svm.SupportVectorMachineModel:
+		if s.FunctionName == "" {
+			s.FunctionName = me.FunctionName
+		}
+		if me.SVMRepresentation == svm.SVMRepresentations.Coefficients {
+			s.Kernel = "linear"
+		} else if me.Kernel != nil {
+			s.Kernel = kernelName(me.Kernel)
+		}
+		s.SupportVectorMachines += len(me.SupportVectorMachines)
+		s.SupportVectors += len(me.VectorDictionary.Instances)
+	case *naivebayes.NaiveBayesModel:
+		if s.FunctionName == "" {
+			s.FunctionName = me.FunctionName
+		}
+		s.BayesInputs += len(me.BayesInputs)
+		for _, bi := range me.BayesInputs {
+			if len(bi.TargetValueStats) > 0 {
+				s.ContinuousBayesInputs++
+			}
+		}
+		s.BayesCategories = len(me.BayesOutput.TargetValueCounts)
+	case *model.AnomalyDetectionModel:
+		if s.FunctionName == "" {
+			s.FunctionName = me.FunctionName
+		}
+		s.AnomalyAlgorithm = me.AlgorithmType
+		s.SampleDataSize = me.SampleDataSize
+		s.add(me.ModelElement, segment)
+	case *timeseries.TimeSeriesModel:
+		s.Forecast = forecastName(me)
+		for _, ts := range me.TimeSeries {
+			if ts.Usage == "" || ts.Usage == "original" {
+				s.History = len(ts.TimeValues)
+				break
+			}
+		}
+	case *bayesiannetwork.BayesianNetworkModel:
+		if s.FunctionName == "" {
+			s.FunctionName = me.FunctionName
+		}
+		s.DiscreteNodes += len(me.DiscreteNodes)
+		s.ContinuousNodes += len(me.ContinuousNodes)
+	case *gaussianprocess.GaussianProcessModel:
+		if s.FunctionName == "" {
+			s.FunctionName = me.FunctionName
+		}
+		s.Kernel = me.Kernel.XMLName.Local
+		s.TrainingInstances = len(me.TrainingInstances.InlineTable.Rows)
+	case *model.MiningModel:
+		if s.FunctionName == "" {
+			s.FunctionName = me.FunctionName
+		}
+		if s.MultipleModelMethod == "" {
+			s.MultipleModelMethod = me.Segmentation.MultipleModelMethod
+			s.Segments = len(me.Segmentation.Segments)
+		}
+		if s.SegmentElements == nil {
+			s.SegmentElements = make(map[string]int)
+		}
+		for _, seg := range me.Segmentation.Segments {
+			if seg.ModelElement == nil {
+				continue
+			}
+			s.SegmentElements[elementName(seg.ModelElement)]++
+			s.add(seg.ModelElement, seg.ID)
+		}
+	}
+}
+
+// addNode counts n and its descendants, returning the depth of the subtree below n.
+func (s *Stats) addNode(n *node.Node, depth int) int {
+	s.Nodes++
+	if n.Predicate != nil {
+		for _, f := range predicateFields(n.Predicate) {
+			s.splits[f]++
+		}
+	}
+	if len(n.Children) == 0 {
+		s.Leaves++
+		return depth
+	}
+	max := depth
+	for _, c := range n.Children {
+		if d := s.addNode(c, depth+1); d > max {
+			max = d
+		}
+	}
+	return max
+}
+
+// addRules lists the simple rules, in document order.
+func (s *Stats) addRules(rules []ruleset.Rule, segment string) {
+	for _, r := range rules {
+		switch r := r.(type) {
+		case *ruleset.SimpleRule:
+			s.Rules = append(s.Rules, &Rule{
+				Segment:    segment,
+				ID:         r.ID,
+				Score:      r.Score,
+				Confidence: r.Confidence,
+				Weight:     r.Weight,
+			})
+		case *ruleset.CompoundRule:
+			s.addRules(r.Rules, segment)
+		}
+	}
+}
+
+func predicateFields(p predicates.Predicate) []string {
+	switch p := p.(type) {
+	case *predicates.SimplePredicate:
+		return []string{p.Field}
+	case *predicates.SimpleSetPredicate:
+		return []string{p.Field}
+	case *predicates.CompoundPredicate:
+		var fields []string
+		for _, child := range p.Predicates {
+			fields = append(fields, predicateFields(child)...)
+		}
+		return fields
+	}
+	return nil
+}
+
+func newRegressionTable(rt *regression.RegressionTable, segment string) *RegressionTable {
+	res := &RegressionTable{
+		Segment:        segment,
+		TargetCategory: rt.TargetCategory,
+		Intercept:      rt.Intercept,
+		Coefficients:   make([]*Coefficient, 0, len(rt.Predictors)),
+	}
+	for _, p := range rt.Predictors {
+		var c *Coefficient
+		switch p := p.(type) {
+		case *regression.NumericPredictor:
+			c = &Coefficient{Type: "numeric", Name: p.Name, Exponent: p.Exponent, Coefficient: p.Coefficient}
+		case *regression.CategoricalPredictor:
+			c = &Coefficient{Type: "categorical", Name: p.Name, Value: p.Value, Coefficient: p.Coefficient}
+		case *regression.PredictorTerm:
+			c = &Coefficient{Type: "term", Name: p.Name, Coefficient: p.Coefficient}
+			for _, fr := range p.FieldRefs {
+				c.Fields = append(c.Fields, fr.Field)
+			}
+		default:
+			continue
+		}
+		res.Coefficients = append(res.Coefficients, c)
+	}
+	return res
+}
+
+func kernelName(k svm.Kernel) string {
+	switch k.(type) {
+	case svm.LinearKernel:
+		return "linear"
+	case svm.PolynomialKernel:
+		return "polynomial"
+	case svm.RadialBasisKernel:
+		return "radialBasis"
+	case svm.SigmoidKernel:
+		return "sigmoid"
+	}
+	return ""
+}
+
+// forecastName describes the algorithm of the best fit of a time series model.
+func forecastName(m *timeseries.TimeSeriesModel) string {
+	switch {
+	case m.BestFit == timeseries.BestFits.ARIMA && m.ARIMA != nil:
+		name := "ARIMA"
+		if nc := m.ARIMA.NonseasonalComponent; nc != nil {
+			name += fmt.Sprintf("(%d,%d,%d)", nc.P, nc.D, nc.Q)
+		}
+		if sc := m.ARIMA.SeasonalComponent; sc != nil {
+			name += fmt.Sprintf("(%d,%d,%d)%d", sc.P, sc.D, sc.Q, sc.Period)
+		}
+		return name + " by " + m.ARIMA.PredictionMethod
+	case m.BestFit == timeseries.BestFits.ExponentialSmoothing && m.ExponentialSmoothing != nil:
+		name := "exponential smoothing"
+		if tr := m.ExponentialSmoothing.Trend; tr != nil {
+			name += ", " + tr.Trend + " trend"
+		}
+		if se := m.ExponentialSmoothing.Seasonality; se != nil {
+			name += fmt.Sprintf(", %s seasonality of period %d", se.Type, se.Period)
+		}
+		return name
+	}
+	return m.BestFit
+}
+
+// elementName returns the name of the XML element me was decoded from.
+func elementName(me model.ModelElement) string {
+	v := reflect.Indirect(reflect.ValueOf(me))
+	if v.Kind() == reflect.Struct {
+		if f := v.FieldByName("XMLName"); f.IsValid() {
+			if name, ok := f.Interface().(xml.Name); ok && name.Local != "" {
+				return name.Local
+			}
+		}
+	}
+	return v.Type().Name()
+}
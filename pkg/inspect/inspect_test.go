@@ -0,0 +1,199 @@
+package inspect_test
+
+import (
+	"testing"
+
+	"github.com/stillmatic/pummel"
+	"github.com/stillmatic/pummel/pkg/inspect"
+	"github.com/stretchr/testify/assert"
+)
+
+func load(t *testing.T, path string) *pummel.Model {
+	m, err := pummel.LoadFile(path)
+	assert.NoError(t, err)
+	return m
+}
+
+func TestInspectRegression(t *testing.T) {
+	s := inspect.Inspect(load(t, "../../testdata/lr.pmml"))
+	assert.Equal(t, "4.3", s.PMMLVersion)
+	assert.Equal(t, "JPMML-SparkML", s.Application)
+	assert.Equal(t, "1.4.5", s.ApplicationVersion)
+	assert.Equal(t, "RegressionModel", s.Model.Element)
+	assert.Equal(t, "classification", s.Model.FunctionName)
+	assert.Equal(t, 4, len(s.Inputs))
+	assert.Equal(t, &inspect.Field{Name: "x0", UsageType: "active", OpType: "continuous", DataType: "double"}, s.Inputs[0])
+	assert.Equal(t, 1, len(s.Targets))
+	assert.Equal(t, []string{"CATEGORY_0", "CATEGORY_1", "CATEGORY_2", "CATEGORY_3", "CATEGORY_4"}, s.Targets[0].Values)
+	assert.Equal(t, 7, len(s.Outputs))
+	assert.Equal(t, "probability", s.Outputs[2].Feature)
+	assert.Equal(t, "CATEGORY_0", s.Outputs[2].Value)
+
+	assert.Equal(t, 0, s.Model.Trees)
+	assert.Equal(t, 5, len(s.Model.RegressionTables))
+	rt := s.Model.RegressionTables[0]
+	assert.Equal(t, "CATEGORY_0", rt.TargetCategory)
+	assert.InDelta(t, -0.6171116237481978, rt.Intercept, 1e-12)
+	assert.Equal(t, 4, len(rt.Coefficients))
+	assert.Equal(t, &inspect.Coefficient{Type: "numeric", Name: "x0", Exponent: 1, Coefficient: 1.3722911765607988}, rt.Coefficients[0])
+}
+
+func TestInspectEnsemble(t *testing.T) {
+	s := inspect.Inspect(load(t, "../../testdata/rf.pmml"))
+	assert.Equal(t, "randomForest_Model", s.ModelName)
+	assert.Equal(t, "Rattle/PMML", s.Application)
+	st := s.Model
+	assert.Equal(t, "MiningModel", st.Element)
+	assert.Equal(t, "majorityVote", st.MultipleModelMethod)
+	assert.Equal(t, 15, st.Segments)
+	assert.Equal(t, map[string]int{"TreeModel": 15}, st.SegmentElements)
+	assert.Equal(t, 15, st.Trees)
+	assert.Equal(t, 2679, st.Nodes)
+	assert.Equal(t, 1347, st.Leaves)
+	assert.Equal(t, 17, st.Depth)
+	assert.Equal(t, 7, len(st.SplitFeatures))
+	assert.Equal(t, "Age", st.SplitFeatures[0].Name)
+
+	// the chain nests a MiningModel of trees, followed by a regression
+	s = inspect.Inspect(load(t, "../../testdata/gbm.pmml"))
+	st = s.Model
+	assert.Equal(t, "modelChain", st.MultipleModelMethod)
+	assert.Equal(t, 2, st.Segments)
+	assert.Equal(t, map[string]int{"MiningModel": 1, "TreeModel": 100, "RegressionModel": 1}, st.SegmentElements)
+	assert.Equal(t, 100, st.Trees)
+	assert.Equal(t, []*inspect.SplitFeature{{Name: "Sex", Splits: 300}}, st.SplitFeatures)
+	assert.Equal(t, 2, len(st.RegressionTables))
+	assert.Equal(t, "2", st.RegressionTables[0].Segment)
+	assert.Equal(t, "1", st.RegressionTables[0].TargetCategory)
+	assert.Equal(t, "gbmValue", st.RegressionTables[0].Coefficients[0].Name)
+}
+
+func TestInspectTree(t *testing.T) {
+	s := inspect.Inspect(load(t, "../../testdata/tree.pmml"))
+	assert.Equal(t, "TreeModel", s.Model.Element)
+	assert.Equal(t, 1, s.Model.Trees)
+	assert.Equal(t, 13, s.Model.Nodes)
+	assert.Equal(t, 9, s.Model.Leaves)
+	assert.Equal(t, 4, s.Model.Depth)
+	assert.Equal(t, []*inspect.SplitFeature{{"f1", 3}, {"f2", 3}, {"f3", 3}, {"f4", 3}}, s.Model.SplitFeatures)
+	assert.Nil(t, s.Model.SegmentElements)
+}
+
+func TestInspectNeuralNetwork(t *testing.T) {
+	s := inspect.Inspect(load(t, "../../testdata/golden/mlp/model.pmml"))
+	st := s.Model
+	assert.Equal(t, "NeuralNetwork", st.Element)
+	assert.Equal(t, "classification", st.FunctionName)
+	assert.Equal(t, 4, st.NeuralInputs)
+	assert.Equal(t, []*inspect.NeuralLayer{
+		{Neurons: 3, ActivationFunction: "rectifier"},
+		{Neurons: 3, ActivationFunction: "identity", NormalizationMethod: "softmax"},
+	}, st.NeuralLayers)
+}
+
+func TestInspectSupportVectorMachine(t *testing.T) {
+	st := inspect.Inspect(load(t, "../../testdata/golden/svm/model.pmml")).Model
+	assert.Equal(t, "SupportVectorMachineModel", st.Element)
+	assert.Equal(t, "radialBasis", st.Kernel)
+	assert.Equal(t, 3, st.SupportVectorMachines)
+	assert.Equal(t, 6, st.SupportVectors)
+}
+
+func TestInspectNaiveBayes(t *testing.T) {
+	st := inspect.Inspect(load(t, "../../testdata/golden/naivebayes/model.pmml")).Model
+	assert.Equal(t, "NaiveBayesModel", st.Element)
+	assert.Equal(t, "classification", st.FunctionName)
+	assert.Equal(t, 4, st.BayesInputs)
+	assert.Equal(t, 2, st.ContinuousBayesInputs)
+	assert.Equal(t, 3, st.BayesCategories)
+}
+
+func TestInspectGeneralRegression(t *testing.T) {
+	st := inspect.Inspect(load(t, "../../testdata/golden/ordinal/model.pmml")).Model
+	assert.Equal(t, "GeneralRegressionModel", st.Element)
+	assert.Equal(t, "ordinalMultinomial", st.ModelType)
+	assert.Equal(t, "logit", st.Link)
+	assert.Equal(t, 4, len(st.PCells))
+	assert.Equal(t, &inspect.PCell{Parameter: "p0", Label: "low|medium", TargetCategory: "low", Beta: 0.85}, st.PCells[0])
+}
+
+func TestInspectScorecard(t *testing.T) {
+	st := inspect.Inspect(load(t, "../../testdata/golden/scorecard/model.pmml")).Model
+	assert.Equal(t, "Scorecard", st.Element)
+	assert.Equal(t, "pointsBelow", st.ReasonCodeAlgorithm)
+	assert.Equal(t, 4, len(st.Characteristics))
+	assert.Equal(t, "income_score", st.Characteristics[1].Name)
+	assert.Equal(t, 4, st.Characteristics[1].Attributes)
+	// derived from the baseline method
+	assert.Equal(t, 45.0, *st.Characteristics[1].BaselineScore)
+}
+
+func TestInspectClustering(t *testing.T) {
+	st := inspect.Inspect(load(t, "../../testdata/golden/kmeans/model.pmml")).Model
+	assert.Equal(t, "ClusteringModel", st.Element)
+	assert.Equal(t, "clustering", st.FunctionName)
+	assert.Equal(t, "euclidean distance", st.ComparisonMeasure)
+	assert.Equal(t, 3, len(st.Clusters))
+	assert.Equal(t, &inspect.Cluster{ID: "1", Name: "versicolor", Size: 53}, st.Clusters[1])
+}
+
+func TestInspectNearestNeighbor(t *testing.T) {
+	st := inspect.Inspect(load(t, "../../testdata/golden/knn/model.pmml")).Model
+	assert.Equal(t, "NearestNeighborModel", st.Element)
+	assert.Equal(t, "euclidean distance", st.ComparisonMeasure)
+	assert.Equal(t, 3, st.Neighbors)
+	assert.Equal(t, 24, st.TrainingInstances)
+}
+
+func TestInspectRuleSet(t *testing.T) {
+	st := inspect.Inspect(load(t, "../../testdata/golden/ruleset/model.pmml")).Model
+	assert.Equal(t, "RuleSetModel", st.Element)
+	assert.Equal(t, "classification", st.FunctionName)
+	assert.Equal(t, "weightedSum", st.RuleSelectionMethod)
+	assert.Equal(t, 5, len(st.Rules))
+	// rules nested in a CompoundRule are listed in document order
+	assert.Equal(t, &inspect.Rule{ID: "R3", Score: "review", Confidence: 0.6, Weight: 0.5}, st.Rules[2])
+}
+
+func TestInspectAssociation(t *testing.T) {
+	st := inspect.Inspect(load(t, "../../testdata/golden/association/model.pmml")).Model
+	assert.Equal(t, "AssociationModel", st.Element)
+	assert.Equal(t, "associationRules", st.FunctionName)
+	assert.Equal(t, 6, st.Items)
+	assert.Equal(t, 8, st.Itemsets)
+	assert.Equal(t, 6, st.AssociationRules)
+}
+
+func TestInspectAnomalyDetection(t *testing.T) {
+	st := inspect.Inspect(load(t, "../../testdata/golden/iforest/model.pmml")).Model
+	assert.Equal(t, "AnomalyDetectionModel", st.Element)
+	assert.Equal(t, "iforest", st.AnomalyAlgorithm)
+	assert.Equal(t, 16, st.SampleDataSize)
+	// the trees of the forest are described too
+	assert.Equal(t, "average", st.MultipleModelMethod)
+	assert.Equal(t, 3, st.Trees)
+	assert.Equal(t, 3, st.Depth)
+}
+
+func TestInspectTimeSeries(t *testing.T) {
+	st := inspect.Inspect(load(t, "../../testdata/golden/arima/model.pmml")).Model
+	assert.Equal(t, "TimeSeriesModel", st.Element)
+	assert.Equal(t, "ARIMA(1,1,1)(1,0,0)4 by conditionalLeastSquares", st.Forecast)
+	assert.Equal(t, 16, st.History)
+}
+
+func TestInspectBayesianNetwork(t *testing.T) {
+	st := inspect.Inspect(load(t, "../../testdata/golden/bayesnet/model.pmml")).Model
+	assert.Equal(t, "BayesianNetworkModel", st.Element)
+	assert.Equal(t, "classification", st.FunctionName)
+	assert.Equal(t, 3, st.DiscreteNodes)
+	assert.Equal(t, 1, st.ContinuousNodes)
+}
+
+func TestInspectGaussianProcess(t *testing.T) {
+	st := inspect.Inspect(load(t, "../../testdata/golden/gaussianprocess/model.pmml")).Model
+	assert.Equal(t, "GaussianProcessModel", st.Element)
+	assert.Equal(t, "ARDSquaredExponentialKernel", st.Kernel)
+	assert.Equal(t, 6, st.TrainingInstances)
+	assert.Equal(t, 0, st.Neighbors)
+}
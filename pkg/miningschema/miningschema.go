@@ -11,7 +11,7 @@ type MiningField struct {
 	XMLName    xml.Name `xml:"MiningField"`
 	Name       string   `xml:"name,attr"`
 	UsageType  string   `xml:"usageType,attr"`
-	OpType     string   `xml:"opType,attr"`
+	OpType     string   `xml:"optype,attr"`
 	Importance float64  `xml:"importance,attr"`
 	// Outliers determines how outliers are handled by the model.
 	// Outliers are valid numeric values which are either greater than the specified
@@ -0,0 +1,216 @@
+package model
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/stillmatic/pummel/pkg/fields"
+	"github.com/stillmatic/pummel/pkg/miningschema"
+	"github.com/stillmatic/pummel/pkg/transformations"
+	"github.com/stillmatic/pummel/pkg/verification"
+)
+
+// AnomalyDetectionModel scores how anomalous a record is with the model it wraps. It lives in this package,
+// rather than its own, because it decodes its inner model like a Segment does.
+type AnomalyDetectionModel struct {
+	XMLName       xml.Name `xml:"AnomalyDetectionModel"`
+	ModelName     string   `xml:"modelName,attr"`
+	FunctionName  string   `xml:"functionName,attr"`
+	AlgorithmName string   `xml:"algorithmName,attr"`
+	AlgorithmType string   `xml:"algorithmType,attr"`
+	// SampleDataSize is the number of records each tree of an isolation forest was grown from.
+	SampleDataSize       int                                   `xml:"sampleDataSize,attr"`
+	IsScorable           bool                                  `xml:"isScorable,attr"`
+	MiningSchema         *miningschema.MiningSchema            `xml:"MiningSchema"`
+	Output               *fields.Outputs                       `xml:"Output"`
+	LocalTransformations *transformations.LocalTransformations `xml:"LocalTransformations"`
+	ModelVerification    *verification.ModelVerification       `xml:"ModelVerification"`
+	// ModelElement is the inner model: for iforest, an ensemble predicting the average path length of the
+	// record in the trees of the forest, and for ocsvm, a one-class support vector machine.
+	ModelElement ModelElement
+
+	doc *transformations.Document
+}
+
+// NewAnomalyDetectionModel returns an empty AnomalyDetectionModel to be decoded into, whose expressions belong to doc.
+func NewAnomalyDetectionModel(doc *transformations.Document) *AnomalyDetectionModel {
+	return &AnomalyDetectionModel{doc: doc}
+}
+
+var AlgorithmTypes = struct {
+	IForest         string
+	OCSVM           string
+	ClusterMeanDist string
+	Other           string
+}{
+	IForest:         "iforest",
+	OCSVM:           "ocsvm",
+	ClusterMeanDist: "clusterMeanDist",
+	Other:           "other",
+}
+
+func (m *AnomalyDetectionModel) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	m.XMLName = start.Name
+	for _, attr := range start.Attr {
+		var err error
+		switch attr.Name.Local {
+		case "modelName":
+			m.ModelName = attr.Value
+		case "functionName":
+			m.FunctionName = attr.Value
+		case "algorithmName":
+			m.AlgorithmName = attr.Value
+		case "algorithmType":
+			m.AlgorithmType = attr.Value
+		case "sampleDataSize":
+			m.SampleDataSize, err = strconv.Atoi(attr.Value)
+		case "isScorable":
+			m.IsScorable = attr.Value == "true"
+		}
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s of AnomalyDetectionModel", attr.Name.Local)
+		}
+	}
+	switch m.AlgorithmType {
+	case AlgorithmTypes.IForest:
+		if m.SampleDataSize < 2 {
+			return fmt.Errorf("iforest needs a sampleDataSize of at least 2, got %d", m.SampleDataSize)
+		}
+	case AlgorithmTypes.OCSVM, AlgorithmTypes.Other:
+	default:
+		return fmt.Errorf("unsupported algorithm type: %q", m.AlgorithmType)
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "MiningSchema":
+				var ms miningschema.MiningSchema
+				if err := d.DecodeElement(&ms, &tt); err != nil {
+					return err
+				}
+				m.MiningSchema = &ms
+			case "Output":
+				out := fields.NewOutputs(m.doc)
+				if err := d.DecodeElement(out, &tt); err != nil {
+					return err
+				}
+				m.Output = out
+			case "LocalTransformations":
+				lt := m.doc.NewLocalTransformations()
+				if err := d.DecodeElement(lt, &tt); err != nil {
+					return err
+				}
+				m.LocalTransformations = lt
+			case "ModelVerification":
+				var mv verification.ModelVerification
+				if err := d.DecodeElement(&mv, &tt); err != nil {
+					return err
+				}
+				m.ModelVerification = &mv
+			case "Extension", "ModelStats", "ModelExplanation":
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			default:
+				if !IsModelElement(tt.Name.Local) {
+					return fmt.Errorf("unknown element: %s", tt.Name.Local)
+				}
+				m.ModelElement, err = DecodeModelElement(d, tt, m.doc)
+				if err != nil {
+					return err
+				}
+			}
+		case xml.EndElement:
+			if m.ModelElement == nil {
+				return errors.New("AnomalyDetectionModel has no inner model")
+			}
+			return nil
+		}
+	}
+}
+
+// averagePathLength is the average length of the path to a leaf in a binary search tree of n records,
+// which normalizes the path lengths of an isolation forest.
+func averagePathLength(n int) float64 {
+	if n <= 1 {
+		return 0
+	}
+	if n == 2 {
+		return 1
+	}
+	const eulerGamma = 0.5772156649015329
+	return 2*(math.Log(float64(n-1))+eulerGamma) - 2*float64(n-1)/float64(n)
+}
+
+// Evaluate returns the anomaly score of the record. For iforest, it is 2^(-E(h)/c(n)), where E(h) is the
+// average path length predicted by the inner model and c(n) the average path length for the sample size,
+// so that it ranges from 0 to 1 and scores above 0.5 are anomalous. For ocsvm it is the value of the
+// machine, which is negative for outliers.
+func (m *AnomalyDetectionModel) Evaluate(values map[string]interface{}) (map[string]interface{}, error) {
+	out, err := m.evaluate(values)
+	return m.Output.Complete(values, out, err)
+}
+
+func (m *AnomalyDetectionModel) evaluate(values map[string]interface{}) (map[string]interface{}, error) {
+	if m.LocalTransformations != nil {
+		for _, tr := range m.LocalTransformations.DerivedFields {
+			val, err := tr.Transform(values)
+			if err != nil {
+				return nil, err
+			}
+			values[tr.RequiredField()] = val
+		}
+	}
+	res, err := m.ModelElement.Evaluate(values)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to evaluate inner model")
+	}
+	raw := res[m.ModelElement.GetOutputField()]
+	if raw == nil {
+		return nil, nil
+	}
+	score, err := transformations.InterfaceToFloat64(raw)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid result of inner model")
+	}
+	if m.AlgorithmType == AlgorithmTypes.IForest {
+		score = math.Pow(2, -score/averagePathLength(m.SampleDataSize))
+	}
+
+	out := make(map[string]interface{})
+	if target := m.GetOutputField(); target != "" {
+		out[target] = score
+	}
+	if m.Output != nil {
+		for _, of := range m.Output.OutputFields {
+			if of.Feature == "predictedValue" {
+				out[of.Name] = score
+			}
+		}
+	}
+	return out, nil
+}
+
+func (m *AnomalyDetectionModel) GetOutputField() string {
+	return m.MiningSchema.GetOutputField()
+}
+
+func (m *AnomalyDetectionModel) GetMiningSchema() *miningschema.MiningSchema {
+	return m.MiningSchema
+}
+
+func (m *AnomalyDetectionModel) GetOutput() *fields.Outputs {
+	return m.Output
+}
+
+func (m *AnomalyDetectionModel) GetModelVerification() *verification.ModelVerification {
+	return m.ModelVerification
+}
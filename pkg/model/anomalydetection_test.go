@@ -0,0 +1,141 @@
+package model_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/stillmatic/pummel/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+var isolationForestXML = `<AnomalyDetectionModel functionName="regression" algorithmType="iforest" sampleDataSize="8">
+	<MiningSchema>
+		<MiningField name="x"/>
+		<MiningField name="y"/>
+	</MiningSchema>
+	<Output>
+		<OutputField name="anomalyScore" feature="predictedValue"/>
+	</Output>
+	<MiningModel functionName="regression">
+		<MiningSchema>
+			<MiningField name="x"/>
+			<MiningField name="y"/>
+		</MiningSchema>
+		<Segmentation multipleModelMethod="average">
+			<Segment id="1">
+				<True/>
+				<TreeModel functionName="regression">
+					<MiningSchema>
+						<MiningField name="x"/>
+					</MiningSchema>
+					<Node>
+						<True/>
+						<Node score="2">
+							<SimplePredicate field="x" operator="lessOrEqual" value="0"/>
+						</Node>
+						<Node score="4.5">
+							<True/>
+						</Node>
+					</Node>
+				</TreeModel>
+			</Segment>
+			<Segment id="2">
+				<True/>
+				<TreeModel functionName="regression">
+					<MiningSchema>
+						<MiningField name="y"/>
+					</MiningSchema>
+					<Node>
+						<True/>
+						<Node score="3">
+							<SimplePredicate field="y" operator="lessOrEqual" value="1"/>
+						</Node>
+						<Node score="1">
+							<True/>
+						</Node>
+					</Node>
+				</TreeModel>
+			</Segment>
+		</Segmentation>
+	</MiningModel>
+</AnomalyDetectionModel>`
+
+func TestIsolationForest(t *testing.T) {
+	var m model.AnomalyDetectionModel
+	err := xml.Unmarshal([]byte(isolationForestXML), &m)
+	assert.NoError(t, err)
+	assert.Equal(t, "iforest", m.AlgorithmType)
+	assert.IsType(t, &model.MiningModel{}, m.ModelElement)
+
+	tcs := []struct {
+		x, y     float64
+		expected float64
+	}{
+		// the average path length is 1.5, out of 3.296 for samples of 8 records
+		{-1, 5, 0.7294786468693},
+		{1, 0, 0.4544974591084908},
+	}
+	for _, tc := range tcs {
+		out, err := m.Evaluate(map[string]interface{}{"x": tc.x, "y": tc.y})
+		assert.NoError(t, err)
+		assert.InDelta(t, tc.expected, out["anomalyScore"], 1e-12)
+	}
+}
+
+var oneClassSVMXML = `<AnomalyDetectionModel functionName="regression" algorithmType="ocsvm">
+	<MiningSchema>
+		<MiningField name="x"/>
+		<MiningField name="y"/>
+	</MiningSchema>
+	<Output>
+		<OutputField name="decision" feature="predictedValue"/>
+	</Output>
+	<SupportVectorMachineModel functionName="regression" svmRepresentation="Coefficients">
+		<MiningSchema>
+			<MiningField name="x"/>
+			<MiningField name="y"/>
+		</MiningSchema>
+		<LinearKernelType/>
+		<VectorDictionary>
+			<VectorFields>
+				<FieldRef field="x"/>
+				<FieldRef field="y"/>
+			</VectorFields>
+		</VectorDictionary>
+		<SupportVectorMachine>
+			<Coefficients absoluteValue="0.5">
+				<Coefficient value="1"/>
+				<Coefficient value="-1"/>
+			</Coefficients>
+		</SupportVectorMachine>
+	</SupportVectorMachineModel>
+</AnomalyDetectionModel>`
+
+func TestOneClassSVM(t *testing.T) {
+	var m model.AnomalyDetectionModel
+	err := xml.Unmarshal([]byte(oneClassSVMXML), &m)
+	assert.NoError(t, err)
+	out, err := m.Evaluate(map[string]interface{}{"x": 1.0, "y": 0.0})
+	assert.NoError(t, err)
+	assert.Equal(t, 1.5, out["decision"])
+	out, err = m.Evaluate(map[string]interface{}{"x": 0.0, "y": 2.0})
+	assert.NoError(t, err)
+	assert.Equal(t, -1.5, out["decision"])
+}
+
+func TestAnomalyDetectionErrors(t *testing.T) {
+	tcs := []struct {
+		old, new string
+		expected string
+	}{
+		{`sampleDataSize="8"`, ``, "iforest needs a sampleDataSize of at least 2, got 0"},
+		{`algorithmType="iforest"`, `algorithmType="clusterMeanDist"`, `unsupported algorithm type: "clusterMeanDist"`},
+		{`<Output>`, `<Targets/><Output>`, "unknown element: Targets"},
+	}
+	for _, tc := range tcs {
+		var m model.AnomalyDetectionModel
+		err := xml.Unmarshal([]byte(strings.Replace(isolationForestXML, tc.old, tc.new, 1)), &m)
+		assert.EqualError(t, err, tc.expected)
+	}
+}
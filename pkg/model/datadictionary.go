@@ -1,6 +1,15 @@
 package model
 
-import "encoding/xml"
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/stillmatic/pummel/pkg/fields"
+)
 
 type DataDictionary struct {
 	XMLName    xml.Name     `xml:"DataDictionary"`
@@ -18,4 +27,130 @@ type DataField struct {
 type Value struct {
 	XMLName xml.Name `xml:"Value"`
 	Value   string   `xml:"value,attr"`
+	// Property is one of valid (the default), invalid or missing.
+	Property string `xml:"property,attr"`
+}
+
+// GetDataField returns the field with the given name, or nil if there is none.
+func (dd *DataDictionary) GetDataField(name string) *DataField {
+	if dd == nil {
+		return nil
+	}
+	for _, df := range dd.DataFields {
+		if df.Name == name {
+			return df
+		}
+	}
+	return nil
+}
+
+// Coerce converts a raw value, e.g. a CSV cell or a decoded JSON value, into the type used
+// during evaluation for the named field. Values of fields not in the dictionary are returned as is.
+func (dd *DataDictionary) Coerce(name string, value interface{}) (interface{}, error) {
+	df := dd.GetDataField(name)
+	if df == nil {
+		return value, nil
+	}
+	return df.Coerce(value)
+}
+
+// Coerce converts value according to the field's dataType.
+// Empty strings and values declared with property="missing" are treated as missing and return nil.
+// integer fields yield int, float and double fields yield float64, boolean fields yield bool
+// and every other type yields string. Arrays, e.g. the items of a transaction decoded from JSON,
+// yield a fields.Collection of the coerced values, leaving out missing ones.
+func (df *DataField) Coerce(value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	if values, ok := value.([]interface{}); ok {
+		c := make(fields.Collection, 0, len(values))
+		for _, v := range values {
+			coerced, err := df.Coerce(v)
+			if err != nil {
+				return nil, err
+			}
+			if coerced != nil {
+				c = append(c, coerced)
+			}
+		}
+		return c, nil
+	}
+	if s, ok := value.(string); ok {
+		if s == "" || df.isMissingValue(s) {
+			return nil, nil
+		}
+	}
+	var res interface{}
+	var err error
+	switch df.DataType {
+	case "integer":
+		res, err = coerceInteger(value)
+	case "float", "double":
+		res, err = coerceDouble(value)
+	case "boolean":
+		res, err = coerceBoolean(value)
+	default:
+		res = coerceString(value)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid %s value for field %s", df.DataType, df.Name)
+	}
+	return res, nil
+}
+
+func (df *DataField) isMissingValue(s string) bool {
+	for _, v := range df.Values {
+		if v.Property == "missing" && v.Value == s {
+			return true
+		}
+	}
+	return false
+}
+
+func coerceInteger(value interface{}) (interface{}, error) {
+	switch value := value.(type) {
+	case int:
+		return value, nil
+	case float64:
+		if value != math.Trunc(value) {
+			return nil, fmt.Errorf("%v is not an integer", value)
+		}
+		return int(value), nil
+	case string:
+		return strconv.Atoi(strings.TrimSpace(value))
+	}
+	return nil, fmt.Errorf("unsupported type %T", value)
+}
+
+func coerceDouble(value interface{}) (interface{}, error) {
+	switch value := value.(type) {
+	case int:
+		return float64(value), nil
+	case float64:
+		return value, nil
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(value), 64)
+	}
+	return nil, fmt.Errorf("unsupported type %T", value)
+}
+
+func coerceBoolean(value interface{}) (interface{}, error) {
+	switch value := value.(type) {
+	case bool:
+		return value, nil
+	case string:
+		return strconv.ParseBool(strings.TrimSpace(value))
+	}
+	return nil, fmt.Errorf("unsupported type %T", value)
+}
+
+func coerceString(value interface{}) string {
+	switch value := value.(type) {
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
 }
@@ -0,0 +1,137 @@
+package model
+
+import (
+	"encoding/xml"
+	"fmt"
+
+	"github.com/stillmatic/pummel/pkg/association"
+	"github.com/stillmatic/pummel/pkg/bayesiannetwork"
+	"github.com/stillmatic/pummel/pkg/clustering"
+	"github.com/stillmatic/pummel/pkg/gaussianprocess"
+	"github.com/stillmatic/pummel/pkg/generalregression"
+	"github.com/stillmatic/pummel/pkg/naivebayes"
+	"github.com/stillmatic/pummel/pkg/nearestneighbor"
+	"github.com/stillmatic/pummel/pkg/neuralnetwork"
+	"github.com/stillmatic/pummel/pkg/regression"
+	"github.com/stillmatic/pummel/pkg/ruleset"
+	"github.com/stillmatic/pummel/pkg/scorecard"
+	"github.com/stillmatic/pummel/pkg/svm"
+	"github.com/stillmatic/pummel/pkg/timeseries"
+	"github.com/stillmatic/pummel/pkg/transformations"
+	"github.com/stillmatic/pummel/pkg/tree"
+)
+
+// modelElements holds a constructor for each model element pummel can evaluate,
+// keyed by the local name of the element. Each takes the document the element belongs to.
+var modelElements = map[string]func(doc *transformations.Document) ModelElement{
+	"TreeModel": func(doc *transformations.Document) ModelElement {
+		return tree.NewTreeModel(doc)
+	},
+	"RegressionModel": func(doc *transformations.Document) ModelElement {
+		return regression.NewRegressionModel(doc)
+	},
+	"MiningModel": func(doc *transformations.Document) ModelElement {
+		return NewMiningModel(doc)
+	},
+	"NeuralNetwork": func(doc *transformations.Document) ModelElement {
+		return neuralnetwork.NewNeuralNetwork(doc)
+	},
+	"SupportVectorMachineModel": func(doc *transformations.Document) ModelElement {
+		return svm.NewSupportVectorMachineModel(doc)
+	},
+	"NaiveBayesModel": func(doc *transformations.Document) ModelElement {
+		return naivebayes.NewNaiveBayesModel(doc)
+	},
+	"GeneralRegressionModel": func(doc *transformations.Document) ModelElement {
+		return generalregression.NewGeneralRegressionModel(doc)
+	},
+	"Scorecard": func(doc *transformations.Document) ModelElement {
+		return scorecard.NewScorecard(doc)
+	},
+	"ClusteringModel": func(doc *transformations.Document) ModelElement {
+		return clustering.NewClusteringModel(doc)
+	},
+	"NearestNeighborModel": func(doc *transformations.Document) ModelElement {
+		return nearestneighbor.NewNearestNeighborModel(doc)
+	},
+	"RuleSetModel": func(doc *transformations.Document) ModelElement {
+		return ruleset.NewRuleSetModel(doc)
+	},
+	"AssociationModel": func(doc *transformations.Document) ModelElement {
+		return association.NewAssociationModel(doc)
+	},
+	"AnomalyDetectionModel": func(doc *transformations.Document) ModelElement {
+		return NewAnomalyDetectionModel(doc)
+	},
+	"TimeSeriesModel": func(doc *transformations.Document) ModelElement {
+		return timeseries.NewTimeSeriesModel(doc)
+	},
+	"BayesianNetworkModel": func(doc *transformations.Document) ModelElement {
+		return bayesiannetwork.NewBayesianNetworkModel(doc)
+	},
+	"GaussianProcessModel": func(doc *transformations.Document) ModelElement {
+		return gaussianprocess.NewGaussianProcessModel(doc)
+	},
+}
+
+// pmmlModelElements lists every model element defined by PMML 4.4,
+// whether or not pummel supports it.
+var pmmlModelElements = map[string]bool{
+	"AnomalyDetectionModel":     true,
+	"AssociationModel":          true,
+	"BayesianNetworkModel":      true,
+	"BaselineModel":             true,
+	"ClusteringModel":           true,
+	"GaussianProcessModel":      true,
+	"GeneralRegressionModel":    true,
+	"MiningModel":               true,
+	"NaiveBayesModel":           true,
+	"NearestNeighborModel":      true,
+	"NeuralNetwork":             true,
+	"RegressionModel":           true,
+	"RuleSetModel":              true,
+	"SequenceModel":             true,
+	"Scorecard":                 true,
+	"SupportVectorMachineModel": true,
+	"TextModel":                 true,
+	"TimeSeriesModel":           true,
+	"TreeModel":                 true,
+}
+
+// UnsupportedModelError is returned when a document contains a model element
+// which pummel does not know how to evaluate.
+type UnsupportedModelError struct {
+	Element string
+}
+
+func (e *UnsupportedModelError) Error() string {
+	return fmt.Sprintf("unsupported model element: %s", e.Element)
+}
+
+// IsModelElement reports whether name is a PMML model element, supported or not.
+func IsModelElement(name string) bool {
+	return pmmlModelElements[name]
+}
+
+// IsSupportedModelElement reports whether pummel can evaluate the named model element.
+func IsSupportedModelElement(name string) bool {
+	_, ok := modelElements[name]
+	return ok
+}
+
+// DecodeModelElement decodes the model element starting at start, whose expressions belong to doc.
+// Model elements pummel does not support are skipped and reported with an UnsupportedModelError.
+func DecodeModelElement(d *xml.Decoder, start xml.StartElement, doc *transformations.Document) (ModelElement, error) {
+	newElement, ok := modelElements[start.Name.Local]
+	if !ok {
+		if err := d.Skip(); err != nil {
+			return nil, err
+		}
+		return nil, &UnsupportedModelError{Element: start.Name.Local}
+	}
+	me := newElement(doc)
+	if err := d.DecodeElement(me, &start); err != nil {
+		return nil, err
+	}
+	return me, nil
+}
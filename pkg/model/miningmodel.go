@@ -3,11 +3,14 @@ package model
 import (
 	"encoding/xml"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/stillmatic/pummel/pkg/fields"
 	"github.com/stillmatic/pummel/pkg/miningschema"
 	"github.com/stillmatic/pummel/pkg/transformations"
+	"github.com/stillmatic/pummel/pkg/verification"
 )
 
 type MiningModel struct {
@@ -21,6 +24,14 @@ type MiningModel struct {
 	LocalTransformations *transformations.LocalTransformations `xml:"LocalTransformations"`
 	IsScorable           bool                                  `xml:"isScorable,attr"`
 	Targets              []Target                              `xml:"Targets>Target"`
+	ModelVerification    *verification.ModelVerification       `xml:"ModelVerification"`
+
+	doc *transformations.Document
+}
+
+// NewMiningModel returns an empty MiningModel to be decoded into, whose expressions belong to doc.
+func NewMiningModel(doc *transformations.Document) *MiningModel {
+	return &MiningModel{doc: doc}
 }
 
 type Target struct {
@@ -54,7 +65,86 @@ var MultipleModelMethod = struct {
 	ModelChain:           "modelChain",
 }
 
+func (mm *MiningModel) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*mm = MiningModel{XMLName: start.Name, doc: mm.doc}
+	for _, attr := range start.Attr {
+		var err error
+		switch attr.Name.Local {
+		case "functionName":
+			mm.FunctionName = attr.Value
+		case "modelName":
+			mm.ModelName = attr.Value
+		case "algorithmName":
+			mm.AlgorithmName = attr.Value
+		case "isScorable":
+			mm.IsScorable, err = strconv.ParseBool(strings.TrimSpace(attr.Value))
+		}
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s of MiningModel", attr.Name.Local)
+		}
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "MiningSchema":
+				var ms miningschema.MiningSchema
+				if err := d.DecodeElement(&ms, &tt); err != nil {
+					return err
+				}
+				mm.MiningSchema = &ms
+			case "Output":
+				out := fields.NewOutputs(mm.doc)
+				if err := d.DecodeElement(out, &tt); err != nil {
+					return err
+				}
+				mm.Output = out
+			case "LocalTransformations":
+				lt := mm.doc.NewLocalTransformations()
+				if err := d.DecodeElement(lt, &tt); err != nil {
+					return err
+				}
+				mm.LocalTransformations = lt
+			case "Segmentation":
+				mm.Segmentation.doc = mm.doc
+				if err := d.DecodeElement(&mm.Segmentation, &tt); err != nil {
+					return err
+				}
+			case "Targets":
+				var targets struct {
+					Targets []Target `xml:"Target"`
+				}
+				if err := d.DecodeElement(&targets, &tt); err != nil {
+					return err
+				}
+				mm.Targets = append(mm.Targets, targets.Targets...)
+			case "ModelVerification":
+				var mv verification.ModelVerification
+				if err := d.DecodeElement(&mv, &tt); err != nil {
+					return err
+				}
+				mm.ModelVerification = &mv
+			default:
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
 func (mm *MiningModel) Evaluate(values map[string]interface{}) (map[string]interface{}, error) {
+	out, err := mm.evaluate(values)
+	return mm.Output.Complete(values, out, err)
+}
+
+func (mm *MiningModel) evaluate(values map[string]interface{}) (map[string]interface{}, error) {
 	if mm.LocalTransformations != nil {
 		if len(mm.LocalTransformations.DerivedFields) > 0 {
 			for _, tr := range mm.LocalTransformations.DerivedFields {
@@ -114,3 +204,15 @@ func (mm *MiningModel) GetOutputField() string {
 	}
 	return mm.MiningSchema.GetOutputField()
 }
+
+func (mm *MiningModel) GetMiningSchema() *miningschema.MiningSchema {
+	return mm.MiningSchema
+}
+
+func (mm *MiningModel) GetOutput() *fields.Outputs {
+	return mm.Output
+}
+
+func (mm *MiningModel) GetModelVerification() *verification.ModelVerification {
+	return mm.ModelVerification
+}
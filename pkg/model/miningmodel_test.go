@@ -765,6 +765,147 @@ func TestModelChain(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+var neuralNetworkSumXML = []byte(`
+<MiningModel functionName="regression">
+  <MiningSchema>
+    <MiningField name="x"/>
+    <MiningField name="y" usageType="target"/>
+  </MiningSchema>
+  <Segmentation multipleModelMethod="sum">
+    <Segment id="1">
+      <True/>
+      <NeuralNetwork functionName="regression" activationFunction="identity">
+        <MiningSchema>
+          <MiningField name="x"/>
+          <MiningField name="y" usageType="target"/>
+        </MiningSchema>
+        <NeuralInputs>
+          <NeuralInput id="x">
+            <DerivedField optype="continuous" dataType="double">
+              <FieldRef field="x"/>
+            </DerivedField>
+          </NeuralInput>
+        </NeuralInputs>
+        <NeuralLayer>
+          <Neuron id="y" bias="1">
+            <Con from="x" weight="2"/>
+          </Neuron>
+        </NeuralLayer>
+        <NeuralOutputs>
+          <NeuralOutput outputNeuron="y">
+            <DerivedField optype="continuous" dataType="double">
+              <FieldRef field="y"/>
+            </DerivedField>
+          </NeuralOutput>
+        </NeuralOutputs>
+      </NeuralNetwork>
+    </Segment>
+    <Segment id="2">
+      <True/>
+      <NeuralNetwork functionName="regression" activationFunction="identity">
+        <MiningSchema>
+          <MiningField name="x"/>
+          <MiningField name="y" usageType="target"/>
+        </MiningSchema>
+        <NeuralInputs>
+          <NeuralInput id="x">
+            <DerivedField optype="continuous" dataType="double">
+              <FieldRef field="x"/>
+            </DerivedField>
+          </NeuralInput>
+        </NeuralInputs>
+        <NeuralLayer>
+          <Neuron id="y" bias="-1">
+            <Con from="x" weight="0.5"/>
+          </Neuron>
+        </NeuralLayer>
+        <NeuralOutputs>
+          <NeuralOutput outputNeuron="y">
+            <DerivedField optype="continuous" dataType="double">
+              <FieldRef field="y"/>
+            </DerivedField>
+          </NeuralOutput>
+        </NeuralOutputs>
+      </NeuralNetwork>
+    </Segment>
+  </Segmentation>
+</MiningModel>
+`)
+
+func TestNeuralNetworkSegments(t *testing.T) {
+	var mm model.MiningModel
+	err := xml.Unmarshal(neuralNetworkSumXML, &mm)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(mm.Segmentation.Segments))
+	res, err := mm.Evaluate(map[string]interface{}{"x": 3.0})
+	assert.NoError(t, err)
+	// (2 * 3 + 1) + (0.5 * 3 - 1)
+	assert.InDelta(t, 7.5, res["y"], 1e-12)
+}
+
+var supportVectorMachineSelectFirstXML = []byte(`
+<MiningModel functionName="regression">
+  <MiningSchema>
+    <MiningField name="x"/>
+    <MiningField name="y" usageType="target"/>
+  </MiningSchema>
+  <Segmentation multipleModelMethod="selectFirst">
+    <Segment id="1">
+      <SimplePredicate field="x" operator="lessThan" value="0"/>
+      <SupportVectorMachineModel functionName="regression" svmRepresentation="Coefficients">
+        <MiningSchema>
+          <MiningField name="x"/>
+          <MiningField name="y" usageType="target"/>
+        </MiningSchema>
+        <LinearKernelType/>
+        <VectorDictionary>
+          <VectorFields>
+            <FieldRef field="x"/>
+          </VectorFields>
+        </VectorDictionary>
+        <SupportVectorMachine>
+          <Coefficients absoluteValue="1">
+            <Coefficient value="-1"/>
+          </Coefficients>
+        </SupportVectorMachine>
+      </SupportVectorMachineModel>
+    </Segment>
+    <Segment id="2">
+      <True/>
+      <SupportVectorMachineModel functionName="regression" svmRepresentation="Coefficients">
+        <MiningSchema>
+          <MiningField name="x"/>
+          <MiningField name="y" usageType="target"/>
+        </MiningSchema>
+        <LinearKernelType/>
+        <VectorDictionary>
+          <VectorFields>
+            <FieldRef field="x"/>
+          </VectorFields>
+        </VectorDictionary>
+        <SupportVectorMachine>
+          <Coefficients absoluteValue="-1">
+            <Coefficient value="2"/>
+          </Coefficients>
+        </SupportVectorMachine>
+      </SupportVectorMachineModel>
+    </Segment>
+  </Segmentation>
+</MiningModel>
+`)
+
+func TestSupportVectorMachineSegments(t *testing.T) {
+	var mm model.MiningModel
+	err := xml.Unmarshal(supportVectorMachineSelectFirstXML, &mm)
+	assert.NoError(t, err)
+	res, err := mm.Evaluate(map[string]interface{}{"x": -3.0})
+	assert.NoError(t, err)
+	assert.InDelta(t, 4.0, res["y"], 1e-12)
+	res, err = mm.Evaluate(map[string]interface{}{"x": 3.0})
+	assert.NoError(t, err)
+	assert.InDelta(t, 5.0, res["y"], 1e-12)
+}
+
 var RFFixtureCases = []struct {
 	name          string
 	features      map[string]interface{}
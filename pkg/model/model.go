@@ -4,6 +4,8 @@ import (
 	"encoding/xml"
 	"fmt"
 
+	"github.com/stillmatic/pummel/pkg/fields"
+	"github.com/stillmatic/pummel/pkg/miningschema"
 	"github.com/stillmatic/pummel/pkg/regression"
 	"github.com/stillmatic/pummel/pkg/tree"
 )
@@ -12,18 +14,31 @@ import (
 type ModelElement interface {
 	Evaluate(map[string]interface{}) (map[string]interface{}, error)
 	GetOutputField() string
+	GetMiningSchema() *miningschema.MiningSchema
+	GetOutput() *fields.Outputs
 }
 
 type PMMLModel struct {
 	XMLName        xml.Name        `xml:"PMML"`
+	Version        string          `xml:"version,attr"`
 	Header         *Header         `xml:"Header"`
 	DataDictionary *DataDictionary `xml:"DataDictionary"`
 }
 
 type Header struct {
-	XMLName     xml.Name `xml:"Header"`
-	Copyright   string   `xml:"copyright,attr"`
-	Description string   `xml:"description,attr"`
+	XMLName      xml.Name     `xml:"Header"`
+	Copyright    string       `xml:"copyright,attr"`
+	Description  string       `xml:"description,attr"`
+	ModelVersion string       `xml:"modelVersion,attr"`
+	Application  *Application `xml:"Application"`
+	Timestamp    string       `xml:"Timestamp"`
+}
+
+// Application describes the software which produced the PMML document.
+type Application struct {
+	XMLName xml.Name `xml:"Application"`
+	Name    string   `xml:"name,attr"`
+	Version string   `xml:"version,attr"`
 }
 
 type PMMLTreeModel struct {
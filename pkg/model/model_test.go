@@ -4,6 +4,7 @@ import (
 	"encoding/xml"
 	"testing"
 
+	"github.com/stillmatic/pummel/pkg/fields"
 	"github.com/stillmatic/pummel/pkg/model"
 	"github.com/stretchr/testify/assert"
 )
@@ -146,3 +147,56 @@ func BenchmarkTreeModel(b *testing.B) {
 		assert.NoError(b, err)
 	}
 }
+
+var coerceTestCases = []struct {
+	dataType string
+	input    interface{}
+	expected interface{}
+	err      bool
+}{
+	{"double", "1.5", 1.5, false},
+	{"double", 2, 2.0, false},
+	{"double", "abc", nil, true},
+	{"integer", "42", 42, false},
+	{"integer", 42.0, 42, false},
+	{"integer", 42.5, nil, true},
+	{"boolean", "TRUE", true, false},
+	{"boolean", false, false, false},
+	{"string", 1.0, "1", false},
+	{"string", "sunny", "sunny", false},
+	{"string", "", nil, false},
+	{"string", "NA", nil, false},
+	{"double", nil, nil, false},
+	{"string", []interface{}{"bread", "", 2.0}, fields.Collection{"bread", "2"}, false},
+	{"integer", []interface{}{1.0, "2"}, fields.Collection{1, 2}, false},
+	{"integer", []interface{}{1.5}, nil, true},
+}
+
+func TestDataFieldCoerce(t *testing.T) {
+	for _, tc := range coerceTestCases {
+		df := &model.DataField{Name: "f", DataType: tc.dataType, Values: []model.Value{{Value: "NA", Property: "missing"}}}
+		res, err := df.Coerce(tc.input)
+		if tc.err {
+			assert.Error(t, err, "%s %v", tc.dataType, tc.input)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected, res, "%s %v", tc.dataType, tc.input)
+	}
+}
+
+func TestDataDictionaryCoerce(t *testing.T) {
+	var tm *model.PMMLTreeModel
+	err := xml.Unmarshal(simpleXMlStr, &tm)
+	assert.NoError(t, err)
+	res, err := tm.DataDictionary.Coerce("temperature", "75")
+	assert.NoError(t, err)
+	assert.Equal(t, 75.0, res)
+	res, err = tm.DataDictionary.Coerce("unknown", "75")
+	assert.NoError(t, err)
+	assert.Equal(t, "75", res)
+	var dd *model.DataDictionary
+	res, err = dd.Coerce("temperature", "75")
+	assert.NoError(t, err)
+	assert.Equal(t, "75", res)
+}
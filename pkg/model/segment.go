@@ -7,8 +7,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/stillmatic/pummel/pkg/predicates"
-	"github.com/stillmatic/pummel/pkg/regression"
-	"github.com/stillmatic/pummel/pkg/tree"
+	"github.com/stillmatic/pummel/pkg/transformations"
 )
 
 const (
@@ -19,6 +18,8 @@ type Segmentation struct {
 	XMLName             xml.Name  `xml:"Segmentation"`
 	MultipleModelMethod string    `xml:"multipleModelMethod,attr"`
 	Segments            []Segment `xml:"Segment"`
+
+	doc *transformations.Document
 }
 
 type Segment struct {
@@ -27,6 +28,39 @@ type Segment struct {
 	ModelElement ModelElement
 	ID           string  `xml:"id,attr"`
 	Weight       float64 `xml:"weight,attr"`
+
+	doc *transformations.Document
+}
+
+func (sg *Segmentation) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*sg = Segmentation{XMLName: start.Name, doc: sg.doc}
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "multipleModelMethod" {
+			sg.MultipleModelMethod = attr.Value
+		}
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			if tt.Name.Local != "Segment" {
+				if err := d.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			s := Segment{doc: sg.doc}
+			if err := d.DecodeElement(&s, &tt); err != nil {
+				return err
+			}
+			sg.Segments = append(sg.Segments, s)
+		case xml.EndElement:
+			return nil
+		}
+	}
 }
 
 // custom xml unmarshaler for Segment
@@ -60,29 +94,14 @@ func (s *Segment) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 				p = &predicates.FalsePredicate{}
 			case "CompoundPredicate":
 				p = &predicates.CompoundPredicate{}
-			case "TreeModel":
-				var tm tree.TreeModel
-				err = d.DecodeElement(&tm, &tt)
-				if err != nil {
-					return err
-				}
-				s.ModelElement = &tm
-			case "RegressionModel":
-				var rm regression.RegressionModel
-				err = d.DecodeElement(&rm, &tt)
-				if err != nil {
-					return err
+			default:
+				if !IsModelElement(tt.Name.Local) {
+					return fmt.Errorf("unknown children type: %s", tt.Name.Local)
 				}
-				s.ModelElement = &rm
-			case "MiningModel":
-				var mm MiningModel
-				err = d.DecodeElement(&mm, &tt)
+				s.ModelElement, err = DecodeModelElement(d, tt, s.doc)
 				if err != nil {
 					return err
 				}
-				s.ModelElement = &mm
-			default:
-				return fmt.Errorf("unknown children type: %s", tt.Name.Local)
 			}
 			if p != nil {
 				if err := d.DecodeElement(&p, &tt); err != nil {
@@ -234,7 +253,6 @@ func (sg *Segmentation) EvaluateWeightedAverage(values map[string]interface{}) (
 }
 
 func (sg *Segmentation) EvaluateAverage(values map[string]interface{}) (map[string]interface{}, error) {
-	outputName := sg.Segments[0].ModelElement.GetOutputField()
 	nSegments := float64(len(sg.Segments))
 	out := make(map[string]interface{})
 	count := make(map[string]float64)
@@ -245,10 +263,13 @@ func (sg *Segmentation) EvaluateAverage(values map[string]interface{}) (map[stri
 			return nil, errors.Wrapf(err, SegmentFailEval)
 		}
 		for k, v := range res {
-			if k != outputName {
-				newCount := count[k] + v.(float64)
-				count[k] = newCount
+			// the predicted category of a classification is not averaged, only its probabilities, while the
+			// prediction of a regression is
+			f, ok := v.(float64)
+			if !ok {
+				continue
 			}
+			count[k] += f
 		}
 	}
 
@@ -0,0 +1,447 @@
+// Package naivebayes implements the NaiveBayesModel element. The probability of each target category is
+// its prior, taken from the counts of BayesOutput, times the likelihood of each input given the category.
+// Categorical inputs take their likelihood from PairCounts, and continuous inputs from the distribution
+// of their TargetValueStats.
+package naivebayes
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/stillmatic/pummel/pkg/distributions"
+	"github.com/stillmatic/pummel/pkg/fields"
+	"github.com/stillmatic/pummel/pkg/miningschema"
+	"github.com/stillmatic/pummel/pkg/transformations"
+	"github.com/stillmatic/pummel/pkg/verification"
+)
+
+type NaiveBayesModel struct {
+	XMLName       xml.Name `xml:"NaiveBayesModel"`
+	ModelName     string   `xml:"modelName,attr"`
+	FunctionName  string   `xml:"functionName,attr"`
+	AlgorithmName string   `xml:"algorithmName,attr"`
+	// Threshold is the likelihood used in place of a zero count or density.
+	Threshold            float64                               `xml:"threshold,attr"`
+	IsScorable           bool                                  `xml:"isScorable,attr"`
+	MiningSchema         *miningschema.MiningSchema            `xml:"MiningSchema"`
+	Output               *fields.Outputs                       `xml:"Output"`
+	LocalTransformations *transformations.LocalTransformations `xml:"LocalTransformations"`
+	BayesInputs          []*BayesInput                         `xml:"BayesInputs>BayesInput"`
+	BayesOutput          *BayesOutput                          `xml:"BayesOutput"`
+	ModelVerification    *verification.ModelVerification       `xml:"ModelVerification"`
+
+	doc *transformations.Document
+}
+
+// NewNaiveBayesModel returns an empty NaiveBayesModel to be decoded into, whose expressions belong to doc.
+func NewNaiveBayesModel(doc *transformations.Document) *NaiveBayesModel {
+	return &NaiveBayesModel{doc: doc}
+}
+
+// BayesInput holds the statistics of an input: PairCounts if it is categorical, or TargetValueStats if it
+// is continuous. A continuous input may be discretized by its DerivedField, and then has PairCounts for
+// the bins.
+type BayesInput struct {
+	XMLName          xml.Name                      `xml:"BayesInput"`
+	FieldName        string                        `xml:"fieldName,attr"`
+	DerivedField     *transformations.DerivedField `xml:"DerivedField"`
+	PairCounts       []*PairCounts                 `xml:"PairCounts"`
+	TargetValueStats []*TargetValueStat            `xml:"TargetValueStats>TargetValueStat"`
+	// totals holds the count of each target category over all of the PairCounts.
+	totals map[string]float64
+	doc    *transformations.Document
+}
+
+// PairCounts counts the records of each target category with the given input value.
+type PairCounts struct {
+	XMLName           xml.Name           `xml:"PairCounts"`
+	Value             string             `xml:"value,attr"`
+	TargetValueCounts []TargetValueCount `xml:"TargetValueCounts>TargetValueCount"`
+}
+
+type TargetValueCount struct {
+	XMLName xml.Name `xml:"TargetValueCount"`
+	Value   string   `xml:"value,attr"`
+	Count   float64  `xml:"count,attr"`
+}
+
+// TargetValueStat is the distribution of a continuous input given the target category Value.
+type TargetValueStat struct {
+	XMLName      xml.Name
+	Value        string
+	Distribution distributions.Distribution
+}
+
+// BayesOutput counts the records of each target category, which gives the prior probabilities.
+type BayesOutput struct {
+	XMLName           xml.Name           `xml:"BayesOutput"`
+	FieldName         string             `xml:"fieldName,attr"`
+	TargetValueCounts []TargetValueCount `xml:"TargetValueCounts>TargetValueCount"`
+}
+
+func (m *NaiveBayesModel) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	m.XMLName = start.Name
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "modelName":
+			m.ModelName = attr.Value
+		case "functionName":
+			m.FunctionName = attr.Value
+		case "algorithmName":
+			m.AlgorithmName = attr.Value
+		case "threshold":
+			threshold, err := strconv.ParseFloat(attr.Value, 64)
+			if err != nil {
+				return errors.Wrap(err, "invalid threshold of NaiveBayesModel")
+			}
+			m.Threshold = threshold
+		case "isScorable":
+			m.IsScorable = attr.Value == "true"
+		}
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "MiningSchema":
+				var ms miningschema.MiningSchema
+				if err := d.DecodeElement(&ms, &tt); err != nil {
+					return err
+				}
+				m.MiningSchema = &ms
+			case "Output":
+				out := fields.NewOutputs(m.doc)
+				if err := d.DecodeElement(out, &tt); err != nil {
+					return err
+				}
+				m.Output = out
+			case "LocalTransformations":
+				lt := m.doc.NewLocalTransformations()
+				if err := d.DecodeElement(lt, &tt); err != nil {
+					return err
+				}
+				m.LocalTransformations = lt
+			case "BayesInputs":
+				if err := m.decodeBayesInputs(d); err != nil {
+					return err
+				}
+			case "BayesOutput":
+				var bo BayesOutput
+				if err := d.DecodeElement(&bo, &tt); err != nil {
+					return err
+				}
+				m.BayesOutput = &bo
+			case "ModelVerification":
+				var mv verification.ModelVerification
+				if err := d.DecodeElement(&mv, &tt); err != nil {
+					return err
+				}
+				m.ModelVerification = &mv
+			case "Extension", "ModelStats", "ModelExplanation":
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unknown element: %s", tt.Name.Local)
+			}
+		case xml.EndElement:
+			return m.check()
+		}
+	}
+}
+
+// decodeBayesInputs decodes the BayesInput elements of BayesInputs.
+func (m *NaiveBayesModel) decodeBayesInputs(d *xml.Decoder) error {
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			if tt.Name.Local != "BayesInput" {
+				if err := d.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			bi := &BayesInput{doc: m.doc}
+			if err := d.DecodeElement(bi, &tt); err != nil {
+				return err
+			}
+			m.BayesInputs = append(m.BayesInputs, bi)
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// check verifies that the model has a prior for each category, and that each input has statistics.
+func (m *NaiveBayesModel) check() error {
+	if m.BayesOutput == nil || len(m.BayesOutput.TargetValueCounts) == 0 {
+		return errors.New("NaiveBayesModel has no BayesOutput counts")
+	}
+	if m.FunctionName != "classification" {
+		return fmt.Errorf("unsupported function of NaiveBayesModel: %s", m.FunctionName)
+	}
+	for _, bi := range m.BayesInputs {
+		if len(bi.PairCounts) == 0 && len(bi.TargetValueStats) == 0 {
+			return fmt.Errorf("BayesInput %s has neither PairCounts nor TargetValueStats", bi.FieldName)
+		}
+		if len(bi.PairCounts) > 0 && len(bi.TargetValueStats) > 0 {
+			return fmt.Errorf("BayesInput %s has both PairCounts and TargetValueStats", bi.FieldName)
+		}
+		bi.totals = make(map[string]float64)
+		for _, pc := range bi.PairCounts {
+			for _, tvc := range pc.TargetValueCounts {
+				bi.totals[tvc.Value] += tvc.Count
+			}
+		}
+	}
+	return nil
+}
+
+func (bi *BayesInput) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	bi.XMLName = start.Name
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "fieldName" {
+			bi.FieldName = attr.Value
+		}
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "DerivedField":
+				df := bi.doc.NewDerivedField()
+				if err := d.DecodeElement(df, &tt); err != nil {
+					return err
+				}
+				if df.Expression == nil {
+					return fmt.Errorf("DerivedField of BayesInput %s has no expression", bi.FieldName)
+				}
+				bi.DerivedField = df
+			case "PairCounts":
+				var pc PairCounts
+				if err := d.DecodeElement(&pc, &tt); err != nil {
+					return err
+				}
+				bi.PairCounts = append(bi.PairCounts, &pc)
+			case "TargetValueStats":
+				stats, err := decodeTargetValueStats(d)
+				if err != nil {
+					return errors.Wrapf(err, "invalid TargetValueStats of BayesInput %s", bi.FieldName)
+				}
+				bi.TargetValueStats = append(bi.TargetValueStats, stats...)
+			case "Extension":
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unexpected element in BayesInput: %s", tt.Name.Local)
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+func decodeTargetValueStats(d *xml.Decoder) ([]*TargetValueStat, error) {
+	var res []*TargetValueStat
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "TargetValueStat":
+				tvs, err := decodeTargetValueStat(d, tt)
+				if err != nil {
+					return nil, err
+				}
+				res = append(res, tvs)
+			case "Extension":
+				if err := d.Skip(); err != nil {
+					return nil, err
+				}
+			default:
+				return nil, fmt.Errorf("unexpected element in TargetValueStats: %s", tt.Name.Local)
+			}
+		case xml.EndElement:
+			return res, nil
+		}
+	}
+}
+
+func decodeTargetValueStat(d *xml.Decoder, start xml.StartElement) (*TargetValueStat, error) {
+	tvs := &TargetValueStat{XMLName: start.Name}
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "value" {
+			tvs.Value = attr.Value
+		}
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			if !distributions.IsDistribution(tt.Name.Local) {
+				if err := d.Skip(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			tvs.Distribution, err = distributions.Decode(d, tt)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid distribution of target value %s", tvs.Value)
+			}
+		case xml.EndElement:
+			if tvs.Distribution == nil {
+				return nil, fmt.Errorf("TargetValueStat %s has no distribution", tvs.Value)
+			}
+			return tvs, nil
+		}
+	}
+}
+
+// likelihood returns the likelihood of the input's value given each category, or nil if the value is
+// missing or was not seen in training, in which case the input is ignored.
+func (m *NaiveBayesModel) likelihood(bi *BayesInput, values map[string]interface{}) (map[string]float64, error) {
+	value := values[bi.FieldName]
+	if bi.DerivedField != nil {
+		var err error
+		value, err = bi.DerivedField.Transform(values)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to compute derived field of BayesInput %s", bi.FieldName)
+		}
+	}
+	if value == nil {
+		return nil, nil
+	}
+	res := make(map[string]float64, len(m.BayesOutput.TargetValueCounts))
+	if len(bi.TargetValueStats) > 0 {
+		x, err := transformations.InterfaceToFloat64(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid value of field %s", bi.FieldName)
+		}
+		for _, tvs := range bi.TargetValueStats {
+			res[tvs.Value] = math.Max(tvs.Distribution.Probability(x), m.Threshold)
+		}
+		return res, nil
+	}
+	for _, pc := range bi.PairCounts {
+		if !transformations.EqualsValue(value, pc.Value) {
+			continue
+		}
+		for _, tvc := range pc.TargetValueCounts {
+			if tvc.Count > 0 {
+				res[tvc.Value] = tvc.Count / bi.totals[tvc.Value]
+			}
+		}
+		return res, nil
+	}
+	return nil, nil
+}
+
+func (m *NaiveBayesModel) Evaluate(values map[string]interface{}) (map[string]interface{}, error) {
+	out, err := m.evaluate(values)
+	return m.Output.Complete(values, out, err)
+}
+
+func (m *NaiveBayesModel) evaluate(values map[string]interface{}) (map[string]interface{}, error) {
+	if m.LocalTransformations != nil {
+		for _, tr := range m.LocalTransformations.DerivedFields {
+			val, err := tr.Transform(values)
+			if err != nil {
+				return nil, err
+			}
+			values[tr.RequiredField()] = val
+		}
+	}
+	categories := m.BayesOutput.TargetValueCounts
+	probabilities := make([]float64, len(categories))
+	for i, tvc := range categories {
+		probabilities[i] = tvc.Count
+	}
+	for _, bi := range m.BayesInputs {
+		likelihood, err := m.likelihood(bi, values)
+		if err != nil {
+			return nil, err
+		}
+		if likelihood == nil {
+			continue
+		}
+		for i, tvc := range categories {
+			p, ok := likelihood[tvc.Value]
+			if !ok || p == 0 {
+				p = m.Threshold
+			}
+			probabilities[i] *= p
+		}
+	}
+
+	top := 0
+	var sum float64
+	for i, p := range probabilities {
+		if p > probabilities[top] {
+			top = i
+		}
+		sum += p
+	}
+	if sum == 0 {
+		return nil, errors.New("every category of NaiveBayesModel has zero probability")
+	}
+
+	out := make(map[string]interface{}, len(categories)+1)
+	for i, tvc := range categories {
+		// check if we have a output field for this value
+		name := tvc.Value
+		if m.Output != nil {
+			if of, err := m.Output.GetFeature(tvc.Value); err == nil {
+				name = of.Name
+			}
+		}
+		out[name] = probabilities[i] / sum
+	}
+	predicted := categories[top].Value
+	out[m.GetOutputField()] = predicted
+	if m.Output != nil {
+		for _, of := range m.Output.OutputFields {
+			if of.Feature == "predictedValue" {
+				out[of.Name] = predicted
+			}
+		}
+	}
+	return out, nil
+}
+
+func (m *NaiveBayesModel) GetOutputField() string {
+	return m.MiningSchema.GetOutputField()
+}
+
+func (m *NaiveBayesModel) GetMiningSchema() *miningschema.MiningSchema {
+	return m.MiningSchema
+}
+
+func (m *NaiveBayesModel) GetOutput() *fields.Outputs {
+	return m.Output
+}
+
+func (m *NaiveBayesModel) GetModelVerification() *verification.ModelVerification {
+	return m.ModelVerification
+}
@@ -0,0 +1,130 @@
+package naivebayes_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/stillmatic/pummel/pkg/naivebayes"
+	"github.com/stretchr/testify/assert"
+)
+
+var naiveBayesXML = `<NaiveBayesModel functionName="classification" threshold="0.01">
+	<MiningSchema>
+		<MiningField name="color"/>
+		<MiningField name="x"/>
+		<MiningField name="label" usageType="target"/>
+	</MiningSchema>
+	<Output>
+		<OutputField name="probability(a)" feature="probability" value="a"/>
+		<OutputField name="predicted" feature="predictedValue"/>
+	</Output>
+	<BayesInputs>
+		<BayesInput fieldName="color">
+			<PairCounts value="red">
+				<TargetValueCounts>
+					<TargetValueCount value="a" count="2"/>
+					<TargetValueCount value="b" count="0"/>
+				</TargetValueCounts>
+			</PairCounts>
+			<PairCounts value="blue">
+				<TargetValueCounts>
+					<TargetValueCount value="a" count="1"/>
+					<TargetValueCount value="b" count="1"/>
+				</TargetValueCounts>
+			</PairCounts>
+		</BayesInput>
+		<BayesInput fieldName="x">
+			<TargetValueStats>
+				<TargetValueStat value="a">
+					<UniformDistribution lower="0" upper="2"/>
+				</TargetValueStat>
+				<TargetValueStat value="b">
+					<UniformDistribution lower="0" upper="4"/>
+				</TargetValueStat>
+			</TargetValueStats>
+		</BayesInput>
+	</BayesInputs>
+	<BayesOutput fieldName="label">
+		<TargetValueCounts>
+			<TargetValueCount value="a" count="3"/>
+			<TargetValueCount value="b" count="1"/>
+		</TargetValueCounts>
+	</BayesOutput>
+</NaiveBayesModel>`
+
+func TestNaiveBayes(t *testing.T) {
+	var m naivebayes.NaiveBayesModel
+	err := xml.Unmarshal([]byte(naiveBayesXML), &m)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.01, m.Threshold)
+	assert.Equal(t, 2, len(m.BayesInputs))
+	assert.Equal(t, "label", m.BayesOutput.FieldName)
+
+	tcs := []struct {
+		inputs   map[string]interface{}
+		expected string
+		pa       float64
+	}{
+		// the zero count of b is replaced by the threshold
+		{map[string]interface{}{"color": "red"}, "a", 2 / 2.01},
+		// ties go to the first category
+		{map[string]interface{}{"color": "blue"}, "a", 0.5},
+		{map[string]interface{}{"color": "red", "x": 1.0}, "a", 1 / 1.0025},
+		// x lies outside of the distribution of a, whose density is replaced by the threshold
+		{map[string]interface{}{"color": "blue", "x": 3}, "b", 0.01 / 0.26},
+		// missing and unseen values are ignored, leaving the prior
+		{map[string]interface{}{"color": nil}, "a", 0.75},
+		{map[string]interface{}{"color": "green"}, "a", 0.75},
+	}
+	for _, tc := range tcs {
+		out, err := m.Evaluate(tc.inputs)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected, out["label"])
+		assert.Equal(t, tc.expected, out["predicted"])
+		assert.InDelta(t, tc.pa, out["probability(a)"], 1e-12)
+		assert.InDelta(t, 1-tc.pa, out["b"], 1e-12)
+	}
+}
+
+func TestNaiveBayesDerivedField(t *testing.T) {
+	discretized := strings.Replace(naiveBayesXML, `<BayesInput fieldName="color">`, `<BayesInput fieldName="n">
+			<DerivedField name="color" optype="categorical" dataType="string">
+				<Discretize field="n">
+					<DiscretizeBin binValue="red"><Interval closure="closedOpen" leftMargin="0" rightMargin="10"/></DiscretizeBin>
+					<DiscretizeBin binValue="blue"><Interval closure="closedOpen" leftMargin="10"/></DiscretizeBin>
+				</Discretize>
+			</DerivedField>`, 1)
+	var m naivebayes.NaiveBayesModel
+	err := xml.Unmarshal([]byte(discretized), &m)
+	assert.NoError(t, err)
+	out, err := m.Evaluate(map[string]interface{}{"n": 5})
+	assert.NoError(t, err)
+	assert.InDelta(t, 2/2.01, out["probability(a)"], 1e-12)
+	out, err = m.Evaluate(map[string]interface{}{"n": 12.5})
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.5, out["probability(a)"], 1e-12)
+	// values outside of every bin are ignored
+	out, err = m.Evaluate(map[string]interface{}{"n": -1})
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.75, out["probability(a)"], 1e-12)
+}
+
+func TestNaiveBayesErrors(t *testing.T) {
+	tcs := []struct {
+		old, new string
+		expected string
+	}{
+		{`<TargetValueCount value="a" count="3"/>
+			<TargetValueCount value="b" count="1"/>`, ``, "NaiveBayesModel has no BayesOutput counts"},
+		{`functionName="classification"`, `functionName="regression"`, "unsupported function of NaiveBayesModel: regression"},
+		{`<UniformDistribution lower="0" upper="2"/>`, `<AnyDistribution mean="1" variance="1"/>`,
+			"invalid TargetValueStats of BayesInput x: invalid distribution of target value a: unsupported distribution: AnyDistribution"},
+		{`<BayesInput fieldName="x">`, `<BayesInput fieldName="x"><PairCounts value="1"/>`, "BayesInput x has both PairCounts and TargetValueStats"},
+	}
+	for _, tc := range tcs {
+		var m naivebayes.NaiveBayesModel
+		err := xml.Unmarshal([]byte(strings.Replace(naiveBayesXML, tc.old, tc.new, 1)), &m)
+		assert.EqualError(t, err, tc.expected)
+	}
+}
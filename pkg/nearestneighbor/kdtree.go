@@ -0,0 +1,186 @@
+package nearestneighbor
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/stillmatic/pummel/pkg/comparisonmeasure"
+	"github.com/stillmatic/pummel/pkg/transformations"
+)
+
+// minIndexedInstances is the size of the instance table from which the neighbors are searched in a
+// k-d tree, rather than by measuring the distance to every instance.
+const minIndexedInstances = 256
+
+// indexable reports whether the instances can be searched in a k-d tree. The measure has to be a distance
+// which grows with the difference between the values of each field, so that the difference along a single
+// field bounds the distance, and the inputs of every instance have to be numbers or missing.
+func (m *NearestNeighborModel) indexable() bool {
+	cm := m.ComparisonMeasure
+	if cm.Kind != comparisonmeasure.Kinds.Distance || cm.IsBinary() {
+		return false
+	}
+	for _, f := range m.measures {
+		compareFunction := f.CompareFunction
+		if compareFunction == "" {
+			compareFunction = cm.CompareFunction
+		}
+		if compareFunction != comparisonmeasure.CompareFunctions.AbsDiff {
+			return false
+		}
+	}
+	for _, inst := range m.instances {
+		for _, v := range inst.inputs {
+			if _, ok := v.(float64); !ok && v != nil {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// kdTree splits the instances by the median of one input at each level, cycling through the inputs.
+// Instances missing an input are not in the tree, and are measured one by one.
+type kdTree struct {
+	root       *kdNode
+	incomplete []int
+}
+
+type kdNode struct {
+	instance int
+	axis     int
+	// value is the input of the instance along axis: the instances of left are at most value,
+	// and those of right at least value.
+	value       float64
+	left, right *kdNode
+}
+
+func newKDTree(instances []*instance, dims int) *kdTree {
+	t := &kdTree{}
+	idx := make([]int, 0, len(instances))
+	for i, inst := range instances {
+		if complete(inst) {
+			idx = append(idx, i)
+		} else {
+			t.incomplete = append(t.incomplete, i)
+		}
+	}
+	t.root = buildKDNode(instances, idx, 0, dims)
+	return t
+}
+
+func complete(inst *instance) bool {
+	for _, v := range inst.inputs {
+		if v == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func buildKDNode(instances []*instance, idx []int, depth, dims int) *kdNode {
+	if len(idx) == 0 {
+		return nil
+	}
+	axis := depth % dims
+	coord := func(i int) float64 { return instances[idx[i]].inputs[axis].(float64) }
+	sort.Slice(idx, func(i, j int) bool { return coord(i) < coord(j) })
+	mid := len(idx) / 2
+	return &kdNode{
+		instance: idx[mid],
+		axis:     axis,
+		value:    coord(mid),
+		left:     buildKDNode(instances, idx[:mid], depth+1, dims),
+		right:    buildKDNode(instances, idx[mid+1:], depth+1, dims),
+	}
+}
+
+// kdSearch keeps the best neighbors found so far, from the closest to the farthest.
+type kdSearch struct {
+	m    *NearestNeighborModel
+	x    []interface{}
+	best []neighbor
+}
+
+// search returns the same neighbors as measuring the distance to every instance: ties between instances
+// at the same distance go to the one which comes first in the table.
+func (t *kdTree) search(m *NearestNeighborModel, x []interface{}) ([]neighbor, error) {
+	s := &kdSearch{m: m, x: x, best: make([]neighbor, 0, m.NumberOfNeighbors+1)}
+	for _, i := range t.incomplete {
+		if err := s.measure(i); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.visit(t.root); err != nil {
+		return nil, err
+	}
+	return s.best, nil
+}
+
+func closer(a, b neighbor) bool {
+	return a.affinity < b.affinity || (a.affinity == b.affinity && a.instance < b.instance)
+}
+
+func (s *kdSearch) add(n neighbor) {
+	i := sort.Search(len(s.best), func(i int) bool { return closer(n, s.best[i]) })
+	if i == s.m.NumberOfNeighbors {
+		return
+	}
+	s.best = append(s.best, neighbor{})
+	copy(s.best[i+1:], s.best[i:])
+	s.best[i] = n
+	if len(s.best) > s.m.NumberOfNeighbors {
+		s.best = s.best[:s.m.NumberOfNeighbors]
+	}
+}
+
+// measure adds the i-th instance to the best neighbors if it is one of them. Like measuring the distance
+// to every instance, it skips an instance which cannot be compared with the inputs.
+func (s *kdSearch) measure(i int) error {
+	inst := s.m.instances[i]
+	d, ok, err := s.m.ComparisonMeasure.Compare(s.m.measures, s.x, inst.inputs, nil)
+	if err != nil {
+		return errors.Wrapf(err, "cannot compare with instance %s", inst.id)
+	}
+	if ok {
+		s.add(neighbor{instance: i, affinity: d})
+	}
+	return nil
+}
+
+func (s *kdSearch) visit(n *kdNode) error {
+	if n == nil {
+		return nil
+	}
+	if err := s.measure(n.instance); err != nil {
+		return err
+	}
+	if s.x[n.axis] == nil {
+		// a missing input does not bound the distance along its axis
+		if err := s.visit(n.left); err != nil {
+			return err
+		}
+		return s.visit(n.right)
+	}
+	v, err := transformations.InterfaceToFloat64(s.x[n.axis])
+	if err != nil {
+		return err
+	}
+	near, far := n.left, n.right
+	if v >= n.value {
+		near, far = n.right, n.left
+	}
+	if err := s.visit(near); err != nil {
+		return err
+	}
+	// the distance along the axis alone, without the adjustment for missing values which only makes
+	// distances larger, is a lower bound of the distance to every instance on the far side
+	bound, _, err := s.m.ComparisonMeasure.Compare(s.m.measures[n.axis:n.axis+1], []interface{}{v}, []interface{}{n.value}, nil)
+	if err != nil {
+		return err
+	}
+	if len(s.best) < s.m.NumberOfNeighbors || bound <= s.best[len(s.best)-1].affinity {
+		return s.visit(far)
+	}
+	return nil
+}
@@ -0,0 +1,583 @@
+// Package nearestneighbor implements the NearestNeighborModel element, which predicts each target from
+// the training instances which are the closest to a record by the model's ComparisonMeasure.
+package nearestneighbor
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/stillmatic/pummel/pkg/comparisonmeasure"
+	"github.com/stillmatic/pummel/pkg/fields"
+	"github.com/stillmatic/pummel/pkg/miningschema"
+	"github.com/stillmatic/pummel/pkg/table"
+	"github.com/stillmatic/pummel/pkg/transformations"
+	"github.com/stillmatic/pummel/pkg/verification"
+)
+
+type NearestNeighborModel struct {
+	XMLName           xml.Name `xml:"NearestNeighborModel"`
+	ModelName         string   `xml:"modelName,attr"`
+	FunctionName      string   `xml:"functionName,attr"`
+	AlgorithmName     string   `xml:"algorithmName,attr"`
+	NumberOfNeighbors int      `xml:"numberOfNeighbors,attr"`
+	// ContinuousScoringMethod combines the values of continuous targets of the neighbors, and
+	// CategoricalScoringMethod those of categorical targets.
+	ContinuousScoringMethod  string `xml:"continuousScoringMethod,attr"`
+	CategoricalScoringMethod string `xml:"categoricalScoringMethod,attr"`
+	// InstanceIDVariable is the field identifying the training instances, which defaults to their
+	// 1-based row number.
+	InstanceIDVariable string `xml:"instanceIdVariable,attr"`
+	// Threshold is added to the distances of the neighbors before they are inverted into weights,
+	// so that an instance at a distance of 0 does not have an infinite weight.
+	Threshold            float64                               `xml:"threshold,attr"`
+	IsScorable           bool                                  `xml:"isScorable,attr"`
+	MiningSchema         *miningschema.MiningSchema            `xml:"MiningSchema"`
+	Output               *fields.Outputs                       `xml:"Output"`
+	LocalTransformations *transformations.LocalTransformations `xml:"LocalTransformations"`
+	TrainingInstances    *TrainingInstances                    `xml:"TrainingInstances"`
+	ComparisonMeasure    *comparisonmeasure.ComparisonMeasure  `xml:"ComparisonMeasure"`
+	KNNInputs            []*KNNInput                           `xml:"KNNInputs>KNNInput"`
+	ModelVerification    *verification.ModelVerification       `xml:"ModelVerification"`
+
+	measures  []comparisonmeasure.Field
+	instances []*instance
+	targets   []*target
+	// index is only built for tables of at least minIndexedInstances instances which it can search.
+	index *kdTree
+	doc   *transformations.Document
+}
+
+// NewNearestNeighborModel returns an empty NearestNeighborModel to be decoded into, whose expressions belong to doc.
+func NewNearestNeighborModel(doc *transformations.Document) *NearestNeighborModel {
+	return &NearestNeighborModel{doc: doc}
+}
+
+var ScoringMethods = struct {
+	MajorityVote         string
+	WeightedMajorityVote string
+	Average              string
+	Median               string
+	WeightedAverage      string
+}{
+	MajorityVote:         "majorityVote",
+	WeightedMajorityVote: "weightedMajorityVote",
+	Average:              "average",
+	Median:               "median",
+	WeightedAverage:      "weightedAverage",
+}
+
+// TrainingInstances holds the instances the neighbors are chosen from. Unless IsTransformed is set,
+// the LocalTransformations of the model are applied to each instance, as to the records.
+type TrainingInstances struct {
+	XMLName        xml.Name           `xml:"TrainingInstances"`
+	IsTransformed  bool               `xml:"isTransformed,attr"`
+	RecordCount    int                `xml:"recordCount,attr"`
+	FieldCount     int                `xml:"fieldCount,attr"`
+	InstanceFields []*InstanceField   `xml:"InstanceFields>InstanceField"`
+	InlineTable    *table.InlineTable `xml:"InlineTable"`
+	TableLocator   *struct{}          `xml:"TableLocator"`
+}
+
+// InstanceField maps a field onto a column of the instance table, which defaults to the field name.
+type InstanceField struct {
+	XMLName xml.Name `xml:"InstanceField"`
+	Field   string   `xml:"field,attr"`
+	Column  string   `xml:"column,attr"`
+}
+
+// KNNInput is a field compared between the records and the instances.
+type KNNInput struct {
+	XMLName         xml.Name `xml:"KNNInput"`
+	Field           string   `xml:"field,attr"`
+	FieldWeight     float64  `xml:"fieldWeight,attr"`
+	CompareFunction string   `xml:"compareFunction,attr"`
+}
+
+// instance is a row of the instance table, with the value of each KNNInput and of each target.
+type instance struct {
+	id      string
+	inputs  []interface{}
+	targets []interface{}
+}
+
+// target is a target field of the model, which is either scored as a category or as a number.
+type target struct {
+	name        string
+	categorical bool
+}
+
+// neighbor is an instance and its distance, or similarity, to a record.
+type neighbor struct {
+	instance int
+	affinity float64
+}
+
+func (m *NearestNeighborModel) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	m.XMLName = start.Name
+	m.ContinuousScoringMethod = ScoringMethods.Average
+	m.CategoricalScoringMethod = ScoringMethods.MajorityVote
+	m.Threshold = 0.001
+	for _, attr := range start.Attr {
+		var err error
+		switch attr.Name.Local {
+		case "modelName":
+			m.ModelName = attr.Value
+		case "functionName":
+			m.FunctionName = attr.Value
+		case "algorithmName":
+			m.AlgorithmName = attr.Value
+		case "numberOfNeighbors":
+			m.NumberOfNeighbors, err = strconv.Atoi(attr.Value)
+		case "continuousScoringMethod":
+			m.ContinuousScoringMethod = attr.Value
+		case "categoricalScoringMethod":
+			m.CategoricalScoringMethod = attr.Value
+		case "instanceIdVariable":
+			m.InstanceIDVariable = attr.Value
+		case "threshold":
+			m.Threshold, err = strconv.ParseFloat(attr.Value, 64)
+		case "isScorable":
+			m.IsScorable = attr.Value == "true"
+		}
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s of NearestNeighborModel", attr.Name.Local)
+		}
+	}
+	switch m.ContinuousScoringMethod {
+	case ScoringMethods.Average, ScoringMethods.Median, ScoringMethods.WeightedAverage:
+	default:
+		return fmt.Errorf("unknown continuous scoring method: %s", m.ContinuousScoringMethod)
+	}
+	switch m.CategoricalScoringMethod {
+	case ScoringMethods.MajorityVote, ScoringMethods.WeightedMajorityVote:
+	default:
+		return fmt.Errorf("unknown categorical scoring method: %s", m.CategoricalScoringMethod)
+	}
+	if m.NumberOfNeighbors < 1 {
+		return fmt.Errorf("numberOfNeighbors of NearestNeighborModel must be positive, got %d", m.NumberOfNeighbors)
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "MiningSchema":
+				var ms miningschema.MiningSchema
+				if err := d.DecodeElement(&ms, &tt); err != nil {
+					return err
+				}
+				m.MiningSchema = &ms
+			case "Output":
+				out := fields.NewOutputs(m.doc)
+				if err := d.DecodeElement(out, &tt); err != nil {
+					return err
+				}
+				m.Output = out
+			case "LocalTransformations":
+				lt := m.doc.NewLocalTransformations()
+				if err := d.DecodeElement(lt, &tt); err != nil {
+					return err
+				}
+				m.LocalTransformations = lt
+			case "TrainingInstances":
+				var ti TrainingInstances
+				if err := d.DecodeElement(&ti, &tt); err != nil {
+					return err
+				}
+				m.TrainingInstances = &ti
+			case "ComparisonMeasure":
+				var cm comparisonmeasure.ComparisonMeasure
+				if err := d.DecodeElement(&cm, &tt); err != nil {
+					return err
+				}
+				m.ComparisonMeasure = &cm
+			case "KNNInputs":
+				var inputs struct {
+					KNNInputs []*KNNInput `xml:"KNNInput"`
+				}
+				if err := d.DecodeElement(&inputs, &tt); err != nil {
+					return err
+				}
+				m.KNNInputs = inputs.KNNInputs
+			case "ModelVerification":
+				var mv verification.ModelVerification
+				if err := d.DecodeElement(&mv, &tt); err != nil {
+					return err
+				}
+				m.ModelVerification = &mv
+			case "Extension", "ModelStats", "ModelExplanation":
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unknown element: %s", tt.Name.Local)
+			}
+		case xml.EndElement:
+			return m.prepare()
+		}
+	}
+}
+
+func (ki *KNNInput) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*ki = KNNInput{XMLName: start.Name, FieldWeight: 1}
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "field":
+			ki.Field = attr.Value
+		case "fieldWeight":
+			weight, err := strconv.ParseFloat(attr.Value, 64)
+			if err != nil {
+				return errors.Wrapf(err, "invalid fieldWeight of KNNInput %s", ki.Field)
+			}
+			ki.FieldWeight = weight
+		case "compareFunction":
+			if err := comparisonmeasure.CheckCompareFunction(attr.Value); err != nil {
+				return errors.Wrapf(err, "invalid compareFunction of KNNInput %s", ki.Field)
+			}
+			ki.CompareFunction = attr.Value
+		}
+	}
+	return d.Skip()
+}
+
+// prepare reads the instance table, keeping the value of each KNNInput and target of every instance,
+// and indexes the instances if there are enough of them.
+func (m *NearestNeighborModel) prepare() error {
+	switch {
+	case m.MiningSchema == nil:
+		return errors.New("NearestNeighborModel has no MiningSchema")
+	case m.ComparisonMeasure == nil:
+		return errors.New("NearestNeighborModel has no ComparisonMeasure")
+	case len(m.KNNInputs) == 0:
+		return errors.New("NearestNeighborModel has no KNNInputs")
+	case m.TrainingInstances == nil:
+		return errors.New("NearestNeighborModel has no TrainingInstances")
+	case m.TrainingInstances.TableLocator != nil:
+		return errors.New("TrainingInstances with a TableLocator are not supported")
+	case m.TrainingInstances.InlineTable == nil:
+		return errors.New("TrainingInstances has no InlineTable")
+	}
+	weighted := m.ContinuousScoringMethod == ScoringMethods.WeightedAverage ||
+		m.CategoricalScoringMethod == ScoringMethods.WeightedMajorityVote
+	if weighted && m.ComparisonMeasure.Kind != comparisonmeasure.Kinds.Distance {
+		return errors.New("weighted scoring methods require a distance")
+	}
+	for _, ki := range m.KNNInputs {
+		m.measures = append(m.measures, comparisonmeasure.Field{CompareFunction: ki.CompareFunction, Weight: ki.FieldWeight})
+	}
+	for _, mf := range m.MiningSchema.MiningFields {
+		if mf.UsageType != "target" && mf.UsageType != "predicted" {
+			continue
+		}
+		t := &target{name: mf.Name}
+		switch m.FunctionName {
+		case "classification":
+			t.categorical = true
+		case "regression":
+		case "mixed":
+			switch mf.OpType {
+			case "categorical", "ordinal":
+				t.categorical = true
+			case "continuous":
+			default:
+				return fmt.Errorf("target %s of a mixed NearestNeighborModel requires an optype", mf.Name)
+			}
+		default:
+			return fmt.Errorf("unknown model type: %s", m.FunctionName)
+		}
+		m.targets = append(m.targets, t)
+	}
+
+	columns := make(map[string]string)
+	for _, f := range m.TrainingInstances.InstanceFields {
+		column := f.Column
+		if column == "" {
+			column = f.Field
+		}
+		columns[f.Field] = table.ColumnName(column)
+	}
+	for _, t := range m.targets {
+		if _, ok := columns[t.name]; !ok {
+			return fmt.Errorf("no InstanceField for target %s", t.name)
+		}
+	}
+	idColumn := ""
+	if m.InstanceIDVariable != "" {
+		var ok bool
+		if idColumn, ok = columns[m.InstanceIDVariable]; !ok {
+			return fmt.Errorf("no InstanceField for instanceIdVariable %s", m.InstanceIDVariable)
+		}
+	}
+	for i, row := range m.TrainingInstances.InlineTable.Rows {
+		values := make(map[string]interface{}, len(columns))
+		for field, column := range columns {
+			values[field] = cellValue(row[column])
+		}
+		if !m.TrainingInstances.IsTransformed && m.LocalTransformations != nil {
+			for _, tr := range m.LocalTransformations.DerivedFields {
+				val, err := tr.Transform(values)
+				if err != nil {
+					return errors.Wrapf(err, "cannot transform instance %d", i+1)
+				}
+				values[tr.RequiredField()] = val
+			}
+		}
+		inst := &instance{id: strconv.Itoa(i + 1)}
+		if idColumn != "" {
+			inst.id = row[idColumn]
+		}
+		for _, ki := range m.KNNInputs {
+			inst.inputs = append(inst.inputs, values[ki.Field])
+		}
+		for _, t := range m.targets {
+			value := values[t.name]
+			if !t.categorical && value != nil {
+				if _, ok := value.(float64); !ok {
+					return fmt.Errorf("invalid value %v of target %s of instance %d", value, t.name, i+1)
+				}
+			}
+			inst.targets = append(inst.targets, value)
+		}
+		m.instances = append(m.instances, inst)
+	}
+	if len(m.instances) == 0 {
+		return errors.New("TrainingInstances has no instances")
+	}
+	if len(m.instances) >= minIndexedInstances && m.indexable() {
+		m.index = newKDTree(m.instances, len(m.KNNInputs))
+	}
+	return nil
+}
+
+// cellValue converts a cell of the instance table into a number if it is one, or nil if it is empty.
+func cellValue(cell string) interface{} {
+	if cell == "" {
+		return nil
+	}
+	if f, err := strconv.ParseFloat(cell, 64); err == nil {
+		return f
+	}
+	return cell
+}
+
+func (m *NearestNeighborModel) Evaluate(values map[string]interface{}) (map[string]interface{}, error) {
+	out, err := m.evaluate(values)
+	return m.Output.Complete(values, out, err)
+}
+
+func (m *NearestNeighborModel) evaluate(values map[string]interface{}) (map[string]interface{}, error) {
+	if m.LocalTransformations != nil {
+		for _, tr := range m.LocalTransformations.DerivedFields {
+			val, err := tr.Transform(values)
+			if err != nil {
+				return nil, err
+			}
+			values[tr.RequiredField()] = val
+		}
+	}
+	x := make([]interface{}, len(m.KNNInputs))
+	var present bool
+	for i, ki := range m.KNNInputs {
+		x[i] = values[ki.Field]
+		present = present || x[i] != nil
+	}
+	if !present {
+		return nil, nil
+	}
+	neighbors, err := m.neighbors(x)
+	if err != nil {
+		return nil, err
+	}
+	if neighbors == nil {
+		return nil, nil
+	}
+
+	out := make(map[string]interface{})
+	// votes holds the share of the votes of each category of the first target, if it is categorical
+	var votes map[string]float64
+	var categories []string
+	for i, t := range m.targets {
+		if t.categorical {
+			winner, shares, order := m.vote(neighbors, i)
+			out[t.name] = winner
+			if i == 0 {
+				votes, categories = shares, order
+			}
+			continue
+		}
+		out[t.name] = m.average(neighbors, i)
+	}
+	if m.FunctionName == "classification" {
+		for _, category := range categories {
+			// check if we have a output field for this value
+			name := category
+			if m.Output != nil {
+				if of, err := m.Output.GetFeature(category); err == nil {
+					name = of.Name
+				}
+			}
+			out[name] = votes[category]
+		}
+	}
+	if m.Output == nil {
+		return out, nil
+	}
+	for _, of := range m.Output.OutputFields {
+		rank := of.Rank
+		if rank == 0 {
+			rank = 1
+		}
+		switch of.Feature {
+		case "predictedValue":
+			if len(m.targets) > 0 {
+				out[of.Name] = out[m.targets[0].name]
+			}
+		case "probability":
+			// categories no neighbor votes for have a probability of 0
+			if votes != nil && of.Value != "" {
+				out[of.Name] = votes[of.Value]
+			}
+		case "entityId", "affinity":
+			out[of.Name] = nil
+			if rank > len(neighbors) {
+				continue
+			}
+			if of.Feature == "entityId" {
+				out[of.Name] = m.instances[neighbors[rank-1].instance].id
+			} else {
+				out[of.Name] = neighbors[rank-1].affinity
+			}
+		}
+	}
+	return out, nil
+}
+
+// neighbors returns the numberOfNeighbors instances closest to x, from the closest to the farthest.
+// Instances at the same distance are ordered as in the table.
+func (m *NearestNeighborModel) neighbors(x []interface{}) ([]neighbor, error) {
+	if m.index != nil {
+		return m.index.search(m, x)
+	}
+	all := make([]neighbor, 0, len(m.instances))
+	for i, inst := range m.instances {
+		affinity, ok, err := m.ComparisonMeasure.Compare(m.measures, x, inst.inputs, nil)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot compare with instance %s", inst.id)
+		}
+		if !ok {
+			// no input is present in both, the instance is not a neighbor
+			continue
+		}
+		all = append(all, neighbor{instance: i, affinity: affinity})
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		return m.ComparisonMeasure.Better(all[i].affinity, all[j].affinity)
+	})
+	if len(all) > m.NumberOfNeighbors {
+		all = all[:m.NumberOfNeighbors]
+	}
+	return all, nil
+}
+
+// weight is the weight of a neighbor in the weighted scoring methods, its inverse distance.
+func (m *NearestNeighborModel) weight(n neighbor) float64 {
+	return 1 / (n.affinity + m.Threshold)
+}
+
+// vote returns the category of the t-th target with the most votes of the neighbors, and the share of
+// the votes of each category, in the order in which the neighbors vote for them. Ties go to the category
+// voted for first, i.e. by the closest neighbor. Neighbors without a value do not vote.
+func (m *NearestNeighborModel) vote(neighbors []neighbor, t int) (interface{}, map[string]float64, []string) {
+	shares := make(map[string]float64)
+	var categories []string
+	var total float64
+	for _, n := range neighbors {
+		value := m.instances[n.instance].targets[t]
+		if value == nil {
+			continue
+		}
+		category := fmt.Sprint(value)
+		if _, ok := shares[category]; !ok {
+			categories = append(categories, category)
+		}
+		w := 1.0
+		if m.CategoricalScoringMethod == ScoringMethods.WeightedMajorityVote {
+			w = m.weight(n)
+		}
+		shares[category] += w
+		total += w
+	}
+	if total == 0 {
+		return nil, nil, nil
+	}
+	winner := categories[0]
+	for _, category := range categories[1:] {
+		if shares[category] > shares[winner] {
+			winner = category
+		}
+	}
+	for category := range shares {
+		shares[category] /= total
+	}
+	return winner, shares, categories
+}
+
+// average combines the values of the t-th target of the neighbors by the continuous scoring method.
+// Neighbors without a value are left out.
+func (m *NearestNeighborModel) average(neighbors []neighbor, t int) interface{} {
+	var values, weights []float64
+	for _, n := range neighbors {
+		value := m.instances[n.instance].targets[t]
+		if value == nil {
+			continue
+		}
+		values = append(values, value.(float64))
+		weights = append(weights, m.weight(n))
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	switch m.ContinuousScoringMethod {
+	case ScoringMethods.Median:
+		sort.Float64s(values)
+		mid := len(values) / 2
+		if len(values)%2 == 1 {
+			return values[mid]
+		}
+		return (values[mid-1] + values[mid]) / 2
+	case ScoringMethods.WeightedAverage:
+		var sum, total float64
+		for i, v := range values {
+			sum += weights[i] * v
+			total += weights[i]
+		}
+		return sum / total
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+func (m *NearestNeighborModel) GetOutputField() string {
+	return m.MiningSchema.GetOutputField()
+}
+
+func (m *NearestNeighborModel) GetMiningSchema() *miningschema.MiningSchema {
+	return m.MiningSchema
+}
+
+func (m *NearestNeighborModel) GetOutput() *fields.Outputs {
+	return m.Output
+}
+
+func (m *NearestNeighborModel) GetModelVerification() *verification.ModelVerification {
+	return m.ModelVerification
+}
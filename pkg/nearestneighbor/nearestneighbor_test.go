@@ -0,0 +1,294 @@
+package nearestneighbor_test
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stillmatic/pummel/pkg/nearestneighbor"
+	"github.com/stretchr/testify/assert"
+)
+
+var knnXML = `<NearestNeighborModel functionName="mixed" numberOfNeighbors="3" instanceIdVariable="id"
+		continuousScoringMethod="average" categoricalScoringMethod="majorityVote">
+	<MiningSchema>
+		<MiningField name="x"/>
+		<MiningField name="y"/>
+		<MiningField name="species" usageType="target" optype="categorical"/>
+		<MiningField name="value" usageType="target" optype="continuous"/>
+	</MiningSchema>
+	<Output>
+		<OutputField name="prediction" feature="predictedValue"/>
+		<OutputField name="nearest" feature="entityId"/>
+		<OutputField name="third" feature="entityId" rank="3"/>
+		<OutputField name="fourth" feature="entityId" rank="4"/>
+		<OutputField name="distance_2" feature="affinity" rank="2"/>
+	</Output>
+	<TrainingInstances>
+		<InstanceFields>
+			<InstanceField field="id" column="id"/>
+			<InstanceField field="x" column="data:x"/>
+			<InstanceField field="y" column="data:y"/>
+			<InstanceField field="species" column="data:class"/>
+			<InstanceField field="value"/>
+		</InstanceFields>
+		<InlineTable>
+			<row><id>a</id><data:x>0</data:x><data:y>0</data:y><data:class>red</data:class><value>1</value></row>
+			<row><id>b</id><data:x>1</data:x><data:y>0</data:y><data:class>red</data:class><value>2</value></row>
+			<row><id>c</id><data:x>0</data:x><data:y>2</data:y><data:class>blue</data:class><value>10</value></row>
+			<row><id>d</id><data:x>5</data:x><data:y>5</data:y><data:class>blue</data:class><value>20</value></row>
+			<row><id>e</id><data:x>6</data:x><data:y>5</data:y><data:class>green</data:class><value>30</value></row>
+		</InlineTable>
+	</TrainingInstances>
+	<ComparisonMeasure kind="distance">
+		<euclidean/>
+	</ComparisonMeasure>
+	<KNNInputs>
+		<KNNInput field="x"/>
+		<KNNInput field="y"/>
+	</KNNInputs>
+</NearestNeighborModel>`
+
+func decode(t *testing.T, s string) *nearestneighbor.NearestNeighborModel {
+	t.Helper()
+	var m nearestneighbor.NearestNeighborModel
+	err := xml.Unmarshal([]byte(s), &m)
+	assert.NoError(t, err)
+	return &m
+}
+
+func TestNearestNeighborModel(t *testing.T) {
+	m := decode(t, knnXML)
+
+	out, err := m.Evaluate(map[string]interface{}{"x": 0, "y": 0.5})
+	assert.NoError(t, err)
+	assert.Equal(t, "red", out["species"])
+	assert.Equal(t, "red", out["prediction"])
+	assert.InDelta(t, 13.0/3, out["value"], 1e-12)
+	assert.Equal(t, "a", out["nearest"])
+	assert.Equal(t, "c", out["third"])
+	assert.Nil(t, out["fourth"])
+	assert.InDelta(t, math.Sqrt(1.25), out["distance_2"], 1e-12)
+
+	// d, e and c are the closest, two of which are blue
+	out, err = m.Evaluate(map[string]interface{}{"x": 3, "y": "4"})
+	assert.NoError(t, err)
+	assert.Equal(t, "blue", out["species"])
+	assert.Equal(t, 20.0, out["value"])
+	assert.Equal(t, "d", out["nearest"])
+
+	// without y, the distance along x is scaled up by 2
+	out, err = m.Evaluate(map[string]interface{}{"x": 6})
+	assert.NoError(t, err)
+	assert.Equal(t, "e", out["nearest"])
+	assert.InDelta(t, math.Sqrt(2), out["distance_2"], 1e-12)
+
+	out, err = m.Evaluate(map[string]interface{}{"species": "red"})
+	assert.NoError(t, err)
+	assert.Nil(t, out)
+}
+
+func TestNearestNeighborScoringMethods(t *testing.T) {
+	w := []float64{1 / 0.501, 1 / (math.Sqrt(1.25) + 0.001), 1 / 1.501}
+	tcs := []struct {
+		continuous, categorical string
+		value                   float64
+		species                 string
+	}{
+		{"median", "majorityVote", 2, "red"},
+		{"weightedAverage", "majorityVote", (w[0]*1 + w[1]*2 + w[2]*10) / (w[0] + w[1] + w[2]), "red"},
+		{"average", "weightedMajorityVote", 13.0 / 3, "red"},
+	}
+	for _, tc := range tcs {
+		s := strings.Replace(knnXML, `continuousScoringMethod="average"`, `continuousScoringMethod="`+tc.continuous+`"`, 1)
+		s = strings.Replace(s, `categoricalScoringMethod="majorityVote"`, `categoricalScoringMethod="`+tc.categorical+`"`, 1)
+		m := decode(t, s)
+		out, err := m.Evaluate(map[string]interface{}{"x": 0.0, "y": 0.5})
+		assert.NoError(t, err)
+		assert.InDelta(t, tc.value, out["value"], 1e-12, tc.continuous)
+		assert.Equal(t, tc.species, out["species"], tc.categorical)
+	}
+
+	// the median of an even number of neighbors is the mean of the middle two
+	s := strings.Replace(knnXML, `numberOfNeighbors="3"`, `numberOfNeighbors="4"`, 1)
+	s = strings.Replace(s, `continuousScoringMethod="average"`, `continuousScoringMethod="median"`, 1)
+	m := decode(t, s)
+	out, err := m.Evaluate(map[string]interface{}{"x": 0.0, "y": 0.5})
+	assert.NoError(t, err)
+	assert.Equal(t, 6.0, out["value"])
+}
+
+func TestNearestNeighborClassification(t *testing.T) {
+	s := strings.Replace(knnXML, `functionName="mixed"`, `functionName="classification"`, 1)
+	s = strings.Replace(s, `<MiningField name="value" usageType="target" optype="continuous"/>`, ``, 1)
+	s = strings.Replace(s, `<InstanceField field="value"/>`, ``, 1)
+	s = strings.Replace(s, `<OutputField name="prediction" feature="predictedValue"/>`,
+		`<OutputField name="prediction" feature="predictedValue"/><OutputField name="p_blue" feature="probability" value="blue"/><OutputField name="p_red" feature="probability" value="red"/>`, 1)
+	m := decode(t, s)
+	out, err := m.Evaluate(map[string]interface{}{"x": 3.0, "y": 4.0})
+	assert.NoError(t, err)
+	assert.Equal(t, "blue", out["species"])
+	assert.InDelta(t, 2.0/3, out["p_blue"], 1e-12)
+	assert.InDelta(t, 1.0/3, out["green"], 1e-12)
+	assert.Equal(t, 0.0, out["p_red"])
+}
+
+// TestNearestNeighborIndex checks the neighbors found in a table large enough to be indexed
+// against measuring the distance to every instance, on inputs with many ties.
+func TestNearestNeighborIndex(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	const n, k = 1000, 5
+	points := make([][3]float64, n)
+	for i := range points {
+		for j := range points[i] {
+			points[i][j] = float64(rng.Intn(10))
+		}
+	}
+	weights := [3]float64{1, 0.5, 2}
+	measures := map[string]func(d [3]float64) float64{
+		"<cityBlock/>": func(d [3]float64) float64 { return d[0] + d[1] + d[2] },
+		"<euclidean/>": func(d [3]float64) float64 { return math.Sqrt(d[0]*d[0] + d[1]*d[1] + d[2]*d[2]) },
+		"<chebychev/>": func(d [3]float64) float64 { return math.Max(d[0], math.Max(d[1], d[2])) },
+	}
+	for measure, distance := range measures {
+		s := indexedModelXML(points, k, measure)
+		m := decode(t, s)
+
+		for q := 0; q < 50; q++ {
+			query := [3]float64{rng.Float64() * 10, float64(rng.Intn(10)), rng.Float64() * 10}
+			inputs := map[string]interface{}{"a": query[0], "b": query[1], "c": query[2]}
+			var missing = -1
+			if q%10 == 0 {
+				missing = q % 3
+				delete(inputs, []string{"a", "b", "c"}[missing])
+			}
+			order := make([]int, n)
+			dists := make([]float64, n)
+			for i := range points {
+				var d [3]float64
+				for j := range d {
+					if j != missing {
+						d[j] = weights[j] * math.Abs(query[j]-points[i][j])
+						if measure == "<euclidean/>" {
+							d[j] = math.Sqrt(weights[j]) * math.Abs(query[j]-points[i][j])
+						}
+					}
+				}
+				order[i], dists[i] = i, distance(d)
+			}
+			sort.SliceStable(order, func(i, j int) bool { return dists[order[i]] < dists[order[j]] })
+
+			out, err := m.Evaluate(inputs)
+			assert.NoError(t, err)
+			for r := 0; r < k; r++ {
+				assert.Equal(t, fmt.Sprintf("i%d", order[r]), out[fmt.Sprintf("n%d", r+1)], "%s %v rank %d", measure, inputs, r+1)
+			}
+		}
+	}
+}
+
+// indexedModelXML is a regression on the row number of the points, whose outputs n1 to nk are the ids of
+// the k nearest neighbors.
+func indexedModelXML(points [][3]float64, k int, measure string) string {
+	var rows, outputs strings.Builder
+	for i, p := range points {
+		fmt.Fprintf(&rows, "<row><id>i%d</id><a>%v</a><b>%v</b><c>%v</c><t>%d</t></row>\n", i, p[0], p[1], p[2], i)
+	}
+	for r := 1; r <= k; r++ {
+		fmt.Fprintf(&outputs, `<OutputField name="n%d" feature="entityId" rank="%d"/>`, r, r)
+	}
+	return fmt.Sprintf(`<NearestNeighborModel functionName="regression" numberOfNeighbors="%d" instanceIdVariable="id">
+		<MiningSchema>
+			<MiningField name="a"/><MiningField name="b"/><MiningField name="c"/>
+			<MiningField name="t" usageType="target"/>
+		</MiningSchema>
+		<Output>%s</Output>
+		<TrainingInstances>
+			<InstanceFields>
+				<InstanceField field="id"/><InstanceField field="a"/><InstanceField field="b"/>
+				<InstanceField field="c"/><InstanceField field="t"/>
+			</InstanceFields>
+			<InlineTable>%s</InlineTable>
+		</TrainingInstances>
+		<ComparisonMeasure kind="distance">%s</ComparisonMeasure>
+		<KNNInputs>
+			<KNNInput field="a"/><KNNInput field="b" fieldWeight="0.5"/><KNNInput field="c" fieldWeight="2"/>
+		</KNNInputs>
+	</NearestNeighborModel>`, k, outputs.String(), rows.String(), measure)
+}
+
+// TestNearestNeighborMissingInstanceInput scores records against a table with an instance missing an input,
+// both by measuring every instance and through the index of the same table padded with distant instances.
+func TestNearestNeighborMissingInstanceInput(t *testing.T) {
+	points := [][3]float64{{0, 0, 0}, {1, 1, 1}, {3, 3, 3}}
+	padded := append([][3]float64(nil), points...)
+	for i := 0; i < 300; i++ {
+		padded = append(padded, [3]float64{1000 + float64(i), 1000, 1000})
+	}
+	tcs := []struct {
+		inputs   map[string]interface{}
+		expected []interface{}
+	}{
+		// the missing input of i0 is left out of its distance
+		{map[string]interface{}{"a": 0.0, "b": 5.0, "c": 0.0}, []interface{}{"i0", "i1"}},
+		// and i0 has no input in common with the record, so it is not a neighbor
+		{map[string]interface{}{"b": 5.0}, []interface{}{"i2", "i1"}},
+	}
+	for _, table := range [][][3]float64{points, padded} {
+		s := strings.Replace(indexedModelXML(table, 2, "<euclidean/>"), "<id>i0</id><a>0</a><b>0</b>", "<id>i0</id><a>0</a><b></b>", 1)
+		m := decode(t, s)
+		for _, tc := range tcs {
+			out, err := m.Evaluate(tc.inputs)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, []interface{}{out["n1"], out["n2"]}, "%d instances, %v", len(table), tc.inputs)
+		}
+	}
+}
+
+func BenchmarkNearestNeighborIndex(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	points := make([][3]float64, 20000)
+	for i := range points {
+		points[i] = [3]float64{rng.Float64() * 100, rng.Float64() * 100, rng.Float64() * 100}
+	}
+	var m nearestneighbor.NearestNeighborModel
+	if err := xml.Unmarshal([]byte(indexedModelXML(points, 5, "<euclidean/>")), &m); err != nil {
+		b.Fatal(err)
+	}
+	inputs := map[string]interface{}{"a": 50.0, "b": 25.0, "c": 75.0}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := m.Evaluate(inputs); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestNearestNeighborErrors(t *testing.T) {
+	tcs := []struct {
+		old, new string
+	}{
+		{`numberOfNeighbors="3"`, `numberOfNeighbors="0"`},
+		{`continuousScoringMethod="average"`, `continuousScoringMethod="mode"`},
+		{`categoricalScoringMethod="majorityVote"`, `categoricalScoringMethod="average"`},
+		{` optype="continuous"`, ``},
+		{`instanceIdVariable="id"`, `instanceIdVariable="key"`},
+		{`<InstanceField field="value"/>`, ``},
+		{`<value>20</value>`, `<value>twenty</value>`},
+		{`<KNNInput field="x"/>`, `<KNNInput field="x" compareFunction="table"/>`},
+		{`<ComparisonMeasure kind="distance">`, `<ComparisonMeasure kind="similarity">`},
+	}
+	for i, tc := range tcs {
+		s := strings.Replace(knnXML, tc.old, tc.new, 1)
+		if i == len(tcs)-1 {
+			s = strings.Replace(s, `categoricalScoringMethod="majorityVote"`, `categoricalScoringMethod="weightedMajorityVote"`, 1)
+		}
+		var m nearestneighbor.NearestNeighborModel
+		err := xml.Unmarshal([]byte(s), &m)
+		assert.Error(t, err, tc.new)
+	}
+}
@@ -0,0 +1,596 @@
+// Package neuralnetwork implements the NeuralNetwork model element: a feed-forward network whose
+// inputs are derived fields, followed by layers of neurons and outputs mapping neurons back onto
+// the target fields.
+package neuralnetwork
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/stillmatic/pummel/pkg/fields"
+	"github.com/stillmatic/pummel/pkg/miningschema"
+	"github.com/stillmatic/pummel/pkg/transformations"
+	"github.com/stillmatic/pummel/pkg/verification"
+)
+
+type NeuralNetwork struct {
+	XMLName              xml.Name                              `xml:"NeuralNetwork"`
+	ModelName            string                                `xml:"modelName,attr"`
+	FunctionName         string                                `xml:"functionName,attr"`
+	AlgorithmName        string                                `xml:"algorithmName,attr"`
+	ActivationFunction   string                                `xml:"activationFunction,attr"`
+	NormalizationMethod  string                                `xml:"normalizationMethod,attr"`
+	Threshold            float64                               `xml:"threshold,attr"`
+	Width                *float64                              `xml:"width,attr"`
+	Altitude             float64                               `xml:"altitude,attr"`
+	IsScorable           bool                                  `xml:"isScorable,attr"`
+	MiningSchema         *miningschema.MiningSchema            `xml:"MiningSchema"`
+	Output               *fields.Outputs                       `xml:"Output"`
+	LocalTransformations *transformations.LocalTransformations `xml:"LocalTransformations"`
+	NeuralInputs         []*NeuralInput                        `xml:"NeuralInputs>NeuralInput"`
+	NeuralLayers         []*NeuralLayer                        `xml:"NeuralLayer"`
+	NeuralOutputs        []*NeuralOutput                       `xml:"NeuralOutputs>NeuralOutput"`
+	ModelVerification    *verification.ModelVerification       `xml:"ModelVerification"`
+
+	doc *transformations.Document
+}
+
+// NewNeuralNetwork returns an empty NeuralNetwork to be decoded into, whose expressions belong to doc.
+func NewNeuralNetwork(doc *transformations.Document) *NeuralNetwork {
+	return &NeuralNetwork{doc: doc}
+}
+
+// NeuralInput feeds the value of its derived field, which is usually a normalization of an input field,
+// into the network as the neuron ID.
+type NeuralInput struct {
+	XMLName      xml.Name                      `xml:"NeuralInput"`
+	ID           string                        `xml:"id,attr"`
+	DerivedField *transformations.DerivedField `xml:"DerivedField"`
+
+	doc *transformations.Document
+}
+
+// NeuralLayer is a layer of neurons. Attributes which are not set on the layer are inherited from the network
+// when it is decoded.
+type NeuralLayer struct {
+	XMLName             xml.Name  `xml:"NeuralLayer"`
+	ActivationFunction  string    `xml:"activationFunction,attr"`
+	NormalizationMethod string    `xml:"normalizationMethod,attr"`
+	Threshold           *float64  `xml:"threshold,attr"`
+	Width               *float64  `xml:"width,attr"`
+	Altitude            *float64  `xml:"altitude,attr"`
+	Neurons             []*Neuron `xml:"Neuron"`
+}
+
+type Neuron struct {
+	XMLName  xml.Name `xml:"Neuron"`
+	ID       string   `xml:"id,attr"`
+	Bias     float64  `xml:"bias,attr"`
+	Width    *float64 `xml:"width,attr"`
+	Altitude *float64 `xml:"altitude,attr"`
+	Cons     []*Con   `xml:"Con"`
+}
+
+// Con is a weighted connection from a neuron or input of an earlier layer.
+type Con struct {
+	XMLName xml.Name `xml:"Con"`
+	From    string   `xml:"from,attr"`
+	Weight  float64  `xml:"weight,attr"`
+}
+
+// NeuralOutput maps the activation of a neuron onto a target field. For classification the derived field is
+// a NormDiscrete, whose value is the category the neuron gives the probability of; for regression it is a
+// FieldRef, or a NormContinuous which is inverted to map the activation back onto the target's scale.
+type NeuralOutput struct {
+	XMLName      xml.Name                      `xml:"NeuralOutput"`
+	OutputNeuron string                        `xml:"outputNeuron,attr"`
+	DerivedField *transformations.DerivedField `xml:"DerivedField"`
+
+	doc *transformations.Document
+}
+
+var ActivationFunctions = struct {
+	Threshold   string
+	Logistic    string
+	Tanh        string
+	Identity    string
+	Exponential string
+	Reciprocal  string
+	Square      string
+	Gauss       string
+	Sine        string
+	Cosine      string
+	Elliott     string
+	Arctan      string
+	Rectifier   string
+	RadialBasis string
+}{
+	Threshold:   "threshold",
+	Logistic:    "logistic",
+	Tanh:        "tanh",
+	Identity:    "identity",
+	Exponential: "exponential",
+	Reciprocal:  "reciprocal",
+	Square:      "square",
+	Gauss:       "Gauss",
+	Sine:        "sine",
+	Cosine:      "cosine",
+	Elliott:     "Elliott",
+	Arctan:      "arctan",
+	Rectifier:   "rectifier",
+	RadialBasis: "radialBasis",
+}
+
+var NormalizationMethods = struct {
+	None      string
+	Simplemax string
+	Softmax   string
+}{
+	None:      "none",
+	Simplemax: "simplemax",
+	Softmax:   "softmax",
+}
+
+// activationFunctions maps each activation function, other than radialBasis, from the weighted sum z of a neuron's
+// inputs to its output. threshold is the layer's threshold.
+var activationFunctions = map[string]func(z, threshold float64) float64{
+	ActivationFunctions.Threshold: func(z, threshold float64) float64 {
+		if z > threshold {
+			return 1
+		}
+		return 0
+	},
+	ActivationFunctions.Logistic:    func(z, _ float64) float64 { return 1 / (1 + math.Exp(-z)) },
+	ActivationFunctions.Tanh:        func(z, _ float64) float64 { return math.Tanh(z) },
+	ActivationFunctions.Identity:    func(z, _ float64) float64 { return z },
+	ActivationFunctions.Exponential: func(z, _ float64) float64 { return math.Exp(z) },
+	ActivationFunctions.Reciprocal:  func(z, _ float64) float64 { return 1 / z },
+	ActivationFunctions.Square:      func(z, _ float64) float64 { return z * z },
+	ActivationFunctions.Gauss:       func(z, _ float64) float64 { return math.Exp(-(z * z)) },
+	ActivationFunctions.Sine:        func(z, _ float64) float64 { return math.Sin(z) },
+	ActivationFunctions.Cosine:      func(z, _ float64) float64 { return math.Cos(z) },
+	ActivationFunctions.Elliott:     func(z, _ float64) float64 { return z / (1 + math.Abs(z)) },
+	ActivationFunctions.Arctan:      func(z, _ float64) float64 { return 2 * math.Atan(z) / math.Pi },
+	ActivationFunctions.Rectifier:   func(z, _ float64) float64 { return math.Max(0, z) },
+}
+
+func (nn *NeuralNetwork) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	nn.XMLName = start.Name
+	nn.NormalizationMethod = NormalizationMethods.None
+	nn.Altitude = 1
+	for _, attr := range start.Attr {
+		var err error
+		switch attr.Name.Local {
+		case "modelName":
+			nn.ModelName = attr.Value
+		case "functionName":
+			nn.FunctionName = attr.Value
+		case "algorithmName":
+			nn.AlgorithmName = attr.Value
+		case "activationFunction":
+			nn.ActivationFunction = attr.Value
+		case "normalizationMethod":
+			nn.NormalizationMethod = attr.Value
+		case "threshold":
+			nn.Threshold, err = strconv.ParseFloat(attr.Value, 64)
+		case "width":
+			var width float64
+			width, err = strconv.ParseFloat(attr.Value, 64)
+			nn.Width = &width
+		case "altitude":
+			nn.Altitude, err = strconv.ParseFloat(attr.Value, 64)
+		case "isScorable":
+			nn.IsScorable = attr.Value == "true"
+		}
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s of NeuralNetwork", attr.Name.Local)
+		}
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "MiningSchema":
+				var ms miningschema.MiningSchema
+				if err := d.DecodeElement(&ms, &tt); err != nil {
+					return err
+				}
+				nn.MiningSchema = &ms
+			case "Output":
+				out := fields.NewOutputs(nn.doc)
+				if err := d.DecodeElement(out, &tt); err != nil {
+					return err
+				}
+				nn.Output = out
+			case "LocalTransformations":
+				lt := nn.doc.NewLocalTransformations()
+				if err := d.DecodeElement(lt, &tt); err != nil {
+					return err
+				}
+				nn.LocalTransformations = lt
+			case "NeuralInputs":
+				if err := nn.decodeNeuralInputs(d); err != nil {
+					return err
+				}
+			case "NeuralLayer":
+				var nl NeuralLayer
+				if err := d.DecodeElement(&nl, &tt); err != nil {
+					return err
+				}
+				nn.NeuralLayers = append(nn.NeuralLayers, &nl)
+			case "NeuralOutputs":
+				if err := nn.decodeNeuralOutputs(d); err != nil {
+					return err
+				}
+			case "ModelVerification":
+				var mv verification.ModelVerification
+				if err := d.DecodeElement(&mv, &tt); err != nil {
+					return err
+				}
+				nn.ModelVerification = &mv
+			case "Extension", "ModelStats", "ModelExplanation":
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unknown element: %s", tt.Name.Local)
+			}
+		case xml.EndElement:
+			return nn.prepare()
+		}
+	}
+}
+
+// decodeNeuralInputs decodes the NeuralInput elements of NeuralInputs.
+func (nn *NeuralNetwork) decodeNeuralInputs(d *xml.Decoder) error {
+	nn.NeuralInputs = nil
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			if tt.Name.Local != "NeuralInput" {
+				if err := d.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			ni := &NeuralInput{doc: nn.doc}
+			if err := d.DecodeElement(ni, &tt); err != nil {
+				return err
+			}
+			nn.NeuralInputs = append(nn.NeuralInputs, ni)
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// decodeNeuralOutputs decodes the NeuralOutput elements of NeuralOutputs.
+func (nn *NeuralNetwork) decodeNeuralOutputs(d *xml.Decoder) error {
+	nn.NeuralOutputs = nil
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			if tt.Name.Local != "NeuralOutput" {
+				if err := d.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			no := &NeuralOutput{doc: nn.doc}
+			if err := d.DecodeElement(no, &tt); err != nil {
+				return err
+			}
+			nn.NeuralOutputs = append(nn.NeuralOutputs, no)
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+func (ni *NeuralInput) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*ni = NeuralInput{XMLName: start.Name, doc: ni.doc}
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "id" {
+			ni.ID = attr.Value
+		}
+	}
+	df, err := decodeDerivedField(d, ni.doc)
+	ni.DerivedField = df
+	return err
+}
+
+func (no *NeuralOutput) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*no = NeuralOutput{XMLName: start.Name, doc: no.doc}
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "outputNeuron" {
+			no.OutputNeuron = attr.Value
+		}
+	}
+	df, err := decodeDerivedField(d, no.doc)
+	no.DerivedField = df
+	return err
+}
+
+// decodeDerivedField decodes the DerivedField of a NeuralInput or NeuralOutput, which is nil if it has none.
+func decodeDerivedField(d *xml.Decoder, doc *transformations.Document) (*transformations.DerivedField, error) {
+	var df *transformations.DerivedField
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			if tt.Name.Local != "DerivedField" {
+				if err := d.Skip(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			df = doc.NewDerivedField()
+			if err := d.DecodeElement(df, &tt); err != nil {
+				return nil, err
+			}
+		case xml.EndElement:
+			return df, nil
+		}
+	}
+}
+
+// prepare resolves the layers' inherited attributes and checks that the network is well-formed,
+// so that problems are reported when the model is loaded rather than when it is evaluated.
+func (nn *NeuralNetwork) prepare() error {
+	ids := make(map[string]bool)
+	for _, in := range nn.NeuralInputs {
+		if in.DerivedField == nil || in.DerivedField.Expression == nil {
+			return fmt.Errorf("NeuralInput %s has no expression", in.ID)
+		}
+		ids[in.ID] = true
+	}
+	for i, nl := range nn.NeuralLayers {
+		if nl.ActivationFunction == "" {
+			nl.ActivationFunction = nn.ActivationFunction
+		}
+		if nl.NormalizationMethod == "" {
+			nl.NormalizationMethod = nn.NormalizationMethod
+		}
+		if nl.Threshold == nil {
+			nl.Threshold = &nn.Threshold
+		}
+		if nl.Width == nil {
+			nl.Width = nn.Width
+		}
+		if nl.Altitude == nil {
+			nl.Altitude = &nn.Altitude
+		}
+		if _, ok := activationFunctions[nl.ActivationFunction]; !ok && nl.ActivationFunction != ActivationFunctions.RadialBasis {
+			return fmt.Errorf("unsupported activation function %q in NeuralLayer %d", nl.ActivationFunction, i+1)
+		}
+		switch nl.NormalizationMethod {
+		case NormalizationMethods.None, NormalizationMethods.Simplemax, NormalizationMethods.Softmax:
+		default:
+			return fmt.Errorf("unsupported normalization method %q in NeuralLayer %d", nl.NormalizationMethod, i+1)
+		}
+		// connections may only come from inputs and earlier layers
+		for _, n := range nl.Neurons {
+			for _, c := range n.Cons {
+				if !ids[c.From] {
+					return fmt.Errorf("neuron %s is connected to unknown neuron %s", n.ID, c.From)
+				}
+			}
+			if nl.ActivationFunction == ActivationFunctions.RadialBasis && n.Width == nil && nl.Width == nil {
+				return fmt.Errorf("radial basis neuron %s has no width", n.ID)
+			}
+		}
+		for _, n := range nl.Neurons {
+			ids[n.ID] = true
+		}
+	}
+	for _, out := range nn.NeuralOutputs {
+		if !ids[out.OutputNeuron] {
+			return fmt.Errorf("NeuralOutput refers to unknown neuron %s", out.OutputNeuron)
+		}
+		if out.DerivedField == nil || out.DerivedField.Expression == nil {
+			return fmt.Errorf("NeuralOutput %s has no expression", out.OutputNeuron)
+		}
+		switch expr := (*out.DerivedField.Expression).(type) {
+		case *transformations.NormDiscrete:
+			if nn.FunctionName != "classification" {
+				return fmt.Errorf("NeuralOutput %s: NormDiscrete requires classification", out.OutputNeuron)
+			}
+		case *transformations.FieldRef, *transformations.NormContinuous:
+			if nn.FunctionName != "regression" {
+				return fmt.Errorf("NeuralOutput %s: %T requires regression", out.OutputNeuron, expr)
+			}
+		default:
+			return fmt.Errorf("unsupported expression %T in NeuralOutput %s", expr, out.OutputNeuron)
+		}
+	}
+	return nil
+}
+
+func (nn *NeuralNetwork) Evaluate(values map[string]interface{}) (map[string]interface{}, error) {
+	out, err := nn.evaluate(values)
+	return nn.Output.Complete(values, out, err)
+}
+
+func (nn *NeuralNetwork) evaluate(values map[string]interface{}) (map[string]interface{}, error) {
+	if nn.LocalTransformations != nil {
+		for _, tr := range nn.LocalTransformations.DerivedFields {
+			val, err := tr.Transform(values)
+			if err != nil {
+				return nil, err
+			}
+			values[tr.RequiredField()] = val
+		}
+	}
+	activations := make(map[string]float64, len(nn.NeuralInputs))
+	for _, in := range nn.NeuralInputs {
+		val, err := in.DerivedField.Transform(values)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to compute neural input %s", in.ID)
+		}
+		// the network cannot be evaluated without every input
+		if val == nil {
+			return nil, nil
+		}
+		f, err := transformations.InterfaceToFloat64(val)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid value of neural input %s", in.ID)
+		}
+		activations[in.ID] = f
+	}
+	for _, nl := range nn.NeuralLayers {
+		nl.evaluate(activations)
+	}
+
+	switch nn.FunctionName {
+	case "regression":
+		return nn.evaluateRegression(activations), nil
+	case "classification":
+		return nn.evaluateClassification(activations), nil
+	default:
+		return nil, fmt.Errorf("unknown model type: %s", nn.FunctionName)
+	}
+}
+
+// evaluate computes the output of every neuron in the layer from activations, and adds them to it.
+func (nl *NeuralLayer) evaluate(activations map[string]float64) {
+	outputs := make([]float64, len(nl.Neurons))
+	activation := activationFunctions[nl.ActivationFunction]
+	for i, n := range nl.Neurons {
+		if nl.ActivationFunction == ActivationFunctions.RadialBasis {
+			outputs[i] = nl.radialBasis(n, activations)
+			continue
+		}
+		z := n.Bias
+		for _, c := range n.Cons {
+			z += c.Weight * activations[c.From]
+		}
+		outputs[i] = activation(z, *nl.Threshold)
+	}
+	switch nl.NormalizationMethod {
+	case NormalizationMethods.Softmax:
+		// subtract the largest output, which leaves the result unchanged but avoids overflow
+		max := math.Inf(-1)
+		for _, y := range outputs {
+			max = math.Max(max, y)
+		}
+		var sum float64
+		for i, y := range outputs {
+			outputs[i] = math.Exp(y - max)
+			sum += outputs[i]
+		}
+		for i := range outputs {
+			outputs[i] /= sum
+		}
+	case NormalizationMethods.Simplemax:
+		var sum float64
+		for _, y := range outputs {
+			sum += y
+		}
+		for i := range outputs {
+			outputs[i] /= sum
+		}
+	}
+	for i, n := range nl.Neurons {
+		activations[n.ID] = outputs[i]
+	}
+}
+
+// radialBasis computes the output of a radial basis neuron, whose weights are the coordinates of its center.
+func (nl *NeuralLayer) radialBasis(n *Neuron, activations map[string]float64) float64 {
+	width, altitude := nl.Width, nl.Altitude
+	if n.Width != nil {
+		width = n.Width
+	}
+	if n.Altitude != nil {
+		altitude = n.Altitude
+	}
+	var z float64
+	for _, c := range n.Cons {
+		d := activations[c.From] - c.Weight
+		z += d * d
+	}
+	z /= 2 * *width * *width
+	return math.Exp(float64(len(n.Cons))*math.Log(*altitude) - z)
+}
+
+func (nn *NeuralNetwork) evaluateRegression(activations map[string]float64) map[string]interface{} {
+	out := make(map[string]interface{}, len(nn.NeuralOutputs))
+	for _, no := range nn.NeuralOutputs {
+		y := activations[no.OutputNeuron]
+		switch expr := (*no.DerivedField.Expression).(type) {
+		case *transformations.FieldRef:
+			out[expr.Field] = y
+		case *transformations.NormContinuous:
+			out[expr.Field] = expr.Denormalize(y)
+		}
+	}
+	return out
+}
+
+func (nn *NeuralNetwork) evaluateClassification(activations map[string]float64) map[string]interface{} {
+	out := make(map[string]interface{}, len(nn.NeuralOutputs)+1)
+	// the top category and its probability of each target field
+	top := make(map[string]string)
+	topScore := make(map[string]float64)
+	for _, no := range nn.NeuralOutputs {
+		nd := (*no.DerivedField.Expression).(*transformations.NormDiscrete)
+		p := activations[no.OutputNeuron]
+		if score, ok := topScore[nd.Field]; !ok || p > score {
+			top[nd.Field] = nd.Value
+			topScore[nd.Field] = p
+		}
+		// check if we have a output field for this value
+		name := nd.Value
+		if nn.Output != nil {
+			if of, err := nn.Output.GetFeature(nd.Value); err == nil {
+				name = of.Name
+			}
+		}
+		out[name] = p
+	}
+	for field, category := range top {
+		out[field] = category
+	}
+	if nn.Output != nil {
+		for _, of := range nn.Output.OutputFields {
+			if of.Feature == "predictedValue" {
+				out[of.Name] = out[nn.GetOutputField()]
+			}
+		}
+	}
+	return out
+}
+
+func (nn *NeuralNetwork) GetOutputField() string {
+	return nn.MiningSchema.GetOutputField()
+}
+
+func (nn *NeuralNetwork) GetMiningSchema() *miningschema.MiningSchema {
+	return nn.MiningSchema
+}
+
+func (nn *NeuralNetwork) GetOutput() *fields.Outputs {
+	return nn.Output
+}
+
+func (nn *NeuralNetwork) GetModelVerification() *verification.ModelVerification {
+	return nn.ModelVerification
+}
@@ -0,0 +1,301 @@
+package neuralnetwork_test
+
+import (
+	"encoding/xml"
+	"fmt"
+	"testing"
+
+	"github.com/stillmatic/pummel/pkg/neuralnetwork"
+	"github.com/stillmatic/pummel/pkg/transformations"
+	"github.com/stretchr/testify/assert"
+)
+
+var regressionXML = []byte(`<NeuralNetwork functionName="regression" activationFunction="tanh">
+	<MiningSchema>
+		<MiningField name="x1"/>
+		<MiningField name="x2"/>
+		<MiningField name="y" usageType="target"/>
+	</MiningSchema>
+	<NeuralInputs numberOfInputs="2">
+		<NeuralInput id="0">
+			<DerivedField optype="continuous" dataType="double">
+				<FieldRef field="x1"/>
+			</DerivedField>
+		</NeuralInput>
+		<NeuralInput id="1">
+			<DerivedField optype="continuous" dataType="double">
+				<NormContinuous field="x2">
+					<LinearNorm orig="0" norm="0"/>
+					<LinearNorm orig="10" norm="0.5"/>
+					<LinearNorm orig="20" norm="2"/>
+				</NormContinuous>
+			</DerivedField>
+		</NeuralInput>
+	</NeuralInputs>
+	<NeuralLayer numberOfNeurons="2">
+		<Neuron id="2" bias="0.1">
+			<Con from="0" weight="0.5"/>
+			<Con from="1" weight="-1.2"/>
+		</Neuron>
+		<Neuron id="3" bias="-0.3">
+			<Con from="0" weight="-0.7"/>
+			<Con from="1" weight="0.9"/>
+		</Neuron>
+	</NeuralLayer>
+	<NeuralLayer numberOfNeurons="1" activationFunction="identity">
+		<Neuron id="4" bias="0.5">
+			<Con from="2" weight="0.8"/>
+			<Con from="3" weight="0.4"/>
+		</Neuron>
+	</NeuralLayer>
+	<NeuralOutputs numberOfOutputs="1">
+		<NeuralOutput outputNeuron="4">
+			<DerivedField optype="continuous" dataType="double">
+				<NormContinuous field="y">
+					<LinearNorm orig="100" norm="0"/>
+					<LinearNorm orig="200" norm="1"/>
+				</NormContinuous>
+			</DerivedField>
+		</NeuralOutput>
+	</NeuralOutputs>
+</NeuralNetwork>`)
+
+func TestRegression(t *testing.T) {
+	var nn neuralnetwork.NeuralNetwork
+	err := xml.Unmarshal(regressionXML, &nn)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(nn.NeuralInputs))
+	assert.Equal(t, 2, len(nn.NeuralLayers))
+	assert.Equal(t, "tanh", nn.NeuralLayers[0].ActivationFunction)
+	assert.Equal(t, "identity", nn.NeuralLayers[1].ActivationFunction)
+	assert.Equal(t, "none", nn.NeuralLayers[1].NormalizationMethod)
+
+	tcs := []struct {
+		x1, x2   float64
+		expected float64
+	}{
+		{1, 5, 147.31191044925046},
+		// x2 in the second segment of the input normalization
+		{-2, 15, 110.3825111857918},
+		// x2 beyond the last point, which is extrapolated
+		{0.5, 25, 108.41049746393203},
+	}
+	for _, tc := range tcs {
+		out, err := nn.Evaluate(map[string]interface{}{"x1": tc.x1, "x2": tc.x2})
+		assert.NoError(t, err)
+		assert.InDelta(t, tc.expected, out["y"], 1e-9)
+	}
+
+	out, err := nn.Evaluate(map[string]interface{}{"x1": 1.0})
+	assert.NoError(t, err)
+	assert.Nil(t, out)
+}
+
+var classificationXML = []byte(`<NeuralNetwork functionName="classification" activationFunction="identity" normalizationMethod="simplemax">
+	<MiningSchema>
+		<MiningField name="x"/>
+		<MiningField name="color"/>
+		<MiningField name="label" usageType="target"/>
+	</MiningSchema>
+	<Output>
+		<OutputField name="predicted" feature="predictedValue"/>
+		<OutputField name="p_yes" feature="probability" value="yes"/>
+	</Output>
+	<NeuralInputs>
+		<NeuralInput id="x">
+			<DerivedField optype="continuous" dataType="double">
+				<FieldRef field="x"/>
+			</DerivedField>
+		</NeuralInput>
+		<NeuralInput id="red">
+			<DerivedField optype="continuous" dataType="double">
+				<NormDiscrete field="color" value="red"/>
+			</DerivedField>
+		</NeuralInput>
+	</NeuralInputs>
+	<NeuralLayer>
+		<Neuron id="no" bias="1">
+			<Con from="x" weight="1"/>
+		</Neuron>
+		<Neuron id="yes" bias="1">
+			<Con from="red" weight="2"/>
+		</Neuron>
+	</NeuralLayer>
+	<NeuralOutputs>
+		<NeuralOutput outputNeuron="no">
+			<DerivedField optype="categorical" dataType="string">
+				<NormDiscrete field="label" value="no"/>
+			</DerivedField>
+		</NeuralOutput>
+		<NeuralOutput outputNeuron="yes">
+			<DerivedField optype="categorical" dataType="string">
+				<NormDiscrete field="label" value="yes"/>
+			</DerivedField>
+		</NeuralOutput>
+	</NeuralOutputs>
+</NeuralNetwork>`)
+
+func TestClassification(t *testing.T) {
+	var nn neuralnetwork.NeuralNetwork
+	err := xml.Unmarshal(classificationXML, &nn)
+	assert.NoError(t, err)
+	assert.Equal(t, "label", nn.GetOutputField())
+
+	out, err := nn.Evaluate(map[string]interface{}{"x": 0.0, "color": "red"})
+	assert.NoError(t, err)
+	assert.Equal(t, "yes", out["label"])
+	assert.Equal(t, "yes", out["predicted"])
+	assert.InDelta(t, 0.25, out["no"], 1e-12)
+	assert.InDelta(t, 0.75, out["p_yes"], 1e-12)
+
+	out, err = nn.Evaluate(map[string]interface{}{"x": 3.0, "color": "blue"})
+	assert.NoError(t, err)
+	assert.Equal(t, "no", out["label"])
+	assert.InDelta(t, 0.8, out["no"], 1e-12)
+	assert.InDelta(t, 0.2, out["p_yes"], 1e-12)
+}
+
+// activationXML is a network with a single neuron computing x + 0.5 with the given activation function.
+const activationXML = `<NeuralNetwork functionName="regression" activationFunction="%s" threshold="0.7">
+	<MiningSchema>
+		<MiningField name="x"/>
+		<MiningField name="y" usageType="target"/>
+	</MiningSchema>
+	<NeuralInputs>
+		<NeuralInput id="x">
+			<DerivedField optype="continuous" dataType="double">
+				<FieldRef field="x"/>
+			</DerivedField>
+		</NeuralInput>
+	</NeuralInputs>
+	<NeuralLayer>
+		<Neuron id="y" bias="0.5">
+			<Con from="x" weight="1"/>
+		</Neuron>
+	</NeuralLayer>
+	<NeuralOutputs>
+		<NeuralOutput outputNeuron="y">
+			<DerivedField optype="continuous" dataType="double">
+				<FieldRef field="y"/>
+			</DerivedField>
+		</NeuralOutput>
+	</NeuralOutputs>
+</NeuralNetwork>`
+
+func TestActivationFunctions(t *testing.T) {
+	tcs := []struct {
+		function string
+		expected float64
+	}{
+		{"threshold", 1},
+		{"logistic", 0.679178699175393},
+		{"tanh", 0.6351489523872873},
+		{"identity", 0.75},
+		{"exponential", 2.117000016612675},
+		{"reciprocal", 1.3333333333333333},
+		{"square", 0.5625},
+		{"Gauss", 0.569782824730923},
+		{"sine", 0.6816387600233341},
+		{"cosine", 0.7316888688738209},
+		{"Elliott", 0.42857142857142855},
+		{"arctan", 0.4096655293982669},
+		{"rectifier", 0.75},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.function, func(t *testing.T) {
+			var nn neuralnetwork.NeuralNetwork
+			err := xml.Unmarshal([]byte(fmt.Sprintf(activationXML, tc.function)), &nn)
+			assert.NoError(t, err)
+			out, err := nn.Evaluate(map[string]interface{}{"x": 0.25})
+			assert.NoError(t, err)
+			assert.InDelta(t, tc.expected, out["y"], 1e-12)
+		})
+	}
+}
+
+func TestRadialBasis(t *testing.T) {
+	var nn neuralnetwork.NeuralNetwork
+	err := xml.Unmarshal([]byte(`<NeuralNetwork functionName="regression" activationFunction="radialBasis" width="2" altitude="1.5">
+	<MiningSchema>
+		<MiningField name="a"/>
+		<MiningField name="b"/>
+		<MiningField name="y" usageType="target"/>
+	</MiningSchema>
+	<NeuralInputs>
+		<NeuralInput id="a"><DerivedField><FieldRef field="a"/></DerivedField></NeuralInput>
+		<NeuralInput id="b"><DerivedField><FieldRef field="b"/></DerivedField></NeuralInput>
+	</NeuralInputs>
+	<NeuralLayer>
+		<Neuron id="y" bias="100">
+			<Con from="a" weight="1"/>
+			<Con from="b" weight="2"/>
+		</Neuron>
+	</NeuralLayer>
+	<NeuralOutputs>
+		<NeuralOutput outputNeuron="y"><DerivedField><FieldRef field="y"/></DerivedField></NeuralOutput>
+	</NeuralOutputs>
+</NeuralNetwork>`), &nn)
+	assert.NoError(t, err)
+	// the bias is ignored, and the weights are the neuron's center
+	out, err := nn.Evaluate(map[string]interface{}{"a": 0.25, "b": 1.5})
+	assert.NoError(t, err)
+	assert.InDelta(t, 2.0327056054494452, out["y"], 1e-12)
+}
+
+func TestInvalidNetworks(t *testing.T) {
+	tcs := []struct {
+		name     string
+		xml      string
+		expected string
+	}{
+		{
+			"unknown activation function",
+			fmt.Sprintf(activationXML, "swish"),
+			`unsupported activation function "swish" in NeuralLayer 1`,
+		},
+		{
+			"unknown neuron",
+			`<NeuralNetwork functionName="regression" activationFunction="identity">
+				<NeuralLayer><Neuron id="y"><Con from="x" weight="1"/></Neuron></NeuralLayer>
+			</NeuralNetwork>`,
+			"neuron y is connected to unknown neuron x",
+		},
+		{
+			"classification output of a regression",
+			`<NeuralNetwork functionName="regression" activationFunction="identity">
+				<NeuralLayer><Neuron id="y"/></NeuralLayer>
+				<NeuralOutputs>
+					<NeuralOutput outputNeuron="y"><DerivedField><NormDiscrete field="y" value="a"/></DerivedField></NeuralOutput>
+				</NeuralOutputs>
+			</NeuralNetwork>`,
+			"NeuralOutput y: NormDiscrete requires classification",
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			var nn neuralnetwork.NeuralNetwork
+			err := xml.Unmarshal([]byte(tc.xml), &nn)
+			assert.EqualError(t, err, tc.expected)
+		})
+	}
+}
+
+func TestNormContinuousDenormalize(t *testing.T) {
+	var nn neuralnetwork.NeuralNetwork
+	err := xml.Unmarshal(regressionXML, &nn)
+	assert.NoError(t, err)
+	nc := (*nn.NeuralInputs[1].DerivedField.Expression).(*transformations.NormContinuous)
+	for _, x := range []float64{-5, 0, 7, 10, 12.5, 20, 30} {
+		assert.InDelta(t, x, nc.Denormalize(nc.Normalize(x)), 1e-12)
+	}
+}
+
+//nolint
+func BenchmarkRegression(b *testing.B) {
+	var nn neuralnetwork.NeuralNetwork
+	xml.Unmarshal(regressionXML, &nn)
+	inputs := map[string]interface{}{"x1": 1.0, "x2": 5.0}
+	for i := 0; i < b.N; i++ {
+		nn.Evaluate(inputs)
+	}
+}
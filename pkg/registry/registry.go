@@ -0,0 +1,296 @@
+// Package registry keeps track of deployed models and their versions.
+// Models are deployed directly, or loaded from a directory of .pmml files which is polled for changes.
+// Replacing a model swaps in a new *Version; callers holding the previous one can keep evaluating it.
+package registry
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stillmatic/pummel"
+)
+
+// Extension is the file extension of the models loaded from a directory.
+const Extension = ".pmml"
+
+var idPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_\-]*$`)
+
+// Version is a single immutable load of a model.
+type Version struct {
+	ID string
+	// Version counts the loads of this ID, starting at 1.
+	Version int
+	Model   *pummel.Model
+	PMML    []byte
+	MD5Sum  string
+	Loaded  time.Time
+	// Path is the file the model was loaded from, empty if it was deployed directly.
+	Path string
+	// accessed holds the unix nanoseconds of the last evaluation, or 0.
+	accessed int64
+}
+
+// Touch records that the version was just used.
+func (v *Version) Touch() {
+	atomic.StoreInt64(&v.accessed, time.Now().UnixNano())
+}
+
+// Accessed returns when the version was last used, or the zero time.
+func (v *Version) Accessed() time.Time {
+	ns := atomic.LoadInt64(&v.accessed)
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+// Status describes the state of a model ID, including the last error loading it.
+type Status struct {
+	ID        string     `json:"id"`
+	Path      string     `json:"path,omitempty"`
+	Version   int        `json:"version,omitempty"`
+	MD5Sum    string     `json:"md5sum,omitempty"`
+	Loaded    *time.Time `json:"loaded,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	ErrorTime *time.Time `json:"errorTime,omitempty"`
+}
+
+type entry struct {
+	current  *Version
+	versions int
+	// the file backing this entry, if any, and what it looked like when last read
+	path    string
+	modTime time.Time
+	size    int64
+	fileSum string
+	// the last error loading the file, cleared by a successful load
+	err     error
+	errTime time.Time
+}
+
+type Registry struct {
+	mu      sync.RWMutex
+	entries map[string]*entry
+}
+
+func New() *Registry {
+	return &Registry{entries: make(map[string]*entry)}
+}
+
+// Get returns the current version of id, or nil if there is none.
+func (r *Registry) Get(id string) *Version {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if e, ok := r.entries[id]; ok {
+		return e.current
+	}
+	return nil
+}
+
+// List returns the current version of every deployed model, sorted by ID.
+func (r *Registry) List() []*Version {
+	r.mu.RLock()
+	versions := make([]*Version, 0, len(r.entries))
+	for _, e := range r.entries {
+		if e.current != nil {
+			versions = append(versions, e.current)
+		}
+	}
+	r.mu.RUnlock()
+	sort.Slice(versions, func(i, j int) bool { return versions[i].ID < versions[j].ID })
+	return versions
+}
+
+// Status returns the status of every known model ID, sorted by ID.
+func (r *Registry) Status() []Status {
+	r.mu.RLock()
+	statuses := make([]Status, 0, len(r.entries))
+	for id, e := range r.entries {
+		s := Status{ID: id, Path: e.path}
+		if e.err != nil {
+			s.Error = e.err.Error()
+			errTime := e.errTime
+			s.ErrorTime = &errTime
+		}
+		if e.current != nil {
+			s.Version = e.current.Version
+			s.MD5Sum = e.current.MD5Sum
+			s.Loaded = &e.current.Loaded
+		}
+		statuses = append(statuses, s)
+	}
+	r.mu.RUnlock()
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].ID < statuses[j].ID })
+	return statuses
+}
+
+// Deploy parses pmml and makes it the current version of id.
+// If pmml does not parse, the current version is kept. It reports whether id was newly deployed.
+// Deployed documents have no directory, so they cannot read local files with a TableLocator.
+func (r *Registry) Deploy(id string, pmml []byte) (*Version, bool, error) {
+	if !idPattern.MatchString(id) {
+		return nil, false, fmt.Errorf("invalid model identifier %q", id)
+	}
+	m, err := pummel.Load(bytes.NewReader(pmml))
+	if err != nil {
+		return nil, false, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e := r.entry(id)
+	created := e.current == nil
+	v := e.swap(id, m, pmml, "")
+	return v, created, nil
+}
+
+// Undeploy removes the current version of id, reporting whether there was one.
+// A model loaded from a directory is loaded again once its file changes.
+func (r *Registry) Undeploy(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.entries[id]
+	if !ok || e.current == nil {
+		return false
+	}
+	e.current = nil
+	if e.path == "" {
+		delete(r.entries, id)
+	}
+	return true
+}
+
+func (r *Registry) entry(id string) *entry {
+	e, ok := r.entries[id]
+	if !ok {
+		e = &entry{}
+		r.entries[id] = e
+	}
+	return e
+}
+
+func (e *entry) swap(id string, m *pummel.Model, pmml []byte, path string) *Version {
+	e.versions++
+	v := &Version{
+		ID:      id,
+		Version: e.versions,
+		Model:   m,
+		PMML:    pmml,
+		MD5Sum:  md5sum(pmml),
+		Loaded:  time.Now(),
+		Path:    path,
+	}
+	e.current = v
+	e.err = nil
+	return v
+}
+
+func md5sum(b []byte) string {
+	sum := md5.Sum(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// Scan loads every new or changed .pmml file in dir, using the file name without extension as the model ID.
+// A file which fails to load does not replace the current version; the error is reported by Status.
+// Models whose files were removed are undeployed. The returned error joins the errors of every file.
+func (r *Registry) Scan(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+Extension))
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool, len(paths))
+	var failed []string
+	for _, path := range paths {
+		id := strings.TrimSuffix(filepath.Base(path), Extension)
+		seen[id] = true
+		if err := r.loadFile(id, path); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", path, err))
+		}
+	}
+
+	r.mu.Lock()
+	for id, e := range r.entries {
+		if e.path != "" && filepath.Dir(e.path) == filepath.Clean(dir) && !seen[id] {
+			delete(r.entries, id)
+		}
+	}
+	r.mu.Unlock()
+
+	if len(failed) > 0 {
+		return errors.New(strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+func (r *Registry) loadFile(id, path string) error {
+	if !idPattern.MatchString(id) {
+		return fmt.Errorf("invalid model identifier %q", id)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	r.mu.RLock()
+	e, ok := r.entries[id]
+	unchanged := ok && e.path == path && e.modTime.Equal(fi.ModTime()) && e.size == fi.Size()
+	r.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	pmml, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	sum := md5sum(pmml)
+	var m *pummel.Model
+	r.mu.RLock()
+	e, ok = r.entries[id]
+	sameContent := ok && e.path == path && e.fileSum == sum
+	r.mu.RUnlock()
+	if !sameContent {
+		m, err = pummel.LoadFrom(bytes.NewReader(pmml), filepath.Dir(path))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e = r.entry(id)
+	e.path, e.modTime, e.size, e.fileSum = path, fi.ModTime(), fi.Size(), sum
+	if sameContent {
+		return nil
+	}
+	if err != nil {
+		e.err = err
+		e.errTime = time.Now()
+		return err
+	}
+	e.swap(id, m, pmml, path)
+	return nil
+}
+
+// Watch scans dir every interval until ctx is done. Scan errors are passed to onError, which may be nil.
+func (r *Registry) Watch(ctx context.Context, dir string, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Scan(dir); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package registry_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stillmatic/pummel/pkg/registry"
+	"github.com/stillmatic/pummel/pkg/table"
+	"github.com/stretchr/testify/assert"
+)
+
+func readFile(t *testing.T, name string) []byte {
+	b, err := os.ReadFile(filepath.Join("../../testdata", name))
+	assert.NoError(t, err)
+	return b
+}
+
+// writeFile writes b to path and moves its modification time forward, since
+// successive writes may otherwise share a timestamp on coarse filesystems.
+func writeFile(t *testing.T, path string, b []byte, modTime time.Time) {
+	assert.NoError(t, os.WriteFile(path, b, 0o644))
+	assert.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+var lrInputs = map[string]interface{}{"x0": 0.1, "x1": 0.1, "x2": 100.0, "x3": 0.1}
+
+func TestDeploy(t *testing.T) {
+	reg := registry.New()
+	lr := readFile(t, "lr.pmml")
+	v1, created, err := reg.Deploy("lr", lr)
+	assert.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, 1, v1.Version)
+	assert.Equal(t, 32, len(v1.MD5Sum))
+	assert.False(t, v1.Loaded.IsZero())
+	assert.True(t, v1.Accessed().IsZero())
+
+	v2, created, err := reg.Deploy("lr", lr)
+	assert.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, 2, v2.Version)
+	assert.Equal(t, v1.MD5Sum, v2.MD5Sum)
+	assert.Equal(t, v2, reg.Get("lr"))
+
+	// a failed deploy keeps the current version
+	_, _, err = reg.Deploy("lr", []byte("<PMML>"))
+	assert.Error(t, err)
+	assert.Equal(t, v2, reg.Get("lr"))
+	_, _, err = reg.Deploy("-lr", lr)
+	assert.Error(t, err)
+	// deployed documents cannot read local files
+	_, _, err = reg.Deploy("lr", []byte(strings.Replace(string(lr), "<InlineTable>", `<TableLocator><Extension name="path" value="/etc/passwd"/></TableLocator><InlineTable>`, 1)))
+	assert.ErrorIs(t, err, table.ErrNoDirectory)
+	assert.Equal(t, v2, reg.Get("lr"))
+
+	assert.Equal(t, []*registry.Version{v2}, reg.List())
+	assert.True(t, reg.Undeploy("lr"))
+	assert.False(t, reg.Undeploy("lr"))
+	assert.Nil(t, reg.Get("lr"))
+	assert.Empty(t, reg.Status())
+}
+
+func TestScan(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lr.pmml")
+	now := time.Now()
+	writeFile(t, path, readFile(t, "lr.pmml"), now)
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("ignored"), 0o644))
+
+	reg := registry.New()
+	assert.NoError(t, reg.Scan(dir))
+	v1 := reg.Get("lr")
+	assert.NotNil(t, v1)
+	assert.Equal(t, 1, v1.Version)
+	assert.Equal(t, path, v1.Path)
+
+	// unchanged files are not reloaded
+	assert.NoError(t, reg.Scan(dir))
+	assert.Equal(t, v1, reg.Get("lr"))
+
+	// a broken file never replaces the working model, and its error is reported
+	writeFile(t, path, []byte("<PMML><Header>"), now.Add(time.Second))
+	assert.Error(t, reg.Scan(dir))
+	assert.Equal(t, v1, reg.Get("lr"))
+	status := reg.Status()
+	assert.Equal(t, 1, len(status))
+	assert.Equal(t, 1, status[0].Version)
+	assert.NotEmpty(t, status[0].Error)
+	assert.NotNil(t, status[0].ErrorTime)
+	// the error is only returned when the file changes
+	assert.NoError(t, reg.Scan(dir))
+
+	writeFile(t, path, readFile(t, "tree.pmml"), now.Add(2*time.Second))
+	assert.NoError(t, reg.Scan(dir))
+	v2 := reg.Get("lr")
+	assert.Equal(t, 2, v2.Version)
+	assert.Equal(t, "TreeModel", v2.Model.Element)
+	assert.NotEqual(t, v1.MD5Sum, v2.MD5Sum)
+	assert.Empty(t, reg.Status()[0].Error)
+
+	// undeployed models stay undeployed until their file changes
+	assert.True(t, reg.Undeploy("lr"))
+	assert.NoError(t, reg.Scan(dir))
+	assert.Nil(t, reg.Get("lr"))
+
+	assert.NoError(t, os.Remove(path))
+	assert.NoError(t, reg.Scan(dir))
+	assert.Empty(t, reg.Status())
+}
+
+func TestSwapInFlight(t *testing.T) {
+	reg := registry.New()
+	_, _, err := reg.Deploy("m", readFile(t, "lr.pmml"))
+	assert.NoError(t, err)
+	// a request holds on to the version it started with
+	old := reg.Get("m")
+	_, _, err = reg.Deploy("m", readFile(t, "tree.pmml"))
+	assert.NoError(t, err)
+	assert.Equal(t, "TreeModel", reg.Get("m").Model.Element)
+
+	inputs := make(map[string]interface{}, len(lrInputs))
+	for k, v := range lrInputs {
+		inputs[k] = v
+	}
+	res, err := old.Model.Evaluate(inputs)
+	assert.NoError(t, err)
+	assert.Equal(t, "CATEGORY_2", res["labels"])
+}
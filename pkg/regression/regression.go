@@ -7,6 +7,7 @@ import (
 	"github.com/stillmatic/pummel/pkg/fields"
 	"github.com/stillmatic/pummel/pkg/miningschema"
 	"github.com/stillmatic/pummel/pkg/transformations"
+	"github.com/stillmatic/pummel/pkg/verification"
 )
 
 type RegressionModel struct {
@@ -21,6 +22,14 @@ type RegressionModel struct {
 	IsScorable           bool                                 `xml:"isScorable,attr"`
 	Output               *fields.Outputs                      `xml:"Output>OutputField"`
 	LocalTransformations transformations.LocalTransformations `xml:"LocalTransformations"`
+	ModelVerification    *verification.ModelVerification      `xml:"ModelVerification"`
+
+	doc *transformations.Document
+}
+
+// NewRegressionModel returns an empty RegressionModel to be decoded into, whose expressions belong to doc.
+func NewRegressionModel(doc *transformations.Document) *RegressionModel {
+	return &RegressionModel{doc: doc}
 }
 
 func (rm *RegressionModel) GetOutputField() string {
@@ -28,6 +37,18 @@ func (rm *RegressionModel) GetOutputField() string {
 	return rm.MiningSchema.GetOutputField()
 }
 
+func (rm *RegressionModel) GetMiningSchema() *miningschema.MiningSchema {
+	return rm.MiningSchema
+}
+
+func (rm *RegressionModel) GetOutput() *fields.Outputs {
+	return rm.Output
+}
+
+func (rm *RegressionModel) GetModelVerification() *verification.ModelVerification {
+	return rm.ModelVerification
+}
+
 func (rm *RegressionModel) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	rm.XMLName = start.Name
 	rm.RegressionTables = make([]*RegressionTable, 0)
@@ -79,19 +100,26 @@ func (rm *RegressionModel) UnmarshalXML(d *xml.Decoder, start xml.StartElement)
 				}
 				rm.MiningSchema = &ms
 			case "Output":
-				var out fields.Outputs
-				err := d.DecodeElement(&out, &tt)
+				out := fields.NewOutputs(rm.doc)
+				err := d.DecodeElement(out, &tt)
 				if err != nil {
 					return err
 				}
-				rm.Output = &out
+				rm.Output = out
 			case "LocalTransformations":
-				var lt transformations.LocalTransformations
-				err := d.DecodeElement(&lt, &tt)
+				lt := rm.doc.NewLocalTransformations()
+				err := d.DecodeElement(lt, &tt)
 				if err != nil {
 					return err
 				}
-				rm.LocalTransformations = lt
+				rm.LocalTransformations = *lt
+			case "ModelVerification":
+				var mv verification.ModelVerification
+				err := d.DecodeElement(&mv, &tt)
+				if err != nil {
+					return err
+				}
+				rm.ModelVerification = &mv
 			default:
 				return fmt.Errorf("unknown element: %s", tt.Name.Local)
 			}
@@ -103,6 +131,11 @@ func (rm *RegressionModel) UnmarshalXML(d *xml.Decoder, start xml.StartElement)
 }
 
 func (rm *RegressionModel) Evaluate(inputs map[string]interface{}) (map[string]interface{}, error) {
+	out, err := rm.evaluate(inputs)
+	return rm.Output.Complete(inputs, out, err)
+}
+
+func (rm *RegressionModel) evaluate(inputs map[string]interface{}) (map[string]interface{}, error) {
 	if rm.LocalTransformations.DerivedFields != nil {
 		if len(rm.LocalTransformations.DerivedFields) > 0 {
 			for _, tr := range rm.LocalTransformations.DerivedFields {
@@ -136,37 +169,60 @@ func (rm *RegressionModel) EvaluateRegression(inputs map[string]interface{}) (ma
 	out := map[string]interface{}{
 		targetFieldName: val,
 	}
+	rm.addPredictedValue(out, val)
 	return out, nil
 }
 
 func (rm *RegressionModel) EvaluateClassification(inputs map[string]interface{}) (map[string]interface{}, error) {
 	// score each category and return the one with the highest score
 	scores := make(map[string]interface{}, len(rm.RegressionTables))
+	names := make([]string, 0, len(rm.RegressionTables))
 	var topCategory string
 	var topScore float64
-	for _, rt := range rm.RegressionTables {
+	for i, rt := range rm.RegressionTables {
 		val, err := rt.Evaluate(inputs)
 		if err != nil {
 			return nil, err
 		}
-		if val > topScore {
+		if i == 0 || val > topScore {
 			topScore = val
 			topCategory = rt.TargetCategory
 		}
 		// check if we have a output field for this value
+		name := rt.TargetCategory
 		if rm.Output != nil {
-			tc, err := rm.Output.GetFeature(rt.TargetCategory)
-			if err != nil {
-				scores[rt.TargetCategory] = val
+			if tc, err := rm.Output.GetFeature(rt.TargetCategory); err == nil {
+				name = tc.Name
 			}
-			scores[tc.Name] = val
-		} else {
-			scores[rt.TargetCategory] = val
 		}
+		scores[name] = val
+		names = append(names, name)
 	}
 	if rm.Normalizer != nil {
 		scores = rm.Normalizer.Normalize(scores)
 	}
+	// with logit, the last category is the reference and takes the remaining probability
+	if _, ok := rm.Normalizer.(LogitNormalizer); ok && len(names) > 1 {
+		var sum float64
+		for _, name := range names[:len(names)-1] {
+			sum += scores[name].(float64)
+		}
+		scores[names[len(names)-1]] = 1 - sum
+	}
 	scores[rm.GetOutputField()] = topCategory
+	rm.addPredictedValue(scores, topCategory)
 	return scores, nil
 }
+
+// addPredictedValue copies the prediction to the predictedValue output fields, so that the expressions of
+// later output fields can refer to it.
+func (rm *RegressionModel) addPredictedValue(out map[string]interface{}, prediction interface{}) {
+	if rm.Output == nil {
+		return
+	}
+	for _, of := range rm.Output.OutputFields {
+		if of.Feature == "predictedValue" {
+			out[of.Name] = prediction
+		}
+	}
+}
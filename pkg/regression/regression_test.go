@@ -1,9 +1,11 @@
 package regression_test
 
 import (
+	"bytes"
 	"encoding/xml"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"testing"
 
 	"github.com/stillmatic/pummel/pkg/model"
@@ -391,3 +393,29 @@ func BenchmarkLRFixture(b *testing.B) {
 		})
 	}
 }
+
+func TestClassificationCategories(t *testing.T) {
+	var prm model.PMMLRegressionModel
+	err := xml.Unmarshal(logisticRegressionXML, &prm)
+	assert.NoError(t, err)
+	rm := prm.RegressionModel
+	inputs := map[string]interface{}{
+		"x1": float64(2),
+		"x2": float64(3),
+	}
+	// the first table scores highest
+	out, err := rm.Evaluate(inputs)
+	assert.NoError(t, err)
+	assert.Equal(t, "no", out["y"])
+
+	// with logit, the last table is the reference category and takes the remaining probability
+	err = xml.Unmarshal(bytes.Replace(logisticRegressionXML, []byte(`normalizationMethod="softmax"`), []byte(`normalizationMethod="logit"`), 1), &prm)
+	assert.NoError(t, err)
+	rm = prm.RegressionModel
+	out, err = rm.Evaluate(inputs)
+	assert.NoError(t, err)
+	assert.Equal(t, "no", out["y"])
+	// 125.56601826 - 2 * 28.6617384 - 3 * 20.42027426
+	assert.InDelta(t, 1/(1+math.Exp(-6.98171868)), out["no"], 1e-9)
+	assert.InDelta(t, 1-out["no"].(float64), out["yes"], 1e-12)
+}
@@ -0,0 +1,627 @@
+// Package ruleset implements the RuleSetModel element, which predicts the score of the rules whose
+// predicates hold for a record, selected by the first RuleSelectionMethod of its RuleSet.
+package ruleset
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/stillmatic/pummel/pkg/fields"
+	"github.com/stillmatic/pummel/pkg/miningschema"
+	"github.com/stillmatic/pummel/pkg/node"
+	"github.com/stillmatic/pummel/pkg/predicates"
+	"github.com/stillmatic/pummel/pkg/transformations"
+	"github.com/stillmatic/pummel/pkg/verification"
+)
+
+type RuleSetModel struct {
+	XMLName              xml.Name                              `xml:"RuleSetModel"`
+	ModelName            string                                `xml:"modelName,attr"`
+	FunctionName         string                                `xml:"functionName,attr"`
+	AlgorithmName        string                                `xml:"algorithmName,attr"`
+	IsScorable           bool                                  `xml:"isScorable,attr"`
+	MiningSchema         *miningschema.MiningSchema            `xml:"MiningSchema"`
+	Output               *fields.Outputs                       `xml:"Output"`
+	LocalTransformations *transformations.LocalTransformations `xml:"LocalTransformations"`
+	RuleSet              *RuleSet                              `xml:"RuleSet"`
+	ModelVerification    *verification.ModelVerification       `xml:"ModelVerification"`
+
+	doc *transformations.Document
+}
+
+// NewRuleSetModel returns an empty RuleSetModel to be decoded into, whose expressions belong to doc.
+func NewRuleSetModel(doc *transformations.Document) *RuleSetModel {
+	return &RuleSetModel{doc: doc}
+}
+
+var Criteria = struct {
+	WeightedSum string
+	WeightedMax string
+	FirstHit    string
+}{
+	WeightedSum: "weightedSum",
+	WeightedMax: "weightedMax",
+	FirstHit:    "firstHit",
+}
+
+// RuleSet holds the rules, and the score predicted when none of them fires.
+type RuleSet struct {
+	XMLName     xml.Name `xml:"RuleSet"`
+	RecordCount float64  `xml:"recordCount,attr"`
+	NbCorrect   float64  `xml:"nbCorrect,attr"`
+	// DefaultScore is the prediction when no rule fires, which is missing if it is nil.
+	DefaultScore      *string `xml:"defaultScore,attr"`
+	DefaultConfidence float64 `xml:"defaultConfidence,attr"`
+	// Criteria lists the criterion of each RuleSelectionMethod. Only the first one is used.
+	Criteria           []string
+	ScoreDistributions []*node.ScoreDistribution `xml:"ScoreDistribution"`
+	Rules              []Rule
+}
+
+// Rule is a SimpleRule or a CompoundRule.
+type Rule interface {
+	// fire appends the simple rules which fire for the values to fired. outer holds the predicates of the
+	// compound rules the rule is nested in, which all hold.
+	fire(values map[string]interface{}, outer []predicates.Predicate, fired []*firedRule) ([]*firedRule, error)
+}
+
+// SimpleRule predicts its score when its predicate holds. Its ID defaults to its 1-based position among
+// the simple rules of the RuleSet, in document order.
+type SimpleRule struct {
+	XMLName            xml.Name `xml:"SimpleRule"`
+	ID                 string   `xml:"id,attr"`
+	Score              string   `xml:"score,attr"`
+	RecordCount        float64  `xml:"recordCount,attr"`
+	NbCorrect          float64  `xml:"nbCorrect,attr"`
+	Confidence         float64  `xml:"confidence,attr"`
+	Weight             float64  `xml:"weight,attr"`
+	Predicate          predicates.Predicate
+	ScoreDistributions []*node.ScoreDistribution `xml:"ScoreDistribution"`
+}
+
+// CompoundRule only lets its rules fire if its predicate holds.
+type CompoundRule struct {
+	XMLName   xml.Name `xml:"CompoundRule"`
+	Predicate predicates.Predicate
+	Rules     []Rule
+}
+
+// firedRule is a simple rule which fires, with the predicates which have to hold for it to fire.
+type firedRule struct {
+	rule       *SimpleRule
+	antecedent []predicates.Predicate
+}
+
+func (m *RuleSetModel) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	m.XMLName = start.Name
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "modelName":
+			m.ModelName = attr.Value
+		case "functionName":
+			m.FunctionName = attr.Value
+		case "algorithmName":
+			m.AlgorithmName = attr.Value
+		case "isScorable":
+			m.IsScorable = attr.Value == "true"
+		}
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "MiningSchema":
+				var ms miningschema.MiningSchema
+				if err := d.DecodeElement(&ms, &tt); err != nil {
+					return err
+				}
+				m.MiningSchema = &ms
+			case "Output":
+				out := fields.NewOutputs(m.doc)
+				if err := d.DecodeElement(out, &tt); err != nil {
+					return err
+				}
+				m.Output = out
+			case "LocalTransformations":
+				lt := m.doc.NewLocalTransformations()
+				if err := d.DecodeElement(lt, &tt); err != nil {
+					return err
+				}
+				m.LocalTransformations = lt
+			case "RuleSet":
+				var rs RuleSet
+				if err := d.DecodeElement(&rs, &tt); err != nil {
+					return err
+				}
+				m.RuleSet = &rs
+			case "ModelVerification":
+				var mv verification.ModelVerification
+				if err := d.DecodeElement(&mv, &tt); err != nil {
+					return err
+				}
+				m.ModelVerification = &mv
+			case "Extension", "ModelStats", "ModelExplanation":
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unknown element: %s", tt.Name.Local)
+			}
+		case xml.EndElement:
+			if m.RuleSet == nil {
+				return errors.New("RuleSetModel has no RuleSet")
+			}
+			return nil
+		}
+	}
+}
+
+func (rs *RuleSet) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	rs.XMLName = start.Name
+	for _, attr := range start.Attr {
+		var err error
+		switch attr.Name.Local {
+		case "recordCount":
+			rs.RecordCount, err = strconv.ParseFloat(attr.Value, 64)
+		case "nbCorrect":
+			rs.NbCorrect, err = strconv.ParseFloat(attr.Value, 64)
+		case "defaultScore":
+			score := attr.Value
+			rs.DefaultScore = &score
+		case "defaultConfidence":
+			rs.DefaultConfidence, err = strconv.ParseFloat(attr.Value, 64)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s of RuleSet", attr.Name.Local)
+		}
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "RuleSelectionMethod":
+				var rsm struct {
+					Criterion string `xml:"criterion,attr"`
+				}
+				if err := d.DecodeElement(&rsm, &tt); err != nil {
+					return err
+				}
+				switch rsm.Criterion {
+				case Criteria.WeightedSum, Criteria.WeightedMax, Criteria.FirstHit:
+				default:
+					return fmt.Errorf("unknown rule selection criterion: %q", rsm.Criterion)
+				}
+				rs.Criteria = append(rs.Criteria, rsm.Criterion)
+			case "ScoreDistribution":
+				var sd node.ScoreDistribution
+				if err := d.DecodeElement(&sd, &tt); err != nil {
+					return err
+				}
+				rs.ScoreDistributions = append(rs.ScoreDistributions, &sd)
+			case "SimpleRule", "CompoundRule":
+				rule, err := decodeRule(d, tt)
+				if err != nil {
+					return err
+				}
+				rs.Rules = append(rs.Rules, rule)
+			case "Extension":
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unexpected element in RuleSet: %s", tt.Name.Local)
+			}
+		case xml.EndElement:
+			if len(rs.Criteria) == 0 {
+				return errors.New("RuleSet has no RuleSelectionMethod")
+			}
+			var n int
+			rs.numberRules(rs.Rules, &n)
+			return nil
+		}
+	}
+}
+
+// numberRules gives the simple rules without an id their position among the simple rules.
+func (rs *RuleSet) numberRules(rules []Rule, n *int) {
+	for _, rule := range rules {
+		switch r := rule.(type) {
+		case *SimpleRule:
+			*n++
+			if r.ID == "" {
+				r.ID = strconv.Itoa(*n)
+			}
+		case *CompoundRule:
+			rs.numberRules(r.Rules, n)
+		}
+	}
+}
+
+func decodeRule(d *xml.Decoder, start xml.StartElement) (Rule, error) {
+	if start.Name.Local == "SimpleRule" {
+		var sr SimpleRule
+		if err := d.DecodeElement(&sr, &start); err != nil {
+			return nil, err
+		}
+		return &sr, nil
+	}
+	var cr CompoundRule
+	if err := d.DecodeElement(&cr, &start); err != nil {
+		return nil, err
+	}
+	return &cr, nil
+}
+
+func (sr *SimpleRule) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*sr = SimpleRule{XMLName: start.Name, Confidence: 1, Weight: 1}
+	var hasScore bool
+	for _, attr := range start.Attr {
+		var err error
+		switch attr.Name.Local {
+		case "id":
+			sr.ID = attr.Value
+		case "score":
+			sr.Score = attr.Value
+			hasScore = true
+		case "recordCount":
+			sr.RecordCount, err = strconv.ParseFloat(attr.Value, 64)
+		case "nbCorrect":
+			sr.NbCorrect, err = strconv.ParseFloat(attr.Value, 64)
+		case "confidence":
+			sr.Confidence, err = strconv.ParseFloat(attr.Value, 64)
+		case "weight":
+			sr.Weight, err = strconv.ParseFloat(attr.Value, 64)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s of SimpleRule %s", attr.Name.Local, sr.ID)
+		}
+	}
+	if !hasScore {
+		return fmt.Errorf("SimpleRule %s has no score", sr.ID)
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			var p predicates.Predicate
+			switch tt.Name.Local {
+			case "SimplePredicate":
+				p = &predicates.SimplePredicate{}
+			case "SimpleSetPredicate":
+				p = &predicates.SimpleSetPredicate{}
+			case "True":
+				p = &predicates.TruePredicate{}
+			case "False":
+				p = &predicates.FalsePredicate{}
+			case "CompoundPredicate":
+				p = &predicates.CompoundPredicate{}
+			case "ScoreDistribution":
+				var sd node.ScoreDistribution
+				if err := d.DecodeElement(&sd, &tt); err != nil {
+					return err
+				}
+				sr.ScoreDistributions = append(sr.ScoreDistributions, &sd)
+			case "Extension":
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unexpected element in SimpleRule: %s", tt.Name.Local)
+			}
+			if p != nil {
+				if err := d.DecodeElement(&p, &tt); err != nil {
+					return err
+				}
+				sr.Predicate = p
+			}
+		case xml.EndElement:
+			if sr.Predicate == nil {
+				return fmt.Errorf("SimpleRule %s has no predicate", sr.ID)
+			}
+			return nil
+		}
+	}
+}
+
+func (cr *CompoundRule) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	cr.XMLName = start.Name
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			var p predicates.Predicate
+			switch tt.Name.Local {
+			case "SimplePredicate":
+				p = &predicates.SimplePredicate{}
+			case "SimpleSetPredicate":
+				p = &predicates.SimpleSetPredicate{}
+			case "True":
+				p = &predicates.TruePredicate{}
+			case "False":
+				p = &predicates.FalsePredicate{}
+			case "CompoundPredicate":
+				p = &predicates.CompoundPredicate{}
+			case "SimpleRule", "CompoundRule":
+				rule, err := decodeRule(d, tt)
+				if err != nil {
+					return err
+				}
+				cr.Rules = append(cr.Rules, rule)
+			case "Extension":
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unexpected element in CompoundRule: %s", tt.Name.Local)
+			}
+			if p != nil {
+				if err := d.DecodeElement(&p, &tt); err != nil {
+					return err
+				}
+				cr.Predicate = p
+			}
+		case xml.EndElement:
+			if cr.Predicate == nil {
+				return errors.New("CompoundRule has no predicate")
+			}
+			if len(cr.Rules) == 0 {
+				return errors.New("CompoundRule has no rules")
+			}
+			return nil
+		}
+	}
+}
+
+// holds reports whether a predicate is true. A predicate which is unknown because of missing values
+// does not hold.
+func holds(p predicates.Predicate, values map[string]interface{}) (bool, error) {
+	res, ok, err := p.Evaluate(values)
+	if err != nil {
+		return false, err
+	}
+	return res && ok, nil
+}
+
+func (sr *SimpleRule) fire(values map[string]interface{}, outer []predicates.Predicate, fired []*firedRule) ([]*firedRule, error) {
+	ok, err := holds(sr.Predicate, values)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to evaluate SimpleRule %s", sr.ID)
+	}
+	if !ok {
+		return fired, nil
+	}
+	antecedent := make([]predicates.Predicate, len(outer), len(outer)+1)
+	copy(antecedent, outer)
+	return append(fired, &firedRule{rule: sr, antecedent: append(antecedent, sr.Predicate)}), nil
+}
+
+func (cr *CompoundRule) fire(values map[string]interface{}, outer []predicates.Predicate, fired []*firedRule) ([]*firedRule, error) {
+	ok, err := holds(cr.Predicate, values)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to evaluate CompoundRule")
+	}
+	if !ok {
+		return fired, nil
+	}
+	outer = append(outer[:len(outer):len(outer)], cr.Predicate)
+	for _, rule := range cr.Rules {
+		fired, err = rule.fire(values, outer, fired)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return fired, nil
+}
+
+// selection is the outcome of a rule selection method: the predicted score, its confidence, the fired rules
+// ranked by the method, and the rule whose score distribution gives the probabilities, if any.
+type selection struct {
+	score      string
+	confidence float64
+	ranked     []*firedRule
+	winner     *SimpleRule
+}
+
+// selectRules applies the first rule selection method to the fired rules. With firstHit, the first rule
+// to fire wins, and with weightedMax the one with the highest weight, ties going to the first. With
+// weightedSum, the score whose rules have the highest total weight wins, with that total divided by the
+// number of fired rules as its confidence. Rules are ranked in document order with firstHit, and by
+// decreasing weight otherwise.
+func (rs *RuleSet) selectRules(fired []*firedRule) *selection {
+	s := &selection{ranked: fired}
+	criterion := rs.Criteria[0]
+	if criterion != Criteria.FirstHit {
+		ranked := make([]*firedRule, len(fired))
+		copy(ranked, fired)
+		sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].rule.Weight > ranked[j].rule.Weight })
+		s.ranked = ranked
+	}
+	switch criterion {
+	case Criteria.FirstHit, Criteria.WeightedMax:
+		s.winner = s.ranked[0].rule
+		s.score, s.confidence = s.winner.Score, s.winner.Confidence
+	case Criteria.WeightedSum:
+		totals := make(map[string]float64)
+		var scores []string
+		for _, f := range fired {
+			if _, ok := totals[f.rule.Score]; !ok {
+				scores = append(scores, f.rule.Score)
+			}
+			totals[f.rule.Score] += f.rule.Weight
+		}
+		s.score = scores[0]
+		for _, score := range scores[1:] {
+			if totals[score] > totals[s.score] {
+				s.score = score
+			}
+		}
+		s.confidence = totals[s.score] / float64(len(fired))
+	}
+	return s
+}
+
+// probabilities normalizes a score distribution by its probabilities if it sets any, or else by its
+// record counts.
+func probabilities(sds []*node.ScoreDistribution) map[string]float64 {
+	res := make(map[string]float64, len(sds))
+	var total float64
+	for _, sd := range sds {
+		total += sd.Probability
+	}
+	if total > 0 {
+		for _, sd := range sds {
+			res[sd.Value] = sd.Probability
+		}
+		return res
+	}
+	for _, sd := range sds {
+		total += float64(sd.RecordCount)
+	}
+	for _, sd := range sds {
+		if total > 0 {
+			res[sd.Value] = float64(sd.RecordCount) / total
+		}
+	}
+	return res
+}
+
+func (m *RuleSetModel) Evaluate(values map[string]interface{}) (map[string]interface{}, error) {
+	out, err := m.evaluate(values)
+	return m.Output.Complete(values, out, err)
+}
+
+func (m *RuleSetModel) evaluate(values map[string]interface{}) (map[string]interface{}, error) {
+	if m.LocalTransformations != nil {
+		for _, tr := range m.LocalTransformations.DerivedFields {
+			val, err := tr.Transform(values)
+			if err != nil {
+				return nil, err
+			}
+			values[tr.RequiredField()] = val
+		}
+	}
+	var fired []*firedRule
+	for _, rule := range m.RuleSet.Rules {
+		var err error
+		fired, err = rule.fire(values, nil, fired)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var s *selection
+	var distribution []*node.ScoreDistribution
+	if len(fired) > 0 {
+		s = m.RuleSet.selectRules(fired)
+		if s.winner != nil {
+			distribution = s.winner.ScoreDistributions
+		}
+	} else {
+		if m.RuleSet.DefaultScore == nil {
+			return nil, nil
+		}
+		s = &selection{score: *m.RuleSet.DefaultScore, confidence: m.RuleSet.DefaultConfidence}
+		distribution = m.RuleSet.ScoreDistributions
+	}
+
+	out := map[string]interface{}{m.GetOutputField(): s.score}
+	probs := probabilities(distribution)
+	for _, sd := range distribution {
+		// check if we have a output field for this value
+		name := sd.Value
+		if m.Output != nil {
+			if of, err := m.Output.GetFeature(sd.Value); err == nil {
+				name = of.Name
+			}
+		}
+		out[name] = probs[sd.Value]
+	}
+	if m.Output == nil {
+		return out, nil
+	}
+	for _, of := range m.Output.OutputFields {
+		switch of.Feature {
+		case "predictedValue":
+			out[of.Name] = s.score
+		case "confidence":
+			out[of.Name] = s.confidence
+		case "probability":
+			if of.Value != "" {
+				out[of.Name] = probs[of.Value]
+			}
+		case "ruleValue":
+			rank := of.Rank
+			if rank == 0 {
+				rank = 1
+			}
+			out[of.Name] = nil
+			if rank <= len(s.ranked) {
+				out[of.Name] = m.ruleValue(s.ranked[rank-1], of.RuleFeature)
+			}
+		}
+	}
+	return out, nil
+}
+
+// ruleValue returns a property of a fired rule. Its antecedent joins the predicates of the compound rules
+// holding it and its own, and its support is its record count relative to that of the RuleSet.
+func (m *RuleSetModel) ruleValue(f *firedRule, feature string) interface{} {
+	antecedent := func() string {
+		parts := make([]string, len(f.antecedent))
+		for i, p := range f.antecedent {
+			parts[i] = p.String()
+		}
+		return strings.Join(parts, " and ")
+	}
+	switch feature {
+	case "ruleId":
+		return f.rule.ID
+	case "", "consequent":
+		return f.rule.Score
+	case "antecedent":
+		return antecedent()
+	case "rule":
+		return antecedent() + " -> " + f.rule.Score
+	case "confidence":
+		return f.rule.Confidence
+	case "support":
+		if m.RuleSet.RecordCount == 0 {
+			return nil
+		}
+		return f.rule.RecordCount / m.RuleSet.RecordCount
+	}
+	return nil
+}
+
+func (m *RuleSetModel) GetOutputField() string {
+	return m.MiningSchema.GetOutputField()
+}
+
+func (m *RuleSetModel) GetMiningSchema() *miningschema.MiningSchema {
+	return m.MiningSchema
+}
+
+func (m *RuleSetModel) GetOutput() *fields.Outputs {
+	return m.Output
+}
+
+func (m *RuleSetModel) GetModelVerification() *verification.ModelVerification {
+	return m.ModelVerification
+}
@@ -0,0 +1,151 @@
+package ruleset_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/stillmatic/pummel/pkg/ruleset"
+	"github.com/stretchr/testify/assert"
+)
+
+var rulesetXML = `<RuleSetModel functionName="classification">
+	<MiningSchema>
+		<MiningField name="temperature"/>
+		<MiningField name="humidity"/>
+		<MiningField name="outlook"/>
+		<MiningField name="play" usageType="target"/>
+	</MiningSchema>
+	<Output>
+		<OutputField name="prediction" feature="predictedValue"/>
+		<OutputField name="confidence" feature="confidence"/>
+		<OutputField name="p_yes" feature="probability" value="yes"/>
+		<OutputField name="rule" feature="ruleValue" ruleFeature="ruleId"/>
+		<OutputField name="consequent" feature="ruleValue"/>
+		<OutputField name="second" feature="ruleValue" ruleFeature="ruleId" rank="2"/>
+		<OutputField name="support" feature="ruleValue" ruleFeature="support"/>
+		<OutputField name="ruleConfidence" feature="ruleValue" ruleFeature="confidence"/>
+		<OutputField name="antecedent" feature="ruleValue" ruleFeature="antecedent"/>
+	</Output>
+	<RuleSet recordCount="100" defaultScore="no" defaultConfidence="0.4">
+		<RuleSelectionMethod criterion="firstHit"/>
+		<ScoreDistribution value="yes" recordCount="40"/>
+		<ScoreDistribution value="no" recordCount="60"/>
+		<SimpleRule id="hot" score="no" recordCount="20" confidence="0.8" weight="0.5">
+			<SimplePredicate field="temperature" operator="greaterThan" value="30"/>
+			<ScoreDistribution value="yes" recordCount="4"/>
+			<ScoreDistribution value="no" recordCount="16"/>
+		</SimpleRule>
+		<CompoundRule>
+			<SimplePredicate field="outlook" operator="equal" value="sunny"/>
+			<SimpleRule score="yes" recordCount="30" confidence="0.7" weight="0.9">
+				<SimplePredicate field="humidity" operator="lessThan" value="80"/>
+			</SimpleRule>
+			<SimpleRule id="humid" score="no" recordCount="10" confidence="0.6" weight="0.3">
+				<True/>
+			</SimpleRule>
+		</CompoundRule>
+		<SimpleRule id="mild" score="yes" recordCount="25" confidence="0.9" weight="0.4">
+			<SimplePredicate field="temperature" operator="lessOrEqual" value="30"/>
+		</SimpleRule>
+	</RuleSet>
+</RuleSetModel>`
+
+func TestRuleSetFirstHit(t *testing.T) {
+	var m ruleset.RuleSetModel
+	err := xml.Unmarshal([]byte(rulesetXML), &m)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"firstHit"}, m.RuleSet.Criteria)
+	assert.Equal(t, 3, len(m.RuleSet.Rules))
+
+	out, err := m.Evaluate(map[string]interface{}{"temperature": 35, "humidity": 50, "outlook": "sunny"})
+	assert.NoError(t, err)
+	assert.Equal(t, "no", out["play"])
+	assert.Equal(t, "no", out["prediction"])
+	assert.Equal(t, 0.8, out["confidence"])
+	assert.Equal(t, 0.2, out["p_yes"])
+	assert.Equal(t, "hot", out["rule"])
+	assert.Equal(t, "no", out["consequent"])
+	// the rule nested in the CompoundRule has no id, so it is named by its position
+	assert.Equal(t, "2", out["second"])
+	assert.Equal(t, 0.2, out["support"])
+	assert.Equal(t, 0.8, out["ruleConfidence"])
+	assert.Equal(t, "SimplePredicate(temperature greaterThan 30)", out["antecedent"])
+
+	out, err = m.Evaluate(map[string]interface{}{"temperature": 20, "humidity": 50, "outlook": "sunny"})
+	assert.NoError(t, err)
+	assert.Equal(t, "yes", out["play"])
+	assert.Equal(t, "2", out["rule"])
+	assert.Equal(t, "humid", out["second"])
+	assert.Equal(t, "SimplePredicate(outlook equal sunny) and SimplePredicate(humidity lessThan 80)", out["antecedent"])
+	// the rule has no score distribution
+	assert.Equal(t, 0.0, out["p_yes"])
+
+	// no rule fires when the temperature is missing and it is not sunny
+	out, err = m.Evaluate(map[string]interface{}{"outlook": "rainy"})
+	assert.NoError(t, err)
+	assert.Equal(t, "no", out["play"])
+	assert.Equal(t, 0.4, out["confidence"])
+	assert.Equal(t, 0.4, out["p_yes"])
+	assert.Nil(t, out["rule"])
+	assert.Nil(t, out["antecedent"])
+}
+
+func TestRuleSetWeighted(t *testing.T) {
+	tcs := []struct {
+		criterion  string
+		inputs     map[string]interface{}
+		prediction string
+		confidence float64
+		rules      []interface{}
+	}{
+		// 2 (0.9) beats mild (0.4) and humid (0.3)
+		{"weightedMax", map[string]interface{}{"temperature": 20, "humidity": 50, "outlook": "sunny"}, "yes", 0.7, []interface{}{"2", "mild"}},
+		// hot (0.5) beats humid (0.3)
+		{"weightedMax", map[string]interface{}{"temperature": 35, "humidity": 90, "outlook": "sunny"}, "no", 0.8, []interface{}{"hot", "humid"}},
+		// yes has 0.9 + 0.4 against 0.3 for no, out of three fired rules
+		{"weightedSum", map[string]interface{}{"temperature": 20, "humidity": 50, "outlook": "sunny"}, "yes", 1.3 / 3, []interface{}{"2", "mild"}},
+		// no has 0.5 + 0.3 against 0.9 for yes
+		{"weightedSum", map[string]interface{}{"temperature": 35, "humidity": 50, "outlook": "sunny"}, "yes", 0.9 / 3, []interface{}{"2", "hot"}},
+		{"weightedSum", map[string]interface{}{"temperature": 35, "humidity": 90, "outlook": "sunny"}, "no", 0.8 / 2, []interface{}{"hot", "humid"}},
+	}
+	for _, tc := range tcs {
+		var m ruleset.RuleSetModel
+		err := xml.Unmarshal([]byte(strings.Replace(rulesetXML, "firstHit", tc.criterion, 1)), &m)
+		assert.NoError(t, err)
+		out, err := m.Evaluate(tc.inputs)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.prediction, out["prediction"], tc.criterion)
+		assert.InDelta(t, tc.confidence, out["confidence"], 1e-9, tc.criterion)
+		assert.Equal(t, tc.rules[0], out["rule"], tc.criterion)
+		assert.Equal(t, tc.rules[1], out["second"], tc.criterion)
+	}
+}
+
+func TestRuleSetNoDefaultScore(t *testing.T) {
+	var m ruleset.RuleSetModel
+	err := xml.Unmarshal([]byte(strings.Replace(rulesetXML, ` defaultScore="no"`, ``, 1)), &m)
+	assert.NoError(t, err)
+	out, err := m.Evaluate(map[string]interface{}{"outlook": "rainy"})
+	assert.NoError(t, err)
+	assert.Nil(t, out)
+}
+
+func TestRuleSetErrors(t *testing.T) {
+	tcs := []struct {
+		old, new string
+		expected string
+	}{
+		{`criterion="firstHit"`, `criterion="lastHit"`, `unknown rule selection criterion: "lastHit"`},
+		{`<RuleSelectionMethod criterion="firstHit"/>`, ``, "RuleSet has no RuleSelectionMethod"},
+		{`<SimpleRule id="hot" score="no"`, `<SimpleRule id="hot"`, "SimpleRule hot has no score"},
+		{`<True/>`, ``, "SimpleRule humid has no predicate"},
+		{`<SimplePredicate field="outlook" operator="equal" value="sunny"/>`, ``, "CompoundRule has no predicate"},
+		{`weight="0.5"`, `weight="heavy"`, `invalid weight of SimpleRule hot: strconv.ParseFloat: parsing "heavy": invalid syntax`},
+	}
+	for _, tc := range tcs {
+		var m ruleset.RuleSetModel
+		err := xml.Unmarshal([]byte(strings.Replace(rulesetXML, tc.old, tc.new, 1)), &m)
+		assert.EqualError(t, err, tc.expected)
+	}
+}
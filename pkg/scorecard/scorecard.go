@@ -0,0 +1,521 @@
+// Package scorecard implements the Scorecard element. The score is the initial score plus the partial score
+// of each Characteristic, which is that of the first of its Attributes whose predicate is true. Each partial
+// score is also compared with a baseline score, and the differences rank the reason codes explaining the score.
+package scorecard
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/stillmatic/pummel/pkg/fields"
+	"github.com/stillmatic/pummel/pkg/miningschema"
+	"github.com/stillmatic/pummel/pkg/predicates"
+	"github.com/stillmatic/pummel/pkg/transformations"
+	"github.com/stillmatic/pummel/pkg/verification"
+)
+
+type Scorecard struct {
+	XMLName       xml.Name `xml:"Scorecard"`
+	ModelName     string   `xml:"modelName,attr"`
+	FunctionName  string   `xml:"functionName,attr"`
+	AlgorithmName string   `xml:"algorithmName,attr"`
+	InitialScore  float64  `xml:"initialScore,attr"`
+	// UseReasonCodes defaults to true, in which case every Attribute needs a reason code and every
+	// Characteristic a baseline score.
+	UseReasonCodes      bool     `xml:"useReasonCodes,attr"`
+	ReasonCodeAlgorithm string   `xml:"reasonCodeAlgorithm,attr"`
+	BaselineScore       *float64 `xml:"baselineScore,attr"`
+	BaselineMethod      string   `xml:"baselineMethod,attr"`
+	IsScorable          bool     `xml:"isScorable,attr"`
+
+	MiningSchema         *miningschema.MiningSchema            `xml:"MiningSchema"`
+	Output               *fields.Outputs                       `xml:"Output"`
+	LocalTransformations *transformations.LocalTransformations `xml:"LocalTransformations"`
+	Characteristics      []*Characteristic                     `xml:"Characteristics>Characteristic"`
+	ModelVerification    *verification.ModelVerification       `xml:"ModelVerification"`
+
+	doc *transformations.Document
+}
+
+// NewScorecard returns an empty Scorecard to be decoded into, whose expressions belong to doc.
+func NewScorecard(doc *transformations.Document) *Scorecard {
+	return &Scorecard{doc: doc}
+}
+
+var ReasonCodeAlgorithms = struct {
+	PointsAbove string
+	PointsBelow string
+}{
+	PointsAbove: "pointsAbove",
+	PointsBelow: "pointsBelow",
+}
+
+// BaselineMethods describe how the baseline scores were derived. Only max and min can be derived by
+// pummel, as the highest or lowest partial score of a Characteristic; the others need explicit scores.
+var BaselineMethods = struct {
+	Max     string
+	Min     string
+	Mean    string
+	Neutral string
+	Other   string
+}{
+	Max:     "max",
+	Min:     "min",
+	Mean:    "mean",
+	Neutral: "neutral",
+	Other:   "other",
+}
+
+type Characteristic struct {
+	XMLName       xml.Name `xml:"Characteristic"`
+	Name          string   `xml:"name,attr"`
+	ReasonCode    string   `xml:"reasonCode,attr"`
+	BaselineScore *float64 `xml:"baselineScore,attr"`
+	Attributes    []*Attribute
+
+	doc *transformations.Document
+}
+
+// Attribute is a bin of a Characteristic. Its partial score is either PartialScore or the value of
+// its ComplexPartialScore expression.
+type Attribute struct {
+	XMLName             xml.Name `xml:"Attribute"`
+	ReasonCode          string   `xml:"reasonCode,attr"`
+	PartialScore        *float64 `xml:"partialScore,attr"`
+	Predicate           predicates.Predicate
+	ComplexPartialScore transformations.Expression
+
+	doc *transformations.Document
+}
+
+func (s *Scorecard) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	s.XMLName = start.Name
+	s.UseReasonCodes = true
+	s.ReasonCodeAlgorithm = ReasonCodeAlgorithms.PointsBelow
+	s.BaselineMethod = BaselineMethods.Other
+	for _, attr := range start.Attr {
+		var err error
+		switch attr.Name.Local {
+		case "modelName":
+			s.ModelName = attr.Value
+		case "functionName":
+			s.FunctionName = attr.Value
+		case "algorithmName":
+			s.AlgorithmName = attr.Value
+		case "initialScore":
+			s.InitialScore, err = strconv.ParseFloat(attr.Value, 64)
+		case "useReasonCodes":
+			s.UseReasonCodes = attr.Value != "false"
+		case "reasonCodeAlgorithm":
+			s.ReasonCodeAlgorithm = attr.Value
+		case "baselineScore":
+			var baseline float64
+			baseline, err = strconv.ParseFloat(attr.Value, 64)
+			s.BaselineScore = &baseline
+		case "baselineMethod":
+			s.BaselineMethod = attr.Value
+		case "isScorable":
+			s.IsScorable = attr.Value == "true"
+		}
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s of Scorecard", attr.Name.Local)
+		}
+	}
+	switch s.ReasonCodeAlgorithm {
+	case ReasonCodeAlgorithms.PointsAbove, ReasonCodeAlgorithms.PointsBelow:
+	default:
+		return fmt.Errorf("unknown reason code algorithm: %s", s.ReasonCodeAlgorithm)
+	}
+	switch s.BaselineMethod {
+	case BaselineMethods.Max, BaselineMethods.Min, BaselineMethods.Mean, BaselineMethods.Neutral, BaselineMethods.Other:
+	default:
+		return fmt.Errorf("unknown baseline method: %s", s.BaselineMethod)
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "MiningSchema":
+				var ms miningschema.MiningSchema
+				if err := d.DecodeElement(&ms, &tt); err != nil {
+					return err
+				}
+				s.MiningSchema = &ms
+			case "Output":
+				out := fields.NewOutputs(s.doc)
+				if err := d.DecodeElement(out, &tt); err != nil {
+					return err
+				}
+				s.Output = out
+			case "LocalTransformations":
+				lt := s.doc.NewLocalTransformations()
+				if err := d.DecodeElement(lt, &tt); err != nil {
+					return err
+				}
+				s.LocalTransformations = lt
+			case "Characteristics":
+				if err := s.decodeCharacteristics(d); err != nil {
+					return err
+				}
+			case "ModelVerification":
+				var mv verification.ModelVerification
+				if err := d.DecodeElement(&mv, &tt); err != nil {
+					return err
+				}
+				s.ModelVerification = &mv
+			case "Extension", "ModelStats", "ModelExplanation":
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unknown element: %s", tt.Name.Local)
+			}
+		case xml.EndElement:
+			return s.prepare()
+		}
+	}
+}
+
+// decodeCharacteristics decodes the Characteristic elements of Characteristics.
+func (s *Scorecard) decodeCharacteristics(d *xml.Decoder) error {
+	s.Characteristics = nil
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			if tt.Name.Local != "Characteristic" {
+				if err := d.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			c := &Characteristic{doc: s.doc}
+			if err := d.DecodeElement(c, &tt); err != nil {
+				return err
+			}
+			s.Characteristics = append(s.Characteristics, c)
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// prepare checks that reason codes can be computed, deriving the baseline scores of the Characteristics
+// which do not have one from the baseline method.
+func (s *Scorecard) prepare() error {
+	if len(s.Characteristics) == 0 {
+		return errors.New("Scorecard has no Characteristic")
+	}
+	if !s.UseReasonCodes {
+		return nil
+	}
+	for _, c := range s.Characteristics {
+		for i, a := range c.Attributes {
+			if a.ReasonCode == "" && c.ReasonCode == "" {
+				return fmt.Errorf("Attribute %d of Characteristic %s has no reason code", i+1, c.Name)
+			}
+		}
+		if c.BaselineScore != nil {
+			continue
+		}
+		if s.BaselineScore != nil {
+			c.BaselineScore = s.BaselineScore
+			continue
+		}
+		if s.BaselineMethod != BaselineMethods.Max && s.BaselineMethod != BaselineMethods.Min {
+			return fmt.Errorf("Characteristic %s has no baseline score", c.Name)
+		}
+		baseline := math.Inf(1)
+		if s.BaselineMethod == BaselineMethods.Max {
+			baseline = math.Inf(-1)
+		}
+		for _, a := range c.Attributes {
+			if a.PartialScore == nil {
+				return fmt.Errorf("baseline score of Characteristic %s cannot be derived from a ComplexPartialScore", c.Name)
+			}
+			if s.BaselineMethod == BaselineMethods.Max {
+				baseline = math.Max(baseline, *a.PartialScore)
+			} else {
+				baseline = math.Min(baseline, *a.PartialScore)
+			}
+		}
+		c.BaselineScore = &baseline
+	}
+	return nil
+}
+
+func (c *Characteristic) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	c.XMLName = start.Name
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "name":
+			c.Name = attr.Value
+		case "reasonCode":
+			c.ReasonCode = attr.Value
+		case "baselineScore":
+			baseline, err := strconv.ParseFloat(attr.Value, 64)
+			if err != nil {
+				return errors.Wrapf(err, "invalid baselineScore of Characteristic %s", c.Name)
+			}
+			c.BaselineScore = &baseline
+		}
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "Attribute":
+				a := &Attribute{doc: c.doc}
+				if err := d.DecodeElement(a, &tt); err != nil {
+					return errors.Wrapf(err, "invalid Attribute %d of Characteristic %s", len(c.Attributes)+1, c.Name)
+				}
+				c.Attributes = append(c.Attributes, a)
+			case "Extension":
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unexpected element in Characteristic: %s", tt.Name.Local)
+			}
+		case xml.EndElement:
+			if len(c.Attributes) == 0 {
+				return fmt.Errorf("Characteristic %s has no Attribute", c.Name)
+			}
+			return nil
+		}
+	}
+}
+
+func (a *Attribute) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	a.XMLName = start.Name
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "reasonCode":
+			a.ReasonCode = attr.Value
+		case "partialScore":
+			score, err := strconv.ParseFloat(attr.Value, 64)
+			if err != nil {
+				return errors.Wrap(err, "invalid partialScore")
+			}
+			a.PartialScore = &score
+		}
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			var p predicates.Predicate
+			switch tt.Name.Local {
+			case "SimplePredicate":
+				p = &predicates.SimplePredicate{}
+			case "SimpleSetPredicate":
+				p = &predicates.SimpleSetPredicate{}
+			case "True":
+				p = &predicates.TruePredicate{}
+			case "False":
+				p = &predicates.FalsePredicate{}
+			case "CompoundPredicate":
+				p = &predicates.CompoundPredicate{}
+			case "ComplexPartialScore":
+				a.ComplexPartialScore, err = decodeComplexPartialScore(d, a.doc)
+				if err != nil {
+					return err
+				}
+			case "Extension":
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unexpected element in Attribute: %s", tt.Name.Local)
+			}
+			if p != nil {
+				if err := d.DecodeElement(&p, &tt); err != nil {
+					return err
+				}
+				a.Predicate = p
+			}
+		case xml.EndElement:
+			if a.Predicate == nil {
+				return errors.New("Attribute has no predicate")
+			}
+			if a.PartialScore == nil && a.ComplexPartialScore == nil {
+				return errors.New("Attribute has neither a partialScore nor a ComplexPartialScore")
+			}
+			return nil
+		}
+	}
+}
+
+func decodeComplexPartialScore(d *xml.Decoder, doc *transformations.Document) (transformations.Expression, error) {
+	var expr transformations.Expression
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			if tt.Name.Local == "Extension" {
+				if err := d.Skip(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			expr = doc.NewExpression(tt.Name.Local)
+			if expr == nil {
+				return nil, fmt.Errorf("unexpected element in ComplexPartialScore: %s", tt.Name.Local)
+			}
+			if err := d.DecodeElement(&expr, &tt); err != nil {
+				return nil, err
+			}
+		case xml.EndElement:
+			if expr == nil {
+				return nil, errors.New("ComplexPartialScore has no expression")
+			}
+			return expr, nil
+		}
+	}
+}
+
+// partialScore finds the first Attribute of the Characteristic whose predicate is true, and computes its
+// partial score. A predicate whose value is unknown does not match.
+func (c *Characteristic) partialScore(values map[string]interface{}) (*Attribute, float64, error) {
+	for _, a := range c.Attributes {
+		matches, ok, err := a.Predicate.Evaluate(values)
+		if err != nil {
+			return nil, 0, errors.Wrapf(err, "failed to evaluate Characteristic %s", c.Name)
+		}
+		if !matches || !ok {
+			continue
+		}
+		if a.ComplexPartialScore == nil {
+			return a, *a.PartialScore, nil
+		}
+		value, err := a.ComplexPartialScore.Transform(values)
+		if err != nil {
+			return nil, 0, errors.Wrapf(err, "failed to compute the partial score of Characteristic %s", c.Name)
+		}
+		if value == nil {
+			return nil, 0, fmt.Errorf("partial score of Characteristic %s is missing", c.Name)
+		}
+		score, err := transformations.InterfaceToFloat64(value)
+		if err != nil {
+			return nil, 0, errors.Wrapf(err, "invalid partial score of Characteristic %s", c.Name)
+		}
+		return a, score, nil
+	}
+	return nil, 0, fmt.Errorf("no Attribute of Characteristic %s matches", c.Name)
+}
+
+func (s *Scorecard) Evaluate(values map[string]interface{}) (map[string]interface{}, error) {
+	out, err := s.evaluate(values)
+	return s.Output.Complete(values, out, err)
+}
+
+func (s *Scorecard) evaluate(values map[string]interface{}) (map[string]interface{}, error) {
+	if s.LocalTransformations != nil {
+		for _, tr := range s.LocalTransformations.DerivedFields {
+			val, err := tr.Transform(values)
+			if err != nil {
+				return nil, err
+			}
+			values[tr.RequiredField()] = val
+		}
+	}
+	score := s.InitialScore
+	// the points of each reason code, summed over the Characteristics sharing it, in order of appearance
+	var reasonCodes []string
+	points := make(map[string]float64)
+	for _, c := range s.Characteristics {
+		a, partial, err := c.partialScore(values)
+		if err != nil {
+			return nil, err
+		}
+		score += partial
+		if !s.UseReasonCodes {
+			continue
+		}
+		reasonCode := a.ReasonCode
+		if reasonCode == "" {
+			reasonCode = c.ReasonCode
+		}
+		difference := *c.BaselineScore - partial
+		if s.ReasonCodeAlgorithm == ReasonCodeAlgorithms.PointsAbove {
+			difference = partial - *c.BaselineScore
+		}
+		if _, ok := points[reasonCode]; !ok {
+			reasonCodes = append(reasonCodes, reasonCode)
+		}
+		points[reasonCode] += difference
+	}
+
+	out := map[string]interface{}{s.GetOutputField(): score}
+	if s.Output == nil {
+		return out, nil
+	}
+	ranked := rankReasonCodes(reasonCodes, points)
+	for _, of := range s.Output.OutputFields {
+		switch of.Feature {
+		case "predictedValue":
+			out[of.Name] = score
+		case "reasonCode":
+			rank := of.Rank
+			if rank == 0 {
+				rank = 1
+			}
+			if rank <= len(ranked) {
+				out[of.Name] = ranked[rank-1]
+			} else {
+				out[of.Name] = nil
+			}
+		}
+	}
+	return out, nil
+}
+
+// rankReasonCodes orders the reason codes by their points, from the most to the fewest, leaving out those
+// with negative points. Ties keep the order in which the reason codes first appear.
+func rankReasonCodes(reasonCodes []string, points map[string]float64) []string {
+	ranked := make([]string, 0, len(reasonCodes))
+	for _, rc := range reasonCodes {
+		if points[rc] >= 0 {
+			ranked = append(ranked, rc)
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return points[ranked[i]] > points[ranked[j]] })
+	return ranked
+}
+
+func (s *Scorecard) GetOutputField() string {
+	return s.MiningSchema.GetOutputField()
+}
+
+func (s *Scorecard) GetMiningSchema() *miningschema.MiningSchema {
+	return s.MiningSchema
+}
+
+func (s *Scorecard) GetOutput() *fields.Outputs {
+	return s.Output
+}
+
+func (s *Scorecard) GetModelVerification() *verification.ModelVerification {
+	return s.ModelVerification
+}
@@ -0,0 +1,146 @@
+package scorecard_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/stillmatic/pummel/pkg/scorecard"
+	"github.com/stretchr/testify/assert"
+)
+
+var scorecardXML = `<Scorecard functionName="regression" initialScore="100" reasonCodeAlgorithm="pointsAbove" baselineScore="10">
+	<MiningSchema>
+		<MiningField name="a"/>
+		<MiningField name="b"/>
+		<MiningField name="c"/>
+		<MiningField name="score" usageType="target"/>
+	</MiningSchema>
+	<Output>
+		<OutputField name="rc1" feature="reasonCode" rank="1"/>
+		<OutputField name="rc2" feature="reasonCode" rank="2"/>
+		<OutputField name="rc3" feature="reasonCode" rank="3"/>
+		<OutputField name="first" feature="reasonCode"/>
+	</Output>
+	<Characteristics>
+		<Characteristic name="a" reasonCode="A">
+			<Attribute partialScore="5">
+				<SimplePredicate field="a" operator="lessThan" value="0"/>
+			</Attribute>
+			<Attribute partialScore="20">
+				<SimplePredicate field="a" operator="greaterOrEqual" value="0"/>
+			</Attribute>
+		</Characteristic>
+		<Characteristic name="b" reasonCode="B" baselineScore="0">
+			<Attribute partialScore="0" reasonCode="MISSING">
+				<SimplePredicate field="b" operator="isMissing"/>
+			</Attribute>
+			<Attribute>
+				<True/>
+				<ComplexPartialScore>
+					<FieldRef field="b"/>
+				</ComplexPartialScore>
+			</Attribute>
+		</Characteristic>
+		<Characteristic name="c">
+			<Attribute partialScore="30" reasonCode="A">
+				<SimpleSetPredicate field="c" booleanOperator="isIn">
+					<Array type="string">x y</Array>
+				</SimpleSetPredicate>
+			</Attribute>
+			<Attribute partialScore="10" reasonCode="C">
+				<True/>
+			</Attribute>
+		</Characteristic>
+	</Characteristics>
+</Scorecard>`
+
+func TestScorecard(t *testing.T) {
+	var s scorecard.Scorecard
+	err := xml.Unmarshal([]byte(scorecardXML), &s)
+	assert.NoError(t, err)
+	assert.True(t, s.UseReasonCodes)
+	assert.Equal(t, "other", s.BaselineMethod)
+	assert.Equal(t, 3, len(s.Characteristics))
+
+	tcs := []struct {
+		inputs   map[string]interface{}
+		score    float64
+		expected []interface{}
+	}{
+		// A has 10 + 20 points above its baselines, B 7 and C 0
+		{map[string]interface{}{"a": 1, "b": 7, "c": "x"}, 157, []interface{}{"A", "B", nil}},
+		// A has -5 points, which are left out, and C ties with MISSING, which comes first
+		{map[string]interface{}{"a": -1, "c": "z"}, 115, []interface{}{"MISSING", "C", nil}},
+		{map[string]interface{}{"a": -1, "b": 40.5, "c": "y"}, 175.5, []interface{}{"B", "A", nil}},
+	}
+	for _, tc := range tcs {
+		out, err := s.Evaluate(tc.inputs)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.score, out["score"])
+		assert.Equal(t, tc.expected[0], out["rc1"])
+		assert.Equal(t, tc.expected[1], out["rc2"])
+		assert.Equal(t, tc.expected[2], out["rc3"])
+		assert.Equal(t, tc.expected[0], out["first"])
+	}
+
+	// no attribute of a matches a missing value
+	_, err = s.Evaluate(map[string]interface{}{"b": 1, "c": "x"})
+	assert.EqualError(t, err, "no Attribute of Characteristic a matches")
+}
+
+func TestScorecardPointsBelow(t *testing.T) {
+	var s scorecard.Scorecard
+	err := xml.Unmarshal([]byte(strings.Replace(scorecardXML, `reasonCodeAlgorithm="pointsAbove"`, `reasonCodeAlgorithm="pointsBelow"`, 1)), &s)
+	assert.NoError(t, err)
+	out, err := s.Evaluate(map[string]interface{}{"a": -1, "b": -3, "c": "z"})
+	assert.NoError(t, err)
+	assert.Equal(t, 112.0, out["score"])
+	// A has 5 points below its baseline, B 3 and C 0
+	assert.Equal(t, "A", out["rc1"])
+	assert.Equal(t, "B", out["rc2"])
+	assert.Equal(t, "C", out["rc3"])
+}
+
+func TestScorecardBaselineMethod(t *testing.T) {
+	noBaseline := strings.Replace(scorecardXML, ` baselineScore="10"`, ``, 1)
+	var s scorecard.Scorecard
+	err := xml.Unmarshal([]byte(noBaseline), &s)
+	assert.EqualError(t, err, "Characteristic a has no baseline score")
+
+	// with max, the baseline is the highest partial score, so no points are above it
+	err = xml.Unmarshal([]byte(strings.Replace(noBaseline, `initialScore="100"`, `initialScore="100" baselineMethod="max"`, 1)), &s)
+	assert.NoError(t, err)
+	assert.Equal(t, 20.0, *s.Characteristics[0].BaselineScore)
+	assert.Equal(t, 30.0, *s.Characteristics[2].BaselineScore)
+	out, err := s.Evaluate(map[string]interface{}{"a": 1, "c": "x"})
+	assert.NoError(t, err)
+	assert.Equal(t, "A", out["rc1"])
+	assert.Equal(t, "MISSING", out["rc2"])
+	assert.Nil(t, out["rc3"])
+
+	// without reason codes, no baseline is needed
+	err = xml.Unmarshal([]byte(strings.Replace(noBaseline, `initialScore="100"`, `initialScore="100" useReasonCodes="false"`, 1)), &s)
+	assert.NoError(t, err)
+	out, err = s.Evaluate(map[string]interface{}{"a": 1, "b": 2, "c": "x"})
+	assert.NoError(t, err)
+	assert.Equal(t, 152.0, out["score"])
+	assert.Nil(t, out["rc1"])
+}
+
+func TestScorecardErrors(t *testing.T) {
+	tcs := []struct {
+		old, new string
+		expected string
+	}{
+		{`reasonCodeAlgorithm="pointsAbove"`, `reasonCodeAlgorithm="pointsAround"`, "unknown reason code algorithm: pointsAround"},
+		{`<Characteristic name="a" reasonCode="A">`, `<Characteristic name="a">`, "Attribute 1 of Characteristic a has no reason code"},
+		{`<Attribute partialScore="5">`, `<Attribute>`, "invalid Attribute 1 of Characteristic a: Attribute has neither a partialScore nor a ComplexPartialScore"},
+		{`<FieldRef field="b"/>`, `<Value value="b"/>`, "invalid Attribute 2 of Characteristic b: unexpected element in ComplexPartialScore: Value"},
+	}
+	for _, tc := range tcs {
+		var s scorecard.Scorecard
+		err := xml.Unmarshal([]byte(strings.Replace(scorecardXML, tc.old, tc.new, 1)), &s)
+		assert.EqualError(t, err, tc.expected)
+	}
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// csvResultWriter writes the id column, if the input has one, followed by the result columns.
+// It keeps the first row error instead of writing it, as Openscoring fails the whole table.
+type csvResultWriter struct {
+	w             *csv.Writer
+	idColumn      string
+	outputColumns []string
+	wroteHeader   bool
+	err           error
+}
+
+func newCSVResultWriter(w io.Writer, comma rune, inputColumns, outputColumns []string) *csvResultWriter {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	rw := &csvResultWriter{w: cw, outputColumns: outputColumns}
+	for _, col := range inputColumns {
+		if strings.EqualFold(col, "id") {
+			rw.idColumn = col
+			break
+		}
+	}
+	return rw
+}
+
+func (rw *csvResultWriter) Write(inputs map[string]interface{}, outputs map[string]interface{}, rowErr error) error {
+	if rw.err != nil {
+		return nil
+	}
+	if rowErr != nil {
+		if rw.idColumn != "" {
+			rowErr = fmt.Errorf("%s %v: %w", rw.idColumn, inputs[rw.idColumn], rowErr)
+		}
+		rw.err = rowErr
+		return nil
+	}
+	if !rw.wroteHeader {
+		if rw.outputColumns == nil {
+			rw.outputColumns = sortedKeys(outputs)
+		}
+		if err := rw.writeHeader(); err != nil {
+			return err
+		}
+	}
+	record := make([]string, 0, len(rw.outputColumns)+1)
+	if rw.idColumn != "" {
+		record = append(record, formatCell(inputs[rw.idColumn]))
+	}
+	for _, col := range rw.outputColumns {
+		record = append(record, formatCell(outputs[col]))
+	}
+	return rw.w.Write(record)
+}
+
+func (rw *csvResultWriter) writeHeader() error {
+	header := make([]string, 0, len(rw.outputColumns)+1)
+	if rw.idColumn != "" {
+		header = append(header, rw.idColumn)
+	}
+	header = append(header, rw.outputColumns...)
+	rw.wroteHeader = true
+	return rw.w.Write(header)
+}
+
+func (rw *csvResultWriter) Flush() error {
+	// an empty table still gets a header
+	if !rw.wroteHeader && rw.err == nil {
+		if err := rw.writeHeader(); err != nil {
+			return err
+		}
+	}
+	rw.w.Flush()
+	return rw.w.Error()
+}
+
+func formatCell(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
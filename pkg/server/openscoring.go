@@ -0,0 +1,115 @@
+package server
+
+import (
+	"github.com/stillmatic/pummel"
+	"github.com/stillmatic/pummel/pkg/miningschema"
+	"github.com/stillmatic/pummel/pkg/registry"
+)
+
+// The request and response types mirror the JSON documents of the Openscoring REST API,
+// so that Openscoring clients can be pointed at pummel-server unchanged.
+
+type SimpleResponse struct {
+	Message string `json:"message,omitempty"`
+}
+
+type ModelResponse struct {
+	ID             string                 `json:"id"`
+	MiningFunction string                 `json:"miningFunction"`
+	Summary        string                 `json:"summary"`
+	Properties     map[string]interface{} `json:"properties"`
+	Schema         *Schema                `json:"schema"`
+}
+
+type BatchModelResponse struct {
+	Responses []*ModelResponse `json:"responses"`
+}
+
+type Schema struct {
+	InputFields  []*Field `json:"inputFields"`
+	TargetFields []*Field `json:"targetFields"`
+	OutputFields []*Field `json:"outputFields"`
+}
+
+type Field struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name,omitempty"`
+	DataType string   `json:"dataType,omitempty"`
+	OpType   string   `json:"opType,omitempty"`
+	Values   []string `json:"values,omitempty"`
+}
+
+type EvaluationRequest struct {
+	ID        string                 `json:"id,omitempty"`
+	Arguments map[string]interface{} `json:"arguments"`
+}
+
+type EvaluationResponse struct {
+	ID      string                 `json:"id,omitempty"`
+	Message string                 `json:"message,omitempty"`
+	Results map[string]interface{} `json:"results,omitempty"`
+}
+
+type BatchEvaluationRequest struct {
+	ID       string               `json:"id,omitempty"`
+	Requests []*EvaluationRequest `json:"requests"`
+}
+
+type BatchEvaluationResponse struct {
+	ID        string                `json:"id,omitempty"`
+	Responses []*EvaluationResponse `json:"responses"`
+}
+
+// StatusResponse is not part of Openscoring. It reports the registry status of each model,
+// including models whose latest file failed to load.
+type StatusResponse struct {
+	Models []registry.Status `json:"models"`
+}
+
+// NewSchema describes the input, target and output fields of m.
+func NewSchema(m *pummel.Model) *Schema {
+	schema := &Schema{
+		InputFields:  make([]*Field, 0),
+		TargetFields: make([]*Field, 0),
+		OutputFields: make([]*Field, 0),
+	}
+	if ms := m.GetMiningSchema(); ms != nil {
+		for _, mf := range ms.MiningFields {
+			switch mf.UsageType {
+			case "", "active":
+				schema.InputFields = append(schema.InputFields, newField(m, mf))
+			case "target", "predicted":
+				schema.TargetFields = append(schema.TargetFields, newField(m, mf))
+			}
+		}
+	}
+	if out := m.GetOutput(); out != nil {
+		for _, of := range out.OutputFields {
+			schema.OutputFields = append(schema.OutputFields, &Field{
+				ID:       of.Name,
+				Name:     of.DisplayName,
+				DataType: of.DataType,
+				OpType:   of.OpType,
+			})
+		}
+	}
+	return schema
+}
+
+func newField(m *pummel.Model, mf *miningschema.MiningField) *Field {
+	f := &Field{ID: mf.Name, OpType: mf.OpType}
+	df := m.DataDictionary.GetDataField(mf.Name)
+	if df == nil {
+		return f
+	}
+	f.DataType = df.DataType
+	if f.OpType == "" {
+		f.OpType = df.OpType
+	}
+	for _, v := range df.Values {
+		if v.Property == "" || v.Property == "valid" {
+			f.Values = append(f.Values, v.Value)
+		}
+	}
+	return f
+}
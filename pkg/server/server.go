@@ -0,0 +1,309 @@
+// Package server implements the Openscoring REST API on top of pummel.
+//
+//	PUT    /model/{id}        deploy the PMML document in the request body
+//	GET    /model             summaries of all deployed models
+//	GET    /model/{id}        summary of a model
+//	GET    /model/{id}/pmml   the deployed PMML document
+//	POST   /model/{id}        evaluate a single EvaluationRequest
+//	POST   /model/{id}/batch  evaluate a BatchEvaluationRequest
+//	POST   /model/{id}/csv    evaluate CSV, returning CSV
+//	DELETE /model/{id}        undeploy a model
+//	GET    /status            the registry status of every model, including errors loading them
+//
+// Each request evaluates the version of the model that was current when it arrived,
+// even if the registry replaces the model while the request is in flight.
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/stillmatic/pummel/pkg/batch"
+	"github.com/stillmatic/pummel/pkg/registry"
+)
+
+// TimestampFormat is used for the created.timestamp and accessed.timestamp model properties.
+const TimestampFormat = "2006-01-02T15:04:05.000-0700"
+
+type Server struct {
+	// Workers bounds the rows evaluated concurrently by batch and CSV requests, defaulting to GOMAXPROCS.
+	Workers  int
+	Registry *registry.Registry
+}
+
+// New serves the models of reg, or of a new empty registry if reg is nil.
+func New(reg *registry.Registry) *Server {
+	if reg == nil {
+		reg = registry.New()
+	}
+	return &Server{Registry: reg}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) == 1 && parts[0] == "status" {
+		if allowMethods(w, r, http.MethodGet) {
+			writeJSON(w, http.StatusOK, &StatusResponse{Models: s.Registry.Status()})
+		}
+		return
+	}
+	if parts[0] != "model" || len(parts) > 3 {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	switch len(parts) {
+	case 1:
+		if allowMethods(w, r, http.MethodGet) {
+			s.handleList(w)
+		}
+	case 2:
+		s.serveModel(w, r, parts[1])
+	case 3:
+		if parts[2] != "pmml" && parts[2] != "batch" && parts[2] != "csv" {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		method := http.MethodPost
+		if parts[2] == "pmml" {
+			method = http.MethodGet
+		}
+		if !allowMethods(w, r, method) {
+			return
+		}
+		v := s.Registry.Get(parts[1])
+		if v == nil {
+			writeModelNotFound(w, parts[1])
+			return
+		}
+		switch parts[2] {
+		case "pmml":
+			w.Header().Set("Content-Type", "application/xml")
+			_, _ = w.Write(v.PMML)
+		case "batch":
+			s.handleBatch(w, r, v)
+		case "csv":
+			s.handleCSV(w, r, v)
+		}
+	}
+}
+
+func (s *Server) serveModel(w http.ResponseWriter, r *http.Request, id string) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete) {
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		s.handleDeploy(w, r, id)
+		return
+	case http.MethodDelete:
+		s.handleUndeploy(w, id)
+		return
+	}
+	v := s.Registry.Get(id)
+	if v == nil {
+		writeModelNotFound(w, id)
+		return
+	}
+	if r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, response(v))
+		return
+	}
+	s.handleEvaluate(w, r, v)
+}
+
+func (s *Server) handleList(w http.ResponseWriter) {
+	versions := s.Registry.List()
+	res := &BatchModelResponse{Responses: make([]*ModelResponse, 0, len(versions))}
+	for _, v := range versions {
+		res.Responses = append(res.Responses, response(v))
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) handleDeploy(w http.ResponseWriter, r *http.Request, id string) {
+	pmml, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	v, created, err := s.Registry.Deploy(id, pmml)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	writeJSON(w, status, response(v))
+}
+
+func (s *Server) handleUndeploy(w http.ResponseWriter, id string) {
+	if !s.Registry.Undeploy(id) {
+		writeModelNotFound(w, id)
+		return
+	}
+	writeJSON(w, http.StatusOK, &SimpleResponse{})
+}
+
+func (s *Server) handleEvaluate(w http.ResponseWriter, r *http.Request, v *registry.Version) {
+	var req EvaluationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	res := evaluate(v, &req)
+	if res.Message != "" {
+		writeJSON(w, http.StatusBadRequest, res)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) handleBatch(w http.ResponseWriter, r *http.Request, v *registry.Version) {
+	var req BatchEvaluationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	res := &BatchEvaluationResponse{ID: req.ID, Responses: make([]*EvaluationResponse, len(req.Requests))}
+	guard := make(chan struct{}, s.workers())
+	var wg sync.WaitGroup
+	for i, er := range req.Requests {
+		wg.Add(1)
+		guard <- struct{}{}
+		go func(i int, er *EvaluationRequest) {
+			defer wg.Done()
+			res.Responses[i] = evaluate(v, er)
+			<-guard
+		}(i, er)
+	}
+	wg.Wait()
+	writeJSON(w, http.StatusOK, res)
+}
+
+// handleCSV evaluates each CSV row. Like Openscoring, the response holds the id column,
+// if the input has one, followed by the model's results, and any failing row fails the whole request.
+func (s *Server) handleCSV(w http.ResponseWriter, r *http.Request, v *registry.Version) {
+	comma := ','
+	if dc := r.URL.Query().Get("delimiterChar"); dc != "" {
+		if dc == "\\t" {
+			dc = "\t"
+		}
+		comma = []rune(dc)[0]
+	}
+	cr, err := batch.NewCSVReaderComma(r.Body, comma)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var out bytes.Buffer
+	cw := newCSVResultWriter(&out, comma, cr.Columns(), batch.OutputColumns(v.Model))
+	scorer := &batch.Scorer{Model: v.Model, Coerce: v.Model.DataDictionary.Coerce, Workers: s.Workers}
+	if _, err := scorer.Score(cr, cw); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if cw.err != nil {
+		writeError(w, http.StatusBadRequest, cw.err.Error())
+		return
+	}
+	v.Touch()
+	w.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+	_, _ = w.Write(out.Bytes())
+}
+
+func (s *Server) workers() int {
+	if s.Workers > 0 {
+		return s.Workers
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+func response(v *registry.Version) *ModelResponse {
+	var accessed interface{}
+	if t := v.Accessed(); !t.IsZero() {
+		accessed = t.Format(TimestampFormat)
+	}
+	return &ModelResponse{
+		ID:             v.ID,
+		MiningFunction: v.Model.FunctionName,
+		Summary:        v.Model.Element,
+		Properties: map[string]interface{}{
+			"created.timestamp":  v.Loaded.Format(TimestampFormat),
+			"accessed.timestamp": accessed,
+			"file.size":          len(v.PMML),
+			"file.md5sum":        v.MD5Sum,
+			"model.version":      v.Version,
+		},
+		Schema: NewSchema(v.Model),
+	}
+}
+
+func evaluate(v *registry.Version, req *EvaluationRequest) *EvaluationResponse {
+	v.Touch()
+	if req == nil {
+		return &EvaluationResponse{Message: "missing evaluation request"}
+	}
+	values := make(map[string]interface{}, len(req.Arguments))
+	for k, arg := range req.Arguments {
+		coerced, err := v.Model.DataDictionary.Coerce(k, arg)
+		if err != nil {
+			return &EvaluationResponse{ID: req.ID, Message: err.Error()}
+		}
+		if coerced != nil {
+			values[k] = coerced
+		}
+	}
+	res, err := v.Model.Evaluate(values)
+	if err != nil {
+		return &EvaluationResponse{ID: req.ID, Message: err.Error()}
+	}
+	return &EvaluationResponse{ID: req.ID, Results: results(batch.OutputColumns(v.Model), res)}
+}
+
+// results restricts res to the target and output fields, when the model declares them.
+func results(cols []string, res map[string]interface{}) map[string]interface{} {
+	if cols == nil {
+		if res == nil {
+			return map[string]interface{}{}
+		}
+		return res
+	}
+	out := make(map[string]interface{}, len(cols))
+	for _, col := range cols {
+		out[col] = res[col]
+	}
+	return out
+}
+
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, m := range methods {
+		if r.Method == m {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, http.StatusMethodNotAllowed, fmt.Sprintf("method %s not allowed", r.Method))
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeModelNotFound(w http.ResponseWriter, id string) {
+	writeError(w, http.StatusNotFound, fmt.Sprintf("model %q not found", id))
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, &SimpleResponse{Message: message})
+}
@@ -0,0 +1,173 @@
+package server_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stillmatic/pummel/pkg/registry"
+	"github.com/stillmatic/pummel/pkg/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func do(t *testing.T, h http.Handler, method, path string, body io.Reader) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, body)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func decode(t *testing.T, rec *httptest.ResponseRecorder, v interface{}) {
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), v))
+}
+
+func deployLR(t *testing.T) (*server.Server, []byte) {
+	pmml, err := ioutil.ReadFile("../../testdata/lr.pmml")
+	assert.NoError(t, err)
+	srv := server.New(nil)
+	rec := do(t, srv, http.MethodPut, "/model/lr", bytes.NewReader(pmml))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	return srv, pmml
+}
+
+func TestDeploy(t *testing.T) {
+	srv, pmml := deployLR(t)
+	// redeploying replaces the model
+	rec := do(t, srv, http.MethodPut, "/model/lr", bytes.NewReader(pmml))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var mr server.ModelResponse
+	decode(t, rec, &mr)
+	assert.Equal(t, "lr", mr.ID)
+	assert.Equal(t, "classification", mr.MiningFunction)
+	assert.Equal(t, float64(len(pmml)), mr.Properties["file.size"])
+	assert.Equal(t, 32, len(mr.Properties["file.md5sum"].(string)))
+	assert.Equal(t, float64(2), mr.Properties["model.version"])
+	assert.Equal(t, 4, len(mr.Schema.InputFields))
+	assert.Equal(t, "x0", mr.Schema.InputFields[0].ID)
+	assert.Equal(t, "double", mr.Schema.InputFields[0].DataType)
+	assert.Equal(t, 1, len(mr.Schema.TargetFields))
+	assert.Equal(t, 5, len(mr.Schema.TargetFields[0].Values))
+	assert.Equal(t, 7, len(mr.Schema.OutputFields))
+
+	rec = do(t, srv, http.MethodPut, "/model/broken", strings.NewReader("<PMML>"))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = do(t, srv, http.MethodPut, "/model/-bad", bytes.NewReader(pmml))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = do(t, srv, http.MethodGet, "/model", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var bmr server.BatchModelResponse
+	decode(t, rec, &bmr)
+	assert.Equal(t, 1, len(bmr.Responses))
+
+	rec = do(t, srv, http.MethodGet, "/model/lr/pmml", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, pmml, rec.Body.Bytes())
+}
+
+func TestEvaluate(t *testing.T) {
+	srv, _ := deployLR(t)
+	rec := do(t, srv, http.MethodPost, "/model/lr", strings.NewReader(`{"id": "record-001", "arguments": {"x0": 0.1, "x1": 0.1, "x2": 100, "x3": 0.1}}`))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var er server.EvaluationResponse
+	decode(t, rec, &er)
+	assert.Equal(t, "record-001", er.ID)
+	assert.Equal(t, "CATEGORY_2", er.Results["labels"])
+	assert.InDelta(t, 1.0, er.Results["probability(CATEGORY_2)"], 1e-9)
+
+	rec = do(t, srv, http.MethodPost, "/model/lr", strings.NewReader(`{"id": "record-002", "arguments": {"x0": "abc"}}`))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	er = server.EvaluationResponse{}
+	decode(t, rec, &er)
+	assert.Contains(t, er.Message, "invalid double value for field x0")
+
+	rec = do(t, srv, http.MethodPost, "/model/missing", strings.NewReader(`{"arguments": {}}`))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = do(t, srv, http.MethodGet, "/model/lr", nil)
+	var mr server.ModelResponse
+	decode(t, rec, &mr)
+	assert.NotNil(t, mr.Properties["accessed.timestamp"])
+}
+
+func TestEvaluateBatch(t *testing.T) {
+	srv, _ := deployLR(t)
+	body := `{"id": "batch-A", "requests": [
+		{"id": "1", "arguments": {"x0": 0.1, "x1": 0.1, "x2": 100, "x3": 0.1}},
+		{"id": "2", "arguments": {"x0": "abc"}},
+		{"id": "3", "arguments": {"x0": 0.1, "x1": 0.1, "x2": 0.1, "x3": 0.1}}
+	]}`
+	rec := do(t, srv, http.MethodPost, "/model/lr/batch", strings.NewReader(body))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var ber server.BatchEvaluationResponse
+	decode(t, rec, &ber)
+	assert.Equal(t, "batch-A", ber.ID)
+	assert.Equal(t, 3, len(ber.Responses))
+	assert.Equal(t, "CATEGORY_2", ber.Responses[0].Results["labels"])
+	assert.NotEmpty(t, ber.Responses[1].Message)
+	assert.Equal(t, "3", ber.Responses[2].ID)
+	assert.Equal(t, "CATEGORY_3", ber.Responses[2].Results["labels"])
+}
+
+func TestEvaluateCSV(t *testing.T) {
+	srv, _ := deployLR(t)
+	body := "Id\tx0\tx1\tx2\tx3\nA\t0.1\t0.1\t100\t0.1\nB\t0.1\t0.1\t0.1\t0.1\n"
+	rec := do(t, srv, http.MethodPost, "/model/lr/csv?delimiterChar=%5Ct", strings.NewReader(body))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	assert.Equal(t, 3, len(lines))
+	assert.True(t, strings.HasPrefix(lines[0], "Id\tlabels\tpmml(prediction)\tprediction"))
+	assert.True(t, strings.HasPrefix(lines[1], "A\tCATEGORY_2\t"))
+	assert.True(t, strings.HasPrefix(lines[2], "B\tCATEGORY_3\t"))
+
+	rec = do(t, srv, http.MethodPost, "/model/lr/csv", strings.NewReader("Id,x0\nA,abc\n"))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	var sr server.SimpleResponse
+	decode(t, rec, &sr)
+	assert.Contains(t, sr.Message, "Id A")
+}
+
+func TestUndeploy(t *testing.T) {
+	srv, _ := deployLR(t)
+	rec := do(t, srv, http.MethodDelete, "/model/lr", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = do(t, srv, http.MethodGet, "/model/lr", nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	rec = do(t, srv, http.MethodDelete, "/model/lr", nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestRouting(t *testing.T) {
+	srv, _ := deployLR(t)
+	assert.Equal(t, http.StatusNotFound, do(t, srv, http.MethodGet, "/models", nil).Code)
+	assert.Equal(t, http.StatusNotFound, do(t, srv, http.MethodGet, "/model/lr/other", nil).Code)
+	rec := do(t, srv, http.MethodGet, "/model/lr/batch", nil)
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, "POST", rec.Header().Get("Allow"))
+	assert.Equal(t, http.StatusMethodNotAllowed, do(t, srv, http.MethodPatch, "/model/lr", nil).Code)
+}
+
+func TestStatus(t *testing.T) {
+	reg := registry.New()
+	srv := server.New(reg)
+	dir := t.TempDir()
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "broken.pmml"), []byte("<PMML>"), 0o644))
+	assert.Error(t, reg.Scan(dir))
+
+	rec := do(t, srv, http.MethodGet, "/status", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var sr server.StatusResponse
+	decode(t, rec, &sr)
+	assert.Equal(t, 1, len(sr.Models))
+	assert.Equal(t, "broken", sr.Models[0].ID)
+	assert.Equal(t, 0, sr.Models[0].Version)
+	assert.NotEmpty(t, sr.Models[0].Error)
+	assert.Equal(t, http.StatusNotFound, do(t, srv, http.MethodGet, "/model/broken", nil).Code)
+}
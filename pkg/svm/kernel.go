@@ -0,0 +1,94 @@
+package svm
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// Kernel computes the similarity of an input vector and a support vector.
+type Kernel interface {
+	Compute(x, y []float64) float64
+}
+
+// LinearKernel is x·y.
+type LinearKernel struct{}
+
+// PolynomialKernel is (gamma * x·y + coef0) ^ degree.
+type PolynomialKernel struct {
+	Gamma  float64
+	Coef0  float64
+	Degree float64
+}
+
+// RadialBasisKernel is exp(-gamma * ||x - y||²).
+type RadialBasisKernel struct {
+	Gamma float64
+}
+
+// SigmoidKernel is tanh(gamma * x·y + coef0).
+type SigmoidKernel struct {
+	Gamma float64
+	Coef0 float64
+}
+
+func dot(x, y []float64) float64 {
+	var res float64
+	for i := range x {
+		res += x[i] * y[i]
+	}
+	return res
+}
+
+func (k LinearKernel) Compute(x, y []float64) float64 {
+	return dot(x, y)
+}
+
+func (k PolynomialKernel) Compute(x, y []float64) float64 {
+	return math.Pow(k.Gamma*dot(x, y)+k.Coef0, k.Degree)
+}
+
+func (k RadialBasisKernel) Compute(x, y []float64) float64 {
+	var dist float64
+	for i := range x {
+		d := x[i] - y[i]
+		dist += d * d
+	}
+	return math.Exp(-k.Gamma * dist)
+}
+
+func (k SigmoidKernel) Compute(x, y []float64) float64 {
+	return math.Tanh(k.Gamma*dot(x, y) + k.Coef0)
+}
+
+// decodeKernel decodes one of the kernel type elements. Each parameter defaults to 1.
+func decodeKernel(d *xml.Decoder, start xml.StartElement) (Kernel, error) {
+	params := map[string]float64{"gamma": 1, "coef0": 1, "degree": 1}
+	for _, attr := range start.Attr {
+		if _, ok := params[attr.Name.Local]; !ok {
+			continue
+		}
+		val, err := strconv.ParseFloat(attr.Value, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s of %s", attr.Name.Local, start.Name.Local)
+		}
+		params[attr.Name.Local] = val
+	}
+	if err := d.Skip(); err != nil {
+		return nil, err
+	}
+	switch start.Name.Local {
+	case "LinearKernelType":
+		return LinearKernel{}, nil
+	case "PolynomialKernelType":
+		return PolynomialKernel{Gamma: params["gamma"], Coef0: params["coef0"], Degree: params["degree"]}, nil
+	case "RadialBasisKernelType":
+		return RadialBasisKernel{Gamma: params["gamma"]}, nil
+	case "SigmoidKernelType":
+		return SigmoidKernel{Gamma: params["gamma"], Coef0: params["coef0"]}, nil
+	}
+	return nil, fmt.Errorf("unknown kernel type: %s", start.Name.Local)
+}
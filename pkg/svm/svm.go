@@ -0,0 +1,554 @@
+// Package svm implements the SupportVectorMachineModel element. The inputs are mapped onto a vector by the
+// VectorFields of the model's VectorDictionary, which also holds the support vectors shared by its machines.
+package svm
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/stillmatic/pummel/pkg/array"
+	"github.com/stillmatic/pummel/pkg/fields"
+	"github.com/stillmatic/pummel/pkg/miningschema"
+	"github.com/stillmatic/pummel/pkg/transformations"
+	"github.com/stillmatic/pummel/pkg/verification"
+)
+
+type SupportVectorMachineModel struct {
+	XMLName       xml.Name `xml:"SupportVectorMachineModel"`
+	ModelName     string   `xml:"modelName,attr"`
+	FunctionName  string   `xml:"functionName,attr"`
+	AlgorithmName string   `xml:"algorithmName,attr"`
+	// Threshold separates the two categories of a OneAgainstOne machine, unless the machine sets its own.
+	Threshold                     float64 `xml:"threshold,attr"`
+	SVMRepresentation             string  `xml:"svmRepresentation,attr"`
+	ClassificationMethod          string  `xml:"classificationMethod,attr"`
+	AlternateBinaryTargetCategory string  `xml:"alternateBinaryTargetCategory,attr"`
+	// MaxWins makes the machine with the largest rather than the smallest value win with OneAgainstAll.
+	MaxWins               bool                                  `xml:"maxWins,attr"`
+	IsScorable            bool                                  `xml:"isScorable,attr"`
+	MiningSchema          *miningschema.MiningSchema            `xml:"MiningSchema"`
+	Output                *fields.Outputs                       `xml:"Output"`
+	LocalTransformations  *transformations.LocalTransformations `xml:"LocalTransformations"`
+	Kernel                Kernel
+	VectorDictionary      *VectorDictionary               `xml:"VectorDictionary"`
+	SupportVectorMachines []*SupportVectorMachine         `xml:"SupportVectorMachine"`
+	ModelVerification     *verification.ModelVerification `xml:"ModelVerification"`
+
+	doc *transformations.Document
+}
+
+// NewSupportVectorMachineModel returns an empty SupportVectorMachineModel to be decoded into, whose expressions belong to doc.
+func NewSupportVectorMachineModel(doc *transformations.Document) *SupportVectorMachineModel {
+	return &SupportVectorMachineModel{doc: doc}
+}
+
+var ClassificationMethods = struct {
+	OneAgainstAll string
+	OneAgainstOne string
+}{
+	OneAgainstAll: "OneAgainstAll",
+	OneAgainstOne: "OneAgainstOne",
+}
+
+var SVMRepresentations = struct {
+	SupportVectors string
+	Coefficients   string
+}{
+	SupportVectors: "SupportVectors",
+	Coefficients:   "Coefficients",
+}
+
+// VectorDictionary defines how inputs are mapped onto vectors, and the support vectors by their id.
+type VectorDictionary struct {
+	XMLName   xml.Name `xml:"VectorDictionary"`
+	Fields    []VectorField
+	Instances map[string][]float64
+}
+
+// VectorField is an entry of the input vector: a FieldRef, or a CategoricalPredictor which is
+// its coefficient if the field has the predictor's value and 0 otherwise.
+type VectorField struct {
+	Field string
+	// Value is the category of a CategoricalPredictor, which is nil for a FieldRef.
+	Value       *string
+	Coefficient float64
+}
+
+// SupportVectorMachine is a single machine, whose value is the weighted sum of its kernel applied to
+// the input and each of its support vectors, plus the intercept. With the Coefficients representation
+// it is a linear function of the input vector instead.
+type SupportVectorMachine struct {
+	XMLName                 xml.Name `xml:"SupportVectorMachine"`
+	TargetCategory          string   `xml:"targetCategory,attr"`
+	AlternateTargetCategory string   `xml:"alternateTargetCategory,attr"`
+	Threshold               *float64 `xml:"threshold,attr"`
+	SupportVectors          []string
+	Coefficients            []float64
+	Intercept               float64
+}
+
+func (m *SupportVectorMachineModel) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	m.XMLName = start.Name
+	m.SVMRepresentation = SVMRepresentations.SupportVectors
+	m.ClassificationMethod = ClassificationMethods.OneAgainstAll
+	for _, attr := range start.Attr {
+		var err error
+		switch attr.Name.Local {
+		case "modelName":
+			m.ModelName = attr.Value
+		case "functionName":
+			m.FunctionName = attr.Value
+		case "algorithmName":
+			m.AlgorithmName = attr.Value
+		case "threshold":
+			m.Threshold, err = strconv.ParseFloat(attr.Value, 64)
+		case "svmRepresentation":
+			m.SVMRepresentation = attr.Value
+		case "classificationMethod":
+			m.ClassificationMethod = attr.Value
+		case "alternateBinaryTargetCategory":
+			m.AlternateBinaryTargetCategory = attr.Value
+		case "maxWins":
+			m.MaxWins = attr.Value == "true"
+		case "isScorable":
+			m.IsScorable = attr.Value == "true"
+		}
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s of SupportVectorMachineModel", attr.Name.Local)
+		}
+	}
+	switch m.SVMRepresentation {
+	case SVMRepresentations.SupportVectors, SVMRepresentations.Coefficients:
+	default:
+		return fmt.Errorf("unknown svm representation: %s", m.SVMRepresentation)
+	}
+	switch m.ClassificationMethod {
+	case ClassificationMethods.OneAgainstAll, ClassificationMethods.OneAgainstOne:
+	default:
+		return fmt.Errorf("unknown classification method: %s", m.ClassificationMethod)
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "MiningSchema":
+				var ms miningschema.MiningSchema
+				if err := d.DecodeElement(&ms, &tt); err != nil {
+					return err
+				}
+				m.MiningSchema = &ms
+			case "Output":
+				out := fields.NewOutputs(m.doc)
+				if err := d.DecodeElement(out, &tt); err != nil {
+					return err
+				}
+				m.Output = out
+			case "LocalTransformations":
+				lt := m.doc.NewLocalTransformations()
+				if err := d.DecodeElement(lt, &tt); err != nil {
+					return err
+				}
+				m.LocalTransformations = lt
+			case "LinearKernelType", "PolynomialKernelType", "RadialBasisKernelType", "SigmoidKernelType":
+				m.Kernel, err = decodeKernel(d, tt)
+				if err != nil {
+					return err
+				}
+			case "VectorDictionary":
+				var vd VectorDictionary
+				if err := d.DecodeElement(&vd, &tt); err != nil {
+					return err
+				}
+				m.VectorDictionary = &vd
+			case "SupportVectorMachine":
+				var svm SupportVectorMachine
+				if err := d.DecodeElement(&svm, &tt); err != nil {
+					return err
+				}
+				m.SupportVectorMachines = append(m.SupportVectorMachines, &svm)
+			case "ModelVerification":
+				var mv verification.ModelVerification
+				if err := d.DecodeElement(&mv, &tt); err != nil {
+					return err
+				}
+				m.ModelVerification = &mv
+			case "Extension", "ModelStats", "ModelExplanation":
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unknown element: %s", tt.Name.Local)
+			}
+		case xml.EndElement:
+			return m.check()
+		}
+	}
+}
+
+// check verifies that the machines are consistent with the vector dictionary.
+func (m *SupportVectorMachineModel) check() error {
+	if m.VectorDictionary == nil {
+		return errors.New("SupportVectorMachineModel has no VectorDictionary")
+	}
+	if m.Kernel == nil && m.SVMRepresentation == SVMRepresentations.SupportVectors {
+		return errors.New("SupportVectorMachineModel has no kernel")
+	}
+	if len(m.SupportVectorMachines) == 0 {
+		return errors.New("SupportVectorMachineModel has no SupportVectorMachine")
+	}
+	n := len(m.VectorDictionary.Fields)
+	for id, v := range m.VectorDictionary.Instances {
+		if len(v) != n {
+			return fmt.Errorf("vector %s has %d entries, expected %d", id, len(v), n)
+		}
+	}
+	for i, svm := range m.SupportVectorMachines {
+		switch m.SVMRepresentation {
+		case SVMRepresentations.SupportVectors:
+			if len(svm.Coefficients) != len(svm.SupportVectors) {
+				return fmt.Errorf("SupportVectorMachine %d has %d coefficients for %d support vectors", i+1, len(svm.Coefficients), len(svm.SupportVectors))
+			}
+			for _, id := range svm.SupportVectors {
+				if _, ok := m.VectorDictionary.Instances[id]; !ok {
+					return fmt.Errorf("SupportVectorMachine %d refers to unknown vector %s", i+1, id)
+				}
+			}
+		case SVMRepresentations.Coefficients:
+			if len(svm.Coefficients) != n {
+				return fmt.Errorf("SupportVectorMachine %d has %d coefficients for %d vector fields", i+1, len(svm.Coefficients), n)
+			}
+		}
+		if m.FunctionName == "classification" && svm.TargetCategory == "" {
+			return fmt.Errorf("SupportVectorMachine %d has no target category", i+1)
+		}
+	}
+	return nil
+}
+
+func (vd *VectorDictionary) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	vd.XMLName = start.Name
+	vd.Instances = make(map[string][]float64)
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "VectorFields":
+				vd.Fields, err = decodeVectorFields(d)
+				if err != nil {
+					return err
+				}
+			case "VectorInstance":
+				id, v, err := decodeVectorInstance(d, tt, len(vd.Fields))
+				if err != nil {
+					return err
+				}
+				vd.Instances[id] = v
+			case "Extension":
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unexpected element in VectorDictionary: %s", tt.Name.Local)
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+func decodeVectorFields(d *xml.Decoder) ([]VectorField, error) {
+	var res []VectorField
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "FieldRef":
+				var fr transformations.FieldRef
+				if err := d.DecodeElement(&fr, &tt); err != nil {
+					return nil, err
+				}
+				res = append(res, VectorField{Field: fr.Field})
+			case "CategoricalPredictor":
+				var cp struct {
+					Name        string  `xml:"name,attr"`
+					Value       string  `xml:"value,attr"`
+					Coefficient float64 `xml:"coefficient,attr"`
+				}
+				if err := d.DecodeElement(&cp, &tt); err != nil {
+					return nil, err
+				}
+				res = append(res, VectorField{Field: cp.Name, Value: &cp.Value, Coefficient: cp.Coefficient})
+			case "Extension":
+				if err := d.Skip(); err != nil {
+					return nil, err
+				}
+			default:
+				return nil, fmt.Errorf("unexpected element in VectorFields: %s", tt.Name.Local)
+			}
+		case xml.EndElement:
+			return res, nil
+		}
+	}
+}
+
+func decodeVectorInstance(d *xml.Decoder, start xml.StartElement, n int) (string, []float64, error) {
+	var id string
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "id" {
+			id = attr.Value
+		}
+	}
+	var v []float64
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return "", nil, err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			if !array.IsNumeric(tt.Name.Local) {
+				if err := d.Skip(); err != nil {
+					return "", nil, err
+				}
+				continue
+			}
+			v, err = array.DecodeFloats(d, tt, n)
+			if err != nil {
+				return "", nil, errors.Wrapf(err, "invalid vector %s", id)
+			}
+		case xml.EndElement:
+			if v == nil {
+				return "", nil, fmt.Errorf("vector %s has no array", id)
+			}
+			return id, v, nil
+		}
+	}
+}
+
+func (svm *SupportVectorMachine) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	svm.XMLName = start.Name
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "targetCategory":
+			svm.TargetCategory = attr.Value
+		case "alternateTargetCategory":
+			svm.AlternateTargetCategory = attr.Value
+		case "threshold":
+			threshold, err := strconv.ParseFloat(attr.Value, 64)
+			if err != nil {
+				return errors.Wrap(err, "invalid threshold of SupportVectorMachine")
+			}
+			svm.Threshold = &threshold
+		}
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "SupportVectors":
+				var svs struct {
+					SupportVectors []struct {
+						VectorID string `xml:"vectorId,attr"`
+					} `xml:"SupportVector"`
+				}
+				if err := d.DecodeElement(&svs, &tt); err != nil {
+					return err
+				}
+				for _, sv := range svs.SupportVectors {
+					svm.SupportVectors = append(svm.SupportVectors, sv.VectorID)
+				}
+			case "Coefficients":
+				var cs struct {
+					AbsoluteValue float64 `xml:"absoluteValue,attr"`
+					Coefficients  []struct {
+						Value float64 `xml:"value,attr"`
+					} `xml:"Coefficient"`
+				}
+				if err := d.DecodeElement(&cs, &tt); err != nil {
+					return err
+				}
+				svm.Intercept = cs.AbsoluteValue
+				for _, c := range cs.Coefficients {
+					svm.Coefficients = append(svm.Coefficients, c.Value)
+				}
+			case "Extension":
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unexpected element in SupportVectorMachine: %s", tt.Name.Local)
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// vector maps the inputs onto the vector fields. It returns nil if an input is missing.
+func (vd *VectorDictionary) vector(values map[string]interface{}) ([]float64, error) {
+	x := make([]float64, len(vd.Fields))
+	for i, vf := range vd.Fields {
+		value := values[vf.Field]
+		if value == nil {
+			return nil, nil
+		}
+		if vf.Value != nil {
+			if fmt.Sprint(value) == *vf.Value {
+				x[i] = vf.Coefficient
+			}
+			continue
+		}
+		f, err := transformations.InterfaceToFloat64(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid value of field %s", vf.Field)
+		}
+		x[i] = f
+	}
+	return x, nil
+}
+
+func (m *SupportVectorMachineModel) evaluateMachine(svm *SupportVectorMachine, x []float64) float64 {
+	res := svm.Intercept
+	if m.SVMRepresentation == SVMRepresentations.Coefficients {
+		for i, c := range svm.Coefficients {
+			res += c * x[i]
+		}
+		return res
+	}
+	for i, id := range svm.SupportVectors {
+		res += svm.Coefficients[i] * m.Kernel.Compute(x, m.VectorDictionary.Instances[id])
+	}
+	return res
+}
+
+func (m *SupportVectorMachineModel) Evaluate(values map[string]interface{}) (map[string]interface{}, error) {
+	out, err := m.evaluate(values)
+	return m.Output.Complete(values, out, err)
+}
+
+func (m *SupportVectorMachineModel) evaluate(values map[string]interface{}) (map[string]interface{}, error) {
+	if m.LocalTransformations != nil {
+		for _, tr := range m.LocalTransformations.DerivedFields {
+			val, err := tr.Transform(values)
+			if err != nil {
+				return nil, err
+			}
+			values[tr.RequiredField()] = val
+		}
+	}
+	x, err := m.VectorDictionary.vector(values)
+	if err != nil {
+		return nil, err
+	}
+	if x == nil {
+		return nil, nil
+	}
+	switch m.FunctionName {
+	case "regression":
+		return map[string]interface{}{m.GetOutputField(): m.evaluateMachine(m.SupportVectorMachines[0], x)}, nil
+	case "classification":
+		return m.evaluateClassification(x), nil
+	default:
+		return nil, fmt.Errorf("unknown model type: %s", m.FunctionName)
+	}
+}
+
+// evaluateClassification scores each category. With OneAgainstAll the score is the value of the category's
+// machine, and the smallest score wins unless MaxWins is set. With OneAgainstOne, or a single binary machine,
+// each machine votes for its target category if its value is below the threshold, and for the alternate
+// category otherwise; the score is the number of votes, and ties go to the category voted for first.
+func (m *SupportVectorMachineModel) evaluateClassification(x []float64) map[string]interface{} {
+	scores := make(map[string]float64)
+	var categories []string
+	binary := len(m.SupportVectorMachines) == 1 && m.AlternateBinaryTargetCategory != ""
+	for _, svm := range m.SupportVectorMachines {
+		value := m.evaluateMachine(svm, x)
+		if m.ClassificationMethod == ClassificationMethods.OneAgainstAll && !binary {
+			categories = append(categories, svm.TargetCategory)
+			scores[svm.TargetCategory] = value
+			continue
+		}
+		alternate := svm.AlternateTargetCategory
+		if alternate == "" {
+			alternate = m.AlternateBinaryTargetCategory
+		}
+		threshold := m.Threshold
+		if svm.Threshold != nil {
+			threshold = *svm.Threshold
+		}
+		category := alternate
+		if value < threshold {
+			category = svm.TargetCategory
+		}
+		if _, ok := scores[category]; !ok {
+			categories = append(categories, category)
+		}
+		scores[category]++
+	}
+
+	minWins := m.ClassificationMethod == ClassificationMethods.OneAgainstAll && !binary && !m.MaxWins
+	var top string
+	topScore := math.Inf(1)
+	if !minWins {
+		topScore = math.Inf(-1)
+	}
+	out := make(map[string]interface{}, len(scores)+1)
+	for _, category := range categories {
+		score := scores[category]
+		if (minWins && score < topScore) || (!minWins && score > topScore) {
+			top, topScore = category, score
+		}
+		// check if we have a output field for this value
+		name := category
+		if m.Output != nil {
+			if of, err := m.Output.GetFeature(category); err == nil {
+				name = of.Name
+			}
+		}
+		out[name] = score
+	}
+	out[m.GetOutputField()] = top
+	if m.Output != nil {
+		for _, of := range m.Output.OutputFields {
+			if of.Feature == "predictedValue" {
+				out[of.Name] = top
+			}
+		}
+	}
+	return out
+}
+
+func (m *SupportVectorMachineModel) GetOutputField() string {
+	return m.MiningSchema.GetOutputField()
+}
+
+func (m *SupportVectorMachineModel) GetMiningSchema() *miningschema.MiningSchema {
+	return m.MiningSchema
+}
+
+func (m *SupportVectorMachineModel) GetOutput() *fields.Outputs {
+	return m.Output
+}
+
+func (m *SupportVectorMachineModel) GetModelVerification() *verification.ModelVerification {
+	return m.ModelVerification
+}
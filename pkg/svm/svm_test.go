@@ -0,0 +1,240 @@
+package svm_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/stillmatic/pummel/pkg/svm"
+	"github.com/stretchr/testify/assert"
+)
+
+var oneAgainstAllXML = `<SupportVectorMachineModel functionName="classification" svmRepresentation="Coefficients" classificationMethod="OneAgainstAll">
+	<MiningSchema>
+		<MiningField name="x"/>
+		<MiningField name="color"/>
+		<MiningField name="label" usageType="target"/>
+	</MiningSchema>
+	<Output>
+		<OutputField name="score(a)" feature="affinity" value="a"/>
+	</Output>
+	<LinearKernelType/>
+	<VectorDictionary>
+		<VectorFields>
+			<FieldRef field="x"/>
+			<CategoricalPredictor name="color" value="red" coefficient="1"/>
+		</VectorFields>
+	</VectorDictionary>
+	<SupportVectorMachine targetCategory="a">
+		<Coefficients absoluteValue="0.5">
+			<Coefficient value="1"/>
+			<Coefficient value="-2"/>
+		</Coefficients>
+	</SupportVectorMachine>
+	<SupportVectorMachine targetCategory="b">
+		<Coefficients absoluteValue="-1">
+			<Coefficient value="0.5"/>
+			<Coefficient value="1"/>
+		</Coefficients>
+	</SupportVectorMachine>
+	<SupportVectorMachine targetCategory="c">
+		<Coefficients absoluteValue="0">
+			<Coefficient value="-1"/>
+			<Coefficient value="0"/>
+		</Coefficients>
+	</SupportVectorMachine>
+</SupportVectorMachineModel>`
+
+func TestOneAgainstAll(t *testing.T) {
+	var m svm.SupportVectorMachineModel
+	err := xml.Unmarshal([]byte(oneAgainstAllXML), &m)
+	assert.NoError(t, err)
+	assert.Equal(t, "Coefficients", m.SVMRepresentation)
+	assert.Equal(t, 2, len(m.VectorDictionary.Fields))
+
+	tcs := []struct {
+		x        float64
+		color    string
+		expected string
+		scores   []float64
+	}{
+		{1, "red", "c", []float64{-0.5, 0.5, -1}},
+		{1, "blue", "c", []float64{1.5, -0.5, -1}},
+		{-2, "blue", "b", []float64{-1.5, -2, 2}},
+	}
+	for _, tc := range tcs {
+		out, err := m.Evaluate(map[string]interface{}{"x": tc.x, "color": tc.color})
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected, out["label"])
+		assert.InDelta(t, tc.scores[0], out["score(a)"], 1e-12)
+		assert.InDelta(t, tc.scores[1], out["b"], 1e-12)
+		assert.InDelta(t, tc.scores[2], out["c"], 1e-12)
+	}
+
+	// the largest value wins instead
+	err = xml.Unmarshal([]byte(strings.Replace(oneAgainstAllXML, `classificationMethod="OneAgainstAll"`, `classificationMethod="OneAgainstAll" maxWins="true"`, 1)), &m)
+	assert.NoError(t, err)
+	assert.True(t, m.MaxWins)
+	for i, expected := range []string{"b", "a", "c"} {
+		out, err := m.Evaluate(map[string]interface{}{"x": tcs[i].x, "color": tcs[i].color})
+		assert.NoError(t, err)
+		assert.Equal(t, expected, out["label"])
+	}
+}
+
+var regressionXML = `<SupportVectorMachineModel functionName="regression">
+	<MiningSchema>
+		<MiningField name="x1"/>
+		<MiningField name="x2"/>
+		<MiningField name="x3"/>
+		<MiningField name="y" usageType="target"/>
+	</MiningSchema>
+	<PolynomialKernelType gamma="0.5" coef0="1" degree="2"/>
+	<VectorDictionary numberOfVectors="3">
+		<VectorFields numberOfFields="3">
+			<FieldRef field="x1"/>
+			<FieldRef field="x2"/>
+			<FieldRef field="x3"/>
+		</VectorFields>
+		<VectorInstance id="sv1">
+			<REAL-SparseArray n="3">
+				<Indices>1 3</Indices>
+				<REAL-Entries>1.5 -2</REAL-Entries>
+			</REAL-SparseArray>
+		</VectorInstance>
+		<VectorInstance id="sv2">
+			<REAL-SparseArray n="3"/>
+		</VectorInstance>
+		<VectorInstance id="sv3">
+			<Array type="real" n="3">0.5 1 0.25</Array>
+		</VectorInstance>
+	</VectorDictionary>
+	<SupportVectorMachine>
+		<SupportVectors>
+			<SupportVector vectorId="sv1"/>
+			<SupportVector vectorId="sv2"/>
+			<SupportVector vectorId="sv3"/>
+		</SupportVectors>
+		<Coefficients absoluteValue="3.2">
+			<Coefficient value="0.8"/>
+			<Coefficient value="-1.5"/>
+			<Coefficient value="2"/>
+		</Coefficients>
+	</SupportVectorMachine>
+</SupportVectorMachineModel>`
+
+func TestRegression(t *testing.T) {
+	var m svm.SupportVectorMachineModel
+	err := xml.Unmarshal([]byte(regressionXML), &m)
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{1.5, 0, -2}, m.VectorDictionary.Instances["sv1"])
+	assert.Equal(t, []float64{0, 0, 0}, m.VectorDictionary.Instances["sv2"])
+
+	out, err := m.Evaluate(map[string]interface{}{"x1": 1.0, "x2": 2.0, "x3": 3.0})
+	assert.NoError(t, err)
+	assert.InDelta(t, 16.73125, out["y"], 1e-9)
+	out, err = m.Evaluate(map[string]interface{}{"x1": -0.5, "x2": 0.0, "x3": "1"})
+	assert.NoError(t, err)
+	assert.InDelta(t, 3.8125, out["y"], 1e-9)
+
+	out, err = m.Evaluate(map[string]interface{}{"x1": 1.0, "x2": 2.0})
+	assert.NoError(t, err)
+	assert.Nil(t, out)
+}
+
+var binaryXML = `<SupportVectorMachineModel functionName="classification" threshold="0.5" alternateBinaryTargetCategory="no">
+	<MiningSchema>
+		<MiningField name="x1"/>
+		<MiningField name="x2"/>
+		<MiningField name="label" usageType="target"/>
+	</MiningSchema>
+	<SigmoidKernelType gamma="0.25" coef0="-0.5"/>
+	<VectorDictionary>
+		<VectorFields>
+			<FieldRef field="x1"/>
+			<FieldRef field="x2"/>
+		</VectorFields>
+		<VectorInstance id="1"><Array type="real">1 2</Array></VectorInstance>
+		<VectorInstance id="2"><Array type="real">-1 0.5</Array></VectorInstance>
+	</VectorDictionary>
+	<SupportVectorMachine targetCategory="yes">
+		<SupportVectors>
+			<SupportVector vectorId="1"/>
+			<SupportVector vectorId="2"/>
+		</SupportVectors>
+		<Coefficients absoluteValue="0.1">
+			<Coefficient value="-1.2"/>
+			<Coefficient value="0.9"/>
+		</Coefficients>
+	</SupportVectorMachine>
+</SupportVectorMachineModel>`
+
+func TestAlternateBinaryTargetCategory(t *testing.T) {
+	var m svm.SupportVectorMachineModel
+	err := xml.Unmarshal([]byte(binaryXML), &m)
+	assert.NoError(t, err)
+	out, err := m.Evaluate(map[string]interface{}{"x1": 1.0, "x2": 1.0})
+	assert.NoError(t, err)
+	assert.Equal(t, "yes", out["label"])
+	out, err = m.Evaluate(map[string]interface{}{"x1": -2.0, "x2": 1.0})
+	assert.NoError(t, err)
+	assert.Equal(t, "no", out["label"])
+}
+
+func TestKernels(t *testing.T) {
+	x := []float64{1, 2, -0.5}
+	y := []float64{0.5, -1, 3}
+	tcs := []struct {
+		kernel   svm.Kernel
+		expected float64
+	}{
+		{svm.LinearKernel{}, -3},
+		{svm.PolynomialKernel{Gamma: 0.5, Coef0: 1, Degree: 2}, 0.25},
+		{svm.RadialBasisKernel{Gamma: 0.5}, 2.1445408316589164e-05},
+		{svm.SigmoidKernel{Gamma: 0.5, Coef0: 1}, -0.46211715726000974},
+	}
+	for _, tc := range tcs {
+		assert.InDelta(t, tc.expected, tc.kernel.Compute(x, y), 1e-12)
+	}
+}
+
+func TestInvalidModels(t *testing.T) {
+	tcs := []struct {
+		name     string
+		xml      string
+		expected string
+	}{
+		{
+			"unknown vector",
+			strings.Replace(binaryXML, `vectorId="2"`, `vectorId="3"`, 1),
+			"SupportVectorMachine 1 refers to unknown vector 3",
+		},
+		{
+			"vector length",
+			strings.Replace(binaryXML, `-1 0.5`, `-1 0.5 2`, 1),
+			"vector 2 has 3 entries, expected 2",
+		},
+		{
+			"coefficients",
+			strings.Replace(binaryXML, `<Coefficient value="0.9"/>`, ``, 1),
+			"SupportVectorMachine 1 has 1 coefficients for 2 support vectors",
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			var m svm.SupportVectorMachineModel
+			err := xml.Unmarshal([]byte(tc.xml), &m)
+			assert.EqualError(t, err, tc.expected)
+		})
+	}
+}
+
+//nolint
+func BenchmarkRegression(b *testing.B) {
+	var m svm.SupportVectorMachineModel
+	xml.Unmarshal([]byte(regressionXML), &m)
+	inputs := map[string]interface{}{"x1": 1.0, "x2": 2.0, "x3": 3.0}
+	for i := 0; i < b.N; i++ {
+		m.Evaluate(inputs)
+	}
+}
@@ -0,0 +1,171 @@
+package table
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Index finds the first row of a table whose cells in some columns equal a tuple of values. It hashes the
+// whole tuple, so lookups take the same time whatever the size of the table and the number of columns.
+// Cells and values are compared by the data type of their column: numbers of a numeric type equal the cells
+// which parse as the same number, so 7 matches "007" and "7.0", integers are compared exactly rather than as
+// floats, and strings are compared as written.
+type Index struct {
+	columns   []string
+	dataTypes []string
+	rows      map[string]Row
+}
+
+// NewIndex indexes rows by their cells in columns, whose values are of the PMML dataTypes; a column without
+// a data type compares strings as written and numbers by their shortest form. Rows which lack one of the
+// columns cannot be found.
+func NewIndex(rows []Row, columns, dataTypes []string) *Index {
+	ix := &Index{columns: columns, dataTypes: dataTypes, rows: make(map[string]Row, len(rows))}
+	values := make([]interface{}, len(columns))
+	for _, row := range rows {
+		complete := true
+		for i, column := range columns {
+			cell, ok := row[column]
+			complete = complete && ok
+			values[i] = cell
+		}
+		if !complete {
+			continue
+		}
+		k := ix.key(values)
+		if _, ok := ix.rows[k]; !ok {
+			ix.rows[k] = row
+		}
+	}
+	return ix
+}
+
+// Lookup returns the row whose cells equal values, in the order of the columns of the index.
+func (ix *Index) Lookup(values []interface{}) (Row, bool) {
+	if len(values) != len(ix.columns) {
+		return nil, false
+	}
+	row, ok := ix.rows[ix.key(values)]
+	return row, ok
+}
+
+// key joins the canonical forms of values with a separator which does not occur in text cells.
+func (ix *Index) key(values []interface{}) string {
+	var b strings.Builder
+	for i, v := range values {
+		if i > 0 {
+			b.WriteByte(0)
+		}
+		var dataType string
+		if i < len(ix.dataTypes) {
+			dataType = ix.dataTypes[i]
+		}
+		b.WriteString(canonical(dataType, v))
+	}
+	return b.String()
+}
+
+// canonical returns the form of a value which is the same for all the values of dataType equal to it.
+// Values which are not of dataType, such as a word in a numeric column, are compared as written.
+func canonical(dataType string, value interface{}) string {
+	switch dataType {
+	case "integer":
+		if i, ok := toInteger(value); ok {
+			return strconv.FormatInt(i, 10)
+		}
+	case "double", "float":
+		if f, ok := toNumber(value); ok {
+			return strconv.FormatFloat(f, 'g', -1, 64)
+		}
+	case "boolean":
+		if b, ok := toBool(value); ok {
+			return strconv.FormatBool(b)
+		}
+	}
+	return text(value)
+}
+
+func text(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 64)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return fmt.Sprint(value)
+}
+
+func toInteger(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case string:
+		s := strings.TrimSpace(v)
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i, true
+		}
+		f, ok := toNumber(s)
+		if !ok {
+			return 0, false
+		}
+		return toInteger(f)
+	case float64:
+		if v != math.Trunc(v) || math.Abs(v) >= 1<<63 {
+			return 0, false
+		}
+		return int64(v), true
+	case float32:
+		return toInteger(float64(v))
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	}
+	return 0, false
+}
+
+// toNumber parses finite numbers, so that words such as "nan" and "inf" are compared as written.
+func toNumber(value interface{}) (float64, bool) {
+	var f float64
+	switch v := value.(type) {
+	case string:
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return 0, false
+		}
+		f = parsed
+	case float64:
+		f = v
+	case float32:
+		f = float64(v)
+	case int:
+		f = float64(v)
+	case int64:
+		f = float64(v)
+	default:
+		return 0, false
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, false
+	}
+	return f, true
+}
+
+func toBool(value interface{}) (bool, bool) {
+	switch v := value.(type) {
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		return b, err == nil
+	case bool:
+		return v, true
+	}
+	return false, false
+}
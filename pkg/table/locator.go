@@ -0,0 +1,124 @@
+package table
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// TableLocator refers to a table stored outside of the document. pummel reads local CSV files, whose path is
+// given by an Extension named "path", e.g. <Extension name="path" value="lookup.csv"/>. The first line of the
+// file holds the column names, and empty cells are left out of their row, as in an InlineTable.
+type TableLocator struct {
+	XMLName xml.Name `xml:"TableLocator"`
+	// Path is the path of the file as written in the document.
+	Path string
+	Rows []Row
+
+	dir string
+}
+
+// ErrNoDirectory is returned for a TableLocator of a document which was not loaded from a directory, such as
+// one uploaded to the server, which may not read local files.
+var ErrNoDirectory = errors.New("TableLocator is only supported in documents loaded from a directory")
+
+// NewTableLocator returns an empty TableLocator to be decoded into, whose path is relative to dir. The path
+// must stay within dir, and without a dir the TableLocator is rejected with ErrNoDirectory.
+func NewTableLocator(dir string) *TableLocator {
+	return &TableLocator{dir: dir}
+}
+
+func (tl *TableLocator) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*tl = TableLocator{XMLName: start.Name, dir: tl.dir}
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := tok.(type) {
+		case xml.StartElement:
+			if tt.Name.Local == "Extension" && attr(tt, "name") == "path" {
+				tl.Path = attr(tt, "value")
+			}
+			if err := d.Skip(); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			if tl.Path == "" {
+				return errors.New("TableLocator has no path")
+			}
+			path, err := tl.resolve()
+			if err != nil {
+				return err
+			}
+			tl.Rows, err = readCSV(path)
+			if err != nil {
+				return errors.Wrapf(err, "cannot read table %s", tl.Path)
+			}
+			return nil
+		}
+	}
+}
+
+// resolve returns the path of the file within the directory of the document.
+func (tl *TableLocator) resolve() (string, error) {
+	if tl.dir == "" {
+		return "", ErrNoDirectory
+	}
+	path := filepath.Clean(filepath.FromSlash(tl.Path))
+	if filepath.IsAbs(path) || filepath.VolumeName(path) != "" || strings.HasPrefix(path, string(filepath.Separator)) ||
+		path == ".." || strings.HasPrefix(path, ".."+string(filepath.Separator)) {
+		return "", errors.Errorf("path %s of TableLocator is outside of the document directory", tl.Path)
+	}
+	return filepath.Join(tl.dir, path), nil
+}
+
+func attr(start xml.StartElement, name string) string {
+	for _, a := range start.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func readCSV(path string) ([]Row, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, errors.New("no header")
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i, column := range header {
+		header[i] = ColumnName(strings.TrimSpace(column))
+	}
+	rows := make([]Row, 0)
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		row := make(Row, len(record))
+		for i, cell := range record {
+			if cell = strings.TrimSpace(cell); cell != "" {
+				row[header[i]] = cell
+			}
+		}
+		rows = append(rows, row)
+	}
+}
@@ -0,0 +1,75 @@
+// Package table reads the tables used by PMML elements such as ModelVerification and MapValues, whether they are
+// inline or located in a file, and indexes them for lookups.
+package table
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// Row maps column names to cell values.
+// Columns are keyed by the local name of their element, without any namespace prefix.
+type Row map[string]string
+
+// InlineTable holds its rows directly in the document. Each row element has one child element per column,
+// whose content is the cell value.
+type InlineTable struct {
+	XMLName xml.Name `xml:"InlineTable"`
+	Rows    []Row
+}
+
+// ColumnName strips the namespace prefix from a column reference, such as "data:input",
+// so that it matches the keys of a Row.
+func ColumnName(column string) string {
+	if i := strings.LastIndexByte(column, ':'); i >= 0 {
+		return column[i+1:]
+	}
+	return column
+}
+
+func (t *InlineTable) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	t.XMLName = start.Name
+	t.Rows = make([]Row, 0)
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := tok.(type) {
+		case xml.StartElement:
+			if tt.Name.Local != "row" {
+				if err := d.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			row, err := decodeRow(d)
+			if err != nil {
+				return err
+			}
+			t.Rows = append(t.Rows, row)
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+func decodeRow(d *xml.Decoder) (Row, error) {
+	row := make(Row)
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch tt := tok.(type) {
+		case xml.StartElement:
+			var cell string
+			if err := d.DecodeElement(&cell, &tt); err != nil {
+				return nil, err
+			}
+			row[tt.Name.Local] = strings.TrimSpace(cell)
+		case xml.EndElement:
+			return row, nil
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package table_test
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stillmatic/pummel/pkg/table"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInlineTable(t *testing.T) {
+	xmlData := []byte(`<InlineTable xmlns:data="http://jpmml.org/jpmml-model/InlineTable">
+		<row>
+			<data:input>CATEGORY_0</data:input>
+			<data:output> 0 </data:output>
+		</row>
+		<Extension/>
+		<row><input>CATEGORY_1</input><output>1</output></row>
+		<row/>
+	</InlineTable>`)
+	var it table.InlineTable
+	assert.NoError(t, xml.Unmarshal(xmlData, &it))
+	assert.Equal(t, []table.Row{
+		{"input": "CATEGORY_0", "output": "0"},
+		{"input": "CATEGORY_1", "output": "1"},
+		{},
+	}, it.Rows)
+}
+
+func TestColumnName(t *testing.T) {
+	assert.Equal(t, "input", table.ColumnName("data:input"))
+	assert.Equal(t, "input", table.ColumnName("input"))
+}
+
+func TestTableLocator(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "lookup.csv"), []byte("data:input,output\nCATEGORY_0, 0\nCATEGORY_1,\n"), 0o644)
+	assert.NoError(t, err)
+	xmlData := []byte(`<TableLocator>
+		<Extension name="format" value="csv"/>
+		<Extension name="path" value="lookup.csv"/>
+	</TableLocator>`)
+
+	tl := table.NewTableLocator(dir)
+	assert.NoError(t, xml.Unmarshal(xmlData, tl))
+	assert.Equal(t, "lookup.csv", tl.Path)
+	assert.Equal(t, []table.Row{
+		{"input": "CATEGORY_0", "output": "0"},
+		{"input": "CATEGORY_1"},
+	}, tl.Rows)
+
+	// without a directory, no file is read
+	var cwd table.TableLocator
+	err = xml.Unmarshal(xmlData, &cwd)
+	assert.ErrorIs(t, err, table.ErrNoDirectory)
+	err = xml.Unmarshal([]byte(`<TableLocator><Extension name="format" value="csv"/></TableLocator>`), &cwd)
+	assert.EqualError(t, err, "TableLocator has no path")
+
+	// nor outside of the directory
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "tables"), 0o755))
+	for _, path := range []string{"../lookup.csv", "tables/../../lookup.csv", "..", filepath.Join(dir, "lookup.csv"), "/etc/passwd"} {
+		xmlData := []byte(`<TableLocator><Extension name="path" value="` + path + `"/></TableLocator>`)
+		err = xml.Unmarshal(xmlData, table.NewTableLocator(filepath.Join(dir, "tables")))
+		assert.EqualError(t, err, "path "+path+" of TableLocator is outside of the document directory")
+	}
+	err = xml.Unmarshal([]byte(`<TableLocator><Extension name="path" value="tables/../lookup.csv"/></TableLocator>`), table.NewTableLocator(dir))
+	assert.NoError(t, err)
+}
+
+func TestIndex(t *testing.T) {
+	rows := []table.Row{
+		{"state": "CA", "band": "1", "rate": "0.5"},
+		{"state": "CA", "band": "2.0", "rate": "0.75"},
+		{"state": "NY", "band": "1", "rate": "0.25"},
+		{"state": "CA", "band": "1", "rate": "0.1"},
+		{"state": "TX", "rate": "0.3"},
+		{"state": "007", "band": "nan", "rate": "0.9"},
+	}
+	ix := table.NewIndex(rows, []string{"state", "band"}, []string{"string", "double"})
+	tcs := []struct {
+		values   []interface{}
+		expected table.Row
+	}{
+		// the first of the matching rows
+		{[]interface{}{"CA", 1.0}, rows[0]},
+		{[]interface{}{"CA", "1"}, rows[0]},
+		{[]interface{}{"CA", 2}, rows[1]},
+		{[]interface{}{"NY", "1.0"}, rows[2]},
+		{[]interface{}{"NY", 2.0}, nil},
+		{[]interface{}{"TX", ""}, nil},
+		{[]interface{}{"CA"}, nil},
+		// strings are compared as written, and only finite numbers as numbers
+		{[]interface{}{"007", "nan"}, rows[5]},
+		{[]interface{}{"7", "nan"}, nil},
+		{[]interface{}{"007", "NaN"}, nil},
+	}
+	for _, tc := range tcs {
+		row, ok := ix.Lookup(tc.values)
+		assert.Equal(t, tc.expected != nil, ok, "%v", tc.values)
+		assert.Equal(t, tc.expected, row, "%v", tc.values)
+	}
+
+	// integers are compared exactly, beyond the precision of a float64
+	rows = []table.Row{
+		{"id": "9007199254740993", "name": "a"},
+		{"id": "9007199254740992", "name": "b"},
+		{"id": "007", "name": "c"},
+	}
+	ix = table.NewIndex(rows, []string{"id"}, []string{"integer"})
+	row, ok := ix.Lookup([]interface{}{"9007199254740993"})
+	assert.True(t, ok)
+	assert.Equal(t, "a", row["name"])
+	row, ok = ix.Lookup([]interface{}{7.0})
+	assert.True(t, ok)
+	assert.Equal(t, "c", row["name"])
+
+	// without a data type, numbers match their shortest form
+	ix = table.NewIndex(rows, []string{"id"}, nil)
+	_, ok = ix.Lookup([]interface{}{7})
+	assert.False(t, ok)
+	row, ok = ix.Lookup([]interface{}{"007"})
+	assert.True(t, ok)
+	assert.Equal(t, "c", row["name"])
+}
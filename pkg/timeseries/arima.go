@@ -0,0 +1,449 @@
+package timeseries
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+
+	"github.com/pkg/errors"
+	"github.com/stillmatic/pummel/pkg/array"
+)
+
+// ARIMA is a seasonal ARIMA(p,d,q)(P,D,Q)s model of the transformed series y:
+//
+//	φ(B)Φ(B^s)(1-B)^d(1-B^s)^D y(t) = c + θ(B)Θ(B^s) a(t)
+//
+// where B is the backshift operator, φ(B) = 1 - φ1 B - ... - φp B^p, and likewise for the other
+// polynomials. RMSE is the standard deviation of the innovations a.
+type ARIMA struct {
+	XMLName               xml.Name               `xml:"ARIMA"`
+	RMSE                  *float64               `xml:"RMSE,attr"`
+	Transformation        string                 `xml:"transformation,attr"`
+	ConstantTerm          float64                `xml:"constantTerm,attr"`
+	PredictionMethod      string                 `xml:"predictionMethod,attr"`
+	NonseasonalComponent  *NonseasonalComponent  `xml:"NonseasonalComponent"`
+	SeasonalComponent     *SeasonalComponent     `xml:"SeasonalComponent"`
+	DynamicRegressors     []*DynamicRegressor    `xml:"DynamicRegressor"`
+	MaximumLikelihoodStat *MaximumLikelihoodStat `xml:"MaximumLikelihoodStat"`
+	OutlierEffects        []*OutlierEffect       `xml:"OutlierEffect"`
+
+	// ar and ma are the coefficients of the expanded polynomials of each side of the model, starting with
+	// the coefficient 1 of B^0.
+	ar, ma []float64
+	// series holds the transformed history, less the outlier effects, and residuals its last innovations.
+	series, residuals []float64
+	// end is the index of the last value of the history.
+	end int
+}
+
+type NonseasonalComponent struct {
+	XMLName xml.Name     `xml:"NonseasonalComponent"`
+	P       int          `xml:"p,attr"`
+	D       int          `xml:"d,attr"`
+	Q       int          `xml:"q,attr"`
+	AR      *array.Array `xml:"AR>Array"`
+	MA      *MA          `xml:"MA"`
+}
+
+type SeasonalComponent struct {
+	XMLName xml.Name     `xml:"SeasonalComponent"`
+	P       int          `xml:"P,attr"`
+	D       int          `xml:"D,attr"`
+	Q       int          `xml:"Q,attr"`
+	Period  int          `xml:"period,attr"`
+	AR      *array.Array `xml:"AR>Array"`
+	MA      *MA          `xml:"MA"`
+}
+
+// MA holds the moving average coefficients of a component, and the last residuals of the series, the
+// latest last.
+type MA struct {
+	XMLName        xml.Name     `xml:"MA"`
+	MACoefficients *array.Array `xml:"MACoefficients>Array"`
+	Residuals      *array.Array `xml:"Residuals>Array"`
+}
+
+// DynamicRegressor is decoded so that models with one can be rejected.
+type DynamicRegressor struct {
+	XMLName xml.Name `xml:"DynamicRegressor"`
+	Field   string   `xml:"field,attr"`
+}
+
+type MaximumLikelihoodStat struct {
+	XMLName             xml.Name     `xml:"MaximumLikelihoodStat"`
+	Method              string       `xml:"method,attr"`
+	PeriodDeficit       int          `xml:"periodDeficit,attr"`
+	KalmanState         *KalmanState `xml:"KalmanState"`
+	ThetaRecursionState *struct{}    `xml:"ThetaRecursionState"`
+}
+
+// KalmanState is the state of the model at the end of the series, in the state space form of Harvey:
+// FinalStateVector is the state, FinalOmega its covariance and HVector the observation vector, which
+// defaults to (1, 0, ..., 0).
+type KalmanState struct {
+	XMLName          xml.Name      `xml:"KalmanState"`
+	FinalOmega       *array.Matrix `xml:"FinalOmega>Matrix"`
+	FinalStateVector *array.Array  `xml:"FinalStateVector>Array"`
+	HVector          *array.Array  `xml:"HVector>Array"`
+
+	state, h []float64
+}
+
+// OutlierEffect is an effect added to the series from StartTime on, an index of the series.
+type OutlierEffect struct {
+	XMLName            xml.Name `xml:"OutlierEffect"`
+	Type               string   `xml:"type,attr"`
+	StartTime          float64  `xml:"startTime,attr"`
+	Magnitude          float64  `xml:"magnitude,attr"`
+	DampingCoefficient float64  `xml:"dampingCoefficient,attr"`
+}
+
+var PredictionMethods = struct {
+	ConditionalLeastSquares string
+	ExactLeastSquares       string
+}{
+	ConditionalLeastSquares: "conditionalLeastSquares",
+	ExactLeastSquares:       "exactLeastSquares",
+}
+
+var OutlierTypes = struct {
+	Additive         string
+	Level            string
+	TransientChange  string
+	SeasonalAdditive string
+	Trend            string
+}{
+	Additive:         "additive",
+	Level:            "level",
+	TransientChange:  "transientChange",
+	SeasonalAdditive: "seasonalAdditive",
+	Trend:            "trend",
+}
+
+// lagPolynomial returns the coefficients of 1 - c1 B^s - c2 B^2s - ...
+func lagPolynomial(c []float64, s int) []float64 {
+	poly := make([]float64, len(c)*s+1)
+	poly[0] = 1
+	for i, v := range c {
+		poly[(i+1)*s] = -v
+	}
+	return poly
+}
+
+// multiply returns the product of two polynomials.
+func multiply(a, b []float64) []float64 {
+	prod := make([]float64, len(a)+len(b)-1)
+	for i, x := range a {
+		for j, y := range b {
+			prod[i+j] += x * y
+		}
+	}
+	return prod
+}
+
+// coefficients decodes the coefficients of a component, of which there must be n.
+func coefficients(a *array.Array, n int, name string) ([]float64, error) {
+	if a == nil {
+		if n > 0 {
+			return nil, fmt.Errorf("%s has no coefficients, expected %d", name, n)
+		}
+		return nil, nil
+	}
+	c, err := a.Floats()
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid coefficients of %s", name)
+	}
+	if len(c) != n {
+		return nil, fmt.Errorf("%s has %d coefficients, expected %d", name, len(c), n)
+	}
+	return c, nil
+}
+
+// component expands the polynomials of one component of the model, returning its residuals as well.
+func component(p, d, q, s int, ar *array.Array, ma *MA, name string) ([]float64, []float64, []float64, error) {
+	if p < 0 || d < 0 || q < 0 {
+		return nil, nil, nil, fmt.Errorf("orders of %s must not be negative", name)
+	}
+	phi, err := coefficients(ar, p, name+" AR")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var theta, residuals []float64
+	if ma != nil {
+		if theta, err = coefficients(ma.MACoefficients, q, name+" MA"); err != nil {
+			return nil, nil, nil, err
+		}
+		if ma.Residuals != nil {
+			if residuals, err = ma.Residuals.Floats(); err != nil {
+				return nil, nil, nil, errors.Wrapf(err, "invalid residuals of %s", name)
+			}
+		}
+	} else if q > 0 {
+		return nil, nil, nil, fmt.Errorf("%s MA has no coefficients, expected %d", name, q)
+	}
+	left := lagPolynomial(phi, s)
+	for i := 0; i < d; i++ {
+		left = multiply(left, lagPolynomial([]float64{1}, s))
+	}
+	return left, lagPolynomial(theta, s), residuals, nil
+}
+
+func (a *ARIMA) init(history []*TimeValue) error {
+	if _, err := transform(a.Transformation, 1); err != nil {
+		return err
+	}
+	switch a.PredictionMethod {
+	case "":
+		a.PredictionMethod = PredictionMethods.ConditionalLeastSquares
+	case PredictionMethods.ConditionalLeastSquares, PredictionMethods.ExactLeastSquares:
+	default:
+		return fmt.Errorf("unknown prediction method: %q", a.PredictionMethod)
+	}
+	if len(a.DynamicRegressors) > 0 {
+		return errors.New("unsupported DynamicRegressor")
+	}
+
+	a.ar, a.ma = []float64{1}, []float64{1}
+	if nc := a.NonseasonalComponent; nc != nil {
+		ar, ma, residuals, err := component(nc.P, nc.D, nc.Q, 1, nc.AR, nc.MA, "NonseasonalComponent")
+		if err != nil {
+			return err
+		}
+		a.ar, a.ma, a.residuals = multiply(a.ar, ar), multiply(a.ma, ma), residuals
+	}
+	if sc := a.SeasonalComponent; sc != nil {
+		if sc.Period < 1 {
+			return fmt.Errorf("SeasonalComponent needs a period of at least 1, got %d", sc.Period)
+		}
+		ar, ma, residuals, err := component(sc.P, sc.D, sc.Q, sc.Period, sc.AR, sc.MA, "SeasonalComponent")
+		if err != nil {
+			return err
+		}
+		a.ar, a.ma = multiply(a.ar, ar), multiply(a.ma, ma)
+		if len(residuals) > len(a.residuals) {
+			a.residuals = residuals
+		}
+	}
+	for _, oe := range a.OutlierEffects {
+		switch oe.Type {
+		case OutlierTypes.Additive, OutlierTypes.Level, OutlierTypes.TransientChange, OutlierTypes.Trend:
+		case OutlierTypes.SeasonalAdditive:
+			if a.SeasonalComponent == nil {
+				return errors.New("seasonalAdditive OutlierEffect needs a SeasonalComponent")
+			}
+		default:
+			return fmt.Errorf("unsupported type of OutlierEffect: %q", oe.Type)
+		}
+	}
+
+	a.series = make([]float64, len(history))
+	for i, tv := range history {
+		v, err := transform(a.Transformation, tv.Value)
+		if err != nil {
+			return errors.Wrapf(err, "invalid value %d of the series", tv.Index)
+		}
+		a.series[i] = v - a.outliers(float64(tv.Index))
+		a.end = tv.Index
+	}
+	if a.PredictionMethod == PredictionMethods.ExactLeastSquares {
+		return a.initKalman()
+	}
+	if len(a.series) < len(a.ar)-1 {
+		return fmt.Errorf("ARIMA needs at least %d values of the series, got %d", len(a.ar)-1, len(a.series))
+	}
+	return nil
+}
+
+// initKalman checks that the KalmanState of an exactLeastSquares model matches its orders.
+func (a *ARIMA) initKalman() error {
+	if a.MaximumLikelihoodStat == nil || a.MaximumLikelihoodStat.KalmanState == nil {
+		return errors.New("exactLeastSquares needs a KalmanState")
+	}
+	ks := a.MaximumLikelihoodStat.KalmanState
+	if ks.FinalStateVector == nil || ks.FinalOmega == nil {
+		return errors.New("KalmanState needs a FinalStateVector and a FinalOmega")
+	}
+	var err error
+	if ks.state, err = ks.FinalStateVector.Floats(); err != nil {
+		return errors.Wrap(err, "invalid FinalStateVector")
+	}
+	r := len(ks.state)
+	if r < len(a.ar)-1 || r < len(a.ma) {
+		return fmt.Errorf("KalmanState has %d states, expected %d", r, maxInt(len(a.ar)-1, len(a.ma)))
+	}
+	if len(ks.FinalOmega.Rows) != r || len(ks.FinalOmega.Rows[0]) != r {
+		return fmt.Errorf("FinalOmega must be a %d by %d matrix", r, r)
+	}
+	ks.h = make([]float64, r)
+	ks.h[0] = 1
+	if ks.HVector != nil {
+		if ks.h, err = ks.HVector.Floats(); err != nil {
+			return errors.Wrap(err, "invalid HVector")
+		}
+		if len(ks.h) != r {
+			return fmt.Errorf("HVector has %d values, expected %d", len(ks.h), r)
+		}
+	}
+	return nil
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func (a *ARIMA) transformation() string {
+	return a.Transformation
+}
+
+// outliers returns the sum of the outlier effects at time t.
+func (a *ARIMA) outliers(t float64) float64 {
+	var sum float64
+	for _, oe := range a.OutlierEffects {
+		if t < oe.StartTime {
+			continue
+		}
+		switch oe.Type {
+		case OutlierTypes.Additive:
+			if t == oe.StartTime {
+				sum += oe.Magnitude
+			}
+		case OutlierTypes.Level:
+			sum += oe.Magnitude
+		case OutlierTypes.TransientChange:
+			sum += oe.Magnitude * math.Pow(oe.DampingCoefficient, t-oe.StartTime)
+		case OutlierTypes.SeasonalAdditive:
+			if math.Mod(t-oe.StartTime, float64(a.SeasonalComponent.Period)) == 0 {
+				sum += oe.Magnitude
+			}
+		case OutlierTypes.Trend:
+			sum += oe.Magnitude * (t - oe.StartTime + 1)
+		}
+	}
+	return sum
+}
+
+func (a *ARIMA) forecast(h int) (float64, float64, bool) {
+	var value, variance float64
+	if a.PredictionMethod == PredictionMethods.ExactLeastSquares {
+		value, variance = a.kalmanForecast(h)
+	} else {
+		value, variance = a.conditionalForecast(h)
+	}
+	value += a.outliers(float64(a.end + h))
+	return value, variance, a.RMSE != nil
+}
+
+// conditionalForecast runs the model over the future, taking the innovations before the series to be 0,
+// as well as those past its end. The variance is σ² times the sum of the squared weights ψ of the
+// innovations of the h periods up to the forecast.
+func (a *ARIMA) conditionalForecast(h int) (float64, float64) {
+	n := len(a.series)
+	y := make([]float64, n, n+h)
+	copy(y, a.series)
+	e := make([]float64, n+h)
+	for i, r := range a.residuals {
+		if j := n - len(a.residuals) + i; j >= 0 {
+			e[j] = r
+		}
+	}
+	for t := n; t < n+h; t++ {
+		v := a.ConstantTerm
+		for k := 1; k < len(a.ar); k++ {
+			v -= a.ar[k] * y[t-k]
+		}
+		for k := 1; k < len(a.ma); k++ {
+			if t-k >= 0 {
+				v += a.ma[k] * e[t-k]
+			}
+		}
+		y = append(y, v)
+	}
+	if a.RMSE == nil {
+		return y[n+h-1], 0
+	}
+
+	psi := make([]float64, h)
+	sum := 0.0
+	for j := range psi {
+		if j == 0 {
+			psi[j] = 1
+		} else if j < len(a.ma) {
+			psi[j] = a.ma[j]
+		}
+		for k := 1; k <= j && k < len(a.ar); k++ {
+			psi[j] -= a.ar[k] * psi[j-k]
+		}
+		sum += psi[j] * psi[j]
+	}
+	return y[n+h-1], *a.RMSE * *a.RMSE * sum
+}
+
+// kalmanForecast propagates the final state h periods ahead, with the transition matrix T whose first
+// column holds the autoregressive coefficients and whose superdiagonal is 1, and the innovations entering
+// through R = (1, θ1, ..., θr-1). The covariance P of the state grows as TPT' + σ²RR'.
+func (a *ARIMA) kalmanForecast(h int) (float64, float64) {
+	ks := a.MaximumLikelihoodStat.KalmanState
+	r := len(ks.state)
+	phi := make([]float64, r)
+	for k := 1; k < len(a.ar); k++ {
+		phi[k-1] = -a.ar[k]
+	}
+	R := make([]float64, r)
+	copy(R, a.ma)
+	var sigma2 float64
+	if a.RMSE != nil {
+		sigma2 = *a.RMSE * *a.RMSE
+	}
+
+	state := append([]float64(nil), ks.state...)
+	P := make([][]float64, r)
+	for i := range P {
+		P[i] = append([]float64(nil), ks.FinalOmega.Rows[i]...)
+	}
+	// transition returns Tx.
+	transition := func(x []float64) []float64 {
+		next := make([]float64, r)
+		for i := 0; i < r; i++ {
+			next[i] = phi[i] * x[0]
+			if i+1 < r {
+				next[i] += x[i+1]
+			}
+		}
+		return next
+	}
+	for step := 0; step < h; step++ {
+		state = transition(state)
+		state[0] += a.ConstantTerm
+		// TPT' is T applied to the columns, then to the rows, of the symmetric P
+		TP := make([][]float64, r)
+		for j := 0; j < r; j++ {
+			col := make([]float64, r)
+			for i := 0; i < r; i++ {
+				col[i] = P[i][j]
+			}
+			TP[j] = transition(col)
+		}
+		for i := 0; i < r; i++ {
+			row := make([]float64, r)
+			for j := 0; j < r; j++ {
+				row[j] = TP[j][i]
+			}
+			P[i] = transition(row)
+			for j := 0; j < r; j++ {
+				P[i][j] += sigma2 * R[i] * R[j]
+			}
+		}
+	}
+
+	var value, variance float64
+	for i := 0; i < r; i++ {
+		value += ks.h[i] * state[i]
+		for j := 0; j < r; j++ {
+			variance += ks.h[i] * P[i][j] * ks.h[j]
+		}
+	}
+	return value, variance
+}
@@ -0,0 +1,189 @@
+package timeseries
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+
+	"github.com/pkg/errors"
+	"github.com/stillmatic/pummel/pkg/array"
+)
+
+// ExponentialSmoothing forecasts from the smoothed level, trend and seasonal indices at the end of the
+// series, in the error-correction form of Gardner. RMSE is the standard deviation of the one-step errors.
+type ExponentialSmoothing struct {
+	XMLName        xml.Name     `xml:"ExponentialSmoothing"`
+	RMSE           *float64     `xml:"RMSE,attr"`
+	Transformation string       `xml:"transformation,attr"`
+	Level          *Level       `xml:"Level"`
+	Trend          *Trend       `xml:"Trend_ExpoSmooth"`
+	Seasonality    *Seasonality `xml:"Seasonality_ExpoSmooth"`
+
+	// seasonal holds the seasonal indices.
+	seasonal []float64
+}
+
+type Level struct {
+	XMLName       xml.Name `xml:"Level"`
+	Alpha         float64  `xml:"alpha,attr"`
+	SmoothedValue float64  `xml:"smoothedValue,attr"`
+}
+
+// Trend is the Trend_ExpoSmooth element. Phi is the damping factor of damped trends, and defaults to 1.
+type Trend struct {
+	XMLName       xml.Name `xml:"Trend_ExpoSmooth"`
+	Trend         string   `xml:"trend,attr"`
+	Gamma         float64  `xml:"gamma,attr"`
+	Phi           *float64 `xml:"phi,attr"`
+	SmoothedValue float64  `xml:"smoothedValue,attr"`
+}
+
+// Seasonality is the Seasonality_ExpoSmooth element. Its Array holds the seasonal index of each position of
+// the period, and Phase is the position of the last value of the series, which defaults to the period.
+type Seasonality struct {
+	XMLName xml.Name     `xml:"Seasonality_ExpoSmooth"`
+	Type    string       `xml:"type,attr"`
+	Period  int          `xml:"period,attr"`
+	Unit    string       `xml:"unit,attr"`
+	Phase   *int         `xml:"phase,attr"`
+	Delta   float64      `xml:"delta,attr"`
+	Array   *array.Array `xml:"Array"`
+}
+
+var Trends = struct {
+	Additive              string
+	DampedAdditive        string
+	Multiplicative        string
+	DampedMultiplicative  string
+	PolynomialExponential string
+}{
+	Additive:              "additive",
+	DampedAdditive:        "damped_additive",
+	Multiplicative:        "multiplicative",
+	DampedMultiplicative:  "damped_multiplicative",
+	PolynomialExponential: "polynomial_exponential",
+}
+
+var SeasonalityTypes = struct {
+	Additive       string
+	Multiplicative string
+}{
+	Additive:       "additive",
+	Multiplicative: "multiplicative",
+}
+
+func (e *ExponentialSmoothing) init() error {
+	if _, err := transform(e.Transformation, 1); err != nil {
+		return err
+	}
+	if e.Level == nil {
+		return errors.New("ExponentialSmoothing has no Level")
+	}
+	if e.Trend != nil {
+		switch e.Trend.Trend {
+		case Trends.Additive, Trends.DampedAdditive, Trends.Multiplicative, Trends.DampedMultiplicative:
+		case Trends.PolynomialExponential:
+			return fmt.Errorf("unsupported trend: %q", e.Trend.Trend)
+		default:
+			return fmt.Errorf("unknown trend: %q", e.Trend.Trend)
+		}
+	}
+	if s := e.Seasonality; s != nil {
+		switch s.Type {
+		case SeasonalityTypes.Additive, SeasonalityTypes.Multiplicative:
+		default:
+			return fmt.Errorf("unknown type of seasonality: %q", s.Type)
+		}
+		if s.Period < 1 {
+			return fmt.Errorf("seasonality needs a period of at least 1, got %d", s.Period)
+		}
+		if s.Array == nil {
+			return errors.New("seasonality has no Array")
+		}
+		var err error
+		if e.seasonal, err = s.Array.Floats(); err != nil {
+			return errors.Wrap(err, "invalid seasonal indices")
+		}
+		if len(e.seasonal) != s.Period {
+			return fmt.Errorf("seasonality has %d indices, expected %d", len(e.seasonal), s.Period)
+		}
+		if s.Phase != nil && (*s.Phase < 1 || *s.Phase > s.Period) {
+			return fmt.Errorf("phase of seasonality must be between 1 and %d, got %d", s.Period, *s.Phase)
+		}
+	}
+	return nil
+}
+
+func (e *ExponentialSmoothing) transformation() string {
+	return e.Transformation
+}
+
+// phi returns the damping factor of the trend, which is 1 for undamped trends.
+func (e *ExponentialSmoothing) phi() float64 {
+	if e.Trend == nil || e.Trend.Phi == nil {
+		return 1
+	}
+	switch e.Trend.Trend {
+	case Trends.DampedAdditive, Trends.DampedMultiplicative:
+		return *e.Trend.Phi
+	}
+	return 1
+}
+
+// damping returns the sum of phi^i for i from 1 to h, which is h for undamped trends.
+func (e *ExponentialSmoothing) damping(h int) float64 {
+	phi := e.phi()
+	var sum, p float64 = 0, 1
+	for i := 0; i < h; i++ {
+		p *= phi
+		sum += p
+	}
+	return sum
+}
+
+// forecast combines the level, the trend h periods ahead and the seasonal index of the period of the
+// forecast. The variance is defined for models without multiplicative components, as RMSE² times 1 plus the
+// sum of the squared weights of the errors of the h-1 periods before the forecast.
+func (e *ExponentialSmoothing) forecast(h int) (float64, float64, bool) {
+	value := e.Level.SmoothedValue
+	multiplicative := false
+	if e.Trend != nil {
+		switch e.Trend.Trend {
+		case Trends.Additive, Trends.DampedAdditive:
+			value += e.damping(h) * e.Trend.SmoothedValue
+		default:
+			value *= math.Pow(e.Trend.SmoothedValue, e.damping(h))
+			multiplicative = true
+		}
+	}
+	if s := e.Seasonality; s != nil {
+		phase := s.Period
+		if s.Phase != nil {
+			phase = *s.Phase
+		}
+		index := e.seasonal[(phase+h-1)%s.Period]
+		if s.Type == SeasonalityTypes.Multiplicative {
+			value *= index
+			multiplicative = true
+		} else {
+			value += index
+		}
+	}
+	if e.RMSE == nil || multiplicative {
+		return value, 0, false
+	}
+
+	alpha := e.Level.Alpha
+	sum := 1.0
+	for j := 1; j < h; j++ {
+		c := alpha
+		if e.Trend != nil {
+			c += alpha * e.Trend.Gamma * e.damping(j)
+		}
+		if s := e.Seasonality; s != nil && j%s.Period == 0 {
+			c += s.Delta * (1 - alpha)
+		}
+		sum += c * c
+	}
+	return value, *e.RMSE * *e.RMSE * sum, true
+}
@@ -0,0 +1,387 @@
+// Package timeseries implements the TimeSeriesModel element, which forecasts a series with exponential
+// smoothing or an ARIMA model.
+package timeseries
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/stillmatic/pummel/pkg/fields"
+	"github.com/stillmatic/pummel/pkg/miningschema"
+	"github.com/stillmatic/pummel/pkg/transformations"
+	"github.com/stillmatic/pummel/pkg/verification"
+)
+
+// TimeSeriesModel forecasts its target field with the algorithm named by BestFit. The forecast horizon,
+// the number of periods past the end of the series, is the value of the active field of the model, and
+// defaults to 1 if it has none.
+type TimeSeriesModel struct {
+	XMLName              xml.Name                              `xml:"TimeSeriesModel"`
+	ModelName            string                                `xml:"modelName,attr"`
+	FunctionName         string                                `xml:"functionName,attr"`
+	AlgorithmName        string                                `xml:"algorithmName,attr"`
+	BestFit              string                                `xml:"bestFit,attr"`
+	IsScorable           bool                                  `xml:"isScorable,attr"`
+	MiningSchema         *miningschema.MiningSchema            `xml:"MiningSchema"`
+	Output               *fields.Outputs                       `xml:"Output"`
+	LocalTransformations *transformations.LocalTransformations `xml:"LocalTransformations"`
+	TimeSeries           []*TimeSeries                         `xml:"TimeSeries"`
+	ExponentialSmoothing *ExponentialSmoothing                 `xml:"ExponentialSmoothing"`
+	ARIMA                *ARIMA                                `xml:"ARIMA"`
+	ModelVerification    *verification.ModelVerification       `xml:"ModelVerification"`
+
+	// horizonField is the active field holding the forecast horizon.
+	horizonField string
+	forecaster   forecaster
+	doc          *transformations.Document
+}
+
+// NewTimeSeriesModel returns an empty TimeSeriesModel to be decoded into, whose expressions belong to doc.
+func NewTimeSeriesModel(doc *transformations.Document) *TimeSeriesModel {
+	return &TimeSeriesModel{doc: doc}
+}
+
+var BestFits = struct {
+	ARIMA                      string
+	ExponentialSmoothing       string
+	SeasonalTrendDecomposition string
+	SpectralAnalysis           string
+	StateSpaceModel            string
+	GARCH                      string
+}{
+	ARIMA:                      "ARIMA",
+	ExponentialSmoothing:       "ExponentialSmoothing",
+	SeasonalTrendDecomposition: "SeasonalTrendDecomposition",
+	SpectralAnalysis:           "SpectralAnalysis",
+	StateSpaceModel:            "StateSpaceModel",
+	GARCH:                      "GARCH",
+}
+
+var Transformations = struct {
+	None        string
+	Logarithmic string
+	SquareRoot  string
+}{
+	None:        "none",
+	Logarithmic: "logarithmic",
+	SquareRoot:  "squareroot",
+}
+
+// TimeSeries holds values of the series. Those of the original series, the default usage, are the history
+// an ARIMA model forecasts from.
+type TimeSeries struct {
+	XMLName    xml.Name     `xml:"TimeSeries"`
+	Usage      string       `xml:"usage,attr"`
+	StartTime  *float64     `xml:"startTime,attr"`
+	EndTime    *float64     `xml:"endTime,attr"`
+	TimeValues []*TimeValue `xml:"TimeValue"`
+}
+
+// TimeValue is a value of a series. Index defaults to the 1-based position of the value in its series.
+type TimeValue struct {
+	XMLName       xml.Name `xml:"TimeValue"`
+	Index         int      `xml:"index,attr"`
+	Time          *float64 `xml:"time,attr"`
+	Value         float64  `xml:"value,attr"`
+	StandardError *float64 `xml:"standardError,attr"`
+}
+
+// forecaster is implemented by the algorithms of a TimeSeriesModel.
+type forecaster interface {
+	// forecast returns the forecast h periods past the end of the series, on the scale of the
+	// transformation of the algorithm, and the variance of its error if it is defined.
+	forecast(h int) (value, variance float64, hasVariance bool)
+	transformation() string
+}
+
+func (m *TimeSeriesModel) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*m = TimeSeriesModel{XMLName: start.Name, doc: m.doc}
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "modelName":
+			m.ModelName = attr.Value
+		case "functionName":
+			m.FunctionName = attr.Value
+		case "algorithmName":
+			m.AlgorithmName = attr.Value
+		case "bestFit":
+			m.BestFit = attr.Value
+		case "isScorable":
+			m.IsScorable = attr.Value == "true"
+		}
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "MiningSchema":
+				var ms miningschema.MiningSchema
+				if err := d.DecodeElement(&ms, &tt); err != nil {
+					return err
+				}
+				m.MiningSchema = &ms
+			case "Output":
+				out := fields.NewOutputs(m.doc)
+				if err := d.DecodeElement(out, &tt); err != nil {
+					return err
+				}
+				m.Output = out
+			case "LocalTransformations":
+				lt := m.doc.NewLocalTransformations()
+				if err := d.DecodeElement(lt, &tt); err != nil {
+					return err
+				}
+				m.LocalTransformations = lt
+			case "TimeSeries":
+				var ts TimeSeries
+				if err := d.DecodeElement(&ts, &tt); err != nil {
+					return err
+				}
+				m.TimeSeries = append(m.TimeSeries, &ts)
+			case "ExponentialSmoothing":
+				var es ExponentialSmoothing
+				if err := d.DecodeElement(&es, &tt); err != nil {
+					return err
+				}
+				m.ExponentialSmoothing = &es
+			case "ARIMA":
+				var a ARIMA
+				if err := d.DecodeElement(&a, &tt); err != nil {
+					return err
+				}
+				m.ARIMA = &a
+			case "ModelVerification":
+				var mv verification.ModelVerification
+				if err := d.DecodeElement(&mv, &tt); err != nil {
+					return err
+				}
+				m.ModelVerification = &mv
+			case "Extension", "ModelStats", "ModelExplanation", "SpectralAnalysis",
+				"SeasonalTrendDecomposition", "StateSpaceModel", "GARCH":
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unknown element: %s", tt.Name.Local)
+			}
+		case xml.EndElement:
+			return m.resolve()
+		}
+	}
+}
+
+// resolve finds the horizon field and prepares the algorithm of the best fit.
+func (m *TimeSeriesModel) resolve() error {
+	if m.MiningSchema == nil {
+		return errors.New("TimeSeriesModel has no MiningSchema")
+	}
+	for _, mf := range m.MiningSchema.MiningFields {
+		if mf.UsageType == "" || mf.UsageType == "active" {
+			if m.horizonField != "" {
+				return fmt.Errorf("TimeSeriesModel has several active fields: %s and %s", m.horizonField, mf.Name)
+			}
+			m.horizonField = mf.Name
+		}
+	}
+	switch m.BestFit {
+	case BestFits.ExponentialSmoothing:
+		if m.ExponentialSmoothing == nil {
+			return errors.New("TimeSeriesModel has no ExponentialSmoothing")
+		}
+		if err := m.ExponentialSmoothing.init(); err != nil {
+			return err
+		}
+		m.forecaster = m.ExponentialSmoothing
+	case BestFits.ARIMA:
+		if m.ARIMA == nil {
+			return errors.New("TimeSeriesModel has no ARIMA")
+		}
+		if err := m.ARIMA.init(m.history()); err != nil {
+			return err
+		}
+		m.forecaster = m.ARIMA
+	default:
+		return fmt.Errorf("unsupported best fit: %q", m.BestFit)
+	}
+	if m.Output != nil {
+		for _, of := range m.Output.OutputFields {
+			if of.Feature == "confidenceIntervalLower" || of.Feature == "confidenceIntervalUpper" {
+				if _, err := confidenceLevel(of); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// history returns the values of the original series, numbering those without an index.
+func (m *TimeSeriesModel) history() []*TimeValue {
+	for _, ts := range m.TimeSeries {
+		if ts.Usage != "" && ts.Usage != "original" {
+			continue
+		}
+		for i, tv := range ts.TimeValues {
+			if tv.Index == 0 {
+				tv.Index = i + 1
+			}
+		}
+		return ts.TimeValues
+	}
+	return nil
+}
+
+// confidenceLevel returns the level, in percent, of the prediction interval bounded by an output field.
+// It is the value of the field, and defaults to 95.
+func confidenceLevel(of *fields.OutputField) (float64, error) {
+	if of.Value == "" {
+		return 95, nil
+	}
+	level, err := strconv.ParseFloat(of.Value, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid confidence level of OutputField %s", of.Name)
+	}
+	if level <= 0 || level >= 100 {
+		return 0, fmt.Errorf("confidence level of OutputField %s must be between 0 and 100, got %v", of.Name, level)
+	}
+	return level, nil
+}
+
+// backTransform undoes the transformation of the series an algorithm was fitted to.
+func backTransform(transformation string, x float64) float64 {
+	switch transformation {
+	case Transformations.Logarithmic:
+		return math.Exp(x)
+	case Transformations.SquareRoot:
+		if x < 0 {
+			return 0
+		}
+		return x * x
+	}
+	return x
+}
+
+// transform applies the transformation of an algorithm to a value of the series.
+func transform(transformation string, x float64) (float64, error) {
+	switch transformation {
+	case Transformations.Logarithmic:
+		if x <= 0 {
+			return 0, fmt.Errorf("cannot take the logarithm of %v", x)
+		}
+		return math.Log(x), nil
+	case Transformations.SquareRoot:
+		if x < 0 {
+			return 0, fmt.Errorf("cannot take the square root of %v", x)
+		}
+		return math.Sqrt(x), nil
+	case "", Transformations.None:
+		return x, nil
+	}
+	return 0, fmt.Errorf("unknown transformation: %q", transformation)
+}
+
+// horizon returns the forecast horizon of a record, which is nil if it is missing.
+func (m *TimeSeriesModel) horizon(values map[string]interface{}) (*int, error) {
+	h := 1
+	if m.horizonField == "" {
+		return &h, nil
+	}
+	raw := values[m.horizonField]
+	if raw == nil {
+		return nil, nil
+	}
+	f, err := transformations.InterfaceToFloat64(raw)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid forecast horizon")
+	}
+	if f < 1 || f != math.Trunc(f) {
+		return nil, fmt.Errorf("forecast horizon must be a positive integer, got %v", raw)
+	}
+	h = int(f)
+	return &h, nil
+}
+
+// Evaluate forecasts the series at the horizon of the record. Besides the forecast, the output fields may
+// return its standardError, which is only defined for an additive model of an untransformed series, and
+// the bounds of its prediction interval, confidenceIntervalLower and confidenceIntervalUpper, which are
+// defined for additive models. Both are nil otherwise, and if the model has no RMSE.
+func (m *TimeSeriesModel) Evaluate(values map[string]interface{}) (map[string]interface{}, error) {
+	out, err := m.evaluate(values)
+	return m.Output.Complete(values, out, err)
+}
+
+func (m *TimeSeriesModel) evaluate(values map[string]interface{}) (map[string]interface{}, error) {
+	if m.LocalTransformations != nil {
+		for _, tr := range m.LocalTransformations.DerivedFields {
+			val, err := tr.Transform(values)
+			if err != nil {
+				return nil, err
+			}
+			values[tr.RequiredField()] = val
+		}
+	}
+	h, err := m.horizon(values)
+	if err != nil || h == nil {
+		return nil, err
+	}
+	value, variance, hasVariance := m.forecaster.forecast(*h)
+	tr := m.forecaster.transformation()
+	forecast := backTransform(tr, value)
+
+	out := make(map[string]interface{})
+	if target := m.GetOutputField(); target != "" {
+		out[target] = forecast
+	}
+	if m.Output == nil {
+		return out, nil
+	}
+	for _, of := range m.Output.OutputFields {
+		switch of.Feature {
+		case "predictedValue":
+			out[of.Name] = forecast
+		case "standardError":
+			out[of.Name] = nil
+			if hasVariance && (tr == "" || tr == Transformations.None) {
+				out[of.Name] = math.Sqrt(variance)
+			}
+		case "confidenceIntervalLower", "confidenceIntervalUpper":
+			out[of.Name] = nil
+			if !hasVariance {
+				continue
+			}
+			level, err := confidenceLevel(of)
+			if err != nil {
+				return nil, err
+			}
+			width := math.Sqrt2 * math.Erfinv(level/100) * math.Sqrt(variance)
+			if of.Feature == "confidenceIntervalLower" {
+				width = -width
+			}
+			out[of.Name] = backTransform(tr, value+width)
+		}
+	}
+	return out, nil
+}
+
+func (m *TimeSeriesModel) GetOutputField() string {
+	return m.MiningSchema.GetOutputField()
+}
+
+func (m *TimeSeriesModel) GetMiningSchema() *miningschema.MiningSchema {
+	return m.MiningSchema
+}
+
+func (m *TimeSeriesModel) GetOutput() *fields.Outputs {
+	return m.Output
+}
+
+func (m *TimeSeriesModel) GetModelVerification() *verification.ModelVerification {
+	return m.ModelVerification
+}
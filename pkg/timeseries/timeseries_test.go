@@ -0,0 +1,250 @@
+package timeseries_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/stillmatic/pummel/pkg/timeseries"
+	"github.com/stretchr/testify/assert"
+)
+
+var smoothingXML = `<TimeSeriesModel functionName="timeSeries" bestFit="ExponentialSmoothing">
+	<MiningSchema>
+		<MiningField name="sales" usageType="target"/>
+		<MiningField name="h"/>
+	</MiningSchema>
+	<Output>
+		<OutputField name="forecast" feature="predictedValue"/>
+		<OutputField name="se" feature="standardError"/>
+		<OutputField name="lower" feature="confidenceIntervalLower"/>
+		<OutputField name="upper" feature="confidenceIntervalUpper" value="95"/>
+	</Output>
+	<ExponentialSmoothing RMSE="3">
+		<Level alpha="0.4" smoothedValue="100"/>
+		<Trend_ExpoSmooth trend="damped_additive" gamma="0.3" phi="0.9" smoothedValue="2"/>
+		<Seasonality_ExpoSmooth type="additive" period="4" phase="2" delta="0.2">
+			<Array n="4" type="real">1.5 -2 0.5 0</Array>
+		</Seasonality_ExpoSmooth>
+	</ExponentialSmoothing>
+</TimeSeriesModel>`
+
+func TestExponentialSmoothing(t *testing.T) {
+	var m timeseries.TimeSeriesModel
+	err := xml.Unmarshal([]byte(smoothingXML), &m)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.9, *m.ExponentialSmoothing.Trend.Phi)
+
+	tcs := []struct {
+		h            float64
+		forecast, se float64
+		lower, upper float64
+	}{
+		{1, 102.3, 3, 96.42010804637984, 108.17989195362016},
+		{3, 106.378, 3.823477391066933, 98.88412201780565, 113.87187798219435},
+		// the errors of the same season a period before add to the error of the forecast
+		{4, 104.1902, 4.351692728306997, 95.66103898073345, 112.71936101926656},
+		{6, 108.434062, 5.698556719915119, 97.26509606510766, 119.60302793489234},
+	}
+	for _, tc := range tcs {
+		out, err := m.Evaluate(map[string]interface{}{"h": tc.h})
+		assert.NoError(t, err)
+		assert.InDelta(t, tc.forecast, out["sales"], 1e-9)
+		assert.InDelta(t, tc.forecast, out["forecast"], 1e-9)
+		assert.InDelta(t, tc.se, out["se"], 1e-9)
+		assert.InDelta(t, tc.lower, out["lower"], 1e-9)
+		assert.InDelta(t, tc.upper, out["upper"], 1e-9)
+	}
+
+	// a missing horizon gives no forecast
+	out, err := m.Evaluate(map[string]interface{}{})
+	assert.NoError(t, err)
+	assert.Nil(t, out)
+	_, err = m.Evaluate(map[string]interface{}{"h": 1.5})
+	assert.EqualError(t, err, "forecast horizon must be a positive integer, got 1.5")
+	_, err = m.Evaluate(map[string]interface{}{"h": 0.0})
+	assert.EqualError(t, err, "forecast horizon must be a positive integer, got 0")
+
+	// the variance of models with multiplicative components is not defined
+	err = xml.Unmarshal([]byte(strings.Replace(smoothingXML, `type="additive"`, `type="multiplicative"`, 1)), &m)
+	assert.NoError(t, err)
+	out, err = m.Evaluate(map[string]interface{}{"h": 1.0})
+	assert.NoError(t, err)
+	// (100 + 0.9*2) * 0.5
+	assert.InDelta(t, 50.9, out["forecast"], 1e-9)
+	assert.Nil(t, out["se"])
+	assert.Nil(t, out["lower"])
+
+	// a logarithmic series has intervals, but no standard error
+	noTrend := `<TimeSeriesModel functionName="timeSeries" bestFit="ExponentialSmoothing">
+	<MiningSchema>
+		<MiningField name="sales" usageType="target"/>
+	</MiningSchema>
+	<Output>
+		<OutputField name="se" feature="standardError"/>
+		<OutputField name="lower" feature="confidenceIntervalLower" value="50"/>
+	</Output>
+	<ExponentialSmoothing RMSE="0.5" transformation="logarithmic">
+		<Level alpha="0.4" smoothedValue="2"/>
+	</ExponentialSmoothing>
+</TimeSeriesModel>`
+	err = xml.Unmarshal([]byte(noTrend), &m)
+	assert.NoError(t, err)
+	out, err = m.Evaluate(map[string]interface{}{})
+	assert.NoError(t, err)
+	// without a horizon field, the model forecasts one period ahead
+	assert.InDelta(t, 7.38905609893065, out["sales"], 1e-12)
+	assert.Nil(t, out["se"])
+	// exp(2 - 0.6744897501960817*0.5)
+	assert.InDelta(t, 5.27382088202605, out["lower"], 1e-12)
+}
+
+var arimaXML = `<TimeSeriesModel functionName="timeSeries" bestFit="ARIMA">
+	<MiningSchema>
+		<MiningField name="y" usageType="target"/>
+		<MiningField name="h"/>
+	</MiningSchema>
+	<Output>
+		<OutputField name="forecast" feature="predictedValue"/>
+		<OutputField name="se" feature="standardError"/>
+		<OutputField name="lower" feature="confidenceIntervalLower" value="90"/>
+		<OutputField name="upper" feature="confidenceIntervalUpper" value="90"/>
+	</Output>
+	<TimeSeries usage="original">
+		<TimeValue index="1" value="10"/>
+		<TimeValue index="2" value="12"/>
+		<TimeValue index="3" value="11"/>
+		<TimeValue index="4" value="14"/>
+		<TimeValue index="5" value="13"/>
+		<TimeValue index="6" value="15"/>
+		<TimeValue index="7" value="14"/>
+		<TimeValue index="8" value="17"/>
+		<TimeValue index="9" value="19"/>
+		<TimeValue index="10" value="21"/>
+		<TimeValue index="11" value="20"/>
+		<TimeValue index="12" value="23"/>
+		<TimeValue index="13" value="22"/>
+		<TimeValue index="14" value="24"/>
+		<TimeValue index="15" value="23"/>
+		<TimeValue index="16" value="26"/>
+	</TimeSeries>
+	<ARIMA RMSE="1.5" constantTerm="0.2">
+		<NonseasonalComponent p="1" d="1" q="1">
+			<AR><Array n="1" type="real">0.5</Array></AR>
+			<MA>
+				<MACoefficients><Array n="1" type="real">0.3</Array></MACoefficients>
+				<Residuals><Array type="real">-0.3 0.4</Array></Residuals>
+			</MA>
+		</NonseasonalComponent>
+		<SeasonalComponent P="1" D="0" Q="0" period="4">
+			<AR><Array n="1" type="real">0.4</Array></AR>
+		</SeasonalComponent>
+		<OutlierEffect type="level" startTime="9" magnitude="3"/>
+	</ARIMA>
+</TimeSeriesModel>`
+
+var arimaCases = []struct {
+	h            float64
+	forecast, se float64
+	lower, upper float64
+}{
+	{1, 26.58, 1.5, 24.112719559572792, 29.047280440427205},
+	{2, 28.07, 2.3430749027719964, 24.215984747956515, 31.924015252043485},
+	{5, 30.55575, 4.525707817568429, 23.11162308174994, 37.99987691825006},
+}
+
+func TestARIMA(t *testing.T) {
+	var m timeseries.TimeSeriesModel
+	err := xml.Unmarshal([]byte(arimaXML), &m)
+	assert.NoError(t, err)
+	assert.Equal(t, "conditionalLeastSquares", m.ARIMA.PredictionMethod)
+	for _, tc := range arimaCases {
+		out, err := m.Evaluate(map[string]interface{}{"h": tc.h})
+		assert.NoError(t, err)
+		assert.InDelta(t, tc.forecast, out["y"], 1e-9)
+		assert.InDelta(t, tc.se, out["se"], 1e-9)
+		assert.InDelta(t, tc.lower, out["lower"], 1e-9)
+		assert.InDelta(t, tc.upper, out["upper"], 1e-9)
+	}
+}
+
+// TestExactLeastSquares checks that a Kalman state known without error, built from the same history,
+// forecasts like conditional least squares.
+func TestExactLeastSquares(t *testing.T) {
+	exact := strings.Replace(arimaXML, `<ARIMA `, `<ARIMA predictionMethod="exactLeastSquares" `, 1)
+	exact = strings.Replace(exact, `<OutlierEffect`, `<MaximumLikelihoodStat method="kalman">
+			<KalmanState>
+				<FinalOmega><Matrix kind="diagonal"><Array type="real">0 0 0 0 0 0</Array></Matrix></FinalOmega>
+				<FinalStateVector><Array type="real">23 -11.12 1 -0.8 -7.8 4</Array></FinalStateVector>
+			</KalmanState>
+		</MaximumLikelihoodStat>
+		<OutlierEffect`, 1)
+	var m timeseries.TimeSeriesModel
+	err := xml.Unmarshal([]byte(exact), &m)
+	assert.NoError(t, err)
+	for _, tc := range arimaCases {
+		out, err := m.Evaluate(map[string]interface{}{"h": tc.h})
+		assert.NoError(t, err)
+		assert.InDelta(t, tc.forecast, out["y"], 1e-9)
+		assert.InDelta(t, tc.se, out["se"], 1e-9)
+	}
+
+	// the uncertainty of the final state adds to the variance of the forecast
+	uncertain := strings.Replace(exact, `0 0 0 0 0 0`, `0.75 0 0 0 0 0`, 1)
+	err = xml.Unmarshal([]byte(uncertain), &m)
+	assert.NoError(t, err)
+	out, err := m.Evaluate(map[string]interface{}{"h": 1.0})
+	assert.NoError(t, err)
+	// the first autoregressive coefficient of the expanded model is 1.5, so the variance is 1.5² * 0.75 + 1.5²
+	assert.InDelta(t, 26.58, out["y"], 1e-9)
+	assert.InDelta(t, 1.984313483298443, out["se"], 1e-9)
+}
+
+func TestTimeSeriesErrors(t *testing.T) {
+	tcs := []struct {
+		old, new string
+		expected string
+	}{
+		{`bestFit="ARIMA"`, `bestFit="GARCH"`, `unsupported best fit: "GARCH"`},
+		{`<TimeValue index="16" value="26"/>`, ``, ""},
+		{`p="1" d="1"`, `p="2" d="1"`, "NonseasonalComponent AR has 1 coefficients, expected 2"},
+		{`<ARIMA `, `<ARIMA predictionMethod="exactLeastSquares" `, "exactLeastSquares needs a KalmanState"},
+		{`<ARIMA `, `<ARIMA predictionMethod="kalman" `, `unknown prediction method: "kalman"`},
+		{`<ARIMA `, `<ARIMA transformation="exp" `, `unknown transformation: "exp"`},
+		{`type="level"`, `type="interventionEffect"`, `unsupported type of OutlierEffect: "interventionEffect"`},
+		{`<OutlierEffect`, `<DynamicRegressor field="x"/><OutlierEffect`, "unsupported DynamicRegressor"},
+		{`value="90"/>`, `value="100"/>`, "confidence level of OutputField lower must be between 0 and 100, got 100"},
+		{`<MiningField name="h"/>`, `<MiningField name="h"/><MiningField name="x"/>`, "TimeSeriesModel has several active fields: h and x"},
+		{`<TimeSeries usage`, `<Segmentation/><TimeSeries usage`, "unknown element: Segmentation"},
+	}
+	for _, tc := range tcs {
+		var m timeseries.TimeSeriesModel
+		err := xml.Unmarshal([]byte(strings.Replace(arimaXML, tc.old, tc.new, 1)), &m)
+		if tc.expected == "" {
+			assert.NoError(t, err)
+			continue
+		}
+		assert.EqualError(t, err, tc.expected)
+	}
+
+	// the model needs as many values of the series as its autoregressive order
+	short := arimaXML[:strings.Index(arimaXML, `<TimeValue index="2"`)] + arimaXML[strings.Index(arimaXML, `</TimeSeries>`):]
+	var m timeseries.TimeSeriesModel
+	err := xml.Unmarshal([]byte(short), &m)
+	assert.EqualError(t, err, "ARIMA needs at least 6 values of the series, got 1")
+
+	tcs = []struct {
+		old, new string
+		expected string
+	}{
+		{`trend="damped_additive"`, `trend="polynomial_exponential"`, `unsupported trend: "polynomial_exponential"`},
+		{`period="4" phase="2"`, `period="3" phase="2"`, "seasonality has 4 indices, expected 3"},
+		{`phase="2"`, `phase="5"`, "phase of seasonality must be between 1 and 4, got 5"},
+		{`<Level alpha="0.4" smoothedValue="100"/>`, ``, "ExponentialSmoothing has no Level"},
+	}
+	for _, tc := range tcs {
+		var m timeseries.TimeSeriesModel
+		err := xml.Unmarshal([]byte(strings.Replace(smoothingXML, tc.old, tc.new, 1)), &m)
+		assert.EqualError(t, err, tc.expected)
+	}
+}
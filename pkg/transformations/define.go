@@ -0,0 +1,166 @@
+package transformations
+
+import (
+	"encoding/xml"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// DefineFunction is a function of the TransformationDictionary, which Apply elements call like a built-in
+// function. Its expression may only refer to its ParameterFields, which are bound to the arguments of the call.
+type DefineFunction struct {
+	XMLName         xml.Name `xml:"DefineFunction"`
+	Name            string   `xml:"name,attr"`
+	OpType          string   `xml:"optype,attr"`
+	DataType        string   `xml:"dataType,attr"`
+	ParameterFields []*ParameterField
+	Expression      Expression
+
+	doc *Document
+}
+
+type ParameterField struct {
+	XMLName     xml.Name `xml:"ParameterField"`
+	Name        string   `xml:"name,attr"`
+	OpType      string   `xml:"optype,attr"`
+	DataType    string   `xml:"dataType,attr"`
+	DisplayName string   `xml:"displayName,attr"`
+}
+
+func (td *TransformationDictionary) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*td = TransformationDictionary{XMLName: start.Name, doc: td.doc}
+	if td.doc == nil {
+		// a dictionary decoded on its own still calls the functions it defines
+		td.doc = NewDocument("")
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "DefineFunction":
+				df := DefineFunction{doc: td.doc}
+				if err := d.DecodeElement(&df, &tt); err != nil {
+					return err
+				}
+				if _, ok := td.doc.lookupFunction(df.Name); ok {
+					return fmt.Errorf("function %s is already defined", df.Name)
+				}
+				td.DefineFunctions = append(td.DefineFunctions, &df)
+				td.doc.functions[df.Name] = df.function()
+			case "DerivedField":
+				df := DerivedField{doc: td.doc}
+				if err := d.DecodeElement(&df, &tt); err != nil {
+					return errors.Wrapf(err, "invalid DerivedField %s", df.Name)
+				}
+				td.DerivedFields = append(td.DerivedFields, &df)
+			case "Extension":
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unexpected element in TransformationDictionary: %s", tt.Name.Local)
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+func (df *DefineFunction) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*df = DefineFunction{XMLName: start.Name, doc: df.doc}
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "name":
+			df.Name = attr.Value
+		case "optype":
+			df.OpType = attr.Value
+		case "dataType":
+			df.DataType = attr.Value
+		}
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "ParameterField":
+				var pf ParameterField
+				if err := d.DecodeElement(&pf, &tt); err != nil {
+					return err
+				}
+				df.ParameterFields = append(df.ParameterFields, &pf)
+			case "Extension":
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			default:
+				expr := df.doc.NewExpression(tt.Name.Local)
+				if expr == nil {
+					return fmt.Errorf("unexpected element in DefineFunction: %s", tt.Name.Local)
+				}
+				if err := d.DecodeElement(expr, &tt); err != nil {
+					return errors.Wrapf(err, "invalid DefineFunction %s", df.Name)
+				}
+				df.Expression = expr
+			}
+		case xml.EndElement:
+			switch {
+			case df.Name == "":
+				return errors.New("DefineFunction has no name")
+			case len(df.ParameterFields) == 0:
+				return fmt.Errorf("DefineFunction %s has no ParameterField", df.Name)
+			case df.Expression == nil:
+				return fmt.Errorf("DefineFunction %s has no expression", df.Name)
+			}
+			return nil
+		}
+	}
+}
+
+// Call binds the parameters of the function to args and evaluates its expression. Numeric parameters and
+// results are converted to numbers.
+func (df *DefineFunction) Call(args []interface{}) (interface{}, error) {
+	if len(args) != len(df.ParameterFields) {
+		return nil, fmt.Errorf("function %s takes %d arguments, got %d", df.Name, len(df.ParameterFields), len(args))
+	}
+	params := make(map[string]interface{}, len(args))
+	for i, pf := range df.ParameterFields {
+		arg, err := convert(pf.DataType, args[i])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s of function %s", pf.Name, df.Name)
+		}
+		params[pf.Name] = arg
+	}
+	res, err := df.Expression.Transform(params)
+	if err != nil {
+		return nil, err
+	}
+	return convert(df.DataType, res)
+}
+
+// function wraps the DefineFunction for Apply. Its arguments are passed even if they are missing, since its
+// expression may handle them.
+func (df *DefineFunction) function() *function {
+	n := len(df.ParameterFields)
+	return &function{n, n, acceptMissing, df.Call}
+}
+
+// convert converts a value which is not missing to a numeric data type.
+func convert(dataType string, value interface{}) (interface{}, error) {
+	if isMissing(value) {
+		return value, nil
+	}
+	switch dataType {
+	case "double", "float", "integer":
+		return toFloat(value)
+	}
+	return value, nil
+}
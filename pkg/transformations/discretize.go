@@ -0,0 +1,181 @@
+package transformations
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// Discretize maps a continuous field onto the value of the first bin whose interval contains it.
+// Values outside of every bin yield DefaultValue, and missing values yield MapMissingTo; either is
+// missing if it is not set.
+type Discretize struct {
+	XMLName      xml.Name `xml:"Discretize"`
+	Field        string   `xml:"field,attr"`
+	MapMissingTo *string  `xml:"mapMissingTo,attr"`
+	DefaultValue *string  `xml:"defaultValue,attr"`
+	DataType     string   `xml:"dataType,attr"`
+	Bins         []*DiscretizeBin
+}
+
+type DiscretizeBin struct {
+	XMLName  xml.Name  `xml:"DiscretizeBin"`
+	BinValue string    `xml:"binValue,attr"`
+	Interval *Interval `xml:"Interval"`
+}
+
+var Closures = struct {
+	OpenClosed   string
+	OpenOpen     string
+	ClosedOpen   string
+	ClosedClosed string
+}{
+	OpenClosed:   "openClosed",
+	OpenOpen:     "openOpen",
+	ClosedOpen:   "closedOpen",
+	ClosedClosed: "closedClosed",
+}
+
+// Interval is a range of numbers. A margin which is not set is infinite.
+type Interval struct {
+	XMLName     xml.Name `xml:"Interval"`
+	Closure     string   `xml:"closure,attr"`
+	LeftMargin  *float64 `xml:"leftMargin,attr"`
+	RightMargin *float64 `xml:"rightMargin,attr"`
+}
+
+func (i *Interval) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	i.XMLName = start.Name
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "closure":
+			i.Closure = attr.Value
+		case "leftMargin", "rightMargin":
+			val, err := strconv.ParseFloat(attr.Value, 64)
+			if err != nil {
+				return errors.Wrapf(err, "invalid %s of Interval", attr.Name.Local)
+			}
+			if attr.Name.Local == "leftMargin" {
+				i.LeftMargin = &val
+			} else {
+				i.RightMargin = &val
+			}
+		}
+	}
+	switch i.Closure {
+	case Closures.OpenClosed, Closures.OpenOpen, Closures.ClosedOpen, Closures.ClosedClosed:
+	default:
+		return fmt.Errorf("unknown closure of Interval: %s", i.Closure)
+	}
+	return d.Skip()
+}
+
+// Contains reports whether x lies in the interval.
+func (i *Interval) Contains(x float64) bool {
+	left, right := math.Inf(-1), math.Inf(1)
+	if i.LeftMargin != nil {
+		left = *i.LeftMargin
+	}
+	if i.RightMargin != nil {
+		right = *i.RightMargin
+	}
+	switch i.Closure {
+	case Closures.OpenClosed:
+		return left < x && x <= right
+	case Closures.OpenOpen:
+		return left < x && x < right
+	case Closures.ClosedOpen:
+		return left <= x && x < right
+	default:
+		return left <= x && x <= right
+	}
+}
+
+func (dz *Discretize) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	dz.XMLName = start.Name
+	for _, attr := range start.Attr {
+		value := attr.Value
+		switch attr.Name.Local {
+		case "field":
+			dz.Field = value
+		case "mapMissingTo":
+			dz.MapMissingTo = &value
+		case "defaultValue":
+			dz.DefaultValue = &value
+		case "dataType":
+			dz.DataType = value
+		}
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "DiscretizeBin":
+				var bin DiscretizeBin
+				if err := d.DecodeElement(&bin, &tt); err != nil {
+					return err
+				}
+				if bin.Interval == nil {
+					return fmt.Errorf("DiscretizeBin %s has no Interval", bin.BinValue)
+				}
+				dz.Bins = append(dz.Bins, &bin)
+			case "Extension":
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unexpected element in Discretize: %s", tt.Name.Local)
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+func (dz *Discretize) Transform(values map[string]interface{}) (interface{}, error) {
+	value := values[dz.Field]
+	if isMissing(value) {
+		return dz.result(dz.MapMissingTo)
+	}
+	x, err := InterfaceToFloat64(value)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid value of field %s", dz.Field)
+	}
+	for _, bin := range dz.Bins {
+		if bin.Interval.Contains(x) {
+			return dz.result(&bin.BinValue)
+		}
+	}
+	return dz.result(dz.DefaultValue)
+}
+
+// result converts a bin value to the data type of the Discretize.
+func (dz *Discretize) result(value *string) (interface{}, error) {
+	return typedValue(dz.DataType, value)
+}
+
+// typedValue converts the value of a bin or a table cell to dataType, which defaults to string.
+// A value which is not set is missing.
+func typedValue(dataType string, value *string) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	switch dataType {
+	case "double", "float":
+		return strconv.ParseFloat(*value, 64)
+	case "integer":
+		return strconv.Atoi(*value)
+	}
+	return *value, nil
+}
+
+func (dz *Discretize) RequiredField() string {
+	return dz.Field
+}
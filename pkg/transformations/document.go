@@ -0,0 +1,115 @@
+package transformations
+
+import (
+	"github.com/stillmatic/pummel/pkg/table"
+)
+
+// Document holds what the elements of a PMML document share while it is decoded: the functions defined by
+// its TransformationDictionary, the data types of its fields, the tokenizers of its TextIndex elements, and
+// the directory its TableLocators refer to. The decoder of each element hands the Document to the elements it contains. Elements decoded
+// without one only call the built-in functions, and do not share their tokenizers.
+type Document struct {
+	tableDir   string
+	functions  map[string]*function
+	dataTypes  map[string]string
+	tokenizers map[string]*tokenizer
+}
+
+// NewDocument returns the context of a document whose TableLocators refer to files in tableDir.
+func NewDocument(tableDir string) *Document {
+	return &Document{
+		tableDir:   tableDir,
+		functions:  make(map[string]*function),
+		dataTypes:  make(map[string]string),
+		tokenizers: make(map[string]*tokenizer),
+	}
+}
+
+// DeclareField records the data type of a field of the document, such as a DataField, for the elements
+// decoded after it which refer to the field.
+func (doc *Document) DeclareField(name, dataType string) {
+	if doc != nil && dataType != "" {
+		doc.dataTypes[name] = dataType
+	}
+}
+
+// dataType returns the data type of the named field, or "" if it has not been declared.
+func (doc *Document) dataType(name string) string {
+	if doc == nil {
+		return ""
+	}
+	return doc.dataTypes[name]
+}
+
+// NewExpression returns an empty expression of the document for the named element, to be decoded into,
+// or nil if the element is not an expression.
+func (doc *Document) NewExpression(name string) Expression {
+	switch name {
+	case "FieldRef":
+		return &FieldRef{}
+	case "Apply":
+		return &Apply{doc: doc}
+	case "Constant":
+		return &Constant{}
+	case "NormContinuous":
+		return &NormContinuous{}
+	case "NormDiscrete":
+		return &NormDiscrete{}
+	case "Discretize":
+		return &Discretize{}
+	case "MapValues":
+		return &MapValues{doc: doc}
+	case "TextIndex":
+		return &TextIndex{doc: doc}
+	}
+	return nil
+}
+
+// NewDerivedField returns an empty DerivedField of the document, to be decoded into.
+func (doc *Document) NewDerivedField() *DerivedField {
+	return &DerivedField{doc: doc}
+}
+
+// NewLocalTransformations returns empty LocalTransformations of the document, to be decoded into.
+func (doc *Document) NewLocalTransformations() *LocalTransformations {
+	return &LocalTransformations{doc: doc}
+}
+
+// NewTransformationDictionary returns an empty TransformationDictionary of the document, to be decoded into.
+// The functions it defines are callable from the elements of the document decoded after it.
+func (doc *Document) NewTransformationDictionary() *TransformationDictionary {
+	return &TransformationDictionary{doc: doc}
+}
+
+// newTableLocator returns an empty TableLocator of the document, to be decoded into.
+func (doc *Document) newTableLocator() *table.TableLocator {
+	if doc == nil {
+		return table.NewTableLocator("")
+	}
+	return table.NewTableLocator(doc.tableDir)
+}
+
+// lookupFunction returns the built-in function or the function defined in the document named name.
+func (doc *Document) lookupFunction(name string) (*function, bool) {
+	if f, ok := functions[name]; ok {
+		return f, true
+	}
+	if doc == nil {
+		return nil, false
+	}
+	f, ok := doc.functions[name]
+	return f, ok
+}
+
+// shareTokenizer returns the tokenizer of the document which splits text the same way as tk, or tk if it is
+// the first one.
+func (doc *Document) shareTokenizer(tk *tokenizer) *tokenizer {
+	if doc == nil {
+		return tk
+	}
+	if shared, ok := doc.tokenizers[tk.key]; ok {
+		return shared
+	}
+	doc.tokenizers[tk.key] = tk
+	return tk
+}
@@ -0,0 +1,533 @@
+package transformations
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// function is a built-in function of Apply, which takes between minArgs and maxArgs arguments; a negative
+// maxArgs does not limit them. The arguments are evaluated before eval is called.
+type function struct {
+	minArgs, maxArgs int
+	missing          missingTreatment
+	eval             func(args []interface{}) (interface{}, error)
+}
+
+// missingTreatment is how a function treats missing arguments.
+type missingTreatment int
+
+const (
+	// propagateMissing functions are not called if any argument is missing: their result is missing.
+	propagateMissing missingTreatment = iota
+	// ignoreMissing functions are called with the arguments which are not missing, unless none is.
+	ignoreMissing
+	// acceptMissing functions are called with every argument, missing or not.
+	acceptMissing
+)
+
+// invalidValueError is returned by functions whose arguments are not in their domain, e.g. a string
+// given to an arithmetic function. How it is handled depends on the invalidValueTreatment of the Apply.
+type invalidValueError struct {
+	msg string
+}
+
+func (e *invalidValueError) Error() string {
+	return e.msg
+}
+
+func invalid(format string, args ...interface{}) error {
+	return &invalidValueError{msg: fmt.Sprintf(format, args...)}
+}
+
+// functions are the built-in functions of PMML.
+var functions = map[string]*function{
+	"+": numeric(2, 2, func(x []float64) float64 { return x[0] + x[1] }),
+	"-": numeric(2, 2, func(x []float64) float64 { return x[0] - x[1] }),
+	"*": numeric(2, 2, func(x []float64) float64 { return x[0] * x[1] }),
+	"/": numeric(2, 2, func(x []float64) float64 { return x[0] / x[1] }),
+
+	"min":     aggregate(func(x []float64) float64 { return reduce(x, math.Min) }),
+	"max":     aggregate(func(x []float64) float64 { return reduce(x, math.Max) }),
+	"sum":     aggregate(func(x []float64) float64 { return reduce(x, add) }),
+	"avg":     aggregate(func(x []float64) float64 { return reduce(x, add) / float64(len(x)) }),
+	"median":  aggregate(median),
+	"product": aggregate(func(x []float64) float64 { return reduce(x, multiply) }),
+
+	"log10":        unary(math.Log10),
+	"ln":           unary(math.Log),
+	"exp":          unary(math.Exp),
+	"sqrt":         unary(math.Sqrt),
+	"abs":          unary(math.Abs),
+	"floor":        unary(math.Floor),
+	"ceil":         unary(math.Ceil),
+	"round":        unary(func(x float64) float64 { return math.Floor(x + 0.5) }),
+	"rint":         unary(math.RoundToEven),
+	"expm1":        unary(math.Expm1),
+	"log1p":        unary(math.Log1p),
+	"sin":          unary(math.Sin),
+	"cos":          unary(math.Cos),
+	"tan":          unary(math.Tan),
+	"asin":         unary(math.Asin),
+	"acos":         unary(math.Acos),
+	"atan":         unary(math.Atan),
+	"sinh":         unary(math.Sinh),
+	"cosh":         unary(math.Cosh),
+	"tanh":         unary(math.Tanh),
+	"erf":          unary(math.Erf),
+	"stdNormalCDF": unary(func(x float64) float64 { return normalCDF(x, 0, 1) }),
+	"stdNormalPDF": unary(func(x float64) float64 { return normalPDF(x, 0, 1) }),
+	"stdNormalIDF": unary(func(p float64) float64 { return normalIDF(p, 0, 1) }),
+	"pow":          numeric(2, 2, func(x []float64) float64 { return math.Pow(x[0], x[1]) }),
+	"threshold":    numeric(2, 2, func(x []float64) float64 { return boolToFloat(x[0] > x[1]) }),
+	"modulo":       numeric(2, 2, func(x []float64) float64 { return x[0] - math.Floor(x[0]/x[1])*x[1] }),
+	"atan2":        numeric(2, 2, func(x []float64) float64 { return math.Atan2(x[0], x[1]) }),
+	"hypot":        numeric(2, 2, func(x []float64) float64 { return math.Hypot(x[0], x[1]) }),
+	"normalCDF":    numeric(3, 3, func(x []float64) float64 { return normalCDF(x[0], x[1], x[2]) }),
+	"normalPDF":    numeric(3, 3, func(x []float64) float64 { return normalPDF(x[0], x[1], x[2]) }),
+	"normalIDF":    numeric(3, 3, func(x []float64) float64 { return normalIDF(x[0], x[1], x[2]) }),
+
+	// without the DataDictionary, every value which is not missing is taken to be valid
+	"isMissing":    {1, 1, acceptMissing, func(args []interface{}) (interface{}, error) { return isMissing(args[0]), nil }},
+	"isNotMissing": {1, 1, acceptMissing, func(args []interface{}) (interface{}, error) { return !isMissing(args[0]), nil }},
+	"isValid":      {1, 1, acceptMissing, func(args []interface{}) (interface{}, error) { return !isMissing(args[0]), nil }},
+	"isNotValid":   {1, 1, acceptMissing, func(args []interface{}) (interface{}, error) { return isMissing(args[0]), nil }},
+
+	"equal":          {2, 2, propagateMissing, func(args []interface{}) (interface{}, error) { return valuesEqual(args[0], args[1]), nil }},
+	"notEqual":       {2, 2, propagateMissing, func(args []interface{}) (interface{}, error) { return !valuesEqual(args[0], args[1]), nil }},
+	"lessThan":       comparison(func(c int) bool { return c < 0 }),
+	"lessOrEqual":    comparison(func(c int) bool { return c <= 0 }),
+	"greaterThan":    comparison(func(c int) bool { return c > 0 }),
+	"greaterOrEqual": comparison(func(c int) bool { return c >= 0 }),
+	"isIn":           {2, -1, propagateMissing, func(args []interface{}) (interface{}, error) { return isIn(args), nil }},
+	"isNotIn":        {2, -1, propagateMissing, func(args []interface{}) (interface{}, error) { return !isIn(args), nil }},
+	"and":            {2, -1, acceptMissing, func(args []interface{}) (interface{}, error) { return logical(args, false) }},
+	"or":             {2, -1, acceptMissing, func(args []interface{}) (interface{}, error) { return logical(args, true) }},
+	"not": {1, 1, propagateMissing, func(args []interface{}) (interface{}, error) {
+		b, err := toBool(args[0])
+		return !b, err
+	}},
+	// if is evaluated by Apply itself, so that only the branch it returns is evaluated
+	"if": {2, 3, acceptMissing, nil},
+
+	"uppercase":    text(func(s string) interface{} { return strings.ToUpper(s) }),
+	"lowercase":    text(func(s string) interface{} { return strings.ToLower(s) }),
+	"trimBlanks":   text(func(s string) interface{} { return strings.TrimSpace(s) }),
+	"stringLength": text(func(s string) interface{} { return float64(len([]rune(s))) }),
+	"substring":    {3, 3, propagateMissing, substring},
+	"concat": {2, -1, propagateMissing, func(args []interface{}) (interface{}, error) {
+		var sb strings.Builder
+		for _, arg := range args {
+			sb.WriteString(toString(arg))
+		}
+		return sb.String(), nil
+	}},
+	"replace": {3, 3, propagateMissing, func(args []interface{}) (interface{}, error) {
+		re, err := compilePattern(toString(args[1]))
+		if err != nil {
+			return nil, err
+		}
+		return re.ReplaceAllString(toString(args[0]), toString(args[2])), nil
+	}},
+	"matches": {2, 2, propagateMissing, func(args []interface{}) (interface{}, error) {
+		re, err := compilePattern(toString(args[1]))
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString(toString(args[0])), nil
+	}},
+	"formatNumber": {2, 2, propagateMissing, formatNumber},
+
+	"formatDatetime":           {2, 2, propagateMissing, formatDatetime},
+	"dateDaysSinceYear":        {2, 2, propagateMissing, sinceYear(24 * time.Hour)},
+	"dateSecondsSinceYear":     {2, 2, propagateMissing, sinceYear(time.Second)},
+	"dateSecondsSinceMidnight": {1, 1, propagateMissing, secondsSinceMidnight},
+}
+
+// IsFunction reports whether name is a built-in function of Apply.
+func IsFunction(name string) bool {
+	_, ok := functions[name]
+	return ok
+}
+
+// Functions returns the names of the built-in functions of Apply, sorted.
+func Functions() []string {
+	names := make([]string, 0, len(functions))
+	for name := range functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkArity returns an error if a built-in function cannot take n arguments.
+func (f *function) checkArity(name string, n int) error {
+	var expected string
+	switch {
+	case f.minArgs == f.maxArgs:
+		expected = fmt.Sprintf("%d", f.minArgs)
+	case f.maxArgs < 0:
+		expected = fmt.Sprintf("at least %d", f.minArgs)
+	default:
+		expected = fmt.Sprintf("%d to %d", f.minArgs, f.maxArgs)
+	}
+	if n < f.minArgs || (f.maxArgs >= 0 && n > f.maxArgs) {
+		return fmt.Errorf("function %s takes %s arguments, got %d", name, expected, n)
+	}
+	return nil
+}
+
+// numeric is a function of numbers, whose arguments are invalid if they are not numbers. Its result is
+// returned as computed, so that e.g. dividing by zero is +Inf.
+func numeric(minArgs, maxArgs int, fn func(x []float64) float64) *function {
+	return &function{minArgs, maxArgs, propagateMissing, func(args []interface{}) (interface{}, error) {
+		x, err := toFloats(args)
+		if err != nil {
+			return nil, err
+		}
+		return fn(x), nil
+	}}
+}
+
+func unary(fn func(x float64) float64) *function {
+	return numeric(1, 1, func(x []float64) float64 { return fn(x[0]) })
+}
+
+// aggregate is a numeric function of any number of arguments, which ignores the missing ones.
+func aggregate(fn func(x []float64) float64) *function {
+	f := numeric(1, -1, fn)
+	f.missing = ignoreMissing
+	return f
+}
+
+func reduce(x []float64, fn func(a, b float64) float64) float64 {
+	res := x[0]
+	for _, v := range x[1:] {
+		res = fn(res, v)
+	}
+	return res
+}
+
+func add(a, b float64) float64      { return a + b }
+func multiply(a, b float64) float64 { return a * b }
+
+func median(x []float64) float64 {
+	sorted := append([]float64(nil), x...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+func normalCDF(x, mean, stdev float64) float64 {
+	return 0.5 * math.Erfc(-(x-mean)/(stdev*math.Sqrt2))
+}
+
+func normalPDF(x, mean, stdev float64) float64 {
+	z := (x - mean) / stdev
+	return math.Exp(-z*z/2) / (stdev * math.Sqrt(2*math.Pi))
+}
+
+func normalIDF(p, mean, stdev float64) float64 {
+	return mean + stdev*math.Sqrt2*math.Erfinv(2*p-1)
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func comparison(fn func(c int) bool) *function {
+	return &function{2, 2, propagateMissing, func(args []interface{}) (interface{}, error) {
+		c, err := compare(args[0], args[1])
+		if err != nil {
+			return nil, err
+		}
+		return fn(c), nil
+	}}
+}
+
+// compare compares two values numerically, or as strings if either is not a number.
+func compare(a, b interface{}) (int, error) {
+	x, errA := toFloat(a)
+	y, errB := toFloat(b)
+	if errA == nil && errB == nil {
+		switch {
+		case x < y:
+			return -1, nil
+		case x > y:
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return strings.Compare(toString(a), toString(b)), nil
+}
+
+// valuesEqual compares two values numerically if both are numbers, and as strings otherwise.
+func valuesEqual(a, b interface{}) bool {
+	if s, ok := b.(string); ok {
+		return EqualsValue(a, s)
+	}
+	if s, ok := a.(string); ok {
+		return EqualsValue(b, s)
+	}
+	c, _ := compare(a, b)
+	return c == 0
+}
+
+func isIn(args []interface{}) bool {
+	for _, arg := range args[1:] {
+		if valuesEqual(args[0], arg) {
+			return true
+		}
+	}
+	return false
+}
+
+// logical is the three-valued and, or or if decisive is set: a missing argument only makes the result missing
+// if no other argument decides it.
+func logical(args []interface{}, decisive bool) (interface{}, error) {
+	var missing bool
+	for _, arg := range args {
+		if isMissing(arg) {
+			missing = true
+			continue
+		}
+		b, err := toBool(arg)
+		if err != nil {
+			return nil, err
+		}
+		if b == decisive {
+			return decisive, nil
+		}
+	}
+	if missing {
+		return nil, nil
+	}
+	return !decisive, nil
+}
+
+func text(fn func(s string) interface{}) *function {
+	return &function{1, 1, propagateMissing, func(args []interface{}) (interface{}, error) {
+		return fn(toString(args[0])), nil
+	}}
+}
+
+// substring returns the characters of its first argument from the 1-based position of its second, as many
+// as its third.
+func substring(args []interface{}) (interface{}, error) {
+	s := []rune(toString(args[0]))
+	x, err := toFloats(args[1:])
+	if err != nil {
+		return nil, err
+	}
+	start, length := int(x[0]), int(x[1])
+	if start < 1 || length < 0 {
+		return nil, invalid("invalid substring from %d of length %d", start, length)
+	}
+	if start > len(s) {
+		return "", nil
+	}
+	end := start - 1 + length
+	if end > len(s) {
+		end = len(s)
+	}
+	return string(s[start-1 : end]), nil
+}
+
+var patterns sync.Map
+
+// compilePattern compiles a regular expression once, since patterns are nearly always constants.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, invalid("invalid pattern %q: %v", pattern, err)
+	}
+	patterns.Store(pattern, re)
+	return re, nil
+}
+
+// formatNumber formats a number by a printf pattern, e.g. "%.2f" or "%3d".
+func formatNumber(args []interface{}) (interface{}, error) {
+	x, err := toFloat(args[0])
+	if err != nil {
+		return nil, err
+	}
+	pattern := toString(args[1])
+	i := strings.LastIndexAny(pattern, "diouxXcfFeEgGs")
+	if i < 0 {
+		return nil, invalid("invalid number format %q", pattern)
+	}
+	switch pattern[i] {
+	case 'd', 'i', 'u':
+		return fmt.Sprintf(pattern[:i]+"d"+pattern[i+1:], int64(x)), nil
+	case 'o', 'x', 'X', 'c':
+		return fmt.Sprintf(pattern, int64(x)), nil
+	case 's':
+		return fmt.Sprintf(pattern, toString(args[0])), nil
+	}
+	return fmt.Sprintf(pattern, x), nil
+}
+
+// strftime maps the conversions of a POSIX strftime pattern onto Go layouts.
+var strftime = map[byte]string{
+	'Y': "2006", 'y': "06", 'm': "01", 'd': "02", 'e': "_2", 'H': "15", 'I': "03", 'M': "04", 'S': "05", 'p': "PM",
+	'b': "Jan", 'h': "Jan", 'B': "January", 'a': "Mon", 'A': "Monday", 'j': "002", 'Z': "MST", 'z': "-0700",
+	'F': "2006-01-02", 'T': "15:04:05", 'D': "01/02/06", 'R': "15:04",
+}
+
+// formatDatetime formats a date or time by a POSIX strftime pattern, e.g. "%Y-%m-%d".
+func formatDatetime(args []interface{}) (interface{}, error) {
+	t, err := toTime(args[0])
+	if err != nil {
+		return nil, err
+	}
+	pattern := toString(args[1])
+	var sb strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' || i == len(pattern)-1 {
+			sb.WriteByte(pattern[i])
+			continue
+		}
+		i++
+		if pattern[i] == '%' {
+			sb.WriteByte('%')
+			continue
+		}
+		layout, ok := strftime[pattern[i]]
+		if !ok {
+			return nil, invalid("unsupported conversion %%%c in date format %q", pattern[i], pattern)
+		}
+		sb.WriteString(t.Format(layout))
+	}
+	return sb.String(), nil
+}
+
+// sinceYear counts the units elapsed between the start of a year and a date.
+func sinceYear(unit time.Duration) func(args []interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		t, err := toTime(args[0])
+		if err != nil {
+			return nil, err
+		}
+		year, err := toFloat(args[1])
+		if err != nil {
+			return nil, err
+		}
+		start := time.Date(int(year), time.January, 1, 0, 0, 0, 0, time.UTC)
+		return math.Floor(float64(t.Sub(start)) / float64(unit)), nil
+	}
+}
+
+func secondsSinceMidnight(args []interface{}) (interface{}, error) {
+	t, err := toTime(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return float64(t.Hour()*3600 + t.Minute()*60 + t.Second()), nil
+}
+
+// isMissing reports whether a value is missing, which includes empty strings.
+func isMissing(value interface{}) bool {
+	return value == nil || value == ""
+}
+
+// toFloat converts a number, or a string holding one, into a float64.
+func toFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case bool:
+		return boolToFloat(v), nil
+	case string:
+		if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+			return f, nil
+		}
+	}
+	return 0, invalid("%v is not a number", value)
+}
+
+func toFloats(args []interface{}) ([]float64, error) {
+	x := make([]float64, len(args))
+	for i, arg := range args {
+		var err error
+		if x[i], err = toFloat(arg); err != nil {
+			return nil, err
+		}
+	}
+	return x, nil
+}
+
+func toBool(value interface{}) (bool, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b, nil
+		}
+	default:
+		if f, err := toFloat(v); err == nil {
+			return f != 0, nil
+		}
+	}
+	return false, invalid("%v is not a boolean", value)
+}
+
+func toString(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(value)
+}
+
+// dateLayouts are the layouts strings are parsed as dates or times by.
+var dateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02", "15:04:05"}
+
+func toTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, nil
+			}
+		}
+	}
+	return time.Time{}, invalid("%v is not a date", value)
+}
+
+// parseValue converts the value of an attribute into a number if it is one.
+func parseValue(s string) interface{} {
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	return s
+}
+
+// isInvalid reports whether err is an invalid argument or result of a function.
+func isInvalid(err error) bool {
+	var ie *invalidValueError
+	return errors.As(err, &ie)
+}
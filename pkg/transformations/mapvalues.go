@@ -0,0 +1,153 @@
+package transformations
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/stillmatic/pummel/pkg/table"
+)
+
+// MapValues looks up its fields in a table, matching each field against the cells of its column, and returns
+// the cell of OutputColumn in the first matching row. A missing field yields MapMissingTo, and fields without a
+// matching row yield DefaultValue; either is missing if it is not set.
+type MapValues struct {
+	XMLName          xml.Name `xml:"MapValues"`
+	OutputColumn     string   `xml:"outputColumn,attr"`
+	DataType         string   `xml:"dataType,attr"`
+	MapMissingTo     *string  `xml:"mapMissingTo,attr"`
+	DefaultValue     *string  `xml:"defaultValue,attr"`
+	FieldColumnPairs []*FieldColumnPair
+	InlineTable      *table.InlineTable
+	TableLocator     *table.TableLocator
+
+	doc *Document
+	// index is built when the MapValues is decoded, or on first use if it was built in code.
+	once     sync.Once
+	index    *table.Index
+	indexErr error
+}
+
+// FieldColumnPair matches a field against a column of the table of a MapValues.
+type FieldColumnPair struct {
+	XMLName xml.Name `xml:"FieldColumnPair"`
+	Field   string   `xml:"field,attr"`
+	Column  string   `xml:"column,attr"`
+}
+
+func (mv *MapValues) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*mv = MapValues{XMLName: start.Name, doc: mv.doc}
+	for _, attr := range start.Attr {
+		value := attr.Value
+		switch attr.Name.Local {
+		case "outputColumn":
+			mv.OutputColumn = value
+		case "dataType":
+			mv.DataType = value
+		case "mapMissingTo":
+			mv.MapMissingTo = &value
+		case "defaultValue":
+			mv.DefaultValue = &value
+		}
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "FieldColumnPair":
+				var fcp FieldColumnPair
+				if err := d.DecodeElement(&fcp, &tt); err != nil {
+					return err
+				}
+				mv.FieldColumnPairs = append(mv.FieldColumnPairs, &fcp)
+			case "InlineTable":
+				mv.InlineTable = &table.InlineTable{}
+				if err := d.DecodeElement(mv.InlineTable, &tt); err != nil {
+					return err
+				}
+			case "TableLocator":
+				mv.TableLocator = mv.doc.newTableLocator()
+				if err := d.DecodeElement(mv.TableLocator, &tt); err != nil {
+					return errors.Wrapf(err, "invalid MapValues %s", mv.OutputColumn)
+				}
+			case "Extension":
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unexpected element in MapValues: %s", tt.Name.Local)
+			}
+		case xml.EndElement:
+			return mv.buildIndex()
+		}
+	}
+}
+
+func (mv *MapValues) buildIndex() error {
+	var rows []table.Row
+	switch {
+	case mv.OutputColumn == "":
+		return errors.New("MapValues has no outputColumn")
+	case mv.InlineTable != nil:
+		rows = mv.InlineTable.Rows
+	case mv.TableLocator != nil:
+		rows = mv.TableLocator.Rows
+	default:
+		return fmt.Errorf("MapValues %s has no table", mv.OutputColumn)
+	}
+	// the cells of each column are compared by the data type of the field matched against it
+	columns := make([]string, len(mv.FieldColumnPairs))
+	dataTypes := make([]string, len(mv.FieldColumnPairs))
+	for i, fcp := range mv.FieldColumnPairs {
+		columns[i] = table.ColumnName(fcp.Column)
+		dataTypes[i] = mv.doc.dataType(fcp.Field)
+	}
+	mv.index = table.NewIndex(rows, columns, dataTypes)
+	return nil
+}
+
+func (mv *MapValues) lookupIndex() (*table.Index, error) {
+	mv.once.Do(func() {
+		if mv.index == nil {
+			mv.indexErr = mv.buildIndex()
+		}
+	})
+	return mv.index, mv.indexErr
+}
+
+func (mv *MapValues) Transform(values map[string]interface{}) (interface{}, error) {
+	keys := make([]interface{}, len(mv.FieldColumnPairs))
+	for i, fcp := range mv.FieldColumnPairs {
+		keys[i] = values[fcp.Field]
+		if isMissing(keys[i]) {
+			return typedValue(mv.DataType, mv.MapMissingTo)
+		}
+	}
+	index, err := mv.lookupIndex()
+	if err != nil {
+		return nil, err
+	}
+	row, ok := index.Lookup(keys)
+	if !ok {
+		return typedValue(mv.DataType, mv.DefaultValue)
+	}
+	cell, ok := row[table.ColumnName(mv.OutputColumn)]
+	if !ok {
+		return nil, nil
+	}
+	value, err := typedValue(mv.DataType, &cell)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid value in column %s of MapValues", mv.OutputColumn)
+	}
+	return value, nil
+}
+
+// RequiredField is empty, since a MapValues may look up several fields.
+func (mv *MapValues) RequiredField() string {
+	return ""
+}
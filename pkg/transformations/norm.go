@@ -0,0 +1,198 @@
+package transformations
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// NormContinuous maps a continuous field onto a piecewise linear function, defined by LinearNorm
+// points sorted by their original value. Values outside of the first and last point are outliers,
+// which are extrapolated unless Outliers says otherwise.
+type NormContinuous struct {
+	XMLName      xml.Name `xml:"NormContinuous"`
+	Field        string   `xml:"field,attr"`
+	MapMissingTo *float64 `xml:"mapMissingTo,attr"`
+	Outliers     string   `xml:"outliers,attr"`
+	LinearNorms  []*LinearNorm
+}
+
+// OutlierTreatments are the ways a NormContinuous handles values outside of its LinearNorm points.
+var OutlierTreatments = struct {
+	AsIs            string
+	AsMissingValues string
+	AsExtremeValues string
+}{
+	AsIs:            "asIs",
+	AsMissingValues: "asMissingValues",
+	AsExtremeValues: "asExtremeValues",
+}
+
+type LinearNorm struct {
+	XMLName xml.Name `xml:"LinearNorm"`
+	Orig    float64  `xml:"orig,attr"`
+	Norm    float64  `xml:"norm,attr"`
+}
+
+// NormDiscrete is 1 if the field equals Value and 0 otherwise, i.e. a one-hot encoding of a single category.
+type NormDiscrete struct {
+	XMLName      xml.Name `xml:"NormDiscrete"`
+	Field        string   `xml:"field,attr"`
+	Value        string   `xml:"value,attr"`
+	MapMissingTo *float64 `xml:"mapMissingTo,attr"`
+}
+
+func (nc *NormContinuous) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*nc = NormContinuous{XMLName: start.Name, Outliers: OutlierTreatments.AsIs}
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "field":
+			nc.Field = attr.Value
+		case "mapMissingTo":
+			val, err := strconv.ParseFloat(attr.Value, 64)
+			if err != nil {
+				return errors.Wrapf(err, "invalid mapMissingTo of NormContinuous %s", nc.Field)
+			}
+			nc.MapMissingTo = &val
+		case "outliers":
+			nc.Outliers = attr.Value
+		}
+	}
+	switch nc.Outliers {
+	case OutlierTreatments.AsIs, OutlierTreatments.AsMissingValues, OutlierTreatments.AsExtremeValues:
+	default:
+		return fmt.Errorf("unknown outlier treatment %s of NormContinuous %s", nc.Outliers, nc.Field)
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "LinearNorm":
+				var ln LinearNorm
+				if err := d.DecodeElement(&ln, &tt); err != nil {
+					return err
+				}
+				nc.LinearNorms = append(nc.LinearNorms, &ln)
+			case "Extension":
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unexpected element in NormContinuous: %s", tt.Name.Local)
+			}
+		case xml.EndElement:
+			if len(nc.LinearNorms) < 2 {
+				return fmt.Errorf("NormContinuous %s requires at least two LinearNorm elements", nc.Field)
+			}
+			sort.SliceStable(nc.LinearNorms, func(i, j int) bool { return nc.LinearNorms[i].Orig < nc.LinearNorms[j].Orig })
+			return nil
+		}
+	}
+}
+
+func (nc *NormContinuous) Transform(values map[string]interface{}) (interface{}, error) {
+	value := values[nc.Field]
+	if isMissing(value) {
+		if nc.MapMissingTo != nil {
+			return *nc.MapMissingTo, nil
+		}
+		return nil, nil
+	}
+	x, err := InterfaceToFloat64(value)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid value of field %s", nc.Field)
+	}
+	first, last := nc.LinearNorms[0], nc.LinearNorms[len(nc.LinearNorms)-1]
+	if x < first.Orig || x > last.Orig {
+		switch nc.Outliers {
+		case OutlierTreatments.AsMissingValues:
+			if nc.MapMissingTo != nil {
+				return *nc.MapMissingTo, nil
+			}
+			return nil, nil
+		case OutlierTreatments.AsExtremeValues:
+			if x < first.Orig {
+				return first.Norm, nil
+			}
+			return last.Norm, nil
+		}
+	}
+	return nc.Normalize(x), nil
+}
+
+// Normalize maps an original value onto the normalized scale.
+func (nc *NormContinuous) Normalize(x float64) float64 {
+	lns := nc.LinearNorms
+	// the segment holding x, or the first or last segment if x lies outside of them
+	i := sort.Search(len(lns)-2, func(i int) bool { return x <= lns[i+1].Orig })
+	a, b := lns[i], lns[i+1]
+	return a.Norm + (x-a.Orig)*(b.Norm-a.Norm)/(b.Orig-a.Orig)
+}
+
+// Denormalize is the inverse of Normalize. It is used to map the output of a model back onto the
+// scale of the target field, so the norm values must be monotonic.
+func (nc *NormContinuous) Denormalize(y float64) float64 {
+	lns := nc.LinearNorms
+	increasing := lns[len(lns)-1].Norm >= lns[0].Norm
+	i := sort.Search(len(lns)-2, func(i int) bool {
+		if increasing {
+			return y <= lns[i+1].Norm
+		}
+		return y >= lns[i+1].Norm
+	})
+	a, b := lns[i], lns[i+1]
+	return a.Orig + (y-a.Norm)*(b.Orig-a.Orig)/(b.Norm-a.Norm)
+}
+
+func (nc *NormContinuous) RequiredField() string {
+	return nc.Field
+}
+
+func (nd *NormDiscrete) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*nd = NormDiscrete{XMLName: start.Name}
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "field":
+			nd.Field = attr.Value
+		case "value":
+			nd.Value = attr.Value
+		case "mapMissingTo":
+			val, err := strconv.ParseFloat(attr.Value, 64)
+			if err != nil {
+				return errors.Wrapf(err, "invalid mapMissingTo of NormDiscrete %s", nd.Field)
+			}
+			nd.MapMissingTo = &val
+		}
+	}
+	return d.Skip()
+}
+
+func (nd *NormDiscrete) Transform(values map[string]interface{}) (interface{}, error) {
+	value := values[nd.Field]
+	if isMissing(value) {
+		if nd.MapMissingTo != nil {
+			return *nd.MapMissingTo, nil
+		}
+		return nil, nil
+	}
+	if nd.Matches(value) {
+		return 1.0, nil
+	}
+	return 0.0, nil
+}
+
+// Matches reports whether value is the category encoded by nd.
+func (nd *NormDiscrete) Matches(value interface{}) bool {
+	return EqualsValue(value, nd.Value)
+}
+
+func (nd *NormDiscrete) RequiredField() string {
+	return nd.Field
+}
@@ -0,0 +1,637 @@
+package transformations
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"github.com/stillmatic/pummel/pkg/table"
+)
+
+// TextIndex weighs how often a term occurs in the text of a field, for bag-of-words features. The text is
+// normalized by the TextIndexNormalizations in order and split into tokens by WordSeparatorCharacterRE, and the
+// term, which is split the same way, is counted wherever its tokens follow each other in the text. With
+// Tokenize, punctuation is trimmed from the ends of the tokens.
+type TextIndex struct {
+	XMLName                  xml.Name `xml:"TextIndex"`
+	TextField                string   `xml:"textField,attr"`
+	LocalTermWeights         string   `xml:"localTermWeights,attr"`
+	IsCaseSensitive          bool     `xml:"isCaseSensitive,attr"`
+	MaxLevenshteinDistance   int      `xml:"maxLevenshteinDistance,attr"`
+	CountHits                string   `xml:"countHits,attr"`
+	WordSeparatorCharacterRE string   `xml:"wordSeparatorCharacterRE,attr"`
+	Tokenize                 bool     `xml:"tokenize,attr"`
+	Normalizations           []*TextIndexNormalization
+	// Expression computes the term, which is usually a Constant.
+	Expression Expression
+
+	doc       *Document
+	tokenizer *tokenizer
+	// term holds the tokens of a Constant term.
+	term []string
+}
+
+var LocalTermWeights = struct {
+	TermFrequency                    string
+	Binary                           string
+	Logarithmic                      string
+	AugmentedNormalizedTermFrequency string
+}{
+	TermFrequency:                    "termFrequency",
+	Binary:                           "binary",
+	Logarithmic:                      "logarithmic",
+	AugmentedNormalizedTermFrequency: "augmentedNormalizedTermFrequency",
+}
+
+var CountHits = struct {
+	AllHits  string
+	BestHits string
+}{
+	AllHits:  "allHits",
+	BestHits: "bestHits",
+}
+
+// TextIndexNormalization replaces the words or patterns in the InField column of its table by the cell of the
+// OutField column. Rows whose RegexField cell is "true" hold regular expressions, and the others hold words,
+// which are matched as whole tokens if the text is tokenized. The attributes which are not set are those of
+// the TextIndex. With Recursive, the table is applied again until the text no longer changes.
+type TextIndexNormalization struct {
+	XMLName                  xml.Name `xml:"TextIndexNormalization"`
+	InField                  string   `xml:"inField,attr"`
+	OutField                 string   `xml:"outField,attr"`
+	RegexField               string   `xml:"regexField,attr"`
+	Recursive                bool     `xml:"recursive,attr"`
+	IsCaseSensitive          *bool    `xml:"isCaseSensitive,attr"`
+	MaxLevenshteinDistance   *int     `xml:"maxLevenshteinDistance,attr"`
+	WordSeparatorCharacterRE *string  `xml:"wordSeparatorCharacterRE,attr"`
+	Tokenize                 *bool    `xml:"tokenize,attr"`
+	InlineTable              *table.InlineTable
+	TableLocator             *table.TableLocator
+
+	doc *Document
+}
+
+// maxRecursions bounds the passes of a recursive TextIndexNormalization whose replacements keep matching.
+const maxRecursions = 100
+
+func (ti *TextIndex) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*ti = TextIndex{
+		XMLName:                  start.Name,
+		LocalTermWeights:         LocalTermWeights.TermFrequency,
+		CountHits:                CountHits.AllHits,
+		WordSeparatorCharacterRE: `\s+`,
+		Tokenize:                 true,
+		doc:                      ti.doc,
+	}
+	for _, attr := range start.Attr {
+		var err error
+		switch attr.Name.Local {
+		case "textField":
+			ti.TextField = attr.Value
+		case "localTermWeights":
+			ti.LocalTermWeights = attr.Value
+		case "isCaseSensitive":
+			ti.IsCaseSensitive, err = strconv.ParseBool(attr.Value)
+		case "maxLevenshteinDistance":
+			ti.MaxLevenshteinDistance, err = strconv.Atoi(attr.Value)
+		case "countHits":
+			ti.CountHits = attr.Value
+		case "wordSeparatorCharacterRE":
+			ti.WordSeparatorCharacterRE = attr.Value
+		case "tokenize":
+			ti.Tokenize, err = strconv.ParseBool(attr.Value)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s of TextIndex %s", attr.Name.Local, ti.TextField)
+		}
+	}
+	switch ti.LocalTermWeights {
+	case LocalTermWeights.TermFrequency, LocalTermWeights.Binary, LocalTermWeights.Logarithmic,
+		LocalTermWeights.AugmentedNormalizedTermFrequency:
+	default:
+		return fmt.Errorf("unknown localTermWeights of TextIndex %s: %s", ti.TextField, ti.LocalTermWeights)
+	}
+	switch ti.CountHits {
+	case CountHits.AllHits, CountHits.BestHits:
+	default:
+		return fmt.Errorf("unknown countHits of TextIndex %s: %s", ti.TextField, ti.CountHits)
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "TextIndexNormalization":
+				tin := TextIndexNormalization{doc: ti.doc}
+				if err := d.DecodeElement(&tin, &tt); err != nil {
+					return errors.Wrapf(err, "invalid TextIndex %s", ti.TextField)
+				}
+				ti.Normalizations = append(ti.Normalizations, &tin)
+			case "Extension":
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			default:
+				expr := ti.doc.NewExpression(tt.Name.Local)
+				if expr == nil {
+					return fmt.Errorf("unexpected element in TextIndex: %s", tt.Name.Local)
+				}
+				if err := d.DecodeElement(expr, &tt); err != nil {
+					return errors.Wrapf(err, "invalid TextIndex %s", ti.TextField)
+				}
+				ti.Expression = expr
+			}
+		case xml.EndElement:
+			if ti.Expression == nil {
+				return fmt.Errorf("TextIndex %s has no term", ti.TextField)
+			}
+			if err := ti.compile(); err != nil {
+				return errors.Wrapf(err, "invalid TextIndex %s", ti.TextField)
+			}
+			return nil
+		}
+	}
+}
+
+func (tin *TextIndexNormalization) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*tin = TextIndexNormalization{XMLName: start.Name, InField: "string", OutField: "stem", RegexField: "regex", doc: tin.doc}
+	for _, attr := range start.Attr {
+		var err error
+		value := attr.Value
+		switch attr.Name.Local {
+		case "inField":
+			tin.InField = value
+		case "outField":
+			tin.OutField = value
+		case "regexField":
+			tin.RegexField = value
+		case "recursive":
+			tin.Recursive, err = strconv.ParseBool(value)
+		case "isCaseSensitive":
+			var b bool
+			b, err = strconv.ParseBool(value)
+			tin.IsCaseSensitive = &b
+		case "maxLevenshteinDistance":
+			var n int
+			n, err = strconv.Atoi(value)
+			tin.MaxLevenshteinDistance = &n
+		case "wordSeparatorCharacterRE":
+			tin.WordSeparatorCharacterRE = &value
+		case "tokenize":
+			var b bool
+			b, err = strconv.ParseBool(value)
+			tin.Tokenize = &b
+		}
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s of TextIndexNormalization", attr.Name.Local)
+		}
+	}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "InlineTable":
+				tin.InlineTable = &table.InlineTable{}
+				if err := d.DecodeElement(tin.InlineTable, &tt); err != nil {
+					return err
+				}
+			case "TableLocator":
+				tin.TableLocator = tin.doc.newTableLocator()
+				if err := d.DecodeElement(tin.TableLocator, &tt); err != nil {
+					return err
+				}
+			case "Extension":
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unexpected element in TextIndexNormalization: %s", tt.Name.Local)
+			}
+		case xml.EndElement:
+			if tin.InlineTable == nil && tin.TableLocator == nil {
+				return errors.New("TextIndexNormalization has no table")
+			}
+			return nil
+		}
+	}
+}
+
+// compile prepares the tokenizer of the TextIndex, sharing it with the TextIndex elements of the document
+// which split text the same way.
+func (ti *TextIndex) compile() error {
+	tk, err := newTokenizer(ti)
+	if err != nil {
+		return err
+	}
+	tk = ti.doc.shareTokenizer(tk)
+	ti.tokenizer = tk
+	if c, ok := ti.Expression.(*Constant); ok && !isMissing(c.Value) {
+		ti.term = tk.tokens(toString(c.Value))
+	}
+	return nil
+}
+
+func (ti *TextIndex) Transform(values map[string]interface{}) (interface{}, error) {
+	text := values[ti.TextField]
+	if isMissing(text) {
+		return nil, nil
+	}
+	term := ti.term
+	if term == nil {
+		value, err := ti.Expression.Transform(values)
+		if err != nil {
+			return nil, err
+		}
+		if isMissing(value) {
+			return nil, nil
+		}
+		term = ti.tokenizer.tokens(toString(value))
+	}
+	doc := ti.tokenizer.document(values, ti.TextField, toString(text))
+	count := doc.count(term, ti.MaxLevenshteinDistance, ti.CountHits == CountHits.BestHits)
+	if count == 0 {
+		return 0.0, nil
+	}
+	switch ti.LocalTermWeights {
+	case LocalTermWeights.Binary:
+		return 1.0, nil
+	case LocalTermWeights.Logarithmic:
+		return math.Log10(1 + float64(count)), nil
+	case LocalTermWeights.AugmentedNormalizedTermFrequency:
+		return 0.5 * (1 + float64(count)/float64(doc.maxCount)), nil
+	}
+	return float64(count), nil
+}
+
+func (ti *TextIndex) RequiredField() string {
+	return ti.TextField
+}
+
+// tokenizer normalizes and splits texts into tokens. The TextIndex elements of a document which split text the
+// same way share it, so that they only split the text of a record once.
+type tokenizer struct {
+	key           string
+	separator     *regexp.Regexp
+	caseSensitive bool
+	trim          bool
+	normalizers   []*normalizer
+}
+
+func newTokenizer(ti *TextIndex) (*tokenizer, error) {
+	separator, err := regexp.Compile(ti.WordSeparatorCharacterRE)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid wordSeparatorCharacterRE")
+	}
+	tk := &tokenizer{
+		separator:     separator,
+		caseSensitive: ti.IsCaseSensitive,
+		trim:          ti.Tokenize,
+	}
+	var key strings.Builder
+	fmt.Fprintf(&key, "%t\x00%t\x00%s", ti.IsCaseSensitive, ti.Tokenize, ti.WordSeparatorCharacterRE)
+	for _, tin := range ti.Normalizations {
+		n, err := newNormalizer(ti, tin)
+		if err != nil {
+			return nil, err
+		}
+		tk.normalizers = append(tk.normalizers, n)
+		fmt.Fprintf(&key, "\x00%s", n.key)
+	}
+	tk.key = key.String()
+	return tk, nil
+}
+
+// tokens splits a text into tokens, folding their case unless the tokenizer is case sensitive.
+func (tk *tokenizer) tokens(text string) []string {
+	return splitTokens(text, tk.separator, tk.trim, tk.caseSensitive)
+}
+
+func splitTokens(text string, separator *regexp.Regexp, trim, caseSensitive bool) []string {
+	tokens := make([]string, 0)
+	for _, token := range separator.Split(text, -1) {
+		if trim {
+			token = strings.TrimFunc(token, unicode.IsPunct)
+		}
+		if token == "" {
+			continue
+		}
+		if !caseSensitive {
+			token = strings.ToLower(token)
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+// document returns the normalized tokens of text, the value of field in the values of the record being
+// evaluated. They are kept in values, so that the TextIndex elements sharing the tokenizer reuse them.
+func (tk *tokenizer) document(values map[string]interface{}, field, text string) *tokenizedText {
+	key := textsKey(field)
+	texts, ok := values[key].(*tokenizedTexts)
+	if !ok || texts.text != text {
+		texts = &tokenizedTexts{text: text, docs: make(map[*tokenizer]*tokenizedText)}
+		values[key] = texts
+	}
+	if doc, ok := texts.docs[tk]; ok {
+		return doc
+	}
+	normalized := text
+	for _, n := range tk.normalizers {
+		normalized = n.normalize(normalized)
+	}
+	doc := newTokenizedText(tk.tokens(normalized))
+	texts.docs[tk] = doc
+	return doc
+}
+
+// tokenizedTexts holds the tokens of the text of a field, by the tokenizer which split it.
+type tokenizedTexts struct {
+	text string
+	docs map[*tokenizer]*tokenizedText
+}
+
+// textsKey is the key of the tokenizedTexts of a field in the values of a record. It starts with a NUL
+// character, which the names of fields do not hold.
+func textsKey(field string) string {
+	return "\x00TextIndex\x00" + field
+}
+
+// tokenizedText holds the tokens of a text and how often each of them occurs.
+type tokenizedText struct {
+	tokens   []string
+	counts   map[string]int
+	maxCount int
+}
+
+func newTokenizedText(tokens []string) *tokenizedText {
+	doc := &tokenizedText{tokens: tokens, counts: make(map[string]int, len(tokens))}
+	for _, token := range tokens {
+		doc.counts[token]++
+		if doc.counts[token] > doc.maxCount {
+			doc.maxCount = doc.counts[token]
+		}
+	}
+	return doc
+}
+
+// count returns how often the tokens of a term follow each other in the text, allowing for a total Levenshtein
+// distance of maxDistance. With best, only the hits at the smallest distance found are counted.
+func (doc *tokenizedText) count(term []string, maxDistance int, best bool) int {
+	if len(term) == 0 {
+		return 0
+	}
+	if len(term) == 1 && maxDistance == 0 {
+		return doc.counts[term[0]]
+	}
+	hits, bestDistance := 0, maxDistance
+	for i := 0; i+len(term) <= len(doc.tokens); i++ {
+		distance, ok := matchTokens(doc.tokens[i:i+len(term)], term, maxDistance)
+		switch {
+		case !ok:
+		case best && distance < bestDistance:
+			hits, bestDistance = 1, distance
+		case !best || distance == bestDistance:
+			hits++
+		}
+	}
+	return hits
+}
+
+// matchTokens returns the total Levenshtein distance between the tokens and the words of a term, and whether
+// it is at most maxDistance.
+func matchTokens(tokens, words []string, maxDistance int) (int, bool) {
+	total := 0
+	for i, word := range words {
+		if tokens[i] == word {
+			continue
+		}
+		if maxDistance == 0 {
+			return 0, false
+		}
+		total += levenshtein(tokens[i], word)
+		if total > maxDistance {
+			return 0, false
+		}
+	}
+	return total, true
+}
+
+// levenshtein returns the number of single character insertions, deletions and substitutions between a and b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, minInt(cur[j-1]+1, prev[j-1]+cost))
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// normalizer applies the table of a TextIndexNormalization. Consecutive rows which replace single words
+// exactly are grouped into one lookup, so that large stemming tables cost one pass over the text.
+type normalizer struct {
+	key       string
+	steps     []*normalizationStep
+	recursive bool
+}
+
+// normalizationStep replaces either the matches of a regular expression, or words and phrases which are
+// whole tokens of the text.
+type normalizationStep struct {
+	pattern *regexp.Regexp
+	// words maps single words to their replacement.
+	words map[string]string
+	// phrase is matched within a Levenshtein distance, and replaced by with, as is pattern.
+	phrase   []string
+	distance int
+	with     string
+
+	separator     *regexp.Regexp
+	caseSensitive bool
+}
+
+func newNormalizer(ti *TextIndex, tin *TextIndexNormalization) (*normalizer, error) {
+	caseSensitive, maxDistance, separatorRE, tokenize := ti.IsCaseSensitive, ti.MaxLevenshteinDistance, ti.WordSeparatorCharacterRE, ti.Tokenize
+	if tin.IsCaseSensitive != nil {
+		caseSensitive = *tin.IsCaseSensitive
+	}
+	if tin.MaxLevenshteinDistance != nil {
+		maxDistance = *tin.MaxLevenshteinDistance
+	}
+	if tin.WordSeparatorCharacterRE != nil {
+		separatorRE = *tin.WordSeparatorCharacterRE
+	}
+	if tin.Tokenize != nil {
+		tokenize = *tin.Tokenize
+	}
+	separator, err := regexp.Compile(separatorRE)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid wordSeparatorCharacterRE of TextIndexNormalization")
+	}
+	rows := tin.rows()
+	n := &normalizer{recursive: tin.Recursive}
+	n.key = fmt.Sprint(caseSensitive, maxDistance, separatorRE, tokenize, tin.InField, tin.OutField, tin.RegexField, tin.Recursive, rows)
+	inField, outField, regexField := table.ColumnName(tin.InField), table.ColumnName(tin.OutField), table.ColumnName(tin.RegexField)
+	// group holds the single words of the consecutive rows before, and replaced the words they are replaced by
+	var group *normalizationStep
+	var replaced map[string]bool
+	for i, row := range rows {
+		in, out := row[inField], row[outField]
+		if in == "" {
+			continue
+		}
+		step := &normalizationStep{with: out, separator: separator, caseSensitive: caseSensitive}
+		if regex := row[regexField] == "true"; regex || !tokenize {
+			if !regex {
+				in = regexp.QuoteMeta(in)
+			}
+			if !caseSensitive {
+				in = "(?i)" + in
+			}
+			if step.pattern, err = regexp.Compile(in); err != nil {
+				return nil, errors.Wrapf(err, "invalid regular expression in row %d of TextIndexNormalization", i+1)
+			}
+			n.steps = append(n.steps, step)
+			group = nil
+			continue
+		}
+		phrase := splitTokens(in, separator, true, caseSensitive)
+		if len(phrase) != 1 || maxDistance > 0 {
+			step.phrase, step.distance = phrase, maxDistance
+			n.steps = append(n.steps, step)
+			group = nil
+			continue
+		}
+		word := phrase[0]
+		// a word which an earlier row of the group replaces by another one is no longer in the text, while
+		// a word which is a replacement has to be looked up again
+		if group != nil {
+			if _, ok := group.words[word]; ok {
+				continue
+			}
+		}
+		if group == nil || replaced[word] {
+			group = &normalizationStep{words: make(map[string]string), separator: separator, caseSensitive: caseSensitive}
+			replaced = make(map[string]bool)
+			n.steps = append(n.steps, group)
+		}
+		group.words[word] = out
+		for _, token := range splitTokens(out, separator, true, caseSensitive) {
+			replaced[token] = true
+		}
+	}
+	return n, nil
+}
+
+func (tin *TextIndexNormalization) rows() []table.Row {
+	if tin.InlineTable != nil {
+		return tin.InlineTable.Rows
+	}
+	return tin.TableLocator.Rows
+}
+
+func (n *normalizer) normalize(text string) string {
+	for i := 0; i < maxRecursions; i++ {
+		normalized := text
+		for _, step := range n.steps {
+			normalized = step.apply(normalized)
+		}
+		if !n.recursive || normalized == text {
+			return normalized
+		}
+		text = normalized
+	}
+	return text
+}
+
+func (s *normalizationStep) apply(text string) string {
+	if s.pattern != nil {
+		return s.pattern.ReplaceAllString(text, s.with)
+	}
+	// the tokens lie between the separators, and are compared without punctuation at their ends
+	var starts, ends []int
+	start := 0
+	for _, sep := range s.separator.FindAllStringIndex(text, -1) {
+		if sep[0] == sep[1] {
+			continue
+		}
+		starts, ends = append(starts, start), append(ends, sep[0])
+		start = sep[1]
+	}
+	starts, ends = append(starts, start), append(ends, len(text))
+	words := make([]string, len(starts))
+	for i := range starts {
+		words[i] = strings.TrimFunc(text[starts[i]:ends[i]], unicode.IsPunct)
+		if !s.caseSensitive {
+			words[i] = strings.ToLower(words[i])
+		}
+	}
+	var b strings.Builder
+	last := 0
+	for i := 0; i < len(words); {
+		n, out := s.match(words[i:])
+		if n == 0 {
+			i++
+			continue
+		}
+		b.WriteString(text[last:starts[i]])
+		b.WriteString(out)
+		last = ends[i+n-1]
+		i += n
+	}
+	if last == 0 {
+		return text
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// match returns how many of words it replaces from the first one on, and their replacement.
+func (s *normalizationStep) match(words []string) (int, string) {
+	if words[0] == "" {
+		return 0, ""
+	}
+	if s.words != nil {
+		if out, ok := s.words[words[0]]; ok {
+			return 1, out
+		}
+		return 0, ""
+	}
+	if len(words) < len(s.phrase) {
+		return 0, ""
+	}
+	if _, ok := matchTokens(words[:len(s.phrase)], s.phrase, s.distance); ok {
+		return len(s.phrase), s.with
+	}
+	return 0, ""
+}
@@ -9,6 +9,7 @@ import (
 	"encoding/xml"
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/pkg/errors"
 )
@@ -21,33 +22,69 @@ type Expression interface {
 type LocalTransformations struct {
 	XMLName       xml.Name `xml:"LocalTransformations"`
 	DerivedFields []*DerivedField
+
+	doc *Document
+}
+
+// TransformationDictionary holds derived fields which are shared by every model in the document.
+// They are computed from the raw inputs before any model element is evaluated.
+type TransformationDictionary struct {
+	XMLName         xml.Name `xml:"TransformationDictionary"`
+	DefineFunctions []*DefineFunction
+	DerivedFields   []*DerivedField
+
+	doc *Document
 }
 
 type DerivedField struct {
 	XMLName     xml.Name `xml:"DerivedField"`
 	Name        string   `xml:"name,attr"`
 	DisplayName string   `xml:"displayName,attr"`
-	OpType      string   `xml:"opType,attr"`
+	OpType      string   `xml:"optype,attr"`
 	DataType    string   `xml:"dataType,attr"`
 	Values      []Value  `xml:"Value"`
 	Expression  *Expression
+
+	doc *Document
 }
 
 type Value struct {
 	XMLName xml.Name `xml:"Value"`
 	Value   string   `xml:"value,attr"`
 }
+
+// FieldRef is the value of Field, or MapMissingTo if the field is missing; it is missing if MapMissingTo is not set.
 type FieldRef struct {
 	XMLName      xml.Name `xml:"FieldRef"`
 	Field        string   `xml:"field,attr"`
-	MapMissingTo string   `xml:"mapMissingTo,attr"`
+	MapMissingTo *string  `xml:"mapMissingTo,attr"`
 	DataType     string
 }
 
+// Apply calls a built-in function on the values of its children. If an argument is missing, the result
+// is MapMissingTo, and if the result is missing, it is DefaultValue; either is missing if it is not set.
+// InvalidValueTreatment decides whether arguments outside of the domain of the function are an error
+// (returnInvalid), make the result missing (asMissing), or are used anyway (asIs).
 type Apply struct {
-	XMLName  xml.Name `xml:"Apply"`
-	Function string   `xml:"function,attr"`
-	Children []*Expression
+	XMLName               xml.Name `xml:"Apply"`
+	Function              string   `xml:"function,attr"`
+	MapMissingTo          *string  `xml:"mapMissingTo,attr"`
+	DefaultValue          *string  `xml:"defaultValue,attr"`
+	InvalidValueTreatment string   `xml:"invalidValueTreatment,attr"`
+	Children              []*Expression
+
+	doc      *Document
+	function *function
+}
+
+var InvalidValueTreatments = struct {
+	ReturnInvalid string
+	AsIs          string
+	AsMissing     string
+}{
+	ReturnInvalid: "returnInvalid",
+	AsIs:          "asIs",
+	AsMissing:     "asMissing",
 }
 
 type Constant struct {
@@ -65,7 +102,7 @@ func (lt *LocalTransformations) UnmarshalXML(d *xml.Decoder, start xml.StartElem
 		}
 		switch tt := t.(type) {
 		case xml.StartElement:
-			var df DerivedField
+			df := DerivedField{doc: lt.doc}
 			if err := d.DecodeElement(&df, &tt); err != nil {
 				return err
 			}
@@ -86,7 +123,7 @@ func (df *DerivedField) UnmarshalXML(d *xml.Decoder, start xml.StartElement) err
 			df.Name = attr.Value
 		case "displayName":
 			df.DisplayName = attr.Value
-		case "opType":
+		case "optype":
 			df.OpType = attr.Value
 		case "dataType":
 			df.DataType = attr.Value
@@ -99,44 +136,61 @@ func (df *DerivedField) UnmarshalXML(d *xml.Decoder, start xml.StartElement) err
 		}
 		switch tt := t.(type) {
 		case xml.StartElement:
-			var expr Expression
-			switch tt.Name.Local {
-			case "FieldRef":
-				expr = &FieldRef{DataType: df.DataType}
-			case "Apply":
-				expr = &Apply{}
-			case "Constant":
-				expr = &Constant{}
-			case "Value":
+			expr := df.doc.NewExpression(tt.Name.Local)
+			switch e := expr.(type) {
+			case *FieldRef:
+				e.DataType = df.DataType
+			case nil:
+				if tt.Name.Local != "Value" {
+					return fmt.Errorf("unexpected element in DerivedField: %s", tt.Name.Local)
+				}
 				var val Value
 				if err := d.DecodeElement(&val, &tt); err != nil {
 					return err
 				}
 				df.Values = append(df.Values, val)
-			default:
-				return fmt.Errorf("unexpected element in DerivedField: %s", tt.Name.Local)
 			}
 			if expr != nil {
 				if err := d.DecodeElement(&expr, &tt); err != nil {
 					return err
 				}
+				InheritDataType(expr, df.DataType)
 				df.Expression = &expr
 				// df.RequiredFields = append(df.RequiredFields, expr.RequiredField())
 			}
 		case xml.EndElement:
+			df.doc.DeclareField(df.Name, df.DataType)
 			return nil
 		}
 	}
 }
 
+// InheritDataType sets the data type of a MapValues or a Discretize which has none to that of the field it computes.
+func InheritDataType(expr Expression, dataType string) {
+	switch expr := expr.(type) {
+	case *MapValues:
+		if expr.DataType == "" {
+			expr.DataType = dataType
+		}
+	case *Discretize:
+		if expr.DataType == "" {
+			expr.DataType = dataType
+		}
+	}
+}
+
 func (c *Constant) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
-	c.XMLName = start.Name
+	*c = Constant{XMLName: start.Name}
+	var missing bool
 	for _, attr := range start.Attr {
 		switch attr.Name.Local {
 		case "dataType":
 			c.DataType = attr.Value
+		case "missing":
+			missing = attr.Value == "true"
 		}
 	}
+	var text strings.Builder
 	for {
 		t, err := d.Token()
 		if err != nil {
@@ -145,29 +199,65 @@ func (c *Constant) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 
 		switch tt := t.(type) {
 		case xml.CharData:
-			switch c.DataType {
-			case "double":
-				parsed, err := strconv.ParseFloat(string(tt), 64)
-				if err != nil {
-					return err
-				}
-				c.Value = parsed
-			}
+			text.Write(tt)
 		case xml.EndElement:
-			return nil
+			if missing {
+				return nil
+			}
+			c.Value, err = parseConstant(c.DataType, text.String())
+			return err
 		}
 	}
 }
 
+// parseConstant converts the text of a Constant to its data type. Numbers are float64 whatever their type,
+// and constants without a data type are numbers if they can be parsed as one, and strings otherwise.
+func parseConstant(dataType, text string) (interface{}, error) {
+	switch dataType {
+	case "double", "float", "integer":
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s Constant", dataType)
+		}
+		return parsed, nil
+	case "boolean":
+		parsed, err := strconv.ParseBool(strings.TrimSpace(text))
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid boolean Constant")
+		}
+		return parsed, nil
+	case "":
+		return parseValue(strings.TrimSpace(text)), nil
+	}
+	return text, nil
+}
+
 func (a *Apply) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
-	a.XMLName = start.Name
+	*a = Apply{XMLName: start.Name, InvalidValueTreatment: InvalidValueTreatments.ReturnInvalid, doc: a.doc}
 	a.Children = make([]*Expression, 0)
 	for _, attr := range start.Attr {
 		switch attr.Name.Local {
 		case "function":
 			a.Function = attr.Value
+		case "mapMissingTo":
+			value := attr.Value
+			a.MapMissingTo = &value
+		case "defaultValue":
+			value := attr.Value
+			a.DefaultValue = &value
+		case "invalidValueTreatment":
+			a.InvalidValueTreatment = attr.Value
 		}
 	}
+	switch a.InvalidValueTreatment {
+	case InvalidValueTreatments.ReturnInvalid, InvalidValueTreatments.AsIs, InvalidValueTreatments.AsMissing:
+	default:
+		return fmt.Errorf("unknown invalidValueTreatment of Apply: %s", a.InvalidValueTreatment)
+	}
+	var ok bool
+	if a.function, ok = a.doc.lookupFunction(a.Function); !ok {
+		return fmt.Errorf("unknown function: %s", a.Function)
+	}
 	for {
 		t, err := d.Token()
 		if err != nil {
@@ -175,27 +265,37 @@ func (a *Apply) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 		}
 		switch tt := t.(type) {
 		case xml.StartElement:
-			var expr Expression
-			switch tt.Name.Local {
-			case "Constant":
-				expr = &Constant{}
-			case "FieldRef":
-				expr = &FieldRef{}
-			case "Apply":
-				expr = &Apply{}
-			default:
-				return fmt.Errorf("unexpected element in Apply: %s", tt.Name.Local)
-			}
-			if expr != nil {
-				if err := d.DecodeElement(&expr, &tt); err != nil {
+			if tt.Name.Local == "Extension" {
+				if err := d.Skip(); err != nil {
 					return err
 				}
-				a.Children = append(a.Children, &expr)
+				continue
+			}
+			expr := a.doc.NewExpression(tt.Name.Local)
+			if expr == nil {
+				return fmt.Errorf("unexpected element in Apply: %s", tt.Name.Local)
+			}
+			if err := d.DecodeElement(expr, &tt); err != nil {
+				return err
 			}
+			a.Children = append(a.Children, &expr)
 		case xml.EndElement:
-			return nil
+			return a.function.checkArity(a.Function, len(a.Children))
+		}
+	}
+}
+
+// Transform computes each derived field in document order and adds it to values,
+// so that later fields may refer to earlier ones.
+func (td *TransformationDictionary) Transform(values map[string]interface{}) error {
+	for _, df := range td.DerivedFields {
+		val, err := df.Transform(values)
+		if err != nil {
+			return errors.Wrapf(err, "failed to compute derived field %s", df.Name)
 		}
+		values[df.Name] = val
 	}
+	return nil
 }
 
 func (df *DerivedField) Transform(values map[string]interface{}) (interface{}, error) {
@@ -210,9 +310,11 @@ func (df *DerivedField) RequiredField() string {
 func (fr *FieldRef) Transform(values map[string]interface{}) (interface{}, error) {
 	value, ok := values[fr.Field]
 	if value == nil {
-		return nil, nil
-	}
-	if !ok {
+		if fr.MapMissingTo == nil {
+			return nil, nil
+		}
+		value = parseValue(*fr.MapMissingTo)
+	} else if !ok {
 		return nil, errors.New("missing field " + fr.Field)
 	}
 	switch fr.DataType {
@@ -232,10 +334,6 @@ func (a *Apply) RequiredField() string {
 }
 
 func (c *Constant) Transform(values map[string]interface{}) (interface{}, error) {
-	switch c.DataType {
-	case "double":
-		return c.Value.(float64), nil
-	}
 	return c.Value, nil
 }
 
@@ -244,74 +342,87 @@ func (c *Constant) RequiredField() string {
 }
 
 func (a *Apply) Transform(values map[string]interface{}) (interface{}, error) {
-	switch a.Function {
-	case "isMissing":
-		// assume that there is a single fieldref
-		ref, ok := values[(*a.Children[0]).RequiredField()]
-		found := (!ok || ref == "")
-		return interface{}(found), nil
-	case "equal":
-		// assume that there are two fieldrefs
-		l, err := (*a.Children[0]).Transform(values)
-		if err != nil {
-			return nil, err
+	f := a.function
+	if f == nil {
+		// an Apply built in code rather than decoded calls a built-in function
+		var ok bool
+		if f, ok = functions[a.Function]; !ok {
+			return nil, fmt.Errorf("unknown function: %s", a.Function)
 		}
-		r, err := (*a.Children[1]).Transform(values)
-		if err != nil {
+		if err := f.checkArity(a.Function, len(a.Children)); err != nil {
 			return nil, err
 		}
-		return interface{}(l == r), nil
-	case "isIn":
-		// is first child in the rest of the children
-		l, err := (*a.Children[0]).Transform(values)
-		if err != nil {
+	}
+	res, err := a.apply(f, values)
+	if err != nil {
+		if !isInvalid(err) {
 			return nil, err
 		}
-		for _, r := range a.Children[1:] {
-			val, err := (*r).Transform(values)
-			if err != nil {
-				return nil, err
-			}
-			if l == val {
-				return true, nil
-			}
+		switch a.InvalidValueTreatment {
+		case InvalidValueTreatments.AsMissing:
+			res = nil
+		case InvalidValueTreatments.AsIs:
+		default:
+			return nil, errors.Wrapf(err, "invalid value in function %s", a.Function)
 		}
-		return false, nil
 	}
+	if res == nil && a.DefaultValue != nil {
+		return parseValue(*a.DefaultValue), nil
+	}
+	return res, nil
+}
 
-	var lf, rf, res float64
-	if len(a.Children) < 2 {
-		return nil, errors.New("Apply requires at least two children")
+// apply evaluates the arguments and calls f on them.
+func (a *Apply) apply(f *function, values map[string]interface{}) (interface{}, error) {
+	if a.Function == "if" {
+		return a.applyIf(values)
 	}
-	// assume only 2 values for each of these...dont know if correct
-	l, err := (*a.Children[0]).Transform(values)
-	if err != nil {
-		return nil, errors.Wrap(err, "error transforming left")
+	args := make([]interface{}, 0, len(a.Children))
+	for _, child := range a.Children {
+		arg, err := (*child).Transform(values)
+		if err != nil {
+			return nil, err
+		}
+		if isMissing(arg) {
+			switch f.missing {
+			case propagateMissing:
+				if a.MapMissingTo != nil {
+					return parseValue(*a.MapMissingTo), nil
+				}
+				return nil, nil
+			case ignoreMissing:
+				continue
+			}
+		}
+		args = append(args, arg)
 	}
-	r, err := (*a.Children[1]).Transform(values)
-	if err != nil {
-		return nil, errors.Wrap(err, "error transforming right")
+	if len(args) == 0 {
+		return nil, nil
 	}
+	return f.eval(args)
+}
 
-	rf, err = InterfaceToFloat64(r)
+// applyIf returns its second argument if the first is true, and its third, or a missing value, otherwise.
+func (a *Apply) applyIf(values map[string]interface{}) (interface{}, error) {
+	cond, err := (*a.Children[0]).Transform(values)
 	if err != nil {
-		return nil, errors.Wrap(err, "error converting right to float64")
+		return nil, err
 	}
-	lf, err = InterfaceToFloat64(l)
+	if isMissing(cond) {
+		if a.MapMissingTo != nil {
+			return parseValue(*a.MapMissingTo), nil
+		}
+		return nil, nil
+	}
+	b, err := toBool(cond)
 	if err != nil {
-		return nil, errors.Wrap(err, "error converting left to float64")
+		return nil, err
 	}
-
-	switch a.Function {
-	case "-":
-		res = lf - rf
-		return interface{}(res), nil
-	case "*":
-		res = lf * rf
-		return interface{}(res), nil
-	case "/":
-		res = lf / rf
-		return interface{}(res), nil
+	switch {
+	case b:
+		return (*a.Children[1]).Transform(values)
+	case len(a.Children) == 3:
+		return (*a.Children[2]).Transform(values)
 	}
 	return nil, nil
 }
@@ -331,3 +442,21 @@ func InterfaceToFloat64(val interface{}) (float64, error) {
 	}
 	return 0, nil
 }
+
+// EqualsValue reports whether value equals s, a value as written in a PMML document.
+// Numeric values are compared numerically, so that e.g. 1 equals "1.0".
+func EqualsValue(value interface{}, s string) bool {
+	if str, ok := value.(string); ok && str == s {
+		return true
+	}
+	switch value.(type) {
+	case float64, int:
+		want, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return false
+		}
+		got, _ := InterfaceToFloat64(value)
+		return got == want
+	}
+	return fmt.Sprint(value) == s
+}
@@ -2,8 +2,13 @@ package transformations_test
 
 import (
 	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/stillmatic/pummel/pkg/table"
 	"github.com/stillmatic/pummel/pkg/transformations"
 	"github.com/stretchr/testify/assert"
 )
@@ -115,6 +120,26 @@ var ApplyTestCases = []struct {
 		Output:   float64(28.3027),
 		Error:    nil,
 	},
+	{
+		XML: `<Apply function="lessOrEqual">
+			<FieldRef field="score" />
+			<Constant dataType="double">0.5</Constant>
+		</Apply>`,
+		Input:    map[string]interface{}{"score": 0.5},
+		Function: "lessOrEqual",
+		Output:   true,
+		Error:    nil,
+	},
+	{
+		XML: `<Apply function="greaterThan">
+			<FieldRef field="score" />
+			<Constant dataType="double">0.5</Constant>
+		</Apply>`,
+		Input:    map[string]interface{}{"score": 0.5},
+		Function: "greaterThan",
+		Output:   false,
+		Error:    nil,
+	},
 }
 
 func TestApply(t *testing.T) {
@@ -188,3 +213,711 @@ func TestTransforms(t *testing.T) {
 	}
 	assert.Equal(t, 6, len(input))
 }
+
+func TestNormContinuous(t *testing.T) {
+	normContinuousXML := []byte(`<DerivedField name="norm(x)" optype="continuous" dataType="double">
+	<NormContinuous field="x" mapMissingTo="-1">
+		<LinearNorm orig="10" norm="0.5"/>
+		<LinearNorm orig="0" norm="0"/>
+		<LinearNorm orig="20" norm="2"/>
+	</NormContinuous>
+</DerivedField>`)
+	var df transformations.DerivedField
+	err := xml.Unmarshal(normContinuousXML, &df)
+	assert.NoError(t, err)
+	tcs := []struct {
+		input    interface{}
+		expected interface{}
+	}{
+		{5.0, 0.25},
+		{"15", 1.25},
+		{10, 0.5},
+		// extrapolated from the first and last segments
+		{-10.0, -0.5},
+		{30.0, 3.5},
+		{nil, -1.0},
+	}
+	for _, tc := range tcs {
+		output, err := df.Transform(map[string]interface{}{"x": tc.input})
+		assert.NoError(t, err)
+		assert.InDelta(t, tc.expected, output, 1e-12)
+	}
+
+	outliers := []struct {
+		treatment string
+		input     float64
+		expected  interface{}
+	}{
+		{"asMissingValues", -10, -1.0},
+		{"asMissingValues", 30, -1.0},
+		{"asMissingValues", 20, 2.0},
+		{"asExtremeValues", -10, 0.0},
+		{"asExtremeValues", 30, 2.0},
+		{"asExtremeValues", 15, 1.25},
+	}
+	for _, tc := range outliers {
+		model := strings.Replace(string(normContinuousXML), `mapMissingTo="-1"`, `mapMissingTo="-1" outliers="`+tc.treatment+`"`, 1)
+		var df transformations.DerivedField
+		err := xml.Unmarshal([]byte(model), &df)
+		assert.NoError(t, err)
+		output, err := df.Transform(map[string]interface{}{"x": tc.input})
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected, output, "%s %v", tc.treatment, tc.input)
+	}
+
+	var nc transformations.NormContinuous
+	err = xml.Unmarshal([]byte(`<NormContinuous field="x" outliers="asBoundaries">
+		<LinearNorm orig="0" norm="0"/>
+		<LinearNorm orig="1" norm="1"/>
+	</NormContinuous>`), &nc)
+	assert.EqualError(t, err, "unknown outlier treatment asBoundaries of NormContinuous x")
+	err = xml.Unmarshal([]byte(`<NormContinuous field="x" outliers="asMissingValues">
+		<LinearNorm orig="0" norm="0"/>
+		<LinearNorm orig="1" norm="1"/>
+	</NormContinuous>`), &nc)
+	assert.NoError(t, err)
+	output, err := nc.Transform(map[string]interface{}{"x": 2.0})
+	assert.NoError(t, err)
+	assert.Nil(t, output)
+}
+
+func TestNormInsideApply(t *testing.T) {
+	applyXML := []byte(`<DerivedField name="score" optype="continuous" dataType="double">
+	<Apply function="+">
+		<Apply function="*">
+			<NormContinuous field="age" outliers="asExtremeValues">
+				<LinearNorm orig="18" norm="0"/>
+				<LinearNorm orig="68" norm="1"/>
+			</NormContinuous>
+			<Constant>10</Constant>
+		</Apply>
+		<NormDiscrete field="color" value="red" method="indicator"/>
+	</Apply>
+</DerivedField>`)
+	var df transformations.DerivedField
+	err := xml.Unmarshal(applyXML, &df)
+	assert.NoError(t, err)
+	tcs := []struct {
+		age, color interface{}
+		expected   interface{}
+	}{
+		{43.0, "red", 6.0},
+		{43.0, "blue", 5.0},
+		{90.0, "red", 11.0},
+		{nil, "red", nil},
+	}
+	for _, tc := range tcs {
+		output, err := df.Transform(map[string]interface{}{"age": tc.age, "color": tc.color})
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected, output, "age %v color %v", tc.age, tc.color)
+	}
+
+	var a transformations.Apply
+	err = xml.Unmarshal([]byte(`<Apply function="+"><FieldRef field="x"/><Aggregate/></Apply>`), &a)
+	assert.EqualError(t, err, "unexpected element in Apply: Aggregate")
+}
+
+func TestNormDiscrete(t *testing.T) {
+	normDiscreteXML := []byte(`<DerivedField name="color=red" optype="continuous" dataType="double">
+	<NormDiscrete field="color" value="red"/>
+</DerivedField>`)
+	var df transformations.DerivedField
+	err := xml.Unmarshal(normDiscreteXML, &df)
+	assert.NoError(t, err)
+	output, err := df.Transform(map[string]interface{}{"color": "red"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, output)
+	output, err = df.Transform(map[string]interface{}{"color": "blue"})
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, output)
+	output, err = df.Transform(map[string]interface{}{})
+	assert.NoError(t, err)
+	assert.Nil(t, output)
+
+	var nd transformations.NormDiscrete
+	err = xml.Unmarshal([]byte(`<NormDiscrete field="n" value="1.0"/>`), &nd)
+	assert.NoError(t, err)
+	assert.True(t, nd.Matches(1))
+	assert.False(t, nd.Matches(2.0))
+}
+
+func TestDiscretize(t *testing.T) {
+	discretizeXML := []byte(`<DerivedField name="band" optype="categorical" dataType="string">
+	<Discretize field="income" mapMissingTo="unknown" defaultValue="other">
+		<DiscretizeBin binValue="low">
+			<Interval closure="openClosed" rightMargin="100"/>
+		</DiscretizeBin>
+		<DiscretizeBin binValue="mid">
+			<Interval closure="openOpen" leftMargin="100" rightMargin="200"/>
+		</DiscretizeBin>
+		<DiscretizeBin binValue="high">
+			<Interval closure="closedClosed" leftMargin="200" rightMargin="300"/>
+		</DiscretizeBin>
+	</Discretize>
+</DerivedField>`)
+	var df transformations.DerivedField
+	err := xml.Unmarshal(discretizeXML, &df)
+	assert.NoError(t, err)
+	assert.Equal(t, "categorical", df.OpType)
+	tcs := []struct {
+		input    interface{}
+		expected interface{}
+	}{
+		{-1e9, "low"},
+		{100, "low"},
+		{"150", "mid"},
+		{200.0, "high"},
+		{300.0, "high"},
+		{300.5, "other"},
+		{nil, "unknown"},
+	}
+	for _, tc := range tcs {
+		output, err := df.Transform(map[string]interface{}{"income": tc.input})
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected, output, "income %v", tc.input)
+	}
+
+	var dz transformations.Discretize
+	err = xml.Unmarshal([]byte(`<Discretize field="x" dataType="integer">
+	<DiscretizeBin binValue="1"><Interval closure="closedOpen" leftMargin="0"/></DiscretizeBin>
+</Discretize>`), &dz)
+	assert.NoError(t, err)
+	output, err := dz.Transform(map[string]interface{}{"x": 5.0})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, output)
+	output, err = dz.Transform(map[string]interface{}{"x": -5.0})
+	assert.NoError(t, err)
+	assert.Nil(t, output)
+
+	// the bins take the data type of the field they compute
+	var typed transformations.DerivedField
+	err = xml.Unmarshal([]byte(`<DerivedField name="half" optype="continuous" dataType="double">
+	<Discretize field="x" defaultValue="1">
+		<DiscretizeBin binValue="0.5"><Interval closure="closedOpen" leftMargin="0" rightMargin="10"/></DiscretizeBin>
+	</Discretize>
+</DerivedField>`), &typed)
+	assert.NoError(t, err)
+	output, err = typed.Transform(map[string]interface{}{"x": 5.0})
+	assert.NoError(t, err)
+	assert.Equal(t, 0.5, output)
+	output, err = typed.Transform(map[string]interface{}{"x": 50.0})
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, output)
+
+	err = xml.Unmarshal([]byte(`<Discretize field="x"><DiscretizeBin binValue="a"><Interval closure="open"/></DiscretizeBin></Discretize>`), &dz)
+	assert.EqualError(t, err, "unknown closure of Interval: open")
+}
+
+var mapValuesXML = `<DerivedField name="rate" optype="continuous" dataType="double" xmlns:data="http://jpmml.org/jpmml-model/InlineTable">
+	<MapValues outputColumn="data:rate" mapMissingTo="-1" defaultValue="0">
+		<FieldColumnPair field="state" column="data:state"/>
+		<FieldColumnPair field="band" column="band"/>
+		<InlineTable>
+			<row><data:state>CA</data:state><band>1</band><data:rate>0.5</data:rate></row>
+			<row><data:state>CA</data:state><band>2</band><data:rate>0.75</data:rate></row>
+			<row><data:state>NY</data:state><band>1</band><data:rate>0.25</data:rate></row>
+			<row><data:state>NY</data:state><band>2</band></row>
+		</InlineTable>
+	</MapValues>
+</DerivedField>`
+
+func TestMapValues(t *testing.T) {
+	var df transformations.DerivedField
+	err := xml.Unmarshal([]byte(mapValuesXML), &df)
+	assert.NoError(t, err)
+	tcs := []struct {
+		state, band interface{}
+		expected    interface{}
+	}{
+		{"CA", 1.0, 0.5},
+		{"CA", "2", 0.75},
+		{"NY", 1, 0.25},
+		{"TX", 1.0, 0.0},
+		{"CA", nil, -1.0},
+		{"", 1.0, -1.0},
+		// the row has no cell in the output column
+		{"NY", 2.0, nil},
+	}
+	for _, tc := range tcs {
+		// the MapValues takes the data type of the DerivedField
+		output, err := df.Transform(map[string]interface{}{"state": tc.state, "band": tc.band})
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected, output, "state %v band %v", tc.state, tc.band)
+	}
+
+	var mv transformations.MapValues
+	err = xml.Unmarshal([]byte(`<MapValues outputColumn="out">
+		<FieldColumnPair field="x" column="in"/>
+		<InlineTable><row><in>a</in><out>A</out></row></InlineTable>
+	</MapValues>`), &mv)
+	assert.NoError(t, err)
+	output, err := mv.Transform(map[string]interface{}{"x": "a"})
+	assert.NoError(t, err)
+	assert.Equal(t, "A", output)
+	output, err = mv.Transform(map[string]interface{}{"x": "b"})
+	assert.NoError(t, err)
+	assert.Nil(t, output)
+
+	// the cells are compared by the data types the document declares for the fields
+	doc := transformations.NewDocument("")
+	doc.DeclareField("band", "integer")
+	typed := doc.NewDerivedField()
+	assert.NoError(t, xml.Unmarshal([]byte(mapValuesXML), typed))
+	for _, band := range []interface{}{"02", "2.0", 2.0} {
+		output, err = typed.Transform(map[string]interface{}{"state": "CA", "band": band})
+		assert.NoError(t, err)
+		assert.Equal(t, 0.75, output, "band %v", band)
+	}
+	output, err = df.Transform(map[string]interface{}{"state": "CA", "band": "02"})
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, output)
+
+	// a MapValues built in code indexes its table on first use
+	built := &transformations.MapValues{
+		OutputColumn:     "out",
+		FieldColumnPairs: []*transformations.FieldColumnPair{{Field: "x", Column: "in"}},
+		InlineTable:      &table.InlineTable{Rows: []table.Row{{"in": "a", "out": "A"}}},
+	}
+	output, err = built.Transform(map[string]interface{}{"x": "a"})
+	assert.NoError(t, err)
+	assert.Equal(t, "A", output)
+	_, err = (&transformations.MapValues{OutputColumn: "out"}).Transform(map[string]interface{}{})
+	assert.EqualError(t, err, "MapValues out has no table")
+}
+
+func TestMapValuesErrors(t *testing.T) {
+	tcs := []struct {
+		old, new string
+		expected string
+	}{
+		{` outputColumn="data:rate"`, ``, "MapValues has no outputColumn"},
+		{`<InlineTable>`, `<InlineTable/><Table>`, "unexpected element in MapValues: Table"},
+		{`<data:rate>0.5</data:rate>`, `<data:rate>half</data:rate>`, `invalid value in column data:rate of MapValues: strconv.ParseFloat: parsing "half": invalid syntax`},
+	}
+	for _, tc := range tcs {
+		var df transformations.DerivedField
+		err := xml.Unmarshal([]byte(strings.Replace(mapValuesXML, tc.old, tc.new, 1)), &df)
+		if err == nil {
+			_, err = df.Transform(map[string]interface{}{"state": "CA", "band": 1.0})
+		}
+		assert.EqualError(t, err, tc.expected, tc.new)
+	}
+
+	var mv transformations.MapValues
+	err := xml.Unmarshal([]byte(`<MapValues outputColumn="out"><FieldColumnPair field="x" column="in"/></MapValues>`), &mv)
+	assert.EqualError(t, err, "MapValues out has no table")
+	err = xml.Unmarshal([]byte(`<MapValues outputColumn="out"><TableLocator/></MapValues>`), &mv)
+	assert.EqualError(t, err, "invalid MapValues out: TableLocator has no path")
+}
+
+func TestFunctions(t *testing.T) {
+	values := map[string]interface{}{
+		"x": 2.5, "n": -3.0, "s": " Hello World ", "date": "2021-03-04T05:06:07", "flag": true, "missing": nil,
+	}
+	tcs := []struct {
+		xml      string
+		expected interface{}
+	}{
+		{`<Apply function="+"><FieldRef field="x"/><Constant>1</Constant></Apply>`, 3.5},
+		{`<Apply function="min"><FieldRef field="x"/><FieldRef field="n"/><FieldRef field="missing"/></Apply>`, -3.0},
+		{`<Apply function="max"><FieldRef field="x"/><FieldRef field="n"/></Apply>`, 2.5},
+		{`<Apply function="sum"><FieldRef field="x"/><FieldRef field="n"/><Constant>4</Constant></Apply>`, 3.5},
+		{`<Apply function="avg"><FieldRef field="x"/><FieldRef field="missing"/><Constant>4.5</Constant></Apply>`, 3.5},
+		{`<Apply function="median"><Constant>3</Constant><Constant>1</Constant><Constant>10</Constant><Constant>2</Constant></Apply>`, 2.5},
+		{`<Apply function="product"><FieldRef field="x"/><FieldRef field="n"/></Apply>`, -7.5},
+		{`<Apply function="sum"><FieldRef field="missing"/></Apply>`, nil},
+		{`<Apply function="log10"><Constant>1000</Constant></Apply>`, 3.0},
+		{`<Apply function="ln"><Constant>1</Constant></Apply>`, 0.0},
+		{`<Apply function="sqrt"><Constant>16</Constant></Apply>`, 4.0},
+		{`<Apply function="abs"><FieldRef field="n"/></Apply>`, 3.0},
+		{`<Apply function="pow"><FieldRef field="x"/><Constant>2</Constant></Apply>`, 6.25},
+		{`<Apply function="threshold"><FieldRef field="x"/><Constant>2</Constant></Apply>`, 1.0},
+		{`<Apply function="floor"><FieldRef field="n"/></Apply>`, -3.0},
+		{`<Apply function="ceil"><FieldRef field="x"/></Apply>`, 3.0},
+		{`<Apply function="round"><FieldRef field="x"/></Apply>`, 3.0},
+		{`<Apply function="round"><Constant>-2.5</Constant></Apply>`, -2.0},
+		{`<Apply function="modulo"><FieldRef field="n"/><Constant>2</Constant></Apply>`, 1.0},
+		{`<Apply function="hypot"><Constant>3</Constant><Constant>4</Constant></Apply>`, 5.0},
+		{`<Apply function="atan2"><Constant>0</Constant><Constant>-1</Constant></Apply>`, math.Pi},
+		{`<Apply function="stdNormalCDF"><Constant>0</Constant></Apply>`, 0.5},
+		{`<Apply function="stdNormalIDF"><Constant>0.975</Constant></Apply>`, 1.959963984540054},
+		{`<Apply function="normalPDF"><Constant>1</Constant><Constant>1</Constant><Constant>2</Constant></Apply>`, 0.19947114020071635},
+		{`<Apply function="and"><FieldRef field="flag"/><Constant dataType="boolean">false</Constant></Apply>`, false},
+		{`<Apply function="and"><FieldRef field="flag"/><FieldRef field="missing"/></Apply>`, nil},
+		{`<Apply function="or"><FieldRef field="flag"/><FieldRef field="missing"/></Apply>`, true},
+		{`<Apply function="not"><FieldRef field="flag"/></Apply>`, false},
+		{`<Apply function="equal"><FieldRef field="x"/><Constant dataType="string">2.50</Constant></Apply>`, true},
+		{`<Apply function="notEqual"><FieldRef field="x"/><FieldRef field="missing"/></Apply>`, nil},
+		{`<Apply function="isNotIn"><FieldRef field="n"/><Constant>1</Constant><Constant>2</Constant></Apply>`, true},
+		{`<Apply function="isIn"><FieldRef field="n"/><Constant>1</Constant><Constant>-3</Constant></Apply>`, true},
+		{`<Apply function="isMissing"><FieldRef field="missing"/></Apply>`, true},
+		{`<Apply function="isNotMissing"><FieldRef field="x"/></Apply>`, true},
+		{`<Apply function="isValid"><FieldRef field="other"/></Apply>`, false},
+		{`<Apply function="if"><FieldRef field="flag"/><Constant>1</Constant><Constant>2</Constant></Apply>`, 1.0},
+		{`<Apply function="if"><Apply function="lessThan"><FieldRef field="x"/><Constant>0</Constant></Apply><Constant>1</Constant></Apply>`, nil},
+		{`<Apply function="if"><FieldRef field="missing"/><Constant>1</Constant><Constant>2</Constant></Apply>`, nil},
+		// only the returned branch is evaluated
+		{`<Apply function="if">
+			<Apply function="greaterThan"><FieldRef field="n"/><Constant>0</Constant></Apply>
+			<Apply function="ln"><FieldRef field="n"/></Apply>
+			<Constant>0</Constant>
+		</Apply>`, 0.0},
+		{`<Apply function="uppercase"><FieldRef field="s"/></Apply>`, " HELLO WORLD "},
+		{`<Apply function="lowercase"><FieldRef field="s"/></Apply>`, " hello world "},
+		{`<Apply function="trimBlanks"><FieldRef field="s"/></Apply>`, "Hello World"},
+		{`<Apply function="stringLength"><FieldRef field="s"/></Apply>`, 13.0},
+		{`<Apply function="substring"><FieldRef field="s"/><Constant>2</Constant><Constant>5</Constant></Apply>`, "Hello"},
+		{`<Apply function="substring"><FieldRef field="s"/><Constant>8</Constant><Constant>50</Constant></Apply>`, "World "},
+		{`<Apply function="concat"><Constant dataType="string">id-</Constant><Constant>7</Constant></Apply>`, "id-7"},
+		{`<Apply function="replace"><FieldRef field="s"/><Constant dataType="string">o(\w)</Constant><Constant dataType="string">0$1</Constant></Apply>`, " Hello W0rld "},
+		{`<Apply function="matches"><FieldRef field="s"/><Constant dataType="string">^ H.*d $</Constant></Apply>`, true},
+		{`<Apply function="formatNumber"><FieldRef field="x"/><Constant dataType="string">%.3f</Constant></Apply>`, "2.500"},
+		{`<Apply function="formatNumber"><FieldRef field="n"/><Constant dataType="string">%3d</Constant></Apply>`, " -3"},
+		{`<Apply function="formatDatetime"><FieldRef field="date"/><Constant dataType="string">%d/%m/%Y %H:%M</Constant></Apply>`, "04/03/2021 05:06"},
+		{`<Apply function="dateDaysSinceYear"><FieldRef field="date"/><Constant>2021</Constant></Apply>`, 62.0},
+		{`<Apply function="dateDaysSinceYear"><Constant dataType="string">1960-01-03</Constant><Constant>1960</Constant></Apply>`, 2.0},
+		{`<Apply function="dateSecondsSinceYear"><FieldRef field="date"/><Constant>2021</Constant></Apply>`, 5375167.0},
+		{`<Apply function="dateSecondsSinceMidnight"><FieldRef field="date"/></Apply>`, 18367.0},
+		// mapMissingTo replaces the result of a missing argument, and defaultValue a missing result
+		{`<Apply function="+" mapMissingTo="-1"><FieldRef field="missing"/><Constant>1</Constant></Apply>`, -1.0},
+		{`<Apply function="+"><FieldRef field="missing" mapMissingTo="2"/><Constant>1</Constant></Apply>`, 3.0},
+		{`<Apply function="+" mapMissingTo="-1"><FieldRef field="other" mapMissingTo="2"/><Constant>1</Constant></Apply>`, 3.0},
+		{`<Apply function="if" defaultValue="none"><Constant dataType="boolean">false</Constant><Constant>1</Constant></Apply>`, "none"},
+		{`<Apply function="ln" invalidValueTreatment="asMissing"><FieldRef field="s"/></Apply>`, nil},
+		{`<Apply function="/" invalidValueTreatment="asMissing" defaultValue="0"><FieldRef field="s"/><Constant>2</Constant></Apply>`, 0.0},
+		// results which are not finite numbers are valid
+		{`<Apply function="/"><FieldRef field="x"/><Constant>0</Constant></Apply>`, math.Inf(1)},
+		{`<Apply function="/" invalidValueTreatment="asMissing"><FieldRef field="n"/><Constant>0</Constant></Apply>`, math.Inf(-1)},
+	}
+	for _, tc := range tcs {
+		var a transformations.Apply
+		err := xml.Unmarshal([]byte(tc.xml), &a)
+		assert.NoError(t, err, tc.xml)
+		output, err := a.Transform(values)
+		assert.NoError(t, err, tc.xml)
+		if f, ok := tc.expected.(float64); ok && !math.IsInf(f, 0) {
+			assert.InDelta(t, f, output, 1e-9, tc.xml)
+		} else {
+			assert.Equal(t, tc.expected, output, tc.xml)
+		}
+	}
+}
+
+func TestFunctionErrors(t *testing.T) {
+	tcs := []struct {
+		xml      string
+		expected string
+	}{
+		{`<Apply function="x-weekday"><Constant>1</Constant></Apply>`, "unknown function: x-weekday"},
+		{`<Apply function="+"><Constant>1</Constant></Apply>`, "function + takes 2 arguments, got 1"},
+		{`<Apply function="isIn"><Constant>1</Constant></Apply>`, "function isIn takes at least 2 arguments, got 1"},
+		{`<Apply function="if"><Constant>1</Constant></Apply>`, "function if takes 2 to 3 arguments, got 1"},
+		{`<Apply function="ln" invalidValueTreatment="asDefault"><Constant>1</Constant></Apply>`, "unknown invalidValueTreatment of Apply: asDefault"},
+		{`<Apply function="if"><Apply function="sqrt"/><Constant>1</Constant></Apply>`, "function sqrt takes 1 arguments, got 0"},
+		{`<Apply function="+"><Constant dataType="integer">one</Constant><Constant>1</Constant></Apply>`, `invalid integer Constant: strconv.ParseFloat: parsing "one": invalid syntax`},
+	}
+	for _, tc := range tcs {
+		var a transformations.Apply
+		err := xml.Unmarshal([]byte(tc.xml), &a)
+		assert.EqualError(t, err, tc.expected, tc.xml)
+	}
+
+	values := map[string]interface{}{"n": -3.0, "s": "abc"}
+	evalTcs := []struct {
+		xml      string
+		expected string
+	}{
+		{`<Apply function="ln"><FieldRef field="s"/></Apply>`, "invalid value in function ln: abc is not a number"},
+		{`<Apply function="*"><FieldRef field="s"/><Constant>2</Constant></Apply>`, "invalid value in function *: abc is not a number"},
+		{`<Apply function="not"><FieldRef field="s"/></Apply>`, "invalid value in function not: abc is not a boolean"},
+		{`<Apply function="dateSecondsSinceMidnight"><FieldRef field="s"/></Apply>`, "invalid value in function dateSecondsSinceMidnight: abc is not a date"},
+	}
+	for _, tc := range evalTcs {
+		var a transformations.Apply
+		err := xml.Unmarshal([]byte(tc.xml), &a)
+		assert.NoError(t, err, tc.xml)
+		_, err = a.Transform(values)
+		assert.EqualError(t, err, tc.expected, tc.xml)
+	}
+}
+
+var defineFunctionXML = `<TransformationDictionary>
+	<DefineFunction name="scale" optype="continuous" dataType="double">
+		<ParameterField name="value" dataType="double"/>
+		<ParameterField name="mean" dataType="double"/>
+		<Apply function="-">
+			<FieldRef field="value"/>
+			<FieldRef field="mean"/>
+		</Apply>
+	</DefineFunction>
+	<DefineFunction name="clip" optype="continuous" dataType="double">
+		<ParameterField name="value"/>
+		<Apply function="if">
+			<Apply function="isMissing"><FieldRef field="value"/></Apply>
+			<Constant dataType="double">0</Constant>
+			<Apply function="max">
+				<Apply function="scale"><FieldRef field="value"/><Constant dataType="double">10</Constant></Apply>
+				<Constant dataType="double">0</Constant>
+			</Apply>
+		</Apply>
+	</DefineFunction>
+	<DerivedField name="clipped" optype="continuous" dataType="double">
+		<Apply function="clip"><FieldRef field="x"/></Apply>
+	</DerivedField>
+</TransformationDictionary>`
+
+func TestDefineFunction(t *testing.T) {
+	var td transformations.TransformationDictionary
+	err := xml.Unmarshal([]byte(defineFunctionXML), &td)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(td.DefineFunctions))
+	assert.Equal(t, "value", td.DefineFunctions[0].ParameterFields[0].Name)
+	tcs := []struct {
+		x        interface{}
+		expected interface{}
+	}{
+		{"14", 4.0},
+		{3.0, 0.0},
+		// the function is called with missing arguments, which its expression handles
+		{nil, 0.0},
+	}
+	for _, tc := range tcs {
+		values := map[string]interface{}{"x": tc.x}
+		err := td.Transform(values)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected, values["clipped"])
+	}
+
+	res, err := td.DefineFunctions[0].Call([]interface{}{"1.5", 1.0})
+	assert.NoError(t, err)
+	assert.Equal(t, 0.5, res)
+	_, err = td.DefineFunctions[0].Call([]interface{}{"one", 1.0})
+	assert.EqualError(t, err, "invalid value of function scale: one is not a number")
+
+	// the functions of a dictionary are not visible once it is decoded on its own
+	var a transformations.Apply
+	err = xml.Unmarshal([]byte(`<Apply function="clip"><Constant>1</Constant></Apply>`), &a)
+	assert.EqualError(t, err, "unknown function: clip")
+
+	// but they are to the later elements of its document
+	doc := transformations.NewDocument("")
+	assert.NoError(t, xml.Unmarshal([]byte(defineFunctionXML), doc.NewTransformationDictionary()))
+	expr := doc.NewExpression("Apply")
+	err = xml.Unmarshal([]byte(`<Apply function="clip"><Constant>14</Constant></Apply>`), expr)
+	assert.NoError(t, err)
+	res, err = expr.Transform(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 4.0, res)
+}
+
+func TestDefineFunctionErrors(t *testing.T) {
+	tcs := []struct {
+		old, new string
+		expected string
+	}{
+		{`<Apply function="clip">`, `<Apply function="clamp">`, "invalid DerivedField clipped: unknown function: clamp"},
+		{`<Apply function="clip"><FieldRef field="x"/></Apply>`, `<Apply function="clip"/>`, "invalid DerivedField clipped: function clip takes 1 arguments, got 0"},
+		{`name="clip"`, `name="scale"`, "function scale is already defined"},
+		{`name="clip"`, `name="sqrt"`, "function sqrt is already defined"},
+		{`<ParameterField name="value"/>`, ``, "DefineFunction clip has no ParameterField"},
+		{`<DefineFunction name="scale" optype="continuous" dataType="double">`, `<DefineFunction optype="continuous" dataType="double">`, "DefineFunction has no name"},
+		{`<Apply function="scale"><FieldRef field="value"/>`, `<Apply function="clip"><FieldRef field="value"/>`, "invalid DefineFunction clip: unknown function: clip"},
+		{`<DerivedField name="clipped"`, `<MapValues/><DerivedField name="clipped"`, "unexpected element in TransformationDictionary: MapValues"},
+	}
+	for _, tc := range tcs {
+		var td transformations.TransformationDictionary
+		err := xml.Unmarshal([]byte(strings.Replace(defineFunctionXML, tc.old, tc.new, 1)), &td)
+		assert.EqualError(t, err, tc.expected, tc.new)
+	}
+}
+
+var textIndexXML = `<DerivedField name="tf(dog)" optype="continuous" dataType="double">
+	<TextIndex textField="text" localTermWeights="termFrequency">
+		<Constant dataType="string">dog</Constant>
+	</TextIndex>
+</DerivedField>`
+
+func TestTextIndex(t *testing.T) {
+	const text = "The quick brown fox jumps over the lazy dog. The dog sleeps!"
+	tcs := []struct {
+		attrs    string
+		term     string
+		text     string
+		expected float64
+	}{
+		{``, "dog", text, 2},
+		{``, "the", text, 3},
+		{``, "Lazy dog", text, 1},
+		{``, "cat", text, 0},
+		{`localTermWeights="binary"`, "dog", text, 1},
+		{`localTermWeights="binary"`, "cat", text, 0},
+		{`localTermWeights="logarithmic"`, "dog", text, math.Log10(3)},
+		// half of one plus the frequency of the term over that of the most frequent token, "the"
+		{`localTermWeights="augmentedNormalizedTermFrequency"`, "dog", text, 0.5 * (1 + 2.0/3)},
+		{`localTermWeights="augmentedNormalizedTermFrequency"`, "cat", text, 0},
+		{`isCaseSensitive="true"`, "the", text, 1},
+		{`isCaseSensitive="true"`, "The", text, 2},
+		{`maxLevenshteinDistance="1"`, "fix", text, 1},
+		{`maxLevenshteinDistance="1"`, "dog", "dog dot dots", 2},
+		{`maxLevenshteinDistance="1" countHits="bestHits"`, "dog", "dog dot dots", 1},
+		{`maxLevenshteinDistance="1" countHits="bestHits"`, "dug", "dog dot dots", 1},
+		{`maxLevenshteinDistance="2"`, "lasy dig", text, 1},
+		{`wordSeparatorCharacterRE="[\s,]+"`, "b", "a,b, c b", 2},
+		// without tokenizing, punctuation stays part of the tokens
+		{`tokenize="false"`, "dog", text, 1},
+		{`tokenize="false"`, "dog.", text, 1},
+	}
+	for _, tc := range tcs {
+		doc := strings.Replace(textIndexXML, `localTermWeights="termFrequency"`, tc.attrs, 1)
+		doc = strings.Replace(doc, ">dog<", ">"+tc.term+"<", 1)
+		var df transformations.DerivedField
+		err := xml.Unmarshal([]byte(doc), &df)
+		assert.NoError(t, err, tc.attrs)
+		output, err := df.Transform(map[string]interface{}{"text": tc.text})
+		assert.NoError(t, err)
+		assert.InDelta(t, tc.expected, output, 1e-12, "%s %s", tc.attrs, tc.term)
+	}
+
+	var df transformations.DerivedField
+	err := xml.Unmarshal([]byte(textIndexXML), &df)
+	assert.NoError(t, err)
+	output, err := df.Transform(map[string]interface{}{"text": ""})
+	assert.NoError(t, err)
+	assert.Nil(t, output)
+
+	// the term may be computed
+	err = xml.Unmarshal([]byte(strings.Replace(textIndexXML, `<Constant dataType="string">dog</Constant>`, `<FieldRef field="term"/>`, 1)), &df)
+	assert.NoError(t, err)
+	output, err = df.Transform(map[string]interface{}{"text": text, "term": "fox"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, output)
+	output, err = df.Transform(map[string]interface{}{"text": text})
+	assert.NoError(t, err)
+	assert.Nil(t, output)
+}
+
+var textIndexNormalizationXML = `<TransformationDictionary xmlns:data="http://jpmml.org/jpmml-model/InlineTable">
+	<DerivedField name="tf(run)" optype="continuous" dataType="double">
+		<TextIndex textField="text">
+			NORMALIZATION
+			<Constant>run</Constant>
+		</TextIndex>
+	</DerivedField>
+	<DerivedField name="tf(new_york)" optype="continuous" dataType="double">
+		<TextIndex textField="text">
+			NORMALIZATION
+			<Constant>new_york</Constant>
+		</TextIndex>
+	</DerivedField>
+	<DerivedField name="tf(num)" optype="continuous" dataType="double">
+		<Apply function="*">
+			<TextIndex textField="text" localTermWeights="binary">
+				NORMALIZATION
+				<Constant>num</Constant>
+			</TextIndex>
+			<Constant>1.5</Constant>
+		</Apply>
+	</DerivedField>
+</TransformationDictionary>`
+
+const normalization = `<TextIndexNormalization inField="data:from" outField="data:to" regexField="data:regex">
+	<InlineTable>
+		<row><data:from>runs</data:from><data:to>run</data:to></row>
+		<row><data:from>running</data:from><data:to>run</data:to></row>
+		<row><data:from>New York</data:from><data:to>new_york</data:to></row>
+		<row><data:from>[0-9]+</data:from><data:to>num</data:to><data:regex>true</data:regex></row>
+	</InlineTable>
+</TextIndexNormalization>`
+
+func TestTextIndexNormalization(t *testing.T) {
+	var td transformations.TransformationDictionary
+	err := xml.Unmarshal([]byte(strings.ReplaceAll(textIndexNormalizationXML, "NORMALIZATION", normalization)), &td)
+	assert.NoError(t, err)
+	values := map[string]interface{}{"text": "Running in New York, she runs 10 km; 5 km when it rains"}
+	assert.NoError(t, td.Transform(values))
+	assert.Equal(t, 2.0, values["tf(run)"])
+	assert.Equal(t, 1.0, values["tf(new_york)"])
+	assert.Equal(t, 1.5, values["tf(num)"])
+	// the tokens are kept with the values of the record, and split again when its text changes
+	values["text"] = "no numbers here"
+	assert.NoError(t, td.Transform(values))
+	assert.Equal(t, 0.0, values["tf(run)"])
+	assert.Equal(t, 0.0, values["tf(num)"])
+
+	// the rows of a table apply in order, and again until nothing changes if it is recursive
+	chained := `<TextIndexNormalization RECURSIVE>
+		<InlineTable>
+			<row><string>b</string><stem>c</stem></row>
+			<row><string>a</string><stem>b</stem></row>
+		</InlineTable>
+	</TextIndexNormalization>`
+	tcs := []struct {
+		normalizations string
+		expected       float64
+	}{
+		{strings.Replace(chained, "RECURSIVE", "", 1), 1},
+		{strings.Replace(chained, "RECURSIVE", `recursive="true"`, 1), 2},
+		// a later normalization applies to the result of an earlier one
+		{strings.Replace(chained, "RECURSIVE", "", 1) + strings.Replace(chained, "RECURSIVE", "", 1), 2},
+	}
+	for _, tc := range tcs {
+		doc := strings.Replace(textIndexXML, `<Constant dataType="string">dog</Constant>`, tc.normalizations+`<Constant>c</Constant>`, 1)
+		var df transformations.DerivedField
+		err := xml.Unmarshal([]byte(doc), &df)
+		assert.NoError(t, err)
+		output, err := df.Transform(map[string]interface{}{"text": "a b"})
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected, output, tc.normalizations)
+	}
+}
+
+func TestTextIndexErrors(t *testing.T) {
+	doc := strings.Replace(textIndexXML, `<Constant dataType="string">dog</Constant>`, normalization+`<Constant>dog</Constant>`, 1)
+	tcs := []struct {
+		old, new string
+		expected string
+	}{
+		{`localTermWeights="termFrequency"`, `localTermWeights="tf"`, "unknown localTermWeights of TextIndex text: tf"},
+		{`localTermWeights="termFrequency"`, `countHits="someHits"`, "unknown countHits of TextIndex text: someHits"},
+		{`localTermWeights="termFrequency"`, `maxLevenshteinDistance="x"`, `invalid maxLevenshteinDistance of TextIndex text: strconv.Atoi: parsing "x": invalid syntax`},
+		{`<Constant>dog</Constant>`, ``, "TextIndex text has no term"},
+		{`<Constant>dog</Constant>`, `<Value value="dog"/>`, "unexpected element in TextIndex: Value"},
+		{`localTermWeights="termFrequency"`, `wordSeparatorCharacterRE="["`, "invalid TextIndex text: invalid wordSeparatorCharacterRE: error parsing regexp: missing closing ]: `[`"},
+		{`[0-9]+`, `[0-9`, "invalid TextIndex text: invalid regular expression in row 4 of TextIndexNormalization: error parsing regexp: missing closing ]: `[0-9`"},
+		{`regexField="data:regex">`, `regexField="data:regex" recursive="maybe">`, `invalid TextIndex text: invalid recursive of TextIndexNormalization: strconv.ParseBool: parsing "maybe": invalid syntax`},
+		{`<InlineTable>`, `<Table>`, "invalid TextIndex text: unexpected element in TextIndexNormalization: Table"},
+	}
+	for _, tc := range tcs {
+		var df transformations.DerivedField
+		err := xml.Unmarshal([]byte(strings.Replace(doc, tc.old, tc.new, 1)), &df)
+		assert.EqualError(t, err, tc.expected, tc.new)
+	}
+
+	var tin transformations.TextIndexNormalization
+	err := xml.Unmarshal([]byte(`<TextIndexNormalization/>`), &tin)
+	assert.EqualError(t, err, "TextIndexNormalization has no table")
+}
+
+// BenchmarkTextIndex scores a bag of words of 200 terms, whose TextIndex elements share their tokenizer.
+func BenchmarkTextIndex(b *testing.B) {
+	var doc strings.Builder
+	doc.WriteString(`<TransformationDictionary>`)
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&doc, `<DerivedField name="tf(%d)" optype="continuous" dataType="double">
+			<TextIndex textField="text" localTermWeights="logarithmic">%s<Constant>word%d</Constant></TextIndex>
+		</DerivedField>`, i, normalization, i)
+	}
+	doc.WriteString(`</TransformationDictionary>`)
+	var td transformations.TransformationDictionary
+	if err := xml.Unmarshal([]byte(doc.String()), &td); err != nil {
+		b.Fatal(err)
+	}
+	text := strings.Repeat("word1 running word17, 42 words in New York; word199 ", 20)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		values := map[string]interface{}{"text": text + strconv.Itoa(i)}
+		if err := td.Transform(values); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -4,25 +4,36 @@ import (
 	"encoding/xml"
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/stillmatic/pummel/pkg/fields"
 	ms "github.com/stillmatic/pummel/pkg/miningschema"
 	"github.com/stillmatic/pummel/pkg/node"
+	"github.com/stillmatic/pummel/pkg/transformations"
+	"github.com/stillmatic/pummel/pkg/verification"
 )
 
 type TreeModel struct {
-	XMLName              xml.Name         `xml:"TreeModel"`
-	Node                 *node.Node       `xml:"Node"`
-	MiningSchema         *ms.MiningSchema `xml:"MiningSchema"`
-	ModelName            string           `xml:"modelName,attr"`
-	FunctionName         string           `xml:"functionName,attr"`
-	MissingValueStrategy string           `xml:"missingValueStrategy,attr"`
-	MissingValuePenalty  float64          `xml:"missingValuePenalty,attr"`
-	NoTrueChildStrategy  string           `xml:"noTrueChildStrategy,attr"`
-	SplitCharacteristic  string           `xml:"splitCharacteristic,attr"`
-	IsScorable           bool             `xml:"isScorable,attr"`
-	Output               *fields.Outputs  `xml:"Output"`
+	XMLName              xml.Name                        `xml:"TreeModel"`
+	Node                 *node.Node                      `xml:"Node"`
+	MiningSchema         *ms.MiningSchema                `xml:"MiningSchema"`
+	ModelName            string                          `xml:"modelName,attr"`
+	FunctionName         string                          `xml:"functionName,attr"`
+	MissingValueStrategy string                          `xml:"missingValueStrategy,attr"`
+	MissingValuePenalty  float64                         `xml:"missingValuePenalty,attr"`
+	NoTrueChildStrategy  string                          `xml:"noTrueChildStrategy,attr"`
+	SplitCharacteristic  string                          `xml:"splitCharacteristic,attr"`
+	IsScorable           bool                            `xml:"isScorable,attr"`
+	Output               *fields.Outputs                 `xml:"Output"`
+	ModelVerification    *verification.ModelVerification `xml:"ModelVerification"`
+
+	doc *transformations.Document
+}
+
+// NewTreeModel returns an empty TreeModel to be decoded into, whose expressions belong to doc.
+func NewTreeModel(doc *transformations.Document) *TreeModel {
+	return &TreeModel{doc: doc}
 }
 
 // generate an enum struct for MissingValueStrategy
@@ -58,7 +69,79 @@ var MissingValueStrategy = struct {
 	None:               "none",
 }
 
+func (t *TreeModel) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*t = TreeModel{XMLName: start.Name, doc: t.doc}
+	for _, attr := range start.Attr {
+		var err error
+		switch attr.Name.Local {
+		case "modelName":
+			t.ModelName = attr.Value
+		case "functionName":
+			t.FunctionName = attr.Value
+		case "missingValueStrategy":
+			t.MissingValueStrategy = attr.Value
+		case "missingValuePenalty":
+			t.MissingValuePenalty, err = strconv.ParseFloat(strings.TrimSpace(attr.Value), 64)
+		case "noTrueChildStrategy":
+			t.NoTrueChildStrategy = attr.Value
+		case "splitCharacteristic":
+			t.SplitCharacteristic = attr.Value
+		case "isScorable":
+			t.IsScorable, err = strconv.ParseBool(strings.TrimSpace(attr.Value))
+		}
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s of TreeModel", attr.Name.Local)
+		}
+	}
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tt := tok.(type) {
+		case xml.StartElement:
+			switch tt.Name.Local {
+			case "Node":
+				var n node.Node
+				if err := d.DecodeElement(&n, &tt); err != nil {
+					return err
+				}
+				t.Node = &n
+			case "MiningSchema":
+				var schema ms.MiningSchema
+				if err := d.DecodeElement(&schema, &tt); err != nil {
+					return err
+				}
+				t.MiningSchema = &schema
+			case "Output":
+				out := fields.NewOutputs(t.doc)
+				if err := d.DecodeElement(out, &tt); err != nil {
+					return err
+				}
+				t.Output = out
+			case "ModelVerification":
+				var mv verification.ModelVerification
+				if err := d.DecodeElement(&mv, &tt); err != nil {
+					return err
+				}
+				t.ModelVerification = &mv
+			default:
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
 func (t *TreeModel) Evaluate(features map[string]interface{}) (map[string]interface{}, error) {
+	out, err := t.evaluate(features)
+	return t.Output.Complete(features, out, err)
+}
+
+func (t *TreeModel) evaluate(features map[string]interface{}) (map[string]interface{}, error) {
 	rootPredRes, ok, err := t.Node.Evaluate(features)
 	if err != nil {
 		return nil, err
@@ -164,3 +247,15 @@ func (t *TreeModel) traverse(features map[string]interface{}) (*node.Node, error
 func (t *TreeModel) GetOutputField() string {
 	return t.MiningSchema.GetOutputField()
 }
+
+func (t *TreeModel) GetMiningSchema() *ms.MiningSchema {
+	return t.MiningSchema
+}
+
+func (t *TreeModel) GetOutput() *fields.Outputs {
+	return t.Output
+}
+
+func (t *TreeModel) GetModelVerification() *verification.ModelVerification {
+	return t.ModelVerification
+}
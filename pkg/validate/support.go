@@ -0,0 +1,906 @@
+package validate
+
+import "github.com/stillmatic/pummel/pkg/transformations"
+
+// The tables below describe the subset of PMML which pummel evaluates. They have to be kept in step
+// with the decoders: an element or attribute which is decoded but ignored is not supported.
+
+// rule describes what pummel supports of a single element.
+type rule struct {
+	attrs []string
+	// enums restricts the values of some of attrs.
+	enums map[string]enum
+	// fields lists the attributes which refer to a field by name.
+	fields   []string
+	children []string
+	// models is set if the element may contain model elements.
+	models bool
+}
+
+type enum struct {
+	kind   Kind
+	label  string
+	values []string
+}
+
+// opaque elements are accepted wherever they are listed as children, but their content is not checked,
+// since it does not affect evaluation.
+var opaque = map[string]bool{
+	"Header":            true,
+	"MiningBuildTask":   true,
+	"Extension":         true,
+	"ModelStats":        true,
+	"ModelExplanation":  true,
+	"ModelVerification": true,
+	"Interval":          true,
+	"PCovMatrix":        true,
+	"EventValues":       true,
+	"KohonenMap":        true,
+	"Covariances":       true,
+	"Counts":            true,
+	"NumericInfo":       true,
+	"InlineTable":       true,
+	"Array":             true,
+	"REAL-SparseArray":  true,
+	"INT-SparseArray":   true,
+	// a TimeSeriesModel ignores the algorithms other than its best fit, which is ARIMA or ExponentialSmoothing
+	"SpectralAnalysis":           true,
+	"SeasonalTrendDecomposition": true,
+	"StateSpaceModel":            true,
+	"GARCH":                      true,
+}
+
+var (
+	modelExtras = []string{"ModelStats", "ModelExplanation", "ModelVerification", "Extension"}
+	predicates  = []string{"SimplePredicate", "SimpleSetPredicate", "CompoundPredicate", "True", "False"}
+	expressions = []string{"Constant", "FieldRef", "Apply", "NormContinuous", "NormDiscrete", "Discretize", "MapValues", "TextIndex"}
+	// modelAttrs are common to every model element.
+	modelAttrs = []string{"modelName", "functionName", "algorithmName", "isScorable"}
+
+	activationFunctions = []string{
+		"threshold", "logistic", "tanh", "identity", "exponential", "reciprocal", "square", "Gauss", "sine", "cosine",
+		"Elliott", "arctan", "rectifier", "radialBasis",
+	}
+	neuralNormalizations      = []string{"none", "simplemax", "softmax"}
+	timeSeriesTransformations = []string{"none", "logarithmic", "squareroot"}
+	// distributions have a density; AnyDistribution does not, so it is left out.
+	distributions = []string{"GaussianDistribution", "PoissonDistribution", "UniformDistribution"}
+	measures      = []string{
+		"euclidean", "squaredEuclidean", "chebychev", "cityBlock", "minkowski",
+		"simpleMatching", "jaccard", "tanimoto", "binarySimilarity",
+	}
+	// compareFunctions leaves out table, which needs a matrix of the comparisons of the categories.
+	compareFunctions = []string{"absDiff", "gaussSim", "delta", "equal"}
+)
+
+func join(lists ...[]string) []string {
+	var out []string
+	for _, l := range lists {
+		out = append(out, l...)
+	}
+	return out
+}
+
+var rules = map[string]*rule{
+	"PMML": {
+		attrs:    []string{"version"},
+		children: []string{"Header", "MiningBuildTask", "DataDictionary", "TransformationDictionary", "Extension"},
+		models:   true,
+	},
+	"DataDictionary": {
+		attrs:    []string{"numberOfFields"},
+		children: []string{"DataField", "Extension"},
+	},
+	"DataField": {
+		attrs:    []string{"name", "displayName", "optype", "dataType", "isCyclic"},
+		children: []string{"Value", "Interval", "Extension"},
+	},
+	"Value": {
+		attrs: []string{"value", "displayValue", "property"},
+	},
+	"TransformationDictionary": {
+		children: []string{"DefineFunction", "DerivedField", "Extension"},
+	},
+	"DefineFunction": {
+		attrs:    []string{"name", "optype", "dataType"},
+		children: join(expressions, []string{"ParameterField", "Extension"}),
+	},
+	"ParameterField": {
+		attrs: []string{"name", "optype", "dataType", "displayName"},
+	},
+	"LocalTransformations": {
+		children: []string{"DerivedField"},
+	},
+	"DerivedField": {
+		attrs:    []string{"name", "displayName", "optype", "dataType"},
+		children: join(expressions, []string{"Value"}),
+	},
+	"FieldRef": {
+		attrs:  []string{"field"},
+		fields: []string{"field"},
+	},
+	"Constant": {
+		attrs: []string{"dataType", "missing"},
+		enums: map[string]enum{
+			"dataType": {UnsupportedValue, "constant data type", []string{"double", "float", "integer", "boolean", "string"}},
+		},
+	},
+	"Apply": {
+		attrs: []string{"function", "mapMissingTo", "defaultValue", "invalidValueTreatment"},
+		enums: map[string]enum{
+			"function":              {UnsupportedFunction, "function", transformations.Functions()},
+			"invalidValueTreatment": {UnsupportedValue, "invalid value treatment", []string{"returnInvalid", "asIs", "asMissing"}},
+		},
+		children: join(expressions, []string{"Extension"}),
+	},
+	"NormContinuous": {
+		attrs: []string{"field", "mapMissingTo", "outliers"},
+		enums: map[string]enum{
+			"outliers": {UnsupportedValue, "outlier treatment", []string{"asIs", "asMissingValues", "asExtremeValues"}},
+		},
+		fields:   []string{"field"},
+		children: []string{"LinearNorm", "Extension"},
+	},
+	"LinearNorm": {
+		attrs: []string{"orig", "norm"},
+	},
+	"NormDiscrete": {
+		attrs: []string{"field", "value", "method", "mapMissingTo"},
+		enums: map[string]enum{
+			"method": {UnsupportedValue, "normalization method", []string{"indicator"}},
+		},
+		fields: []string{"field"},
+	},
+	"Discretize": {
+		attrs:    []string{"field", "mapMissingTo", "defaultValue", "dataType"},
+		fields:   []string{"field"},
+		children: []string{"DiscretizeBin", "Extension"},
+	},
+	"DiscretizeBin": {
+		attrs:    []string{"binValue"},
+		children: []string{"Interval", "Extension"},
+	},
+	"MapValues": {
+		attrs:    []string{"outputColumn", "dataType", "mapMissingTo", "defaultValue"},
+		children: []string{"FieldColumnPair", "InlineTable", "TableLocator", "Extension"},
+	},
+	"FieldColumnPair": {
+		attrs:  []string{"field", "column"},
+		fields: []string{"field"},
+	},
+	"TableLocator": {
+		children: []string{"Extension"},
+	},
+	"TextIndex": {
+		attrs: []string{
+			"textField", "localTermWeights", "isCaseSensitive", "maxLevenshteinDistance", "countHits",
+			"wordSeparatorCharacterRE", "tokenize",
+		},
+		enums: map[string]enum{
+			"localTermWeights": {UnsupportedValue, "local term weights", []string{"termFrequency", "binary", "logarithmic", "augmentedNormalizedTermFrequency"}},
+			"countHits":        {UnsupportedValue, "hit count", []string{"allHits", "bestHits"}},
+		},
+		fields:   []string{"textField"},
+		children: join(expressions, []string{"TextIndexNormalization", "Extension"}),
+	},
+	"TextIndexNormalization": {
+		attrs: []string{
+			"inField", "outField", "regexField", "recursive", "isCaseSensitive", "maxLevenshteinDistance",
+			"wordSeparatorCharacterRE", "tokenize",
+		},
+		children: []string{"InlineTable", "TableLocator", "Extension"},
+	},
+	"MiningSchema": {
+		children: []string{"MiningField", "Extension"},
+	},
+	"MiningField": {
+		attrs: []string{
+			"name", "usageType", "optype", "importance", "outliers", "lowValue", "highValue",
+			"missingValueTreatment", "invalidValueTreatment",
+		},
+		enums: map[string]enum{
+			"usageType":             {UnsupportedValue, "usage type", []string{"active", "target", "predicted", "supplementary", "group"}},
+			"outliers":              {UnsupportedValue, "outlier treatment", []string{"asIs"}},
+			"invalidValueTreatment": {UnsupportedValue, "invalid value treatment", []string{"asIs"}},
+		},
+		fields: []string{"name"},
+	},
+	"Output": {
+		children: []string{"OutputField", "Extension"},
+	},
+	"OutputField": {
+		attrs: []string{
+			"name", "displayName", "optype", "dataType", "feature", "value", "rank", "ruleFeature", "algorithm", "rankBasis",
+			"rankOrder", "isMultiValued", "isFinalResult",
+		},
+		enums: map[string]enum{
+			"algorithm": {UnsupportedValue, "association algorithm", []string{"recommendation", "exclusiveRecommendation", "ruleAssociation"}},
+			"rankBasis": {UnsupportedValue, "rank basis", []string{"confidence", "support", "lift", "leverage", "affinity"}},
+			"rankOrder": {UnsupportedValue, "rank order", []string{"descending", "ascending"}},
+		},
+		children: join(expressions, []string{"Extension"}),
+	},
+	"Targets": {
+		children: []string{"Target", "Extension"},
+	},
+	"Target": {
+		attrs:  []string{"field", "optype", "rescaleConstant", "rescaleFactor"},
+		fields: []string{"field"},
+	},
+
+	"SimplePredicate": {
+		attrs: []string{"field", "operator", "value"},
+		enums: map[string]enum{
+			"operator": {UnsupportedValue, "operator", []string{
+				"equal", "notEqual", "lessThan", "lessOrEqual", "greaterThan", "greaterOrEqual", "isMissing", "isNotMissing",
+			}},
+		},
+		fields: []string{"field"},
+	},
+	"SimpleSetPredicate": {
+		attrs: []string{"field", "booleanOperator"},
+		enums: map[string]enum{
+			"booleanOperator": {UnsupportedValue, "boolean operator", []string{"isIn", "isNotIn"}},
+		},
+		fields:   []string{"field"},
+		children: []string{"Array"},
+	},
+	"CompoundPredicate": {
+		attrs: []string{"booleanOperator"},
+		enums: map[string]enum{
+			"booleanOperator": {UnsupportedValue, "boolean operator", []string{"and", "or", "xor", "surrogate"}},
+		},
+		children: []string{"SimplePredicate", "SimpleSetPredicate", "True", "False"},
+	},
+	"True":  {},
+	"False": {},
+
+	"TreeModel": {
+		attrs: join(modelAttrs, []string{"missingValueStrategy", "noTrueChildStrategy", "splitCharacteristic"}),
+		enums: map[string]enum{
+			"missingValueStrategy": {UnsupportedValue, "missing value strategy", []string{"lastPrediction", "nullPrediction", "defaultChild", "none"}},
+			"noTrueChildStrategy":  {UnsupportedValue, "no true child strategy", []string{"returnLastPrediction"}},
+		},
+		children: join([]string{"MiningSchema", "Output", "Node"}, modelExtras),
+	},
+	"Node": {
+		attrs:    []string{"id", "score", "recordCount", "defaultChild"},
+		children: join(predicates, []string{"Node", "ScoreDistribution"}),
+	},
+	"ScoreDistribution": {
+		attrs: []string{"value", "recordCount", "confidence", "probability"},
+	},
+
+	"RegressionModel": {
+		attrs: join(modelAttrs, []string{"modelType", "targetFieldName", "normalizationMethod"}),
+		enums: map[string]enum{
+			"functionName":        {UnsupportedValue, "function name", []string{"regression", "classification"}},
+			"normalizationMethod": {UnsupportedNormalization, "normalization method", []string{"softmax", "logit"}},
+		},
+		children: []string{"MiningSchema", "Output", "LocalTransformations", "RegressionTable", "ModelVerification"},
+	},
+	"RegressionTable": {
+		attrs:    []string{"intercept", "targetCategory"},
+		children: []string{"NumericPredictor", "CategoricalPredictor", "PredictorTerm"},
+	},
+	"NumericPredictor": {
+		attrs:  []string{"name", "exponent", "coefficient"},
+		fields: []string{"name"},
+	},
+	"CategoricalPredictor": {
+		attrs:  []string{"name", "value", "coefficient"},
+		fields: []string{"name"},
+	},
+	"PredictorTerm": {
+		attrs:    []string{"name", "coefficient"},
+		children: []string{"FieldRef"},
+	},
+
+	"NeuralNetwork": {
+		attrs: join(modelAttrs, []string{"activationFunction", "normalizationMethod", "threshold", "width", "altitude", "numberOfLayers"}),
+		enums: map[string]enum{
+			"functionName":        {UnsupportedValue, "function name", []string{"regression", "classification"}},
+			"activationFunction":  {UnsupportedValue, "activation function", activationFunctions},
+			"normalizationMethod": {UnsupportedNormalization, "normalization method", neuralNormalizations},
+		},
+		children: join([]string{"MiningSchema", "Output", "LocalTransformations", "NeuralInputs", "NeuralLayer", "NeuralOutputs"}, modelExtras),
+	},
+	"NeuralInputs": {
+		attrs:    []string{"numberOfInputs"},
+		children: []string{"NeuralInput", "Extension"},
+	},
+	"NeuralInput": {
+		attrs:    []string{"id"},
+		children: []string{"DerivedField", "Extension"},
+	},
+	"NeuralLayer": {
+		attrs: []string{"numberOfNeurons", "activationFunction", "threshold", "width", "altitude", "normalizationMethod"},
+		enums: map[string]enum{
+			"activationFunction":  {UnsupportedValue, "activation function", activationFunctions},
+			"normalizationMethod": {UnsupportedNormalization, "normalization method", neuralNormalizations},
+		},
+		children: []string{"Neuron", "Extension"},
+	},
+	"Neuron": {
+		attrs:    []string{"id", "bias", "width", "altitude"},
+		children: []string{"Con", "Extension"},
+	},
+	"Con": {
+		attrs: []string{"from", "weight"},
+	},
+	"NeuralOutputs": {
+		attrs:    []string{"numberOfOutputs"},
+		children: []string{"NeuralOutput", "Extension"},
+	},
+	"NeuralOutput": {
+		attrs:    []string{"outputNeuron"},
+		children: []string{"DerivedField", "Extension"},
+	},
+
+	"SupportVectorMachineModel": {
+		attrs: join(modelAttrs, []string{"threshold", "svmRepresentation", "classificationMethod", "alternateBinaryTargetCategory", "maxWins"}),
+		enums: map[string]enum{
+			"functionName":         {UnsupportedValue, "function name", []string{"regression", "classification"}},
+			"svmRepresentation":    {UnsupportedValue, "svm representation", []string{"SupportVectors", "Coefficients"}},
+			"classificationMethod": {UnsupportedValue, "classification method", []string{"OneAgainstAll", "OneAgainstOne"}},
+		},
+		children: join([]string{
+			"MiningSchema", "Output", "LocalTransformations", "LinearKernelType", "PolynomialKernelType",
+			"RadialBasisKernelType", "SigmoidKernelType", "VectorDictionary", "SupportVectorMachine",
+		}, modelExtras),
+	},
+	"LinearKernelType": {
+		attrs: []string{"description"},
+	},
+	"PolynomialKernelType": {
+		attrs: []string{"description", "gamma", "coef0", "degree"},
+	},
+	"RadialBasisKernelType": {
+		attrs: []string{"description", "gamma"},
+	},
+	"SigmoidKernelType": {
+		attrs: []string{"description", "gamma", "coef0"},
+	},
+	"VectorDictionary": {
+		attrs:    []string{"numberOfVectors"},
+		children: []string{"VectorFields", "VectorInstance", "Extension"},
+	},
+	"VectorFields": {
+		attrs:    []string{"numberOfFields"},
+		children: []string{"FieldRef", "CategoricalPredictor", "Extension"},
+	},
+	"VectorInstance": {
+		attrs:    []string{"id"},
+		children: []string{"Array", "REAL-SparseArray", "INT-SparseArray", "Extension"},
+	},
+	"SupportVectorMachine": {
+		attrs:    []string{"targetCategory", "alternateTargetCategory", "threshold"},
+		children: []string{"SupportVectors", "Coefficients", "Extension"},
+	},
+	"SupportVectors": {
+		attrs:    []string{"numberOfAttributes", "numberOfSupportVectors"},
+		children: []string{"SupportVector", "Extension"},
+	},
+	"SupportVector": {
+		attrs: []string{"vectorId"},
+	},
+	"Coefficients": {
+		attrs:    []string{"numberOfCoefficients", "absoluteValue"},
+		children: []string{"Coefficient", "Extension"},
+	},
+	"Coefficient": {
+		attrs: []string{"value"},
+	},
+
+	"NaiveBayesModel": {
+		attrs: join(modelAttrs, []string{"threshold"}),
+		enums: map[string]enum{
+			"functionName": {UnsupportedValue, "function name", []string{"classification"}},
+		},
+		children: join([]string{"MiningSchema", "Output", "LocalTransformations", "BayesInputs", "BayesOutput"}, modelExtras),
+	},
+	"BayesInputs": {
+		children: []string{"BayesInput", "Extension"},
+	},
+	"BayesInput": {
+		attrs:    []string{"fieldName"},
+		fields:   []string{"fieldName"},
+		children: []string{"DerivedField", "PairCounts", "TargetValueStats", "Extension"},
+	},
+	"PairCounts": {
+		attrs:    []string{"value"},
+		children: []string{"TargetValueCounts", "Extension"},
+	},
+	"TargetValueCounts": {
+		children: []string{"TargetValueCount", "Extension"},
+	},
+	"TargetValueCount": {
+		attrs: []string{"value", "count"},
+	},
+	"TargetValueStats": {
+		children: []string{"TargetValueStat", "Extension"},
+	},
+	"TargetValueStat": {
+		attrs:    []string{"value"},
+		children: join(distributions, []string{"Extension"}),
+	},
+	"GaussianDistribution": {
+		attrs: []string{"mean", "variance"},
+	},
+	"PoissonDistribution": {
+		attrs: []string{"mean"},
+	},
+	"UniformDistribution": {
+		attrs: []string{"lower", "upper"},
+	},
+	"BayesOutput": {
+		attrs:    []string{"fieldName"},
+		fields:   []string{"fieldName"},
+		children: []string{"TargetValueCounts", "Extension"},
+	},
+
+	"GeneralRegressionModel": {
+		attrs: join(modelAttrs, []string{
+			"targetVariableName", "modelType", "targetReferenceCategory", "cumulativeLink", "linkFunction", "linkParameter",
+			"trialsVariable", "trialsValue", "distribution", "distParameter", "offsetVariable", "offsetValue", "modelDF",
+			"endTimeVariable", "startTimeVariable", "subjectIDVariable", "statusVariable", "baselineStrataVariable",
+		}),
+		enums: map[string]enum{
+			"functionName": {UnsupportedValue, "function name", []string{"regression", "classification"}},
+			"modelType": {UnsupportedValue, "model type", []string{
+				"regression", "generalLinear", "generalizedLinear", "multinomialLogistic", "ordinalMultinomial", "CoxRegression",
+			}},
+			"linkFunction": {UnsupportedValue, "link function", []string{
+				"cloglog", "identity", "log", "logc", "logit", "loglog", "negbin", "oddspower", "power", "probit",
+			}},
+			"cumulativeLink": {UnsupportedValue, "cumulative link", []string{"logit", "probit", "cloglog", "loglog", "cauchit"}},
+		},
+		fields: []string{
+			"targetVariableName", "trialsVariable", "offsetVariable", "endTimeVariable", "startTimeVariable", "subjectIDVariable",
+			"statusVariable", "baselineStrataVariable",
+		},
+		children: join([]string{
+			"MiningSchema", "Output", "LocalTransformations", "ParameterList", "FactorList", "CovariateList", "PPMatrix",
+			"PCovMatrix", "ParamMatrix", "EventValues", "BaseCumHazardTables",
+		}, modelExtras),
+	},
+	"ParameterList": {
+		children: []string{"Parameter", "Extension"},
+	},
+	"Parameter": {
+		attrs: []string{"name", "label", "referencePoint"},
+	},
+	"FactorList": {
+		children: []string{"Predictor", "Extension"},
+	},
+	"CovariateList": {
+		children: []string{"Predictor", "Extension"},
+	},
+	"Predictor": {
+		attrs:    []string{"name"},
+		fields:   []string{"name"},
+		children: []string{"Extension"},
+	},
+	"PPMatrix": {
+		children: []string{"PPCell", "Extension"},
+	},
+	"PPCell": {
+		attrs:  []string{"value", "predictorName", "parameterName", "targetCategory"},
+		fields: []string{"predictorName"},
+	},
+	"ParamMatrix": {
+		children: []string{"PCell", "Extension"},
+	},
+	"PCell": {
+		attrs: []string{"targetCategory", "parameterName", "beta", "df"},
+	},
+	"BaseCumHazardTables": {
+		attrs:    []string{"maxTime"},
+		children: []string{"BaselineStratum", "BaselineCell", "Extension"},
+	},
+	"BaselineStratum": {
+		attrs:    []string{"value", "label", "maxTime"},
+		children: []string{"BaselineCell", "Extension"},
+	},
+	"BaselineCell": {
+		attrs: []string{"time", "cumHazard"},
+	},
+
+	"Scorecard": {
+		attrs: join(modelAttrs, []string{"initialScore", "useReasonCodes", "reasonCodeAlgorithm", "baselineScore", "baselineMethod"}),
+		enums: map[string]enum{
+			"functionName":        {UnsupportedValue, "function name", []string{"regression"}},
+			"reasonCodeAlgorithm": {UnsupportedValue, "reason code algorithm", []string{"pointsAbove", "pointsBelow"}},
+			"baselineMethod":      {UnsupportedValue, "baseline method", []string{"max", "min", "mean", "neutral", "other"}},
+		},
+		children: join([]string{"MiningSchema", "Output", "LocalTransformations", "Characteristics"}, modelExtras),
+	},
+	"Characteristics": {
+		children: []string{"Characteristic", "Extension"},
+	},
+	"Characteristic": {
+		attrs:    []string{"name", "reasonCode", "baselineScore"},
+		children: []string{"Attribute", "Extension"},
+	},
+	"Attribute": {
+		attrs:    []string{"reasonCode", "partialScore"},
+		children: join(predicates, []string{"ComplexPartialScore", "Extension"}),
+	},
+	"ComplexPartialScore": {
+		children: join(expressions, []string{"Extension"}),
+	},
+
+	"ClusteringModel": {
+		attrs: join(modelAttrs, []string{"modelClass", "numberOfClusters"}),
+		enums: map[string]enum{
+			"functionName": {UnsupportedValue, "function name", []string{"clustering"}},
+		},
+		children: join([]string{
+			"MiningSchema", "Output", "LocalTransformations", "ComparisonMeasure", "ClusteringField",
+			"MissingValueWeights", "Cluster",
+		}, modelExtras),
+	},
+	"ComparisonMeasure": {
+		attrs: []string{"kind", "compareFunction", "minimum", "maximum"},
+		enums: map[string]enum{
+			"compareFunction": {UnsupportedValue, "compare function", compareFunctions},
+		},
+		children: join(measures, []string{"Extension"}),
+	},
+	"euclidean":        {children: []string{"Extension"}},
+	"squaredEuclidean": {children: []string{"Extension"}},
+	"chebychev":        {children: []string{"Extension"}},
+	"cityBlock":        {children: []string{"Extension"}},
+	"minkowski": {
+		attrs:    []string{"p-parameter"},
+		children: []string{"Extension"},
+	},
+	"simpleMatching": {children: []string{"Extension"}},
+	"jaccard":        {children: []string{"Extension"}},
+	"tanimoto":       {children: []string{"Extension"}},
+	"binarySimilarity": {
+		attrs: []string{
+			"c00-parameter", "c01-parameter", "c10-parameter", "c11-parameter",
+			"d00-parameter", "d01-parameter", "d10-parameter", "d11-parameter",
+		},
+		children: []string{"Extension"},
+	},
+	"ClusteringField": {
+		attrs: []string{"field", "isCenterField", "fieldWeight", "similarityScale", "compareFunction"},
+		enums: map[string]enum{
+			"compareFunction": {UnsupportedValue, "compare function", compareFunctions},
+		},
+		fields:   []string{"field"},
+		children: []string{"Extension"},
+	},
+	"MissingValueWeights": {
+		children: []string{"Array", "Extension"},
+	},
+	"Cluster": {
+		attrs:    []string{"id", "name", "size"},
+		children: []string{"Array", "Partition", "KohonenMap", "Covariances", "Extension"},
+	},
+	"Partition": {
+		attrs:    []string{"name", "size"},
+		children: []string{"PartitionFieldStats", "Extension"},
+	},
+	"PartitionFieldStats": {
+		attrs:    []string{"field", "weighted"},
+		fields:   []string{"field"},
+		children: []string{"Counts", "NumericInfo", "Array", "Extension"},
+	},
+
+	"NearestNeighborModel": {
+		attrs: join(modelAttrs, []string{
+			"numberOfNeighbors", "continuousScoringMethod", "categoricalScoringMethod", "instanceIdVariable", "threshold",
+		}),
+		enums: map[string]enum{
+			"functionName":             {UnsupportedValue, "function name", []string{"classification", "regression", "mixed"}},
+			"continuousScoringMethod":  {UnsupportedValue, "scoring method", []string{"average", "median", "weightedAverage"}},
+			"categoricalScoringMethod": {UnsupportedValue, "scoring method", []string{"majorityVote", "weightedMajorityVote"}},
+		},
+		children: join([]string{
+			"MiningSchema", "Output", "LocalTransformations", "TrainingInstances", "ComparisonMeasure", "KNNInputs",
+		}, modelExtras),
+	},
+	"TrainingInstances": {
+		attrs:    []string{"isTransformed", "recordCount", "fieldCount"},
+		children: []string{"InstanceFields", "InlineTable", "Extension"},
+	},
+	"InstanceFields": {
+		children: []string{"InstanceField", "Extension"},
+	},
+	"InstanceField": {
+		attrs:    []string{"field", "column"},
+		children: []string{"Extension"},
+	},
+	"KNNInputs": {
+		children: []string{"KNNInput", "Extension"},
+	},
+	"KNNInput": {
+		attrs: []string{"field", "fieldWeight", "compareFunction"},
+		enums: map[string]enum{
+			"compareFunction": {UnsupportedValue, "compare function", compareFunctions},
+		},
+		fields:   []string{"field"},
+		children: []string{"Extension"},
+	},
+
+	"RuleSetModel": {
+		attrs: modelAttrs,
+		enums: map[string]enum{
+			"functionName": {UnsupportedValue, "function name", []string{"classification", "regression"}},
+		},
+		children: join([]string{"MiningSchema", "Output", "LocalTransformations", "RuleSet"}, modelExtras),
+	},
+	"RuleSet": {
+		attrs:    []string{"recordCount", "nbCorrect", "defaultScore", "defaultConfidence"},
+		children: []string{"RuleSelectionMethod", "ScoreDistribution", "SimpleRule", "CompoundRule", "Extension"},
+	},
+	"RuleSelectionMethod": {
+		attrs: []string{"criterion"},
+		enums: map[string]enum{
+			"criterion": {UnsupportedValue, "rule selection criterion", []string{"weightedSum", "weightedMax", "firstHit"}},
+		},
+		children: []string{"Extension"},
+	},
+	"SimpleRule": {
+		attrs:    []string{"id", "score", "recordCount", "nbCorrect", "confidence", "weight"},
+		children: join(predicates, []string{"ScoreDistribution", "Extension"}),
+	},
+	"CompoundRule": {
+		children: join(predicates, []string{"SimpleRule", "CompoundRule", "Extension"}),
+	},
+
+	"AssociationModel": {
+		attrs: join(modelAttrs, []string{
+			"numberOfTransactions", "maxNumberOfItemsPerTA", "avgNumberOfItemsPerTA", "minimumSupport", "minimumConfidence",
+			"lengthLimit", "numberOfItems", "numberOfItemsets", "numberOfRules",
+		}),
+		children: join([]string{"MiningSchema", "Output", "LocalTransformations", "Item", "Itemset", "AssociationRule"}, modelExtras),
+	},
+	"Item": {
+		attrs:    []string{"id", "value", "mappedValue", "weight"},
+		children: []string{"Extension"},
+	},
+	"Itemset": {
+		attrs:    []string{"id", "support", "numberOfItems"},
+		children: []string{"ItemRef", "Extension"},
+	},
+	"ItemRef": {
+		attrs:    []string{"itemRef"},
+		children: []string{"Extension"},
+	},
+	"AssociationRule": {
+		attrs:    []string{"id", "antecedent", "consequent", "support", "confidence", "lift", "leverage", "affinity"},
+		children: []string{"Extension"},
+	},
+
+	"AnomalyDetectionModel": {
+		attrs: join(modelAttrs, []string{"algorithmType", "sampleDataSize"}),
+		enums: map[string]enum{
+			"algorithmType": {UnsupportedValue, "anomaly detection algorithm", []string{"iforest", "ocsvm", "other"}},
+		},
+		children: join([]string{"MiningSchema", "Output", "LocalTransformations"}, modelExtras),
+		models:   true,
+	},
+
+	"TimeSeriesModel": {
+		attrs: join(modelAttrs, []string{"bestFit"}),
+		enums: map[string]enum{
+			"functionName": {UnsupportedValue, "function name", []string{"timeSeries"}},
+			"bestFit":      {UnsupportedValue, "forecasting algorithm", []string{"ARIMA", "ExponentialSmoothing"}},
+		},
+		children: join([]string{
+			"MiningSchema", "Output", "LocalTransformations", "TimeSeries", "ExponentialSmoothing", "ARIMA",
+			"SpectralAnalysis", "SeasonalTrendDecomposition", "StateSpaceModel", "GARCH",
+		}, modelExtras),
+	},
+	"TimeSeries": {
+		attrs:    []string{"usage", "startTime", "endTime"},
+		children: []string{"TimeValue"},
+	},
+	"TimeValue": {
+		attrs: []string{"index", "time", "value", "standardError"},
+	},
+	"ExponentialSmoothing": {
+		attrs: []string{"RMSE", "transformation"},
+		enums: map[string]enum{
+			"transformation": {UnsupportedValue, "transformation", timeSeriesTransformations},
+		},
+		children: []string{"Level", "Trend_ExpoSmooth", "Seasonality_ExpoSmooth"},
+	},
+	"Level": {
+		attrs: []string{"alpha", "smoothedValue"},
+	},
+	"Trend_ExpoSmooth": {
+		attrs: []string{"trend", "gamma", "phi", "smoothedValue"},
+		enums: map[string]enum{
+			"trend": {UnsupportedValue, "trend", []string{"additive", "damped_additive", "multiplicative", "damped_multiplicative"}},
+		},
+	},
+	"Seasonality_ExpoSmooth": {
+		attrs: []string{"type", "period", "unit", "phase", "delta"},
+		enums: map[string]enum{
+			"type": {UnsupportedValue, "seasonality", []string{"additive", "multiplicative"}},
+		},
+		children: []string{"Array"},
+	},
+	"ARIMA": {
+		attrs: []string{"RMSE", "transformation", "constantTerm", "predictionMethod"},
+		enums: map[string]enum{
+			"transformation":   {UnsupportedValue, "transformation", timeSeriesTransformations},
+			"predictionMethod": {UnsupportedValue, "prediction method", []string{"conditionalLeastSquares", "exactLeastSquares"}},
+		},
+		children: []string{"NonseasonalComponent", "SeasonalComponent", "MaximumLikelihoodStat", "OutlierEffect", "Extension"},
+	},
+	"NonseasonalComponent": {
+		attrs:    []string{"p", "d", "q"},
+		children: []string{"AR", "MA", "Extension"},
+	},
+	"SeasonalComponent": {
+		attrs:    []string{"P", "D", "Q", "period"},
+		children: []string{"AR", "MA", "Extension"},
+	},
+	"AR": {
+		children: []string{"Array", "Extension"},
+	},
+	"MA": {
+		children: []string{"MACoefficients", "Residuals", "Extension"},
+	},
+	"MACoefficients": {
+		children: []string{"Array", "Extension"},
+	},
+	"Residuals": {
+		children: []string{"Array", "Extension"},
+	},
+	"MaximumLikelihoodStat": {
+		attrs: []string{"method", "periodDeficit"},
+		enums: map[string]enum{
+			"method": {UnsupportedValue, "likelihood method", []string{"kalman"}},
+		},
+		children: []string{"KalmanState"},
+	},
+	"KalmanState": {
+		children: []string{"FinalOmega", "FinalStateVector", "HVector"},
+	},
+	"FinalOmega": {
+		children: []string{"Matrix"},
+	},
+	"FinalStateVector": {
+		children: []string{"Array"},
+	},
+	"HVector": {
+		children: []string{"Array"},
+	},
+	"Matrix": {
+		attrs: []string{"kind", "nbRows", "nbCols", "diagDefault", "offDiagDefault"},
+		enums: map[string]enum{
+			"kind": {UnsupportedValue, "kind of matrix", []string{"any", "symmetric", "diagonal"}},
+		},
+		children: []string{"Array", "MatCell"},
+	},
+	"MatCell": {
+		attrs: []string{"row", "col"},
+	},
+	"OutlierEffect": {
+		attrs: []string{"type", "startTime", "magnitude", "dampingCoefficient"},
+		enums: map[string]enum{
+			"type": {UnsupportedValue, "outlier effect", []string{"additive", "level", "transientChange", "seasonalAdditive", "trend"}},
+		},
+		children: []string{"Extension"},
+	},
+
+	"BayesianNetworkModel": {
+		attrs: join(modelAttrs, []string{"modelStructure", "inferenceMethod"}),
+		enums: map[string]enum{
+			"functionName":   {UnsupportedValue, "function name", []string{"classification", "regression"}},
+			"modelStructure": {UnsupportedValue, "model structure", []string{"directed"}},
+		},
+		children: join([]string{"MiningSchema", "Output", "LocalTransformations", "BayesianNetworkNodes"}, modelExtras),
+	},
+	"BayesianNetworkNodes": {
+		children: []string{"DiscreteNode", "ContinuousNode", "Extension"},
+	},
+	"DiscreteNode": {
+		attrs:    []string{"name", "count"},
+		children: []string{"DiscreteConditionalProbability", "ValueProbability", "Extension"},
+	},
+	"DiscreteConditionalProbability": {
+		attrs:    []string{"count"},
+		children: []string{"ParentValue", "ValueProbability", "Extension"},
+	},
+	"ValueProbability": {
+		attrs:    []string{"value", "probability"},
+		children: []string{"Extension"},
+	},
+	"ParentValue": {
+		attrs:    []string{"parent", "value"},
+		children: []string{"Extension"},
+	},
+	"ContinuousNode": {
+		attrs:    []string{"name", "count"},
+		children: []string{"ContinuousConditionalProbability", "ContinuousDistribution", "Extension"},
+	},
+	"ContinuousConditionalProbability": {
+		attrs:    []string{"count"},
+		children: []string{"ParentValue", "ContinuousDistribution", "Extension"},
+	},
+	"ContinuousDistribution": {
+		children: []string{
+			"NormalDistributionForBN", "LognormalDistributionForBN", "UniformDistributionForBN", "TriangularDistributionForBN",
+			"Extension",
+		},
+	},
+	"NormalDistributionForBN": {
+		children: []string{"Mean", "Variance", "Extension"},
+	},
+	"LognormalDistributionForBN": {
+		children: []string{"Mean", "Variance", "Extension"},
+	},
+	"UniformDistributionForBN": {
+		children: []string{"Lower", "Upper", "Extension"},
+	},
+	"TriangularDistributionForBN": {
+		children: []string{"Mean", "Lower", "Upper", "Extension"},
+	},
+	"Mean": {
+		children: expressions,
+	},
+	"Variance": {
+		children: expressions,
+	},
+	"Lower": {
+		children: expressions,
+	},
+	"Upper": {
+		children: expressions,
+	},
+
+	"GaussianProcessModel": {
+		attrs: join(modelAttrs, []string{"optimizer"}),
+		enums: map[string]enum{
+			"functionName": {UnsupportedValue, "function name", []string{"regression"}},
+		},
+		children: join([]string{
+			"MiningSchema", "Output", "LocalTransformations", "RadialBasisKernel", "ARDSquaredExponentialKernel",
+			"AbsoluteExponentialKernel", "GeneralizedExponentialKernel", "TrainingInstances",
+		}, modelExtras),
+	},
+	"RadialBasisKernel": {
+		attrs:    []string{"description", "gamma", "noiseVariance", "lambda"},
+		children: []string{"Extension"},
+	},
+	"ARDSquaredExponentialKernel": {
+		attrs:    []string{"description", "gamma", "noiseVariance"},
+		children: []string{"Lambda", "Extension"},
+	},
+	"AbsoluteExponentialKernel": {
+		attrs:    []string{"description", "gamma", "noiseVariance"},
+		children: []string{"Lambda", "Extension"},
+	},
+	"GeneralizedExponentialKernel": {
+		attrs:    []string{"description", "gamma", "noiseVariance", "degree"},
+		children: []string{"Lambda", "Extension"},
+	},
+	"Lambda": {
+		children: []string{"Array"},
+	},
+
+	"MiningModel": {
+		attrs:    modelAttrs,
+		children: join([]string{"MiningSchema", "Output", "LocalTransformations", "Targets", "Segmentation"}, modelExtras),
+	},
+	"Segmentation": {
+		attrs: []string{"multipleModelMethod"},
+		enums: map[string]enum{
+			"multipleModelMethod": {UnsupportedSegmentation, "multiple model method", []string{"sum", "selectFirst", "modelChain", "majorityVote", "average"}},
+		},
+		children: []string{"Segment", "Extension"},
+	},
+	"Segment": {
+		attrs:    []string{"id", "weight"},
+		children: predicates,
+		models:   true,
+	},
+}
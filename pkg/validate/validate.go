@@ -0,0 +1,280 @@
+// Package validate checks a PMML document against what pummel can evaluate.
+// Unlike decoding, which stops at the first problem (or silently ignores it), validation walks the
+// whole document and reports every unsupported element, attribute and attribute value, along with
+// references to undefined fields.
+package validate
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/stillmatic/pummel/pkg/model"
+)
+
+// Kind classifies an Issue.
+type Kind string
+
+const (
+	UnsupportedElement       Kind = "element"
+	UnsupportedAttribute     Kind = "attribute"
+	UnsupportedFunction      Kind = "function"
+	UnsupportedNormalization Kind = "normalization"
+	UnsupportedSegmentation  Kind = "segmentation"
+	// UnsupportedValue is any other attribute value pummel does not implement, e.g. a predicate operator.
+	UnsupportedValue Kind = "value"
+	MissingField     Kind = "field"
+)
+
+// Issue is a single problem found in a document.
+type Issue struct {
+	Line    int    `json:"line"`
+	Kind    Kind   `json:"kind"`
+	Element string `json:"element"`
+	Message string `json:"message"`
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("line %d: %s", i.Line, i.Message)
+}
+
+// element is a generic XML element, keeping the line it started on.
+type element struct {
+	name     string
+	attrs    []xml.Attr
+	line     int
+	children []*element
+}
+
+func (e *element) attr(name string) (string, bool) {
+	for _, a := range e.attrs {
+		if a.Name.Space == "" && a.Name.Local == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+func (e *element) child(name string) *element {
+	for _, c := range e.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// ValidateFile validates the PMML document at path.
+func ValidateFile(path string) ([]Issue, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Validate(f)
+}
+
+// Validate reports the issues in the PMML document read from r, ordered by line.
+// The document may also be a bare model element, as accepted by pummel.Load.
+// An error is only returned if the document cannot be read or is not well-formed XML.
+func Validate(r io.Reader) ([]Issue, error) {
+	root, err := parse(r)
+	if err != nil {
+		return nil, err
+	}
+	v := &validator{fields: make(map[string]bool), computed: make(map[string]bool), functions: make(map[string]bool)}
+	v.collectFields(root)
+	switch {
+	case root.name == "PMML":
+		v.walk(root, rules["PMML"], v.fields)
+	case model.IsModelElement(root.name):
+		v.bare = true
+		v.walkModel(root)
+	default:
+		return nil, fmt.Errorf("expected PMML element, found %s", root.name)
+	}
+	sort.SliceStable(v.issues, func(i, j int) bool { return v.issues[i].Line < v.issues[j].Line })
+	return v.issues, nil
+}
+
+func parse(r io.Reader) (*element, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var newlines []int
+	for i, c := range b {
+		if c == '\n' {
+			newlines = append(newlines, i)
+		}
+	}
+	d := xml.NewDecoder(bytes.NewReader(b))
+	var root *element
+	var stack []*element
+	for {
+		// the offset before reading a start element is that of its opening bracket
+		offset := int(d.InputOffset())
+		t, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse XML")
+		}
+		switch tt := t.(type) {
+		case xml.StartElement:
+			e := &element{
+				name:  tt.Name.Local,
+				attrs: tt.Attr,
+				line:  sort.SearchInts(newlines, offset) + 1,
+			}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, e)
+			} else if root == nil {
+				root = e
+			}
+			stack = append(stack, e)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		}
+	}
+	if root == nil {
+		return nil, errors.New("empty document")
+	}
+	return root, nil
+}
+
+type validator struct {
+	// fields holds every field defined in the document: data fields, derived fields and output fields.
+	fields map[string]bool
+	// computed holds the derived and output fields, which are visible from every model,
+	// e.g. a segment may refer to the result of an earlier segment.
+	computed map[string]bool
+	// functions holds the functions defined in the TransformationDictionary, which Apply may call.
+	functions map[string]bool
+	// bare is set for documents without a DataDictionary, whose mining fields cannot be checked.
+	bare   bool
+	issues []Issue
+}
+
+func (v *validator) report(e *element, kind Kind, format string, args ...interface{}) {
+	v.issues = append(v.issues, Issue{Line: e.line, Kind: kind, Element: e.name, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) collectFields(e *element) {
+	switch e.name {
+	case "DataField":
+		if name, ok := e.attr("name"); ok {
+			v.fields[name] = true
+		}
+	case "DerivedField", "OutputField":
+		if name, ok := e.attr("name"); ok {
+			v.fields[name] = true
+			v.computed[name] = true
+		}
+	case "DefineFunction":
+		if name, ok := e.attr("name"); ok {
+			v.functions[name] = true
+		}
+		// its parameters are not fields of the document
+		return
+	}
+	for _, c := range e.children {
+		v.collectFields(c)
+	}
+}
+
+// walk checks the attributes and children of e against r. Field references are resolved in scope,
+// unless scope is nil.
+func (v *validator) walk(e *element, r *rule, scope map[string]bool) {
+	for _, a := range e.attrs {
+		// namespace declarations and attributes from other namespaces, e.g. xsi:schemaLocation
+		if a.Name.Space != "" || a.Name.Local == "xmlns" {
+			continue
+		}
+		if !contains(r.attrs, a.Name.Local) {
+			v.report(e, UnsupportedAttribute, "unsupported attribute %s on %s", a.Name.Local, e.name)
+			continue
+		}
+		if e.name == "Apply" && a.Name.Local == "function" && v.functions[a.Value] {
+			continue
+		}
+		if en, ok := r.enums[a.Name.Local]; ok && !contains(en.values, a.Value) {
+			v.report(e, en.kind, "unsupported %s %q on %s", en.label, a.Value, e.name)
+		}
+		if scope != nil && contains(r.fields, a.Name.Local) && !scope[a.Value] {
+			v.report(e, MissingField, "%s refers to undefined field %q", e.name, a.Value)
+		}
+	}
+	for _, c := range e.children {
+		switch {
+		case contains(r.children, c.name):
+			if opaque[c.name] {
+				continue
+			}
+			childScope := scope
+			if c.name == "DefineFunction" {
+				// the expression of a function refers to its parameters only
+				childScope = parameters(c)
+			}
+			if c.name == "MiningSchema" {
+				// mining fields refer to the document's fields rather than to the model's own
+				childScope = v.fields
+				if v.bare {
+					childScope = nil
+				}
+			}
+			v.walk(c, rules[c.name], childScope)
+		case r.models && model.IsModelElement(c.name):
+			v.walkModel(c)
+		default:
+			v.report(c, UnsupportedElement, "unsupported element %s in %s", c.name, e.name)
+		}
+	}
+}
+
+// walkModel checks a model element, whose references must be to its mining fields or to computed fields.
+func (v *validator) walkModel(e *element) {
+	r, ok := rules[e.name]
+	if !ok || !model.IsSupportedModelElement(e.name) {
+		v.report(e, UnsupportedElement, "unsupported model element %s", e.name)
+		return
+	}
+	scope := make(map[string]bool, len(v.computed))
+	for name := range v.computed {
+		scope[name] = true
+	}
+	if ms := e.child("MiningSchema"); ms != nil {
+		for _, mf := range ms.children {
+			if name, ok := mf.attr("name"); ok {
+				scope[name] = true
+			}
+		}
+	}
+	v.walk(e, r, scope)
+}
+
+// parameters returns the names of the ParameterFields of a DefineFunction.
+func parameters(e *element) map[string]bool {
+	params := make(map[string]bool)
+	for _, c := range e.children {
+		if name, ok := c.attr("name"); ok && c.name == "ParameterField" {
+			params[name] = true
+		}
+	}
+	return params
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
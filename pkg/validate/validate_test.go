@@ -0,0 +1,184 @@
+package validate_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stillmatic/pummel/pkg/validate"
+	"github.com/stretchr/testify/assert"
+)
+
+var unsupportedPMML = []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<PMML xmlns="http://www.dmg.org/PMML-4_4" version="4.4">
+	<Header copyright="example"/>
+	<DataDictionary>
+		<DataField name="x" optype="continuous" dataType="double"/>
+		<DataField name="y" optype="categorical" dataType="string"/>
+	</DataDictionary>
+	<MiningModel functionName="classification">
+		<MiningSchema>
+			<MiningField name="x" missingValueReplacement="0"/>
+			<MiningField name="y" usageType="target"/>
+		</MiningSchema>
+		<Segmentation multipleModelMethod="weightedMajorityVote">
+			<Segment id="1">
+				<True/>
+				<TreeModel functionName="classification" missingValuePenalty="0.5">
+					<MiningSchema>
+						<MiningField name="x"/>
+						<MiningField name="z"/>
+					</MiningSchema>
+					<Node>
+						<True/>
+						<Node score="a">
+							<SimplePredicate field="w" operator="lessThan" value="1"/>
+							<Extension/>
+						</Node>
+					</Node>
+				</TreeModel>
+			</Segment>
+			<Segment id="2">
+				<True/>
+				<RegressionModel functionName="regression" normalizationMethod="probit">
+					<MiningSchema>
+						<MiningField name="x"/>
+					</MiningSchema>
+					<LocalTransformations>
+						<DerivedField name="x2" dataType="double" optype="continuous">
+							<Apply function="x-weekday">
+								<FieldRef field="x"/>
+								<Constant dataType="double">2</Constant>
+							</Apply>
+						</DerivedField>
+					</LocalTransformations>
+					<RegressionTable intercept="1">
+						<NumericPredictor name="x2" coefficient="1"/>
+					</RegressionTable>
+				</RegressionModel>
+			</Segment>
+			<Segment id="3">
+				<True/>
+				<SequenceModel functionName="sequences"/>
+			</Segment>
+		</Segmentation>
+	</MiningModel>
+</PMML>
+`)
+
+func TestValidate(t *testing.T) {
+	issues, err := validate.Validate(bytes.NewReader(unsupportedPMML))
+	assert.NoError(t, err)
+	expected := []validate.Issue{
+		{Line: 10, Kind: validate.UnsupportedAttribute, Element: "MiningField", Message: "unsupported attribute missingValueReplacement on MiningField"},
+		{Line: 13, Kind: validate.UnsupportedSegmentation, Element: "Segmentation", Message: `unsupported multiple model method "weightedMajorityVote" on Segmentation`},
+		{Line: 16, Kind: validate.UnsupportedAttribute, Element: "TreeModel", Message: "unsupported attribute missingValuePenalty on TreeModel"},
+		{Line: 19, Kind: validate.MissingField, Element: "MiningField", Message: `MiningField refers to undefined field "z"`},
+		{Line: 24, Kind: validate.MissingField, Element: "SimplePredicate", Message: `SimplePredicate refers to undefined field "w"`},
+		{Line: 25, Kind: validate.UnsupportedElement, Element: "Extension", Message: "unsupported element Extension in Node"},
+		{Line: 32, Kind: validate.UnsupportedNormalization, Element: "RegressionModel", Message: `unsupported normalization method "probit" on RegressionModel`},
+		{Line: 38, Kind: validate.UnsupportedFunction, Element: "Apply", Message: `unsupported function "x-weekday" on Apply`},
+		{Line: 51, Kind: validate.UnsupportedElement, Element: "SequenceModel", Message: "unsupported model element SequenceModel"},
+	}
+	assert.Equal(t, expected, issues)
+	assert.Equal(t, "line 10: unsupported attribute missingValueReplacement on MiningField", issues[0].String())
+}
+
+func TestValidateDefineFunction(t *testing.T) {
+	doc := `<PMML version="4.4">
+	<DataDictionary>
+		<DataField name="x" optype="continuous" dataType="double"/>
+		<DataField name="y" optype="continuous" dataType="double"/>
+	</DataDictionary>
+	<TransformationDictionary>
+		<DefineFunction name="shift" optype="continuous" dataType="double">
+			<ParameterField name="value" dataType="double"/>
+			<Apply function="-">
+				<FieldRef field="value"/>
+				<FieldRef field="x"/>
+			</Apply>
+		</DefineFunction>
+		<DerivedField name="shifted" optype="continuous" dataType="double">
+			<Apply function="shift">
+				<FieldRef field="x"/>
+			</Apply>
+		</DerivedField>
+	</TransformationDictionary>
+	<RegressionModel functionName="regression">
+		<MiningSchema>
+			<MiningField name="x"/>
+			<MiningField name="y" usageType="target"/>
+		</MiningSchema>
+		<RegressionTable intercept="1">
+			<NumericPredictor name="shifted" coefficient="2"/>
+		</RegressionTable>
+	</RegressionModel>
+</PMML>`
+	issues, err := validate.Validate(strings.NewReader(doc))
+	assert.NoError(t, err)
+	// the function may only refer to its parameters
+	assert.Equal(t, []validate.Issue{
+		{Line: 11, Kind: validate.MissingField, Element: "FieldRef", Message: `FieldRef refers to undefined field "x"`},
+	}, issues)
+}
+
+func TestValidateOpType(t *testing.T) {
+	doc := `<PMML version="4.4">
+	<DataDictionary>
+		<DataField name="x" optype="continuous" dataType="double"/>
+		<DataField name="y" optype="continuous" dataType="double"/>
+	</DataDictionary>
+	<RegressionModel functionName="regression">
+		<MiningSchema>
+			<MiningField name="x"/>
+			<MiningField name="y" usageType="target" opType="continuous"/>
+		</MiningSchema>
+		<LocalTransformations>
+			<DerivedField name="x2" optype="continuous" dataType="double"><FieldRef field="x"/></DerivedField>
+			<DerivedField name="x3" opType="continuous" dataType="double"><FieldRef field="x"/></DerivedField>
+		</LocalTransformations>
+		<RegressionTable intercept="1">
+			<NumericPredictor name="x2" coefficient="2"/>
+		</RegressionTable>
+	</RegressionModel>
+</PMML>`
+	issues, err := validate.Validate(strings.NewReader(doc))
+	assert.NoError(t, err)
+	// optype is only read as the specification spells it
+	assert.Equal(t, []validate.Issue{
+		{Line: 9, Kind: validate.UnsupportedAttribute, Element: "MiningField", Message: "unsupported attribute opType on MiningField"},
+		{Line: 13, Kind: validate.UnsupportedAttribute, Element: "DerivedField", Message: "unsupported attribute opType on DerivedField"},
+	}, issues)
+}
+
+func TestValidateFile(t *testing.T) {
+	var tests = []struct {
+		path     string
+		messages []string
+	}{
+		{"../../testdata/LogisticRegressionAudit.pmml", nil},
+		{"../../testdata/rf.pmml", nil},
+		// a bare model element, without a DataDictionary to check its mining fields against
+		{"../../testdata/tree.pmml", nil},
+		{"../../testdata/lr.pmml", nil},
+		{"../../testdata/gbm.pmml", []string{"line 33: unsupported attribute a on Target"}},
+	}
+	for _, tt := range tests {
+		issues, err := validate.ValidateFile(tt.path)
+		assert.NoError(t, err, tt.path)
+		var messages []string
+		for _, issue := range issues {
+			messages = append(messages, issue.String())
+		}
+		assert.Equal(t, tt.messages, messages, tt.path)
+	}
+}
+
+func TestValidateErrors(t *testing.T) {
+	_, err := validate.Validate(bytes.NewReader([]byte(`<PMML><DataDictionary></PMML>`)))
+	assert.Error(t, err)
+	_, err = validate.Validate(bytes.NewReader([]byte(`<Other/>`)))
+	assert.Error(t, err)
+	_, err = validate.Validate(bytes.NewReader(nil))
+	assert.Error(t, err)
+}
@@ -0,0 +1,166 @@
+// Package verification implements the ModelVerification element, which embeds sample records
+// and the results the producing tool computed for them, so that a consumer can check that it
+// evaluates the model the same way.
+package verification
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/stillmatic/pummel/pkg/table"
+)
+
+const (
+	DefaultPrecision     = 1e-6
+	DefaultZeroThreshold = 1e-16
+)
+
+type ModelVerification struct {
+	XMLName     xml.Name             `xml:"ModelVerification"`
+	RecordCount int                  `xml:"recordCount,attr"`
+	FieldCount  int                  `xml:"fieldCount,attr"`
+	Fields      []*VerificationField `xml:"VerificationFields>VerificationField"`
+	InlineTable *table.InlineTable   `xml:"InlineTable"`
+}
+
+// VerificationField maps a field to its column in the table.
+// Numeric results match if they are within Precision of the expected value, relative to it,
+// or if both are within ZeroThreshold of zero.
+type VerificationField struct {
+	XMLName       xml.Name `xml:"VerificationField"`
+	Field         string   `xml:"field,attr"`
+	Column        string   `xml:"column,attr"`
+	Precision     float64  `xml:"precision,attr"`
+	ZeroThreshold float64  `xml:"zeroThreshold,attr"`
+}
+
+func (vf *VerificationField) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	vf.XMLName = start.Name
+	vf.Precision = DefaultPrecision
+	vf.ZeroThreshold = DefaultZeroThreshold
+	for _, attr := range start.Attr {
+		var err error
+		switch attr.Name.Local {
+		case "field":
+			vf.Field = attr.Value
+		case "column":
+			vf.Column = attr.Value
+		case "precision":
+			vf.Precision, err = strconv.ParseFloat(attr.Value, 64)
+		case "zeroThreshold":
+			vf.ZeroThreshold, err = strconv.ParseFloat(attr.Value, 64)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid %s of VerificationField %s: %w", attr.Name.Local, vf.Field, err)
+		}
+	}
+	return d.Skip()
+}
+
+// ColumnName returns the key of the field's cells in a table.Row, which defaults to the field name.
+func (vf *VerificationField) ColumnName() string {
+	if vf.Column == "" {
+		return vf.Field
+	}
+	return table.ColumnName(vf.Column)
+}
+
+// Match reports whether actual, as computed by pummel, matches the expected cell.
+// An empty cell expects a missing result.
+func (vf *VerificationField) Match(expected string, actual interface{}) bool {
+	if actual == nil {
+		return expected == ""
+	}
+	if e, err := strconv.ParseFloat(expected, 64); err == nil {
+		if a, ok := toFloat(actual); ok {
+			if math.Abs(e) <= vf.ZeroThreshold && math.Abs(a) <= vf.ZeroThreshold {
+				return true
+			}
+			return math.Abs(a-e) <= vf.Precision*math.Abs(e)
+		}
+	}
+	if a, ok := actual.(bool); ok {
+		if e, err := strconv.ParseBool(expected); err == nil {
+			return a == e
+		}
+	}
+	return fmt.Sprint(actual) == expected
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// Mismatch is a result which differs from the expected value, or a row which failed to evaluate.
+type Mismatch struct {
+	// Row is the 1-based index of the row in the table.
+	Row      int
+	Field    string
+	Expected string
+	Actual   interface{}
+	Err      error
+}
+
+func (m *Mismatch) String() string {
+	if m.Err != nil {
+		return fmt.Sprintf("row %d: %v", m.Row, m.Err)
+	}
+	return fmt.Sprintf("row %d: %s: expected %q, got %v", m.Row, m.Field, m.Expected, m.Actual)
+}
+
+// Report summarizes the verification of every row.
+type Report struct {
+	Rows       int
+	Mismatches []*Mismatch
+}
+
+func (r *Report) OK() bool {
+	return len(r.Mismatches) == 0
+}
+
+// Verify evaluates each row and compares the results for outputs with the expected values.
+// isInput reports whether a verification field is passed to evaluate rather than compared.
+// Input cells are passed as strings, and empty cells are left out.
+func (mv *ModelVerification) Verify(isInput func(field string) bool, evaluate func(map[string]interface{}) (map[string]interface{}, error)) *Report {
+	report := &Report{}
+	if mv.InlineTable == nil {
+		return report
+	}
+	for i, row := range mv.InlineTable.Rows {
+		report.Rows++
+		inputs := make(map[string]interface{})
+		for _, vf := range mv.Fields {
+			if isInput(vf.Field) {
+				if cell := row[vf.ColumnName()]; cell != "" {
+					inputs[vf.Field] = cell
+				}
+			}
+		}
+		results, err := evaluate(inputs)
+		if err != nil {
+			report.Mismatches = append(report.Mismatches, &Mismatch{Row: i + 1, Err: err})
+			continue
+		}
+		for _, vf := range mv.Fields {
+			if isInput(vf.Field) {
+				continue
+			}
+			expected := row[vf.ColumnName()]
+			if actual := results[vf.Field]; !vf.Match(expected, actual) {
+				report.Mismatches = append(report.Mismatches, &Mismatch{Row: i + 1, Field: vf.Field, Expected: expected, Actual: actual})
+			}
+		}
+	}
+	return report
+}
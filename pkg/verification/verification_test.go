@@ -0,0 +1,52 @@
+package verification_test
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/stillmatic/pummel/pkg/verification"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerificationFieldMatch(t *testing.T) {
+	vf := &verification.VerificationField{Field: "y", Precision: 1e-6, ZeroThreshold: 1e-16}
+	var tests = []struct {
+		expected string
+		actual   interface{}
+		match    bool
+	}{
+		{"1.0", 1.0, true},
+		{"1.0", 1.0000005, true},
+		{"1.0", 1.00001, false},
+		{"-200", -200.0001, true},
+		{"1E-17", 0.0, true},
+		{"1E-17", 1e-10, false},
+		{"3", 3, true},
+		{"3", "3.0", true},
+		{"setosa", "setosa", true},
+		{"setosa", "versicolor", false},
+		{"true", true, true},
+		{"0", false, true},
+		{"true", false, false},
+		{"", nil, true},
+		{"1", nil, false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.match, vf.Match(tt.expected, tt.actual), "%q vs %v", tt.expected, tt.actual)
+	}
+}
+
+func TestVerificationFieldUnmarshal(t *testing.T) {
+	var vf verification.VerificationField
+	assert.NoError(t, xml.Unmarshal([]byte(`<VerificationField field="y" column="data:Y"/>`), &vf))
+	assert.Equal(t, verification.DefaultPrecision, vf.Precision)
+	assert.Equal(t, verification.DefaultZeroThreshold, vf.ZeroThreshold)
+	assert.Equal(t, "Y", vf.ColumnName())
+
+	vf = verification.VerificationField{}
+	assert.NoError(t, xml.Unmarshal([]byte(`<VerificationField field="y" precision="0.01"/>`), &vf))
+	assert.Equal(t, 0.01, vf.Precision)
+	assert.Equal(t, "y", vf.ColumnName())
+
+	assert.Error(t, xml.Unmarshal([]byte(`<VerificationField field="y" precision="high"/>`), &vf))
+}
@@ -0,0 +1,55 @@
+package pummel
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/stillmatic/pummel/pkg/verification"
+)
+
+var errNoModelVerification = errors.New("model has no ModelVerification")
+
+// verifiable is implemented by model elements which decode their ModelVerification.
+type verifiable interface {
+	GetModelVerification() *verification.ModelVerification
+}
+
+// ModelVerification returns the verification records embedded in the model, or nil if there are none.
+func (m *Model) ModelVerification() *verification.ModelVerification {
+	if v, ok := m.ModelElement.(verifiable); ok {
+		return v.GetModelVerification()
+	}
+	return nil
+}
+
+// Verify evaluates the records of the model's ModelVerification and compares the results with the
+// expected values. Verification fields which are mining fields, other than the target, are inputs;
+// all others are compared with the result of the same name.
+func (m *Model) Verify() (*verification.Report, error) {
+	mv := m.ModelVerification()
+	if mv == nil {
+		return nil, errNoModelVerification
+	}
+	inputs := make(map[string]bool)
+	if ms := m.GetMiningSchema(); ms != nil {
+		for _, mf := range ms.MiningFields {
+			if mf.UsageType != "target" && mf.UsageType != "predicted" {
+				inputs[mf.Name] = true
+			}
+		}
+	}
+	isInput := func(field string) bool { return inputs[field] }
+	evaluate := func(row map[string]interface{}) (map[string]interface{}, error) {
+		values := make(map[string]interface{}, len(row))
+		for k, v := range row {
+			coerced, err := m.DataDictionary.Coerce(k, v)
+			if err != nil {
+				return nil, err
+			}
+			if coerced != nil {
+				values[k] = coerced
+			}
+		}
+		return m.Evaluate(values)
+	}
+	return mv.Verify(isInput, evaluate), nil
+}
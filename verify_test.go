@@ -0,0 +1,68 @@
+package pummel_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stillmatic/pummel"
+	"github.com/stretchr/testify/assert"
+)
+
+const verifiedPMML = `<PMML xmlns="http://www.dmg.org/PMML-4_4" xmlns:data="http://jpmml.org/jpmml-model/InlineTable" version="4.4">
+	<DataDictionary>
+		<DataField name="x1" optype="continuous" dataType="double"/>
+		<DataField name="x2" optype="continuous" dataType="double"/>
+		<DataField name="y" optype="continuous" dataType="double"/>
+	</DataDictionary>
+	<RegressionModel functionName="regression">
+		<MiningSchema>
+			<MiningField name="x1"/>
+			<MiningField name="x2"/>
+			<MiningField name="y" usageType="target"/>
+		</MiningSchema>
+		<RegressionTable intercept="1">
+			<NumericPredictor name="x1" coefficient="2"/>
+			<NumericPredictor name="x2" coefficient="-1"/>
+		</RegressionTable>
+		<ModelVerification recordCount="4" fieldCount="3">
+			<VerificationFields>
+				<VerificationField field="x1" column="data:x1"/>
+				<VerificationField field="x2" column="data:x2"/>
+				<VerificationField field="y" column="data:y" precision="1E-3" zeroThreshold="1E-9"/>
+			</VerificationFields>
+			<InlineTable>
+				<row><data:x1>1</data:x1><data:x2>2</data:x2><data:y>1.0001</data:y></row>
+				<row><data:x1>0.5</data:x1><data:x2>2</data:x2><data:y>1E-12</data:y></row>
+				<row><data:x1>2</data:x1><data:x2>0</data:x2><data:y>4</data:y></row>
+				<row><data:x1>abc</data:x1><data:x2>0</data:x2><data:y>1</data:y></row>
+			</InlineTable>
+		</ModelVerification>
+	</RegressionModel>
+</PMML>`
+
+func TestVerify(t *testing.T) {
+	m, err := pummel.Load(strings.NewReader(verifiedPMML))
+	assert.NoError(t, err)
+	mv := m.ModelVerification()
+	assert.NotNil(t, mv)
+	assert.Equal(t, 4, mv.RecordCount)
+	assert.Equal(t, 3, len(mv.Fields))
+	assert.Equal(t, 1e-6, mv.Fields[0].Precision)
+	assert.Equal(t, 1e-3, mv.Fields[2].Precision)
+
+	report, err := m.Verify()
+	assert.NoError(t, err)
+	assert.Equal(t, 4, report.Rows)
+	assert.False(t, report.OK())
+	// rows 1 and 2 match within the precision and zero threshold, row 3 is wrong and row 4 fails
+	assert.Equal(t, 2, len(report.Mismatches))
+	assert.Equal(t, `row 3: y: expected "4", got 5`, report.Mismatches[0].String())
+	assert.Equal(t, 4, report.Mismatches[1].Row)
+	assert.Error(t, report.Mismatches[1].Err)
+
+	m, err = pummel.LoadFile("testdata/lr.pmml")
+	assert.NoError(t, err)
+	assert.Nil(t, m.ModelVerification())
+	_, err = m.Verify()
+	assert.Error(t, err)
+}